@@ -0,0 +1,79 @@
+// Command devtoken mints access tokens signed by the development identity
+// provider, e.g.
+//
+//	go run ./cmd/devtoken -sub alice -roles Gallery,GalleryAdmin -galleries louvre -claim locale=fr
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/paumarro/apollo-be/internal/auth"
+	"github.com/paumarro/apollo-be/internal/devidp"
+)
+
+// claimFlags collects repeated -claim key=value flags. Values are parsed as
+// JSON when possible so numbers, booleans, arrays and objects can be passed.
+type claimFlags map[string]interface{}
+
+func (c claimFlags) String() string { return fmt.Sprint(map[string]interface{}(c)) }
+
+func (c claimFlags) Set(v string) error {
+	key, raw, ok := strings.Cut(v, "=")
+	if !ok || key == "" {
+		return fmt.Errorf("claim must be key=value, got %q", v)
+	}
+	var value interface{}
+	if err := json.Unmarshal([]byte(raw), &value); err != nil {
+		value = raw
+	}
+	c[key] = value
+	return nil
+}
+
+func main() {
+	claims := claimFlags{}
+	keyFile := flag.String("key", envOr("DEV_IDP_KEY_FILE", devidp.DefaultKeyFile), "path to the dev IdP signing key")
+	issuer := flag.String("issuer", envOr("DEV_IDP_ISSUER", auth.BaseURL(envOr("APOLLO_DOMAIN", "http://localhost:3000"))+"/realms/apollo"), "token issuer")
+	clientID := flag.String("client", envOr("KEYCLOAK_CLIENT_ID", "apollo-client"), "client ID used for audience and role claims")
+	sub := flag.String("sub", "dev-user", "subject")
+	name := flag.String("name", "", "display name")
+	email := flag.String("email", "", "email address")
+	roles := flag.String("roles", auth.RoleRegular, "comma separated client roles")
+	galleries := flag.String("galleries", "", "comma separated galleries the user manages")
+	ttl := flag.Duration("ttl", time.Hour, "token lifetime")
+	flag.Var(claims, "claim", "extra claim as key=value (repeatable)")
+	flag.Parse()
+
+	key, err := devidp.LoadOrCreateKey(*keyFile)
+	if err != nil {
+		log.Fatalf("Failed to load signing key: %v", err)
+	}
+
+	provider := devidp.New(*issuer, *clientID, key)
+	token, err := provider.Mint(devidp.Identity{
+		Subject:   *sub,
+		Name:      *name,
+		Email:     *email,
+		Roles:     devidp.SplitList(*roles),
+		Galleries: devidp.SplitList(*galleries),
+		Claims:    claims,
+	}, *ttl)
+	if err != nil {
+		log.Fatalf("Failed to mint token: %v", err)
+	}
+
+	fmt.Println(token)
+}
+
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
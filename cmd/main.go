@@ -1,64 +1,439 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
 	"log"
+	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/gin-gonic/gin"
+	"github.com/paumarro/apollo-be/internal/audit"
+	"github.com/paumarro/apollo-be/internal/auth"
+	"github.com/paumarro/apollo-be/internal/cache"
+	"github.com/paumarro/apollo-be/internal/config"
 	"github.com/paumarro/apollo-be/internal/controllers"
+	"github.com/paumarro/apollo-be/internal/dbrouter"
+	"github.com/paumarro/apollo-be/internal/devidp"
+	"github.com/paumarro/apollo-be/internal/health"
 	"github.com/paumarro/apollo-be/internal/initializers"
+	"github.com/paumarro/apollo-be/internal/lockout"
+	"github.com/paumarro/apollo-be/internal/metrics"
 	"github.com/paumarro/apollo-be/internal/middleware"
-	"github.com/paumarro/apollo-be/internal/models"
+	"github.com/paumarro/apollo-be/internal/migrate"
 	"github.com/paumarro/apollo-be/internal/repositories"
+	"github.com/paumarro/apollo-be/internal/server"
 	"github.com/paumarro/apollo-be/internal/services"
-	env "github.com/paumarro/apollo-be/pkg"
+	"github.com/paumarro/apollo-be/internal/sessions"
+	"gorm.io/gorm"
 )
 
-func init() {
-	env.LoadEnvVariables(".env")
-	initializers.ConnectToDB()
-	log.Println("Starting database migration...")
-	if err := initializers.DB.AutoMigrate(&models.Artwork{}); err != nil {
-		log.Fatalf("Failed to migrate database: %v", err)
+func main() {
+	// SIGTERM from the orchestrator or Ctrl-C starts a graceful shutdown, or
+	// stops waiting for the database during startup
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	args := os.Args[1:]
+	if len(args) > 0 && args[0] == "migrate" {
+		if err := runMigrate(ctx, args[1:], os.Stdout); err != nil {
+			log.Fatalf("%v", err)
+		}
+		return
 	}
-	log.Println("Database migration completed successfully.")
-}
 
-func main() {
+	printConfig := len(args) > 0 && args[0] == "config"
+	if printConfig {
+		if len(args) < 2 || args[1] != "print" {
+			log.Fatalf("Usage: %s config print [flags]", os.Args[0])
+		}
+		args = args[2:]
+	}
+
+	cfg, err := config.Load(args, os.LookupEnv)
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+
+	// config print shows the effective settings, secrets redacted, and
+	// reports validation problems without starting the server
+	if printConfig {
+		if err := cfg.Print(os.Stdout); err != nil {
+			log.Fatalf("Failed to print configuration: %v", err)
+		}
+		if err := cfg.Validate(); err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid configuration:\n%v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if err := cfg.Validate(); err != nil {
+		log.Fatalf("Invalid configuration:\n%v", err)
+	}
+
+	db, err := initializers.ConnectToDB(ctx, cfg.Database)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		log.Fatalf("Failed to access database pool: %v", err)
+	}
+	// Replicas starting together take turns through the migration lock; with
+	// DB_MIGRATE_ON_START=false run `migrate up` as a release step instead
+	if cfg.Database.MigrateOnStart {
+		migrator, err := migrate.ForDialect(sqlDB, db.Dialector.Name())
+		if err != nil {
+			log.Fatalf("Failed to load migrations: %v", err)
+		}
+		if _, err := migrator.Up(ctx); err != nil {
+			log.Fatalf("Failed to migrate database: %v", err)
+		}
+		if _, err := migrate.AssignGallery(ctx, sqlDB, cfg.Database.DefaultGallery); err != nil {
+			log.Fatalf("Failed to assign artworks to DB_DEFAULT_GALLERY: %v", err)
+		}
+		log.Println("Database migration completed successfully.")
+	}
+
+	// Artwork lists and lookups go to the read replicas, if any
+	replicaDBs, err := initializers.ConnectToReplicas(cfg.Database)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	dbRouter := dbrouter.New(db, replicaDBs, cfg.Database.ReadYourWritesWindow, cfg.Database.ReplicaMaxLag)
+	readYourWritesKey := []byte(cfg.Database.ReadYourWritesKey)
+
 	gin.SetMode(gin.ReleaseMode)
 	router := gin.Default()
+	// Client IPs key rate limits, lockouts and the audit trail. Forwarded
+	// headers are only believed from the configured proxies or platform.
+	if err := router.SetTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+		log.Fatalf("Invalid TRUSTED_PROXIES: %v", err)
+	}
+	router.TrustedPlatform = cfg.Server.TrustedPlatform
 
-	// clientID := os.Getenv("KEYCLOAK_CLIENT_ID")
+	// Resources registered with the server are released in reverse order
+	// once in-flight requests have drained
+	srv := server.New(cfg.Server, router)
+	srv.OnShutdown("database", func(context.Context) error { return sqlDB.Close() })
+	if len(replicaDBs) > 0 {
+		checking, stopChecks := context.WithCancel(context.Background())
+		go dbRouter.Run(checking, cfg.Database.ReplicaCheckInterval, cfg.Health.Timeout)
+		srv.OnShutdown("database replicas", func(context.Context) error {
+			stopChecks()
+			var errs []error
+			for _, replica := range replicaDBs {
+				if replicaDB, err := replica.DB(); err == nil {
+					errs = append(errs, replicaDB.Close())
+				}
+			}
+			return errors.Join(errs...)
+		})
+	}
+
+	// Probes are registered before the rate limiter so orchestrators are never
+	// throttled; readiness fails as soon as shutdown begins
+	checker := health.NewChecker(cfg.Health.Timeout)
+	checker.Register("database", health.DatabaseCheck(db))
+	srv.OnDrain(checker.Drain)
+	healthController := controllers.NewHealthController(checker)
+	router.GET("/healthz", healthController.Live)
+	router.GET("/readyz", healthController.Ready)
+
+	// Prometheus scrapes pool statistics and other counters from /metrics
+	registry := metrics.NewRegistry()
+	metrics.RegisterDBStats(registry, sqlDB)
+	registry.Register("apollo_db_replicas_available", "Read replicas that are up and within DB_REPLICA_MAX_LAG.", metrics.Gauge,
+		func() float64 { return float64(dbRouter.Available()) })
+
+	// Security events go to the audit_events table and the log stream. A
+	// single writer stores them after the response, so a flood of failed
+	// requests cannot queue up on the audit chain lock
+	auditStore := audit.NewGormStore(db)
+	auditQueue := audit.NewQueue(audit.NewRecorder(auditStore), cfg.Audit.QueueSize, cfg.Audit.AnonymousPerIP, cfg.Audit.AnonymousWindow)
+	audit.Use(auditQueue)
+	srv.OnShutdown("audit queue", auditQueue.Close)
+	registry.Register("apollo_audit_dropped_total", "Audit events not stored because the queue was full or the client IP was over its limit.", metrics.Counter,
+		func() float64 { return float64(auditQueue.Dropped()) })
+
+	// Failed token validations and callback errors lock out IPs and subjects
+	lockoutTracker := newLockoutTracker(cfg.Lockout)
+	lockout.Use(lockoutTracker)
 
 	router.Use(middleware.RateLimit())
 	router.Use(middleware.SecurityHeaders())
-	router.Use(middleware.Sanitize())
-	router.Use(middleware.Validate())
+	router.Use(middleware.Deadline(cfg.Server.RequestTimeout))
+
+	// Metrics reveal traffic and pool usage, so only scrapers holding
+	// METRICS_TOKEN may read them
+	if cfg.Server.MetricsToken != "" {
+		router.GET("/metrics", middleware.ScrapeToken(cfg.Server.MetricsToken), gin.WrapH(registry))
+	} else {
+		log.Println("METRICS_TOKEN is not set; /metrics is not served")
+	}
+
+	// CSRF checks must see the request before Auth turns cookies into a bearer header
+	trustedOrigins := append([]string{cfg.PublicURL()}, cfg.Server.CSRFTrustedOrigins...)
+	router.Use(middleware.CSRF(trustedOrigins, cfg.CookieDomain()))
+
+	// Development identity provider, serving the Keycloak endpoints in-process.
+	// Point KEYCLOAK_DOMAIN at APOLLO_DOMAIN to use it.
+	if cfg.DevIdP.Enabled {
+		key, err := devidp.LoadOrCreateKey(cfg.DevIdP.KeyFile)
+		if err != nil {
+			log.Fatalf("Failed to load dev IdP key: %v", err)
+		}
+		realmPath := "/realms/" + cfg.Keycloak.Realm
+		issuer := cfg.PublicURL() + realmPath
+		provider := devidp.New(issuer, cfg.Keycloak.ClientID, key)
+		provider.RedirectURI = cfg.PublicURL() + "/auth/callback"
+		provider.Register(router.Group(realmPath))
+		log.Printf("WARNING: development identity provider enabled at %s", issuer)
+	}
+
+	oidc := auth.NewOIDCClient(cfg.RealmURL(), cfg.Keycloak.ClientID, cfg.Keycloak.ClientSecret, cfg.PublicURL()+"/auth/callback")
+	authenticator := newAuthenticator(cfg, oidc)
+	srv.OnShutdown("token verifiers", func(context.Context) error { return authenticator.Close() })
+	checker.Register("jwks", health.KeySetCheck(authenticator, cfg.Health.JWKSMaxAge))
 
 	// Instantiate the service and controller
-	artworkRepo := repositories.NewGormArtworkRepository(initializers.DB) // GORM-based repository
-	artworkService := services.NewArtworkService(artworkRepo)             // Service depends on the repository interface
-	artworkController := controllers.NewArtworkController(artworkService) // Controller depends on the service
+	artworkRepo, artworkUnit := newArtworkRepository(cfg.Cache, db, dbRouter, registry, srv) // GORM-based repository, cached by ID
+	artworkService := services.NewArtworkService(artworkRepo)                                // Service depends on the repository interface
+	artworkService.UnitOfWork = artworkUnit                                                  // Transactions spanning several repository calls
+	artworkController := controllers.NewArtworkController(artworkService)                    // Controller depends on the service
+
+	// Authorization policies; AUTH_POLICY_FILE overrides the built-in rules
+	policies, err := auth.LoadEngine(cfg.Auth.PolicyFile)
+	if err != nil {
+		log.Fatalf("Failed to load authorization policies: %v", err)
+	}
+	artworkController.Policies = policies
+
+	apiKeyRepo := repositories.NewGormAPIKeyRepository(db)
+	apiKeyService := services.NewAPIKeyService(apiKeyRepo)
+	apiKeyController := controllers.NewAPIKeyController(apiKeyService)
 
 	galleryGroup := router.Group("/gallery")
-	// galleryGroup.Use(middleware.Auth("Gallery", clientID))
-	// galleryGroup.Use(middleware.Sanitize())
-	// galleryGroup.Use(middleware.Validate())
 
-	galleryGroup.POST("/artworks", artworkController.Create)
-	galleryGroup.PUT("/artworks/:id", artworkController.Update)
-	galleryGroup.DELETE("/artworks/:id", artworkController.Delete)
+	// Artwork payloads are parsed and validated per route so other gallery
+	// endpoints can carry their own bodies.
+	// Role checks for artworks are made by the policy engine against the loaded artwork.
+	artworkGroup := galleryGroup.Group("/artworks")
+	artworkGroup.Use(authenticator.AuthOrAPIKey("", apiKeyService))
+	artworkGroup.Use(middleware.ReadYourWrites(readYourWritesKey, cfg.Database.ReadYourWritesWindow))
+	artworkGroup.Use(middleware.Sanitize())
+	artworkGroup.Use(middleware.Validate())
+
+	artworkGroup.GET("", artworkController.Index)
+	artworkGroup.GET("/:id", artworkController.Find)
+	artworkGroup.POST("", artworkController.Create)
+	artworkGroup.PUT("/:id", artworkController.Update)
+	artworkGroup.DELETE("/:id", artworkController.Delete)
+	artworkGroup.DELETE("/:id/purge", artworkController.Purge)
+
+	// Duplicate review carries its own bodies, so it skips the artwork payload checks
+	duplicateGroup := galleryGroup.Group("/artworks")
+	duplicateGroup.Use(authenticator.AuthOrAPIKey("", apiKeyService))
+	duplicateGroup.Use(middleware.ReadYourWrites(readYourWritesKey, cfg.Database.ReadYourWritesWindow))
+
+	duplicateGroup.GET("/duplicates", artworkController.Duplicates)
+	duplicateGroup.POST("/merge", artworkController.Merge)
+
+	// API keys are managed by gallery admins with an interactive session only
+	apiKeyGroup := galleryGroup.Group("/api-keys")
+	apiKeyGroup.Use(authenticator.Auth(auth.RoleGalleryAdmin))
+
+	apiKeyGroup.POST("", apiKeyController.Create)
+	apiKeyGroup.GET("", apiKeyController.Index)
+	apiKeyGroup.DELETE("/:id", apiKeyController.Delete)
+
+	auditController := controllers.NewAuditController(auditStore)
+	auditGroup := galleryGroup.Group("/audit")
+	auditGroup.Use(authenticator.Auth(auth.RoleGalleryAdmin))
+
+	auditGroup.GET("", auditController.Index)
+	auditGroup.GET("/verify", auditController.Verify)
+
+	if lockoutTracker != nil {
+		lockoutController := controllers.NewLockoutController(lockoutTracker)
+		lockoutGroup := galleryGroup.Group("/lockouts")
+		lockoutGroup.Use(authenticator.Auth(auth.RolePlatformAdmin))
+
+		lockoutGroup.GET("", lockoutController.Index)
+		lockoutGroup.DELETE("", lockoutController.Delete)
+	}
 
 	regularGroup := router.Group("/")
-	// regularGroup.Use(middleware.Auth("Regular", clientID))
-	// regularGroup.Use(middleware.Sanitize())
-	// regularGroup.Use(middleware.Validate())
+	regularGroup.Use(authenticator.AuthOrAPIKey(auth.RoleRegular, apiKeyService))
+	regularGroup.Use(middleware.ReadYourWrites(readYourWritesKey, cfg.Database.ReadYourWritesWindow))
+	regularGroup.Use(middleware.Validate())
 
 	regularGroup.GET("/artworks", artworkController.Index)
 	regularGroup.GET("/artworks/:id", artworkController.Find)
 
-	router.GET("/auth/callback", middleware.Sanitize(), middleware.Validate(), controllers.AuthCallback)
+	router.GET("/me", authenticator.AuthOrAPIKey("", apiKeyService), controllers.Me)
+
+	// SESSION_MODE=bff keeps tokens server-side behind an opaque session cookie
+	if cfg.Session.Mode == "bff" {
+		sessionController := controllers.NewSessionController(newSessionManager(cfg.Session, db, oidc), cfg.CookieDomain())
+		authenticator.Sessions = sessionController.Sessions
+		authenticator.Sessions.Principal = authenticator.Principal
+		if store, ok := sessionController.Sessions.Store.(io.Closer); ok {
+			srv.OnShutdown("session store", func(context.Context) error { return store.Close() })
+		}
+
+		router.POST("/auth/logout", sessionController.Logout)
+
+		sessionGroup := galleryGroup.Group("/sessions")
+		sessionGroup.Use(authenticator.Auth(auth.RoleGalleryAdmin))
+
+		sessionGroup.GET("", sessionController.Index)
+		sessionGroup.DELETE("/:id", sessionController.Delete)
+	}
+
+	authController := controllers.NewAuthController(oidc, cfg.CookieDomain(), authenticator.Sessions)
+	router.GET("/auth/callback", middleware.Sanitize(), middleware.Validate(), authController.Callback)
+
+	// TLS_CERT_FILE and TLS_KEY_FILE serve HTTPS directly instead of behind a
+	// proxy; renewed certificates are picked up without a restart
+	if cfg.Server.TLSCertFile != "" {
+		if err := srv.EnableTLS(cfg.Server); err != nil {
+			log.Fatalf("Failed to configure TLS: %v", err)
+		}
+		// The watcher polls every interval; missing three polls means it stalled
+		checker.Register("tls_certificate", health.WorkerCheck(srv.Certificates(), 3*cfg.Server.TLSReloadInterval))
+	}
+
+	if err := srv.Run(ctx); err != nil {
+		log.Fatalf("Server stopped with error: %v", err)
+	}
+}
+
+// newAuthenticator verifies tokens of the AUTH_ISSUERS_FILE issuers or, by
+// default, of the single Keycloak realm.
+func newAuthenticator(cfg *config.Config, oidc *auth.OIDCClient) *middleware.Authenticator {
+	a := &middleware.Authenticator{
+		ClientID:     cfg.Keycloak.ClientID,
+		OIDC:         oidc,
+		CookieDomain: cfg.CookieDomain(),
+	}
+
+	if cfg.Auth.IssuersFile != "" {
+		registry, err := auth.LoadRegistry(cfg.Auth.IssuersFile)
+		if err != nil {
+			log.Fatalf("Failed to load trusted issuers: %v", err)
+		}
+		a.Issuers = registry
+	} else {
+		a.Verifier = newTokenVerifier(cfg)
+		if cfg.Auth.ClaimMappingFile != "" {
+			mapper, err := auth.LoadClaimMapper(cfg.Auth.ClaimMappingFile)
+			if err != nil {
+				log.Fatalf("Failed to load claim mapping: %v", err)
+			}
+			a.ClaimMapper = mapper
+		}
+	}
+
+	// Machine clients authenticate with client-credentials tokens or, when
+	// serving TLS with MTLS_CLIENT_CA_FILE, with client certificates
+	if cfg.Auth.ServicesFile != "" {
+		services, err := auth.LoadServiceRegistry(cfg.Auth.ServicesFile)
+		if err != nil {
+			log.Fatalf("Failed to load service principals: %v", err)
+		}
+		if err := services.CheckIssuers(a.Issuers); err != nil {
+			log.Fatalf("Invalid service principals: %v", err)
+		}
+		a.Services = services
+	}
+	return a
+}
+
+// newLockoutTracker applies the LOCKOUT_* settings; nil disables lockouts.
+func newLockoutTracker(cfg config.Lockout) *lockout.Tracker {
+	if !cfg.Enabled {
+		return nil
+	}
+	policy := lockout.DefaultPolicy()
+	// Each instance counts only the failures it serves, so it locks out at
+	// its share of the threshold
+	policy.Threshold = max(1, (cfg.Threshold+cfg.Instances-1)/cfg.Instances)
+	policy.Window = cfg.Window
+	policy.BaseLockout = cfg.Base
+	policy.MaxLockout = cfg.Max
+	return lockout.NewTracker(policy)
+}
+
+// newArtworkRepository builds the artwork repository, reading through
+// dbRouter and cached by ID unless ARTWORK_CACHE_SIZE is 0, and the unit of
+// work that writes through it.
+func newArtworkRepository(cfg config.Cache, db *gorm.DB, dbRouter *dbrouter.Router, registry *metrics.Registry, srv *server.Server) (repositories.ArtworkRepository, repositories.UnitOfWork) {
+	repo := repositories.NewGormArtworkRepository(db)
+	repo.Router = dbRouter
+	unit := repositories.NewGormUnitOfWork(db)
+	unit.Router = dbRouter
+	if cfg.Size == 0 {
+		return repo, unit
+	}
+
+	cached := repositories.NewCachedArtworkRepository(repo, cfg.Size, cfg.TTL, cfg.NegativeTTL)
+	metrics.RegisterCacheStats(registry, "artworks", cached.Stats)
+	if cfg.Invalidation == "redis" {
+		bus, err := cache.NewRedisBus(cfg.RedisURL, "apollo:cache:artworks")
+		if err != nil {
+			log.Fatalf("Failed to configure cache invalidation: %v", err)
+		}
+		cached.Bus = bus
+		// Keep listening while requests drain, so their writes elsewhere are seen
+		listening, stop := context.WithCancel(context.Background())
+		cached.Subscribe(listening)
+		srv.OnShutdown("cache invalidation", func(context.Context) error {
+			stop()
+			return bus.Close()
+		})
+	}
+	return cached, repositories.NewCachedUnitOfWork(unit, cached)
+}
+
+// newSessionManager builds the BFF session store selected by SESSION_STORE.
+func newSessionManager(cfg config.Session, db *gorm.DB, oidc *auth.OIDCClient) *sessions.Manager {
+	var store sessions.Store
+	switch cfg.Store {
+	case "postgres":
+		store = sessions.NewGormStore(db)
+	case "redis":
+		redisStore, err := sessions.NewRedisStore(cfg.RedisURL)
+		if err != nil {
+			log.Fatalf("Failed to configure session store: %v", err)
+		}
+		store = redisStore
+	default:
+		store = sessions.NewMemoryStore()
+	}
+
+	return sessions.NewManager(store, oidc.Refresh, cfg.TTL)
+}
+
+// newTokenVerifier verifies JWTs locally by default. With INTROSPECTION_URL
+// set, opaque tokens and JWTs from INTROSPECTION_ISSUERS are checked at the
+// RFC 7662 introspection endpoint instead.
+func newTokenVerifier(cfg *config.Config) auth.Verifier {
+	jwtVerifier := &auth.JWTVerifier{JWKSURL: cfg.JWKSURL()}
+	ic := cfg.Auth.Introspection
+	if ic.URL == "" {
+		return jwtVerifier
+	}
+
+	introspection := auth.NewIntrospectionVerifier(ic.URL, ic.ClientID, ic.ClientSecret, ic.CacheTTL)
 
-	if err := router.Run(); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+	router := &auth.IssuerRouter{Issuers: map[string]auth.Verifier{}, Default: jwtVerifier, Opaque: introspection}
+	for _, issuer := range ic.Issuers {
+		router.Issuers[issuer] = introspection
 	}
+	return router
 }
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/paumarro/apollo-be/internal/config"
+	"github.com/paumarro/apollo-be/internal/initializers"
+	"github.com/paumarro/apollo-be/internal/migrate"
+)
+
+const migrateUsage = `usage: migrate <command> [flags]
+
+commands:
+  up             apply all pending migrations
+  down [steps]   revert the latest applied migrations (default 1)
+  status         list migrations and whether they are applied
+  create <name>  add empty up and down scripts for every dialect to ` + migrate.Dir + `
+
+flags are the configuration flags of the server, such as -art-db-url`
+
+// runMigrate implements the migrate subcommand. args start after "migrate".
+func runMigrate(ctx context.Context, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+	command, args := args[0], args[1:]
+	// Positional arguments come before the configuration flags
+	var positional []string
+	for len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		positional, args = append(positional, args[0]), args[1:]
+	}
+
+	switch command {
+	case "create":
+		if len(positional) != 1 {
+			return errors.New("usage: migrate create <name>")
+		}
+		for _, dialect := range migrate.Dialects {
+			up, down, err := migrate.Create(filepath.Join(migrate.Dir, dialect), positional[0])
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(out, "Created %s\nCreated %s\n", up, down)
+		}
+		return nil
+	case "up", "status":
+		if len(positional) != 0 {
+			return fmt.Errorf("usage: migrate %s", command)
+		}
+	case "down":
+		if len(positional) > 1 {
+			return errors.New("usage: migrate down [steps]")
+		}
+	default:
+		return errors.New(migrateUsage)
+	}
+
+	steps := 1
+	if command == "down" && len(positional) == 1 {
+		n, err := strconv.Atoi(positional[0])
+		if err != nil || n < 1 {
+			return fmt.Errorf("steps must be a positive number, got %q", positional[0])
+		}
+		steps = n
+	}
+
+	cfg, err := config.Load(args, os.LookupEnv)
+	if err != nil {
+		return err
+	}
+	if cfg.Database.URL == "" {
+		return errors.New("ART_DB_URL is required")
+	}
+	migrator, err := newMigrator(ctx, cfg.Database)
+	if err != nil {
+		return err
+	}
+	defer migrator.DB.Close()
+
+	switch command {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		for _, m := range applied {
+			fmt.Fprintf(out, "Applied %04d_%s\n", m.Version, m.Name)
+		}
+		if err == nil && len(applied) == 0 {
+			fmt.Fprintln(out, "No pending migrations")
+		}
+		if err != nil {
+			return err
+		}
+		assigned, err := migrate.AssignGallery(ctx, migrator.DB, cfg.Database.DefaultGallery)
+		if assigned > 0 {
+			fmt.Fprintf(out, "Assigned %d artworks to gallery %s\n", assigned, cfg.Database.DefaultGallery)
+		}
+		return err
+	case "down":
+		reverted, err := migrator.Down(ctx, steps)
+		for _, m := range reverted {
+			fmt.Fprintf(out, "Reverted %04d_%s\n", m.Version, m.Name)
+		}
+		if err == nil && len(reverted) == 0 {
+			fmt.Fprintln(out, "No applied migrations")
+		}
+		return err
+	default:
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, s := range statuses {
+			appliedAt := "-"
+			if s.AppliedAt != nil {
+				appliedAt = s.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(out, "%04d_%-40s %-9s %s\n", s.Version, s.Name, s.State, appliedAt)
+		}
+		return nil
+	}
+}
+
+// newMigrator connects to the database of cfg with the embedded migrations
+// of its dialect.
+func newMigrator(ctx context.Context, cfg config.Database) (*migrate.Migrator, error) {
+	db, err := initializers.ConnectToDB(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+	migrator, err := migrate.ForDialect(sqlDB, db.Dialector.Name())
+	if err != nil {
+		_ = sqlDB.Close()
+		return nil, err
+	}
+	return migrator, nil
+}
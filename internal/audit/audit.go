@@ -0,0 +1,168 @@
+// Package audit records security events to a tamper-evident store and the
+// application log.
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/paumarro/apollo-be/internal/auth"
+	"github.com/paumarro/apollo-be/internal/models"
+)
+
+// Event types
+const (
+	TypeLoginSuccess  = "login.success"
+	TypeLoginFailure  = "login.failure"
+	TypeLoginRedirect = "login.redirect"
+	TypeLogout        = "logout"
+	TypeTokenRefresh  = "token.refresh"
+	TypeTokenRejected = "token.rejected"
+	TypeAccessDenied  = "access.denied"
+	TypeAdminAction   = "admin.action"
+	TypeLockout       = "lockout"
+)
+
+// Outcomes
+const (
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+	OutcomeDenied  = "denied"
+)
+
+// Filter selects events. Galleries and Tenant restrict visibility: an event
+// is visible when its gallery is in Galleries, or it has no gallery and
+// belongs to Tenant. The remaining fields narrow the result when set.
+type Filter struct {
+	Galleries []string
+	Tenant    string
+
+	Type     string
+	Outcome  string
+	Actor    string
+	Gallery  string
+	Since    time.Time
+	Until    time.Time
+	BeforeID uint
+	Limit    int
+}
+
+// Store persists audit events. Implementations must be safe for concurrent use.
+type Store interface {
+	// Append stores e as the most recent event. It first calls link with the
+	// current most recent event, or nil, so e can be chained to it. Reading
+	// that event and storing e are atomic across every writer of the store,
+	// including other processes, so concurrent writers cannot fork the chain.
+	Append(ctx context.Context, e *models.AuditEvent, link func(last *models.AuditEvent)) error
+	// Last returns the most recent event, or nil when the store is empty.
+	Last(ctx context.Context) (*models.AuditEvent, error)
+	// Query returns events matching the filter, newest first.
+	Query(ctx context.Context, f Filter) ([]models.AuditEvent, error)
+	// List returns up to limit events with an ID above afterID, oldest first.
+	List(ctx context.Context, afterID uint, limit int) ([]models.AuditEvent, error)
+}
+
+// Recorder chains events and writes them to the store and the log stream.
+// Any number of recorders, in any number of processes, may share a store.
+type Recorder struct {
+	Store Store
+	Now   func() time.Time
+}
+
+// NewRecorder creates a recorder. A nil store only writes the log stream.
+func NewRecorder(store Store) *Recorder {
+	return &Recorder{Store: store, Now: time.Now}
+}
+
+// Record stamps, chains and stores the event.
+func (r *Recorder) Record(ctx context.Context, e models.AuditEvent) error {
+	return r.write(ctx, r.stamp(e))
+}
+
+// stamp sets the event time and clears the ID the store assigns.
+func (r *Recorder) stamp(e models.AuditEvent) models.AuditEvent {
+	// Databases keep microseconds; truncating keeps stored hashes verifiable
+	e.Time = r.Now().UTC().Truncate(time.Microsecond)
+	e.ID = 0
+	return e
+}
+
+// write chains and stores a stamped event and writes it to the log stream.
+func (r *Recorder) write(ctx context.Context, e models.AuditEvent) error {
+	var err error
+	if r.Store != nil {
+		err = r.append(ctx, &e)
+	}
+
+	if line, marshalErr := json.Marshal(e); marshalErr == nil {
+		log.Printf("AUDIT %s", line)
+	}
+	return err
+}
+
+func (r *Recorder) append(ctx context.Context, e *models.AuditEvent) error {
+	return r.Store.Append(ctx, e, func(last *models.AuditEvent) {
+		e.PrevHash = ""
+		if last != nil {
+			e.PrevHash = last.Hash
+		}
+		e.Hash = Hash(e)
+	})
+}
+
+// Writer records events. Recorder writes them in the calling goroutine,
+// Queue in the background.
+type Writer interface {
+	Record(ctx context.Context, e models.AuditEvent) error
+}
+
+var (
+	defaultMu       sync.RWMutex
+	defaultRecorder Writer = NewRecorder(nil)
+)
+
+// Use replaces the writer used by Record and RecordRequest.
+func Use(r Writer) {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	defaultRecorder = r
+}
+
+// Record writes an event with the default writer. Failures are logged and
+// never fail the request that caused the event.
+func Record(ctx context.Context, e models.AuditEvent) {
+	defaultMu.RLock()
+	r := defaultRecorder
+	defaultMu.RUnlock()
+
+	if err := r.Record(ctx, e); err != nil {
+		log.Printf("Failed to record audit event %s: %v", e.Type, err)
+	}
+}
+
+// RecordRequest fills in the request and, when authenticated, the principal
+// before recording the event.
+func RecordRequest(c *gin.Context, e models.AuditEvent) {
+	ctx := context.Background()
+	if c.Request != nil {
+		ctx = c.Request.Context()
+		e.Method = c.Request.Method
+		e.Path = c.Request.URL.Path
+		e.IP = c.ClientIP()
+		e.UserAgent = c.Request.UserAgent()
+	}
+	if p, ok := auth.PrincipalFrom(c); ok {
+		if e.Actor == "" {
+			e.Actor = p.Subject
+			e.ActorKind = p.Kind
+		}
+		if e.Tenant == "" {
+			e.Tenant = p.Tenant
+		}
+	}
+	Record(ctx, e)
+}
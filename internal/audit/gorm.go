@@ -0,0 +1,97 @@
+package audit
+
+import (
+	"context"
+	"errors"
+
+	"github.com/paumarro/apollo-be/internal/models"
+	"gorm.io/gorm"
+)
+
+// chainLockKey identifies the audit chain's advisory lock among others on
+// the server.
+const chainLockKey int64 = 0x6175646974 // "audit"
+
+// GormStore keeps events in the application database.
+type GormStore struct {
+	DB *gorm.DB
+}
+
+func NewGormStore(db *gorm.DB) *GormStore {
+	return &GormStore{DB: db}
+}
+
+// Append reads the most recent event and inserts e in one transaction. On
+// Postgres an advisory lock held until the commit serialises the replicas
+// writing the store. SQLite admits one writer at a time, and a transaction
+// overtaken by another writer fails instead of forking the chain.
+func (g *GormStore) Append(ctx context.Context, e *models.AuditEvent, link func(last *models.AuditEvent)) error {
+	return g.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if tx.Dialector.Name() == "postgres" {
+			if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", chainLockKey).Error; err != nil {
+				return err
+			}
+		}
+		last, err := (&GormStore{DB: tx}).Last(ctx)
+		if err != nil {
+			return err
+		}
+		link(last)
+		return tx.Create(e).Error
+	})
+}
+
+func (g *GormStore) Last(ctx context.Context) (*models.AuditEvent, error) {
+	var e models.AuditEvent
+	err := g.DB.WithContext(ctx).Order("id DESC").First(&e).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+func (g *GormStore) Query(ctx context.Context, f Filter) ([]models.AuditEvent, error) {
+	q := g.DB.WithContext(ctx).Model(&models.AuditEvent{})
+	if len(f.Galleries) > 0 {
+		q = q.Where("(gallery IN ? OR (gallery = '' AND tenant = ?))", f.Galleries, f.Tenant)
+	} else {
+		q = q.Where("gallery = '' AND tenant = ?", f.Tenant)
+	}
+	if f.Type != "" {
+		q = q.Where("type = ?", f.Type)
+	}
+	if f.Outcome != "" {
+		q = q.Where("outcome = ?", f.Outcome)
+	}
+	if f.Actor != "" {
+		q = q.Where("actor = ?", f.Actor)
+	}
+	if f.Gallery != "" {
+		q = q.Where("gallery = ?", f.Gallery)
+	}
+	if !f.Since.IsZero() {
+		q = q.Where("occurred_at >= ?", f.Since)
+	}
+	if !f.Until.IsZero() {
+		q = q.Where("occurred_at < ?", f.Until)
+	}
+	if f.BeforeID != 0 {
+		q = q.Where("id < ?", f.BeforeID)
+	}
+	if f.Limit > 0 {
+		q = q.Limit(f.Limit)
+	}
+
+	var out []models.AuditEvent
+	err := q.Order("id DESC").Find(&out).Error
+	return out, err
+}
+
+func (g *GormStore) List(ctx context.Context, afterID uint, limit int) ([]models.AuditEvent, error) {
+	var out []models.AuditEvent
+	err := g.DB.WithContext(ctx).Where("id > ?", afterID).Order("id").Limit(limit).Find(&out).Error
+	return out, err
+}
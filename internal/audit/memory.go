@@ -0,0 +1,106 @@
+package audit
+
+import (
+	"context"
+	"sync"
+
+	"github.com/paumarro/apollo-be/internal/models"
+)
+
+// MemoryStore keeps events in process memory, for development and tests.
+type MemoryStore struct {
+	mu     sync.Mutex
+	events []models.AuditEvent
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{}
+}
+
+func (m *MemoryStore) Append(ctx context.Context, e *models.AuditEvent, link func(last *models.AuditEvent)) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.events) == 0 {
+		link(nil)
+	} else {
+		last := m.events[len(m.events)-1]
+		link(&last)
+	}
+	e.ID = uint(len(m.events) + 1)
+	m.events = append(m.events, *e)
+	return nil
+}
+
+func (m *MemoryStore) Last(ctx context.Context) (*models.AuditEvent, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.events) == 0 {
+		return nil, nil
+	}
+	e := m.events[len(m.events)-1]
+	return &e, nil
+}
+
+func (m *MemoryStore) Query(ctx context.Context, f Filter) ([]models.AuditEvent, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := []models.AuditEvent{}
+	for i := len(m.events) - 1; i >= 0; i-- {
+		if f.Limit > 0 && len(out) >= f.Limit {
+			break
+		}
+		if matches(f, &m.events[i]) {
+			out = append(out, m.events[i])
+		}
+	}
+	return out, nil
+}
+
+func (m *MemoryStore) List(ctx context.Context, afterID uint, limit int) ([]models.AuditEvent, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := []models.AuditEvent{}
+	for _, e := range m.events {
+		if e.ID > afterID && len(out) < limit {
+			out = append(out, e)
+		}
+	}
+	return out, nil
+}
+
+// Tamper replaces a stored event as-is. It exists so tests can check that
+// verification detects modified records.
+func (m *MemoryStore) Tamper(e models.AuditEvent) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range m.events {
+		if m.events[i].ID == e.ID {
+			m.events[i] = e
+		}
+	}
+}
+
+func matches(f Filter, e *models.AuditEvent) bool {
+	visible := false
+	for _, g := range f.Galleries {
+		if e.Gallery == g {
+			visible = true
+		}
+	}
+	if e.Gallery == "" && e.Tenant == f.Tenant {
+		visible = true
+	}
+	switch {
+	case !visible:
+		return false
+	case f.Type != "" && e.Type != f.Type,
+		f.Outcome != "" && e.Outcome != f.Outcome,
+		f.Actor != "" && e.Actor != f.Actor,
+		f.Gallery != "" && e.Gallery != f.Gallery,
+		!f.Since.IsZero() && e.Time.Before(f.Since),
+		!f.Until.IsZero() && !e.Time.Before(f.Until),
+		f.BeforeID != 0 && e.ID >= f.BeforeID:
+		return false
+	}
+	return true
+}
@@ -0,0 +1,146 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/paumarro/apollo-be/internal/models"
+)
+
+// writeTimeout bounds each append, so a stalled store cannot hold up the
+// events queued behind it indefinitely.
+const writeTimeout = 10 * time.Second
+
+// maxLimitedIPs bounds the addresses the anonymous event limit tracks in one
+// window. Events from addresses beyond it are dropped until the window ends.
+const maxLimitedIPs = 10000
+
+// Queue hands events to a single background writer, so requests never wait
+// for the store and each process appends to the chain one event at a time.
+// Events that find the queue full are written to the log stream only.
+//
+// Login redirects and token rejections without an actor can be caused by
+// anyone without credentials, so at most PerIP of them per client IP are
+// recorded in each window; the rest are dropped.
+type Queue struct {
+	recorder *Recorder
+	events   chan models.AuditEvent
+	done     chan struct{}
+	limiter  *ipLimiter
+	dropped  atomic.Int64
+
+	mu     sync.RWMutex
+	closed bool
+}
+
+// NewQueue starts a writer for r holding up to size pending events and
+// recording at most perIP anonymous events per client IP each window.
+func NewQueue(r *Recorder, size, perIP int, window time.Duration) *Queue {
+	q := &Queue{
+		recorder: r,
+		events:   make(chan models.AuditEvent, size),
+		done:     make(chan struct{}),
+		limiter:  &ipLimiter{limit: perIP, window: window, counts: make(map[string]int)},
+	}
+	go q.run()
+	return q
+}
+
+// Record stamps the event and queues it without waiting. Once the queue is
+// closed, events are written in the calling goroutine.
+func (q *Queue) Record(ctx context.Context, e models.AuditEvent) error {
+	e = q.recorder.stamp(e)
+	if anonymous(e) && !q.limiter.allow(e.IP, e.Time) {
+		q.dropped.Add(1)
+		return nil
+	}
+
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	if q.closed {
+		return q.recorder.write(ctx, e)
+	}
+	select {
+	case q.events <- e:
+	default:
+		q.dropped.Add(1)
+		if line, err := json.Marshal(e); err == nil {
+			log.Printf("AUDIT %s", line)
+		}
+		log.Printf("Audit queue full, %s event not stored", e.Type)
+	}
+	return nil
+}
+
+// Dropped returns the number of events that were not stored, because the
+// queue was full or the client IP was over its limit.
+func (q *Queue) Dropped() int64 {
+	return q.dropped.Load()
+}
+
+// Close stops accepting events and waits until the queued ones are stored
+// or ctx is done.
+func (q *Queue) Close(ctx context.Context) error {
+	q.mu.Lock()
+	if !q.closed {
+		q.closed = true
+		close(q.events)
+	}
+	q.mu.Unlock()
+
+	select {
+	case <-q.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (q *Queue) run() {
+	defer close(q.done)
+	for e := range q.events {
+		ctx, cancel := context.WithTimeout(context.Background(), writeTimeout)
+		if err := q.recorder.write(ctx, e); err != nil {
+			log.Printf("Failed to record audit event %s: %v", e.Type, err)
+		}
+		cancel()
+	}
+}
+
+// anonymous reports whether e is an event anyone can cause without
+// credentials.
+func anonymous(e models.AuditEvent) bool {
+	return e.Actor == "" && (e.Type == TypeLoginRedirect || e.Type == TypeTokenRejected)
+}
+
+// ipLimiter counts events per IP in fixed windows.
+type ipLimiter struct {
+	limit  int
+	window time.Duration
+
+	mu     sync.Mutex
+	start  time.Time
+	counts map[string]int
+}
+
+func (l *ipLimiter) allow(ip string, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if now.Sub(l.start) >= l.window {
+		l.start = now
+		clear(l.counts)
+	}
+	n, ok := l.counts[ip]
+	if !ok && len(l.counts) >= maxLimitedIPs {
+		return false
+	}
+	if n >= l.limit {
+		return false
+	}
+	l.counts[ip] = n + 1
+	return true
+}
@@ -0,0 +1,204 @@
+package auth
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ClaimPath is a compiled JSONPath-like expression selecting values from token
+// claims. Supported syntax:
+//
+//	$.realm_access.roles             dotted member names
+//	$.resource_access["my-client"]   quoted member names ('single' or "double")
+//	$.groups[0]                      array index
+//	$.resource_access.*.roles        wildcard over object members or array items
+//
+// The leading "$." is optional.
+type ClaimPath struct {
+	expr     string
+	segments []pathSegment
+}
+
+type pathSegment struct {
+	key      string
+	index    int
+	isIndex  bool
+	wildcard bool
+}
+
+// ParseClaimPath compiles an expression.
+func ParseClaimPath(expr string) (ClaimPath, error) {
+	p := ClaimPath{expr: expr}
+	s := strings.TrimSpace(expr)
+	if strings.HasPrefix(s, "$") {
+		s = s[1:]
+		if s == "" {
+			return p, fmt.Errorf("empty claim path %q", expr)
+		}
+	} else if s != "" && s[0] != '[' {
+		// Bare "realm_access.roles"
+		s = "." + s
+	}
+	if s == "" {
+		return p, fmt.Errorf("empty claim path %q", expr)
+	}
+
+	for len(s) > 0 {
+		switch s[0] {
+		case '.':
+			s = s[1:]
+			end := strings.IndexAny(s, ".[")
+			if end < 0 {
+				end = len(s)
+			}
+			name := s[:end]
+			s = s[end:]
+			if name == "" {
+				return p, fmt.Errorf("empty member name in claim path %q", expr)
+			}
+			if name == "*" {
+				p.segments = append(p.segments, pathSegment{wildcard: true})
+			} else {
+				p.segments = append(p.segments, pathSegment{key: name})
+			}
+		case '[':
+			seg, rest, err := parseBracket(s)
+			if err != nil {
+				return p, fmt.Errorf("invalid claim path %q: %w", expr, err)
+			}
+			p.segments = append(p.segments, seg)
+			s = rest
+		default:
+			return p, fmt.Errorf("unexpected %q in claim path %q", s[0], expr)
+		}
+	}
+	return p, nil
+}
+
+// parseBracket parses a leading [..] selector and returns the remaining input.
+func parseBracket(s string) (pathSegment, string, error) {
+	if len(s) > 1 && (s[1] == '"' || s[1] == '\'') {
+		quote := s[1]
+		end := -1
+		for i := 2; i < len(s); i++ {
+			if s[i] == '\\' {
+				i++
+				continue
+			}
+			if s[i] == quote {
+				end = i
+				break
+			}
+		}
+		if end < 0 || end+1 >= len(s) || s[end+1] != ']' {
+			return pathSegment{}, "", fmt.Errorf("unterminated quoted member")
+		}
+		raw := s[1 : end+1]
+		if quote == '\'' {
+			raw = `"` + strings.ReplaceAll(strings.ReplaceAll(raw[1:len(raw)-1], `\'`, `'`), `"`, `\"`) + `"`
+		}
+		key, err := strconv.Unquote(raw)
+		if err != nil {
+			return pathSegment{}, "", fmt.Errorf("invalid quoted member: %v", err)
+		}
+		return pathSegment{key: key}, s[end+2:], nil
+	}
+
+	end := strings.IndexByte(s, ']')
+	if end < 0 {
+		return pathSegment{}, "", fmt.Errorf("missing ]")
+	}
+	inner := strings.TrimSpace(s[1:end])
+	if inner == "*" {
+		return pathSegment{wildcard: true}, s[end+1:], nil
+	}
+	index, err := strconv.Atoi(inner)
+	if err != nil || index < 0 {
+		return pathSegment{}, "", fmt.Errorf("invalid index %q", inner)
+	}
+	return pathSegment{index: index, isIndex: true}, s[end+1:], nil
+}
+
+// String returns the source expression.
+func (p ClaimPath) String() string {
+	return p.expr
+}
+
+// Values returns every value the path selects. Missing members and values of
+// unexpected types select nothing rather than failing.
+func (p ClaimPath) Values(claims Claims) []interface{} {
+	current := []interface{}{map[string]interface{}(claims)}
+	for _, seg := range p.segments {
+		var next []interface{}
+		for _, v := range current {
+			switch {
+			case seg.wildcard:
+				next = append(next, children(v)...)
+			case seg.isIndex:
+				if items, ok := asSlice(v); ok && seg.index < len(items) {
+					next = append(next, items[seg.index])
+				}
+			default:
+				if obj, ok := v.(map[string]interface{}); ok {
+					if child, ok := obj[seg.key]; ok {
+						next = append(next, child)
+					}
+				}
+			}
+		}
+		current = next
+	}
+	return current
+}
+
+// Strings returns the string values the path selects, flattening arrays.
+func (p ClaimPath) Strings(claims Claims) []string {
+	var out []string
+	for _, v := range p.Values(claims) {
+		if s, ok := v.(string); ok {
+			out = append(out, s)
+			continue
+		}
+		if items, ok := asSlice(v); ok {
+			for _, item := range items {
+				if s, ok := item.(string); ok {
+					out = append(out, s)
+				}
+			}
+		}
+	}
+	return out
+}
+
+func children(v interface{}) []interface{} {
+	if obj, ok := v.(map[string]interface{}); ok {
+		keys := make([]string, 0, len(obj))
+		for k := range obj {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		out := make([]interface{}, 0, len(keys))
+		for _, k := range keys {
+			out = append(out, obj[k])
+		}
+		return out
+	}
+	items, _ := asSlice(v)
+	return items
+}
+
+func asSlice(v interface{}) ([]interface{}, bool) {
+	switch items := v.(type) {
+	case []interface{}:
+		return items, true
+	case []string:
+		out := make([]interface{}, len(items))
+		for i, s := range items {
+			out[i] = s
+		}
+		return out, true
+	}
+	return nil, false
+}
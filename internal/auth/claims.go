@@ -0,0 +1,150 @@
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// Claims are the verified claims of a token, whether decoded from a JWT or
+// returned by an introspection endpoint.
+type Claims map[string]interface{}
+
+// RoleRule maps claim values onto internal roles. With Equals set, Roles are
+// granted when any value selected by Claim equals it. Otherwise the selected
+// values are roles themselves, limited to Roles when that is non-empty.
+type RoleRule struct {
+	Claim  string   `json:"claim"`
+	Equals string   `json:"equals,omitempty"`
+	Roles  []string `json:"roles,omitempty"`
+}
+
+// ClaimMapping declares where an identity provider keeps roles and galleries.
+type ClaimMapping struct {
+	Roles     []RoleRule `json:"roles"`
+	Galleries []string   `json:"galleries"`
+}
+
+// DefaultClaimMapping reads Keycloak client roles and the galleries claim.
+func DefaultClaimMapping(clientID string) ClaimMapping {
+	return ClaimMapping{
+		Roles:     []RoleRule{{Claim: fmt.Sprintf("$.resource_access[%q].roles", clientID)}},
+		Galleries: []string{"$.galleries"},
+	}
+}
+
+type compiledRoleRule struct {
+	RoleRule
+	path ClaimPath
+}
+
+// ClaimMapper builds principals from claims according to a ClaimMapping.
+type ClaimMapper struct {
+	roles     []compiledRoleRule
+	galleries []ClaimPath
+}
+
+// NewClaimMapper compiles the mapping's claim paths.
+func NewClaimMapper(m ClaimMapping) (*ClaimMapper, error) {
+	mapper := &ClaimMapper{}
+	for _, rule := range m.Roles {
+		path, err := ParseClaimPath(rule.Claim)
+		if err != nil {
+			return nil, err
+		}
+		if rule.Equals != "" && len(rule.Roles) == 0 {
+			return nil, fmt.Errorf("role rule for %q matches %q but grants no roles", rule.Claim, rule.Equals)
+		}
+		mapper.roles = append(mapper.roles, compiledRoleRule{RoleRule: rule, path: path})
+	}
+	for _, expr := range m.Galleries {
+		path, err := ParseClaimPath(expr)
+		if err != nil {
+			return nil, err
+		}
+		mapper.galleries = append(mapper.galleries, path)
+	}
+	return mapper, nil
+}
+
+// LoadClaimMapper reads a JSON ClaimMapping from path.
+func LoadClaimMapper(path string) (*ClaimMapper, error) {
+	data, err := os.ReadFile(path) // #nosec G304 -- path comes from operator configuration
+	if err != nil {
+		return nil, fmt.Errorf("failed to read claim mapping file: %w", err)
+	}
+
+	var m ClaimMapping
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("failed to parse claim mapping file: %w", err)
+	}
+	return NewClaimMapper(m)
+}
+
+// Principal builds the request principal from verified claims.
+func (m *ClaimMapper) Principal(claims Claims) *Principal {
+	p := &Principal{
+		Kind:      KindUser,
+		Roles:     []string{},
+		Galleries: []string{},
+	}
+	p.Subject, _ = claims["sub"].(string)
+	p.Email, _ = claims["email"].(string)
+	if azp, ok := claims["azp"].(string); ok && azp != "" {
+		p.Client = azp
+	} else {
+		// RFC 7662 and some client-credentials tokens name the client client_id
+		p.Client, _ = claims["client_id"].(string)
+	}
+	if sid, ok := claims["sid"].(string); ok && sid != "" {
+		p.Session = sid
+	} else {
+		p.Session, _ = claims["session_state"].(string)
+	}
+	if name, ok := claims["name"].(string); ok && name != "" {
+		p.Name = name
+	} else if username, ok := claims["preferred_username"].(string); ok && username != "" {
+		p.Name = username
+	} else {
+		// RFC 7662 introspection responses use "username"
+		p.Name, _ = claims["username"].(string)
+	}
+
+	for _, rule := range m.roles {
+		values := rule.path.Strings(claims)
+		if rule.Equals != "" {
+			if contains(values, rule.Equals) {
+				p.Roles = appendUnique(p.Roles, rule.Roles...)
+			}
+			continue
+		}
+		for _, v := range values {
+			if len(rule.Roles) == 0 || contains(rule.Roles, v) {
+				p.Roles = appendUnique(p.Roles, v)
+			}
+		}
+	}
+	for _, path := range m.galleries {
+		p.Galleries = appendUnique(p.Galleries, path.Strings(claims)...)
+	}
+	return p
+}
+
+// PrincipalFromClaims builds a principal using the DefaultClaimMapping for clientID.
+func PrincipalFromClaims(claims Claims, clientID string) *Principal {
+	mapper, err := NewClaimMapper(DefaultClaimMapping(clientID))
+	if err != nil {
+		// The default mapping always compiles; fall back to an unprivileged principal
+		mapper = &ClaimMapper{}
+	}
+	return mapper.Principal(claims)
+}
+
+func appendUnique(list []string, values ...string) []string {
+	for _, v := range values {
+		if !contains(list, v) {
+			list = append(list, v)
+		}
+	}
+	return list
+}
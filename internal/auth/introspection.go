@@ -0,0 +1,153 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultIntrospectionCacheTTL bounds how long an active result is reused
+	// when the verifier is created without an explicit limit.
+	DefaultIntrospectionCacheTTL = time.Minute
+	// maxIntrospectionCacheEntries triggers a sweep of expired results.
+	maxIntrospectionCacheEntries = 10000
+)
+
+// IntrospectionVerifier validates tokens at an RFC 7662 introspection
+// endpoint. It handles opaque and reference tokens that cannot be verified
+// locally. Active results are cached by token hash until the earlier of the
+// token's exp and CacheTTL.
+type IntrospectionVerifier struct {
+	Endpoint     string
+	ClientID     string
+	ClientSecret string
+	CacheTTL     time.Duration
+	HTTPClient   *http.Client
+	Now          func() time.Time
+
+	mu    sync.Mutex
+	cache map[string]introspectionResult
+}
+
+type introspectionResult struct {
+	claims    Claims
+	expiresAt time.Time
+}
+
+// NewIntrospectionVerifier creates a verifier authenticating to the endpoint with client credentials.
+func NewIntrospectionVerifier(endpoint, clientID, clientSecret string, cacheTTL time.Duration) *IntrospectionVerifier {
+	if cacheTTL <= 0 {
+		cacheTTL = DefaultIntrospectionCacheTTL
+	}
+	return &IntrospectionVerifier{
+		Endpoint:     endpoint,
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		CacheTTL:     cacheTTL,
+		HTTPClient:   &http.Client{Timeout: 10 * time.Second},
+		Now:          time.Now,
+	}
+}
+
+func (v *IntrospectionVerifier) Verify(ctx context.Context, token string) (Claims, error) {
+	key := tokenHash(token)
+	now := v.Now()
+
+	v.mu.Lock()
+	if result, ok := v.cache[key]; ok {
+		if now.Before(result.expiresAt) {
+			v.mu.Unlock()
+			return result.claims, nil
+		}
+		delete(v.cache, key)
+	}
+	v.mu.Unlock()
+
+	claims, err := v.introspect(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	expiresAt := now.Add(v.CacheTTL)
+	if exp, ok := claims["exp"].(float64); ok {
+		tokenExpiry := time.Unix(int64(exp), 0)
+		if !now.Before(tokenExpiry) {
+			return nil, fmt.Errorf("%w: introspection reported an expired token as active", ErrTokenExpired)
+		}
+		if tokenExpiry.Before(expiresAt) {
+			expiresAt = tokenExpiry
+		}
+	}
+
+	v.mu.Lock()
+	if v.cache == nil {
+		v.cache = map[string]introspectionResult{}
+	}
+	if len(v.cache) >= maxIntrospectionCacheEntries {
+		for k, result := range v.cache {
+			if !now.Before(result.expiresAt) {
+				delete(v.cache, k)
+			}
+		}
+	}
+	if len(v.cache) < maxIntrospectionCacheEntries {
+		v.cache[key] = introspectionResult{claims: claims, expiresAt: expiresAt}
+	}
+	v.mu.Unlock()
+
+	return claims, nil
+}
+
+func (v *IntrospectionVerifier) introspect(ctx context.Context, token string) (Claims, error) {
+	data := url.Values{}
+	data.Set("token", token)
+	data.Set("token_type_hint", "access_token")
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, v.Endpoint, strings.NewReader(data.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create introspection request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if v.ClientID != "" {
+		req.SetBasicAuth(url.QueryEscape(v.ClientID), url.QueryEscape(v.ClientSecret))
+	}
+
+	client := v.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to introspect token: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("introspection failed with status %d: %s", resp.StatusCode, string(bodyBytes))
+	}
+
+	var claims Claims
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&claims); err != nil {
+		return nil, fmt.Errorf("failed to decode introspection response: %v", err)
+	}
+	if active, _ := claims["active"].(bool); !active {
+		return nil, ErrTokenInactive
+	}
+	return claims, nil
+}
+
+func tokenHash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
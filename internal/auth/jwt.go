@@ -0,0 +1,155 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/lestrrat-go/jwx/jwk"
+)
+
+// jwksRefetchInterval limits forced key set refreshes triggered by unknown
+// key IDs, so tokens with made-up kids cannot hammer the issuer.
+const jwksRefetchInterval = time.Minute
+
+// JWTVerifier verifies RS256 tokens locally against the issuer's JWKS. Key
+// sets are cached and refreshed in the background; an unknown kid forces a
+// refresh to pick up rotated keys.
+type JWTVerifier struct {
+	// JWKSURL is the key set location.
+	JWKSURL string
+	// Issuer and Audience are checked against iss and aud when set.
+	Issuer   string
+	Audience string
+
+	once        sync.Once
+	keys        *jwk.AutoRefresh
+	stop        context.CancelFunc
+	mu          sync.Mutex
+	lastRefetch map[string]time.Time
+}
+
+func (v *JWTVerifier) Verify(ctx context.Context, tokenString string) (Claims, error) {
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		if token.Method != jwt.SigningMethodRS256 {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return v.publicKey(ctx, token)
+	})
+	if err != nil || token == nil || !token.Valid {
+		var validationErr *jwt.ValidationError
+		if errors.As(err, &validationErr) && validationErr.Errors == jwt.ValidationErrorExpired {
+			return nil, fmt.Errorf("%w: %v", ErrTokenExpired, err)
+		}
+		return nil, fmt.Errorf("%w: %v", ErrTokenInvalid, err)
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, fmt.Errorf("%w: unexpected claims type", ErrTokenInvalid)
+	}
+	if v.Issuer != "" && !claims.VerifyIssuer(v.Issuer, true) {
+		return nil, fmt.Errorf("%w: unexpected issuer", ErrTokenInvalid)
+	}
+	if v.Audience != "" && !claims.VerifyAudience(v.Audience, true) {
+		return nil, fmt.Errorf("%w: token not issued for audience %s", ErrTokenInvalid, v.Audience)
+	}
+	return Claims(claims), nil
+}
+
+func (v *JWTVerifier) publicKey(ctx context.Context, token *jwt.Token) (interface{}, error) {
+	// Extract the "kid" from the token header
+	kid, ok := token.Header["kid"].(string)
+	if !ok {
+		return nil, fmt.Errorf("missing kid in token header")
+	}
+
+	// Fetch the JWKS
+	set, err := v.keySet(ctx)
+	if err != nil {
+		return nil, err
+	}
+	jwksURL := v.JWKSURL
+
+	// Find the key with the matching "kid", refetching once if the issuer rotated keys
+	key, found := set.LookupKeyID(kid)
+	if !found && v.allowRefetch(jwksURL) {
+		if set, err = v.keys.Refresh(ctx, jwksURL); err == nil {
+			key, found = set.LookupKeyID(kid)
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("no matching key found for kid: %s", kid)
+	}
+
+	// Extract the public key
+	var pubKey interface{}
+	if err := key.Raw(&pubKey); err != nil {
+		return nil, fmt.Errorf("failed to extract public key: %v", err)
+	}
+
+	return pubKey, nil
+}
+
+// keySet returns the cached key set, fetching it on first use.
+func (v *JWTVerifier) keySet(ctx context.Context) (jwk.Set, error) {
+	if v.JWKSURL == "" {
+		return nil, fmt.Errorf("no JWKS URL configured")
+	}
+
+	v.once.Do(func() {
+		refreshCtx, stop := context.WithCancel(context.Background())
+		v.keys = jwk.NewAutoRefresh(refreshCtx)
+		v.stop = stop
+		v.lastRefetch = map[string]time.Time{}
+	})
+	if !v.keys.IsRegistered(v.JWKSURL) {
+		v.keys.Configure(v.JWKSURL, jwk.WithMinRefreshInterval(15*time.Minute))
+	}
+
+	set, err := v.keys.Fetch(ctx, v.JWKSURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch JWK set: %v", err)
+	}
+	return set, nil
+}
+
+// KeySets reports the cached key set, fetching it if it was never loaded.
+func (v *JWTVerifier) KeySets(ctx context.Context) []KeySetStatus {
+	status := KeySetStatus{URL: v.JWKSURL}
+	set, err := v.keySet(ctx)
+	if err != nil {
+		status.Error = err.Error()
+		return []KeySetStatus{status}
+	}
+	status.Keys = set.Len()
+	for target := range v.keys.Snapshot() {
+		if target.URL == v.JWKSURL {
+			status.LastRefresh = target.LastRefresh
+		}
+	}
+	return []KeySetStatus{status}
+}
+
+// Close stops the background key set refresh.
+func (v *JWTVerifier) Close() error {
+	v.once.Do(func() {})
+	if v.stop != nil {
+		v.stop()
+	}
+	return nil
+}
+
+func (v *JWTVerifier) allowRefetch(jwksURL string) bool {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	now := time.Now()
+	if now.Sub(v.lastRefetch[jwksURL]) < jwksRefetchInterval {
+		return false
+	}
+	v.lastRefetch[jwksURL] = now
+	return true
+}
@@ -0,0 +1,115 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ErrInvalidTokenResponse is returned when the token endpoint answers 200
+// without an access and refresh token.
+var ErrInvalidTokenResponse = errors.New("invalid token response")
+
+// TokenEndpointError is a non-200 answer of the token endpoint.
+type TokenEndpointError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *TokenEndpointError) Error() string {
+	return fmt.Sprintf("token endpoint returned %d: %s", e.StatusCode, e.Body)
+}
+
+// OIDCClient performs the authorization code and refresh token grants
+// against a Keycloak realm on behalf of the backend's confidential client.
+type OIDCClient struct {
+	// RealmURL is the issuer, e.g. https://keycloak.example.com/realms/apollo.
+	RealmURL     string
+	ClientID     string
+	ClientSecret string
+	// RedirectURL is the backend's /auth/callback URL registered with the realm.
+	RedirectURL string
+	HTTPClient  *http.Client
+}
+
+// NewOIDCClient creates a client for the realm.
+func NewOIDCClient(realmURL, clientID, clientSecret, redirectURL string) *OIDCClient {
+	return &OIDCClient{
+		RealmURL:     strings.TrimRight(realmURL, "/"),
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		RedirectURL:  redirectURL,
+		HTTPClient:   &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// LoginURL returns the authorization endpoint URL. state is echoed back to
+// the callback, which redirects to it after login.
+func (o *OIDCClient) LoginURL(state string) string {
+	q := url.Values{}
+	q.Set("response_type", "code")
+	q.Set("client_id", o.ClientID)
+	q.Set("redirect_uri", o.RedirectURL)
+	q.Set("scope", "openid")
+	q.Set("state", state)
+	return o.RealmURL + "/protocol/openid-connect/auth?" + q.Encode()
+}
+
+// Exchange redeems an authorization code for an access and refresh token.
+func (o *OIDCClient) Exchange(ctx context.Context, code string) (string, string, error) {
+	data := url.Values{}
+	data.Set("grant_type", "authorization_code")
+	data.Set("code", code)
+	data.Set("redirect_uri", o.RedirectURL)
+	return o.token(ctx, data)
+}
+
+// Refresh exchanges a refresh token for a new access and refresh token. Its
+// signature matches sessions.RefreshFunc.
+func (o *OIDCClient) Refresh(refreshToken string) (string, string, error) {
+	data := url.Values{}
+	data.Set("grant_type", "refresh_token")
+	data.Set("refresh_token", refreshToken)
+	return o.token(context.Background(), data)
+}
+
+func (o *OIDCClient) token(ctx context.Context, data url.Values) (string, string, error) {
+	data.Set("client_id", o.ClientID)
+	data.Set("client_secret", o.ClientSecret)
+
+	tokenURL := o.RealmURL + "/protocol/openid-connect/token"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenURL, strings.NewReader(data.Encode()))
+	if err != nil {
+		return "", "", fmt.Errorf("failed to create token request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := o.HTTPClient.Do(req)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to reach token endpoint: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return "", "", &TokenEndpointError{StatusCode: resp.StatusCode, Body: string(body)}
+	}
+
+	var tokens struct {
+		AccessToken  string `json:"access_token"`
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tokens); err != nil {
+		return "", "", fmt.Errorf("%w: %v", ErrInvalidTokenResponse, err)
+	}
+	if tokens.AccessToken == "" || tokens.RefreshToken == "" {
+		return "", "", fmt.Errorf("%w: missing access or refresh token", ErrInvalidTokenResponse)
+	}
+	return tokens.AccessToken, tokens.RefreshToken, nil
+}
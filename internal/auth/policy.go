@@ -0,0 +1,177 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// ErrForbidden is returned when no policy allows an action.
+var ErrForbidden = errors.New("forbidden by policy")
+
+// Artwork actions
+const (
+	ActionArtworkRead   = "artwork:read"
+	ActionArtworkCreate = "artwork:create"
+	ActionArtworkUpdate = "artwork:update"
+	ActionArtworkDelete = "artwork:delete"
+	ActionArtworkPurge  = "artwork:purge"
+)
+
+// Resource is the loaded object an action is evaluated against.
+type Resource struct {
+	Type    string
+	Gallery string
+	Status  string
+}
+
+// Condition is a named predicate over the principal and the resource.
+type Condition func(p *Principal, r Resource) bool
+
+// Rule allows its actions when the principal holds any of Roles (or Roles is
+// empty, which includes anonymous callers) and every condition holds.
+type Rule struct {
+	Name       string   `json:"name"`
+	Actions    []string `json:"actions"`
+	Roles      []string `json:"roles"`
+	Conditions []string `json:"conditions"`
+}
+
+// Conditions available to rules by name
+var builtinConditions = map[string]Condition{
+	"own_gallery": func(p *Principal, r Resource) bool {
+		return p != nil && p.ManagesGallery(r.Gallery)
+	},
+	"draft": func(p *Principal, r Resource) bool {
+		return r.Status == "draft"
+	},
+	"published": func(p *Principal, r Resource) bool {
+		return r.Status == "" || r.Status == "published"
+	},
+	"authenticated": func(p *Principal, r Resource) bool {
+		return p != nil
+	},
+}
+
+// DefaultRules are the artwork policies used when no policy file is configured.
+func DefaultRules() []Rule {
+	return []Rule{
+		{
+			Name:       "anyone-reads-published",
+			Actions:    []string{ActionArtworkRead},
+			Conditions: []string{"published"},
+		},
+		{
+			Name:       "gallery-members-read-drafts",
+			Actions:    []string{ActionArtworkRead},
+			Roles:      []string{RoleGalleryViewer, RoleGallery, RoleGalleryAdmin},
+			Conditions: []string{"own_gallery"},
+		},
+		{
+			Name:       "editors-write-own-gallery",
+			Actions:    []string{ActionArtworkCreate, ActionArtworkUpdate, ActionArtworkDelete},
+			Roles:      []string{RoleGallery, RoleGalleryAdmin},
+			Conditions: []string{"own_gallery"},
+		},
+		{
+			Name:       "admins-purge",
+			Actions:    []string{ActionArtworkPurge},
+			Roles:      []string{RoleGalleryAdmin},
+			Conditions: []string{"own_gallery"},
+		},
+	}
+}
+
+// Engine evaluates rules. It has no HTTP dependencies so it can be unit-tested directly.
+type Engine struct {
+	rules      []Rule
+	conditions map[string]Condition
+}
+
+// NewEngine validates the rules and returns an engine for them.
+func NewEngine(rules []Rule) (*Engine, error) {
+	for _, rule := range rules {
+		if len(rule.Actions) == 0 {
+			return nil, fmt.Errorf("policy %q has no actions", rule.Name)
+		}
+		for _, name := range rule.Conditions {
+			if _, ok := builtinConditions[name]; !ok {
+				return nil, fmt.Errorf("policy %q uses unknown condition %q", rule.Name, name)
+			}
+		}
+	}
+	return &Engine{rules: rules, conditions: builtinConditions}, nil
+}
+
+// LoadEngine reads rules from a JSON file, or uses DefaultRules when path is empty.
+func LoadEngine(path string) (*Engine, error) {
+	if path == "" {
+		return NewEngine(DefaultRules())
+	}
+
+	data, err := os.ReadFile(path) // #nosec G304 -- path comes from operator configuration
+	if err != nil {
+		return nil, fmt.Errorf("failed to read policy file: %w", err)
+	}
+
+	var rules []Rule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("failed to parse policy file: %w", err)
+	}
+	return NewEngine(rules)
+}
+
+// Allowed reports whether any rule grants the action. p may be nil for anonymous callers.
+func (e *Engine) Allowed(p *Principal, action string, r Resource) bool {
+	for _, rule := range e.rules {
+		if e.matches(rule, p, action, r) {
+			return true
+		}
+	}
+	return false
+}
+
+// Authorize returns ErrForbidden when the action is not allowed.
+func (e *Engine) Authorize(p *Principal, action string, r Resource) error {
+	if !e.Allowed(p, action, r) {
+		return fmt.Errorf("%w: %s on %s", ErrForbidden, action, r.Type)
+	}
+	return nil
+}
+
+func (e *Engine) matches(rule Rule, p *Principal, action string, r Resource) bool {
+	if !contains(rule.Actions, action) {
+		return false
+	}
+	if len(rule.Roles) > 0 {
+		if p == nil {
+			return false
+		}
+		granted := false
+		for _, role := range rule.Roles {
+			if p.HasRole(role) {
+				granted = true
+				break
+			}
+		}
+		if !granted {
+			return false
+		}
+	}
+	for _, name := range rule.Conditions {
+		if !e.conditions[name](p, r) {
+			return false
+		}
+	}
+	return true
+}
+
+func contains(list []string, v string) bool {
+	for _, item := range list {
+		if item == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,86 @@
+package auth
+
+import (
+	"github.com/gin-gonic/gin"
+)
+
+// ContextKey is the gin context key under which the authenticated principal is stored.
+const ContextKey = "principal"
+
+// Principal kinds
+const (
+	KindUser    = "user"
+	KindAPIKey  = "api_key"
+	KindService = "service"
+)
+
+// Client roles understood by the application
+const (
+	RoleRegular       = "Regular"
+	RoleGalleryViewer = "GalleryViewer"
+	RoleGallery       = "Gallery"
+	RoleGalleryAdmin  = "GalleryAdmin"
+	// RolePlatformAdmin operates the deployment across tenants, such as
+	// lifting lockouts. Of multiple issuers, only platform issuers grant it.
+	RolePlatformAdmin = "PlatformAdmin"
+)
+
+// Principal is the verified identity behind a request.
+type Principal struct {
+	Kind      string   `json:"kind"`
+	Subject   string   `json:"sub"`
+	Name      string   `json:"name,omitempty"`
+	Email     string   `json:"email,omitempty"`
+	Roles     []string `json:"roles"`
+	Galleries []string `json:"galleries"`
+	// Client is the OAuth2 client the token was issued to (azp).
+	Client string `json:"client,omitempty"`
+	// Issuer and Tenant identify the trusted issuer that authenticated a user.
+	Issuer string `json:"iss,omitempty"`
+	Tenant string `json:"tenant,omitempty"`
+	// Session is the identity provider's login session of a user token
+	// (sid or session_state); client-credentials tokens have none.
+	Session string `json:"-"`
+}
+
+// HasRole reports whether the principal holds the given role.
+// An empty role is always satisfied.
+func (p *Principal) HasRole(role string) bool {
+	if role == "" {
+		return true
+	}
+	for _, r := range p.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// ManagesGallery reports whether the principal is bound to the given gallery.
+func (p *Principal) ManagesGallery(gallery string) bool {
+	if gallery == "" {
+		return false
+	}
+	for _, g := range p.Galleries {
+		if g == gallery {
+			return true
+		}
+	}
+	return false
+}
+
+// SetPrincipal stores the principal in the gin context.
+func SetPrincipal(c *gin.Context, p *Principal) {
+	c.Set(ContextKey, p)
+}
+
+// PrincipalFrom returns the principal stored in the gin context, if any.
+func PrincipalFrom(c *gin.Context) (*Principal, bool) {
+	v, exists := c.Get(ContextKey)
+	if !exists {
+		return nil, false
+	}
+	p, ok := v.(*Principal)
+	return p, ok && p != nil
+}
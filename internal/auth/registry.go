@@ -0,0 +1,250 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// ErrUnknownIssuer is returned for tokens whose iss is not a trusted issuer.
+var ErrUnknownIssuer = errors.New("unknown token issuer")
+
+// AllGalleries in an issuer's Galleries lets it grant any gallery. Artworks
+// carry no tenant, so only the operator's own realm should have it.
+const AllGalleries = "*"
+
+// Issuer describes a trusted identity provider, typically one Keycloak realm
+// per partner museum.
+type Issuer struct {
+	// Issuer must equal the iss claim of the tokens it signs.
+	Issuer string `json:"issuer"`
+	// JWKSURL defaults to the Keycloak certs endpoint below Issuer.
+	JWKSURL string `json:"jwks_url"`
+	// Audience, when set, must be contained in the aud claim.
+	Audience string `json:"audience"`
+	// ClientID selects resource_access.<ClientID>.roles as the role claim
+	// unless Claims overrides the mapping.
+	ClientID string `json:"client_id"`
+	// Claims maps this issuer's role and gallery claims.
+	Claims *ClaimMapping `json:"claims"`
+	// Tenant is recorded on principals of this issuer.
+	Tenant string `json:"tenant"`
+	// Galleries lists the galleries this issuer may grant, or AllGalleries.
+	// It is required, so a partner realm cannot grant another's galleries.
+	Galleries []string `json:"galleries"`
+	// Platform lets this issuer grant RolePlatformAdmin, which tokens of
+	// other issuers lose. Only the operator's own realm should have it.
+	Platform bool `json:"platform"`
+	// Introspection verifies this issuer's tokens remotely instead of via JWKS.
+	Introspection *IntrospectionConfig `json:"introspection"`
+	// Opaque routes tokens that are not JWTs to this issuer. At most one
+	// issuer may set it, and it requires Introspection.
+	Opaque bool `json:"opaque"`
+}
+
+// IntrospectionConfig configures an RFC 7662 endpoint for an issuer.
+type IntrospectionConfig struct {
+	URL          string `json:"url"`
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+	// CacheTTL is a Go duration such as "1m".
+	CacheTTL string `json:"cache_ttl"`
+}
+
+type registeredIssuer struct {
+	Issuer
+	verifier Verifier
+	mapper   *ClaimMapper
+}
+
+// Registry authenticates tokens against a set of trusted issuers, selected by
+// the token's iss claim. Tokens of unknown issuers are rejected.
+type Registry struct {
+	issuers map[string]*registeredIssuer
+	opaque  *registeredIssuer
+}
+
+// Close releases the background work of the issuers' verifiers.
+func (r *Registry) Close() error {
+	return closeVerifiers(r.verifiers())
+}
+
+// KeySets reports the key sets of the issuers verified by JWKS.
+func (r *Registry) KeySets(ctx context.Context) []KeySetStatus {
+	return keySets(ctx, r.verifiers())
+}
+
+func (r *Registry) verifiers() []Verifier {
+	verifiers := make([]Verifier, 0, len(r.issuers)+1)
+	if r.opaque != nil {
+		verifiers = append(verifiers, r.opaque.verifier)
+	}
+	for _, iss := range r.issuers {
+		verifiers = append(verifiers, iss.verifier)
+	}
+	return verifiers
+}
+
+// NewRegistry validates the issuers and builds a verifier for each.
+func NewRegistry(issuers []Issuer) (*Registry, error) {
+	r := &Registry{issuers: make(map[string]*registeredIssuer, len(issuers))}
+	for _, iss := range issuers {
+		if iss.Issuer == "" {
+			return nil, errors.New("trusted issuer without issuer URL")
+		}
+		if _, exists := r.issuers[iss.Issuer]; exists {
+			return nil, fmt.Errorf("issuer %q configured twice", iss.Issuer)
+		}
+		if iss.ClientID == "" && iss.Claims == nil {
+			return nil, fmt.Errorf("issuer %q has neither client_id nor claims mapping", iss.Issuer)
+		}
+		if len(iss.Galleries) == 0 {
+			return nil, fmt.Errorf("issuer %q must list the galleries it may grant, or %q for all", iss.Issuer, AllGalleries)
+		}
+
+		mapping := DefaultClaimMapping(iss.ClientID)
+		if iss.Claims != nil {
+			mapping = *iss.Claims
+		}
+		mapper, err := NewClaimMapper(mapping)
+		if err != nil {
+			return nil, fmt.Errorf("issuer %q has invalid claims mapping: %w", iss.Issuer, err)
+		}
+
+		entry := &registeredIssuer{Issuer: iss, mapper: mapper}
+		if iss.Introspection != nil {
+			var cacheTTL time.Duration
+			if iss.Introspection.CacheTTL != "" {
+				parsed, err := time.ParseDuration(iss.Introspection.CacheTTL)
+				if err != nil {
+					return nil, fmt.Errorf("issuer %q has invalid introspection cache_ttl: %w", iss.Issuer, err)
+				}
+				cacheTTL = parsed
+			}
+			entry.verifier = NewIntrospectionVerifier(iss.Introspection.URL, iss.Introspection.ClientID, iss.Introspection.ClientSecret, cacheTTL)
+		} else {
+			jwksURL := iss.JWKSURL
+			if jwksURL == "" {
+				jwksURL = iss.Issuer + "/protocol/openid-connect/certs"
+			}
+			entry.verifier = &JWTVerifier{JWKSURL: jwksURL, Issuer: iss.Issuer, Audience: iss.Audience}
+		}
+
+		if iss.Opaque {
+			if iss.Introspection == nil {
+				return nil, fmt.Errorf("issuer %q accepts opaque tokens but has no introspection endpoint", iss.Issuer)
+			}
+			if r.opaque != nil {
+				return nil, fmt.Errorf("issuers %q and %q both accept opaque tokens", r.opaque.Issuer.Issuer, iss.Issuer)
+			}
+			r.opaque = entry
+		}
+		r.issuers[iss.Issuer] = entry
+	}
+	return r, nil
+}
+
+// LoadRegistry reads a JSON list of issuers from path.
+func LoadRegistry(path string) (*Registry, error) {
+	data, err := os.ReadFile(path) // #nosec G304 -- path comes from operator configuration
+	if err != nil {
+		return nil, fmt.Errorf("failed to read issuers file: %w", err)
+	}
+
+	var issuers []Issuer
+	if err := json.Unmarshal(data, &issuers); err != nil {
+		return nil, fmt.Errorf("failed to parse issuers file: %w", err)
+	}
+	return NewRegistry(issuers)
+}
+
+// Authenticate verifies the token with its issuer and maps it to a principal.
+func (r *Registry) Authenticate(ctx context.Context, token string) (*Principal, error) {
+	entry, err := r.issuerFor(token)
+	if err != nil {
+		return nil, err
+	}
+
+	claims, err := entry.verifier.Verify(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	// Introspection responses may omit iss; when present it must still match
+	if iss, ok := claims["iss"].(string); ok && iss != entry.Issuer.Issuer {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownIssuer, iss)
+	}
+
+	p := entry.mapper.Principal(claims)
+	p.Issuer = entry.Issuer.Issuer
+	p.Tenant = entry.Tenant
+	r.Restrict(p)
+	return p, nil
+}
+
+// Trusts reports whether iss is a trusted issuer.
+func (r *Registry) Trusts(iss string) bool {
+	_, ok := r.issuers[iss]
+	return ok
+}
+
+// Restrict drops the galleries and platform role of p that its issuer may
+// not grant.
+func (r *Registry) Restrict(p *Principal) {
+	entry, ok := r.issuers[p.Issuer]
+	if !ok {
+		p.Galleries = []string{}
+		p.Roles = without(p.Roles, RolePlatformAdmin)
+		return
+	}
+	if !contains(entry.Galleries, AllGalleries) {
+		p.Galleries = intersect(p.Galleries, entry.Galleries)
+	}
+	if !entry.Platform {
+		p.Roles = without(p.Roles, RolePlatformAdmin)
+	}
+}
+
+func (r *Registry) issuerFor(token string) (*registeredIssuer, error) {
+	if !looksLikeJWT(token) {
+		if r.opaque == nil {
+			return nil, fmt.Errorf("%w: opaque tokens are not accepted", ErrTokenInvalid)
+		}
+		return r.opaque, nil
+	}
+
+	claims := jwt.MapClaims{}
+	if _, _, err := jwt.NewParser().ParseUnverified(token, claims); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrTokenInvalid, err)
+	}
+	iss, _ := claims["iss"].(string)
+	entry, ok := r.issuers[iss]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownIssuer, iss)
+	}
+	return entry, nil
+}
+
+func intersect(values, allowed []string) []string {
+	out := []string{}
+	for _, v := range values {
+		if contains(allowed, v) {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+func without(values []string, value string) []string {
+	out := []string{}
+	for _, v := range values {
+		if v != value {
+			out = append(out, v)
+		}
+	}
+	return out
+}
@@ -0,0 +1,149 @@
+package auth
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// ErrNotServiceAccount is returned for tokens issued to a service's client
+// that do not belong to its service account, such as user logins.
+var ErrNotServiceAccount = errors.New("token of a service client is not a service-account token")
+
+// Service describes a machine client, such as the image service, that calls
+// the API without a user. It is recognised either by the iss and azp of its
+// client-credentials tokens or by its TLS client certificate.
+type Service struct {
+	Name string `json:"name"`
+	// Issuer is the trusted issuer of the service's tokens. It is required
+	// with several issuers and must be empty with a single one.
+	Issuer string `json:"issuer"`
+	// ClientID is the OAuth2 client whose service-account tokens carry it as azp.
+	ClientID string `json:"client_id"`
+	// Subject, when set, must be the sub of the client's tokens, such as
+	// Keycloak's service-account-<client>. Otherwise tokens of a user login,
+	// which carry sid or session_state, are refused.
+	Subject string `json:"subject"`
+	// CertNames match a verified client certificate's common name, DNS or URI SAN.
+	CertNames []string `json:"cert_names"`
+	Roles     []string `json:"roles"`
+	Galleries []string `json:"galleries"`
+}
+
+// ServiceRegistry maps machine credentials to service principals.
+type ServiceRegistry struct {
+	byClient map[serviceClient]*Service
+	byCert   map[string]*Service
+}
+
+// serviceClient identifies an OAuth2 client; client IDs are only unique
+// within their issuer.
+type serviceClient struct {
+	issuer, clientID string
+}
+
+// NewServiceRegistry validates the services and indexes their credentials.
+func NewServiceRegistry(services []Service) (*ServiceRegistry, error) {
+	r := &ServiceRegistry{byClient: map[serviceClient]*Service{}, byCert: map[string]*Service{}}
+	for i := range services {
+		s := &services[i]
+		if s.Name == "" {
+			return nil, errors.New("service without name")
+		}
+		if s.ClientID == "" && len(s.CertNames) == 0 {
+			return nil, fmt.Errorf("service %q has neither client_id nor cert_names", s.Name)
+		}
+		if s.ClientID != "" {
+			key := serviceClient{s.Issuer, s.ClientID}
+			if _, exists := r.byClient[key]; exists {
+				return nil, fmt.Errorf("client %q of issuer %q is mapped to more than one service", s.ClientID, s.Issuer)
+			}
+			r.byClient[key] = s
+		}
+		for _, name := range s.CertNames {
+			if _, exists := r.byCert[name]; exists {
+				return nil, fmt.Errorf("certificate name %q is mapped to more than one service", name)
+			}
+			r.byCert[name] = s
+		}
+	}
+	return r, nil
+}
+
+// LoadServiceRegistry reads a JSON list of services from path.
+func LoadServiceRegistry(path string) (*ServiceRegistry, error) {
+	data, err := os.ReadFile(path) // #nosec G304 -- path comes from operator configuration
+	if err != nil {
+		return nil, fmt.Errorf("failed to read services file: %w", err)
+	}
+
+	var services []Service
+	if err := json.Unmarshal(data, &services); err != nil {
+		return nil, fmt.Errorf("failed to parse services file: %w", err)
+	}
+	return NewServiceRegistry(services)
+}
+
+// CheckIssuers reports services whose issuer would never match a token:
+// with several trusted issuers every client needs one of them, and with a
+// single issuer, whose principals carry none, clients must not set one.
+func (r *ServiceRegistry) CheckIssuers(issuers *Registry) error {
+	for key, s := range r.byClient {
+		switch {
+		case issuers == nil && key.issuer != "":
+			return fmt.Errorf("service %q sets an issuer but only one issuer is trusted", s.Name)
+		case issuers != nil && !issuers.Trusts(key.issuer):
+			return fmt.Errorf("service %q needs the issuer of its client, one of the trusted issuers", s.Name)
+		}
+	}
+	return nil
+}
+
+// ForToken returns the principal of the service owning the OAuth2 client a
+// token principal was issued to, by its issuer and client. ok is false for
+// tokens of other clients. Tokens of a service's client that are not its
+// service account's fail with ErrNotServiceAccount.
+func (r *ServiceRegistry) ForToken(token *Principal) (service *Principal, ok bool, err error) {
+	s, ok := r.byClient[serviceClient{token.Issuer, token.Client}]
+	if !ok || token.Client == "" {
+		return nil, false, nil
+	}
+	if s.Subject != "" && token.Subject != s.Subject || s.Subject == "" && token.Session != "" {
+		return nil, true, fmt.Errorf("%w: client %q", ErrNotServiceAccount, token.Client)
+	}
+	service = s.principal()
+	service.Issuer = token.Issuer
+	service.Tenant = token.Tenant
+	return service, true, nil
+}
+
+// ForCertificate returns the principal of the service a verified client
+// certificate belongs to.
+func (r *ServiceRegistry) ForCertificate(cert *x509.Certificate) (*Principal, bool) {
+	names := []string{cert.Subject.CommonName}
+	names = append(names, cert.DNSNames...)
+	for _, uri := range cert.URIs {
+		names = append(names, uri.String())
+	}
+	for _, name := range names {
+		if s, ok := r.byCert[name]; ok && name != "" {
+			return s.principal(), true
+		}
+	}
+	return nil, false
+}
+
+func (s *Service) principal() *Principal {
+	roles := append([]string{}, s.Roles...)
+	galleries := append([]string{}, s.Galleries...)
+	return &Principal{
+		Kind:      KindService,
+		Subject:   "service:" + s.Name,
+		Name:      s.Name,
+		Client:    s.ClientID,
+		Roles:     roles,
+		Galleries: galleries,
+	}
+}
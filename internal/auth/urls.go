@@ -0,0 +1,26 @@
+package auth
+
+import (
+	"net/url"
+	"strings"
+)
+
+// BaseURL turns a configured domain into a URL prefix. Domains without a
+// scheme are assumed to be served over HTTPS; an explicit scheme (as used for
+// local development over plain HTTP) is kept.
+func BaseURL(domain string) string {
+	domain = strings.TrimRight(domain, "/")
+	if strings.HasPrefix(domain, "http://") || strings.HasPrefix(domain, "https://") {
+		return domain
+	}
+	return "https://" + domain
+}
+
+// CookieDomain returns the host part of a configured domain for use in Set-Cookie.
+func CookieDomain(domain string) string {
+	u, err := url.Parse(BaseURL(domain))
+	if err != nil {
+		return ""
+	}
+	return u.Hostname()
+}
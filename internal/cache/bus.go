@@ -0,0 +1,123 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Bus carries cache invalidations between replicas. A key published on one
+// replica reaches the subscribers of every replica, its own included.
+type Bus interface {
+	Publish(ctx context.Context, key string) error
+	// Subscribe calls invalidate for every published key until ctx ends. It
+	// calls reset whenever invalidations may have been missed, such as after
+	// a lost connection, so the subscriber drops everything it holds.
+	Subscribe(ctx context.Context, invalidate func(key string), reset func())
+}
+
+// LocalBus delivers invalidations within one process. It suits single
+// instances and tests.
+type LocalBus struct {
+	mu          sync.Mutex
+	subscribers map[int]func(string)
+	next        int
+}
+
+func NewLocalBus() *LocalBus {
+	return &LocalBus{subscribers: map[int]func(string){}}
+}
+
+func (b *LocalBus) Publish(ctx context.Context, key string) error {
+	b.mu.Lock()
+	subscribers := make([]func(string), 0, len(b.subscribers))
+	for _, invalidate := range b.subscribers {
+		subscribers = append(subscribers, invalidate)
+	}
+	b.mu.Unlock()
+
+	for _, invalidate := range subscribers {
+		invalidate(key)
+	}
+	return nil
+}
+
+// Subscribe never calls reset, as nothing is lost in process.
+func (b *LocalBus) Subscribe(ctx context.Context, invalidate func(key string), reset func()) {
+	b.mu.Lock()
+	id := b.next
+	b.next++
+	b.subscribers[id] = invalidate
+	b.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		b.mu.Lock()
+		delete(b.subscribers, id)
+		b.mu.Unlock()
+	}()
+}
+
+// RedisBus delivers invalidations through a Redis pub/sub channel, so every
+// replica connected to the same server drops the entries the others change.
+type RedisBus struct {
+	Client  *redis.Client
+	Channel string
+	// RetryDelay is the pause after a failed receive before reconnecting
+	RetryDelay time.Duration
+}
+
+// NewRedisBus connects using a redis:// or rediss:// URL.
+func NewRedisBus(redisURL, channel string) (*RedisBus, error) {
+	opts, err := redis.ParseURL(redisURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cache Redis URL: %w", err)
+	}
+	return &RedisBus{Client: redis.NewClient(opts), Channel: channel, RetryDelay: time.Second}, nil
+}
+
+// Close releases the connection pool.
+func (b *RedisBus) Close() error {
+	return b.Client.Close()
+}
+
+func (b *RedisBus) Publish(ctx context.Context, key string) error {
+	return b.Client.Publish(ctx, b.Channel, key).Err()
+}
+
+func (b *RedisBus) Subscribe(ctx context.Context, invalidate func(key string), reset func()) {
+	pubsub := b.Client.Subscribe(ctx, b.Channel)
+	go func() {
+		defer pubsub.Close()
+		for {
+			msg, err := pubsub.Receive(ctx)
+			if err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				// The next Receive reconnects and subscribes again
+				log.Printf("Cache invalidation channel %s failed: %v", b.Channel, err)
+				reset()
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(b.RetryDelay):
+				}
+				continue
+			}
+			switch m := msg.(type) {
+			case *redis.Subscription:
+				// Invalidations published while unsubscribed were missed
+				if m.Kind == "subscribe" {
+					reset()
+				}
+			case *redis.Message:
+				invalidate(m.Payload)
+			}
+		}
+	}()
+}
@@ -0,0 +1,14 @@
+package cache
+
+// Stats counts the lookups and changes of a cache since it was created.
+type Stats struct {
+	// Hits found a stored value
+	Hits uint64
+	// NegativeHits found a stored miss, such as an ID known not to exist
+	NegativeHits uint64
+	// Misses went to the source
+	Misses        uint64
+	Evictions     uint64
+	Invalidations uint64
+	Entries       int
+}
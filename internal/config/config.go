@@ -0,0 +1,387 @@
+// Package config loads the typed application configuration from defaults,
+// a dotenv file, the environment and command-line flags, in increasing order
+// of precedence, and validates it once at startup.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/paumarro/apollo-be/internal/auth"
+)
+
+// Config is the complete application configuration. Each leaf field names
+// its environment variable in the env tag; the matching flag is the variable
+// in lower case with dashes, e.g. -keycloak-client-id.
+type Config struct {
+	// Environment is "production" on Railway, which skips the .env file and
+	// forbids development features.
+	Environment string `env:"RAILWAY_ENVIRONMENT" usage:"deployment environment"`
+	// AppEnv must be "development" for development features on a server
+	// reachable from other hosts.
+	AppEnv string `env:"APP_ENV" usage:"development allows development features on non-loopback addresses"`
+
+	Server   Server
+	Database Database
+	Keycloak Keycloak
+	Auth     Auth
+	DevIdP   DevIdP
+	Session  Session
+	Lockout  Lockout
+	Audit    Audit
+	Health   Health
+	Cache    Cache
+
+	sources map[string]string
+}
+
+type Server struct {
+	Host               string        `env:"HOST" usage:"listen address; all interfaces when empty"`
+	Port               string        `env:"PORT" default:"8080" usage:"HTTP listen port"`
+	ReadTimeout        time.Duration `env:"HTTP_READ_TIMEOUT" default:"15s" usage:"maximum time to read a request including its body"`
+	ReadHeaderTimeout  time.Duration `env:"HTTP_READ_HEADER_TIMEOUT" default:"10s" usage:"maximum time to read request headers"`
+	WriteTimeout       time.Duration `env:"HTTP_WRITE_TIMEOUT" default:"30s" usage:"maximum time to write a response"`
+	IdleTimeout        time.Duration `env:"HTTP_IDLE_TIMEOUT" default:"60s" usage:"keep-alive idle connection timeout"`
+	MaxHeaderBytes     int           `env:"HTTP_MAX_HEADER_BYTES" default:"1048576" usage:"maximum size of request headers"`
+	RequestTimeout     time.Duration `env:"REQUEST_TIMEOUT" default:"10s" usage:"deadline for handling a request, including its database queries"`
+	ShutdownTimeout    time.Duration `env:"SHUTDOWN_TIMEOUT" default:"20s" usage:"time allowed to drain requests on shutdown"`
+	DrainDelay         time.Duration `env:"SHUTDOWN_DRAIN_DELAY" default:"0s" usage:"time /readyz fails before the server stops accepting connections"`
+	Domain             string        `env:"APOLLO_DOMAIN" usage:"public domain of this backend"`
+	CSRFTrustedOrigins []string      `env:"CSRF_TRUSTED_ORIGINS" usage:"extra origins allowed to send cookie-authenticated writes"`
+	TrustedProxies     []string      `env:"TRUSTED_PROXIES" usage:"IPs or CIDRs of proxies whose X-Forwarded-For gives the client IP; none when empty"`
+	TrustedPlatform    string        `env:"TRUSTED_PLATFORM" usage:"header the hosting platform sets to the client IP, such as CF-Connecting-IP"`
+	TLSCertFile        string        `env:"TLS_CERT_FILE" usage:"serve HTTPS with this certificate"`
+	TLSKeyFile         string        `env:"TLS_KEY_FILE" usage:"private key for TLS_CERT_FILE"`
+	MTLSClientCAFile   string        `env:"MTLS_CLIENT_CA_FILE" usage:"CA bundle for TLS client certificates"`
+	TLSMinVersion      string        `env:"TLS_MIN_VERSION" default:"1.2" usage:"minimum TLS version, 1.2 or 1.3"`
+	TLSCipherSuites    []string      `env:"TLS_CIPHER_SUITES" usage:"TLS 1.2 cipher suites by IANA name; Go defaults when empty"`
+	TLSReloadInterval  time.Duration `env:"TLS_RELOAD_INTERVAL" default:"1m" usage:"how often certificate files are checked for changes"`
+	RedirectPort       string        `env:"HTTP_REDIRECT_PORT" usage:"plain HTTP port redirecting to HTTPS when serving TLS"`
+	MetricsToken       string        `env:"METRICS_TOKEN" secret:"true" usage:"bearer token Prometheus sends to scrape /metrics; /metrics is not served when empty"`
+}
+
+type Database struct {
+	// PG_DB_URL is the name older .env.example files used.
+	URL string `env:"ART_DB_URL,PG_DB_URL" secret:"true" usage:"Postgres connection URL, or sqlite:<path> and sqlite::memory: for local use"`
+
+	MaxOpenConns     int           `env:"DB_MAX_OPEN_CONNS" default:"25" usage:"maximum open connections"`
+	MaxIdleConns     int           `env:"DB_MAX_IDLE_CONNS" default:"10" usage:"maximum idle connections kept in the pool"`
+	ConnMaxLifetime  time.Duration `env:"DB_CONN_MAX_LIFETIME" default:"30m" usage:"time after which a connection is replaced"`
+	ConnMaxIdleTime  time.Duration `env:"DB_CONN_MAX_IDLE_TIME" default:"5m" usage:"time after which an idle connection is closed"`
+	StatementTimeout time.Duration `env:"DB_STATEMENT_TIMEOUT" default:"30s" usage:"server-side limit for a single statement"`
+	ConnectTimeout   time.Duration `env:"DB_CONNECT_TIMEOUT" default:"1m" usage:"time to keep retrying the first connection at startup"`
+	RetryBackoff     time.Duration `env:"DB_RETRY_BACKOFF" default:"500ms" usage:"first delay between connection attempts, doubled up to DB_RETRY_BACKOFF_MAX"`
+	RetryBackoffMax  time.Duration `env:"DB_RETRY_BACKOFF_MAX" default:"10s" usage:"longest delay between connection attempts"`
+	MigrateOnStart   bool          `env:"DB_MIGRATE_ON_START" default:"true" usage:"apply pending migrations when the server starts"`
+	DefaultGallery   string        `env:"DB_DEFAULT_GALLERY" usage:"gallery that migrating assigns to artworks created before galleries existed"`
+
+	ReplicaURLs          []string      `env:"ART_DB_REPLICA_URLS" secret:"true" usage:"read replica URLs; artwork lists and lookups are read from them"`
+	ReadYourWritesWindow time.Duration `env:"DB_READ_YOUR_WRITES_WINDOW" default:"5s" usage:"how long a session reads from the primary after writing"`
+	ReadYourWritesKey    string        `env:"DB_READ_YOUR_WRITES_KEY" secret:"true" usage:"key signing the last-write time clients carry; the same on every instance"`
+	ReplicaMaxLag        time.Duration `env:"DB_REPLICA_MAX_LAG" default:"5s" usage:"replication lag from which a replica is skipped"`
+	ReplicaCheckInterval time.Duration `env:"DB_REPLICA_CHECK_INTERVAL" default:"5s" usage:"how often replicas are checked for health and lag"`
+}
+
+type Keycloak struct {
+	Domain       string `env:"KEYCLOAK_DOMAIN" usage:"Keycloak base URL or host"`
+	Realm        string `env:"KEYCLOAK_REALM" default:"apollo" usage:"Keycloak realm"`
+	ClientID     string `env:"KEYCLOAK_CLIENT_ID" default:"apollo-client" usage:"OAuth2 client ID"`
+	ClientSecret string `env:"KEYCLOAK_CLIENT_SECRET" secret:"true" usage:"OAuth2 client secret"`
+	JWKSURL      string `env:"JWKS_URL" usage:"JWKS endpoint; defaults to the realm certs endpoint"`
+}
+
+type Auth struct {
+	PolicyFile       string `env:"AUTH_POLICY_FILE" usage:"authorization policy JSON file"`
+	IssuersFile      string `env:"AUTH_ISSUERS_FILE" usage:"trusted issuers JSON file"`
+	ClaimMappingFile string `env:"AUTH_CLAIM_MAPPING_FILE" usage:"claim mapping JSON file"`
+	ServicesFile     string `env:"AUTH_SERVICES_FILE" usage:"service principals JSON file"`
+	Introspection    Introspection
+}
+
+type Introspection struct {
+	URL          string        `env:"INTROSPECTION_URL" usage:"RFC 7662 introspection endpoint for opaque tokens"`
+	ClientID     string        `env:"INTROSPECTION_CLIENT_ID" usage:"introspection client ID"`
+	ClientSecret string        `env:"INTROSPECTION_CLIENT_SECRET" secret:"true" usage:"introspection client secret"`
+	Issuers      []string      `env:"INTROSPECTION_ISSUERS" usage:"JWT issuers verified by introspection"`
+	CacheTTL     time.Duration `env:"INTROSPECTION_CACHE_TTL" default:"1m" usage:"upper bound for cached introspection results"`
+}
+
+type DevIdP struct {
+	Enabled bool   `env:"DEV_IDP_ENABLED" default:"false" usage:"serve the development identity provider"`
+	KeyFile string `env:"DEV_IDP_KEY_FILE" default:".devidp/key.pem" usage:"development identity provider signing key"`
+}
+
+type Session struct {
+	Mode     string        `env:"SESSION_MODE" default:"cookie" usage:"cookie or bff"`
+	Store    string        `env:"SESSION_STORE" default:"memory" usage:"memory, postgres or redis"`
+	TTL      time.Duration `env:"SESSION_TTL" default:"24h" usage:"BFF session lifetime"`
+	RedisURL string        `env:"SESSION_REDIS_URL" secret:"true" usage:"Redis URL for SESSION_STORE=redis"`
+}
+
+type Lockout struct {
+	Enabled   bool          `env:"LOCKOUT_ENABLED" default:"true" usage:"lock out repeated authentication failures"`
+	Threshold int           `env:"LOCKOUT_THRESHOLD" default:"10" usage:"failures within the window before a lockout"`
+	Window    time.Duration `env:"LOCKOUT_WINDOW" default:"5m" usage:"failure counting window"`
+	Base      time.Duration `env:"LOCKOUT_BASE" default:"1m" usage:"first lockout duration"`
+	Max       time.Duration `env:"LOCKOUT_MAX" default:"1h" usage:"longest lockout duration"`
+	Instances int           `env:"LOCKOUT_INSTANCES" default:"1" usage:"server instances sharing the traffic; each counts its own failures against its share of the threshold"`
+}
+
+type Audit struct {
+	QueueSize       int           `env:"AUDIT_QUEUE_SIZE" default:"4096" usage:"audit events waiting to be stored; further events only reach the log"`
+	AnonymousPerIP  int           `env:"AUDIT_ANONYMOUS_PER_IP" default:"20" usage:"login redirects and token rejections without an actor stored per client IP each window"`
+	AnonymousWindow time.Duration `env:"AUDIT_ANONYMOUS_WINDOW" default:"1m" usage:"window for AUDIT_ANONYMOUS_PER_IP"`
+}
+
+type Health struct {
+	Timeout    time.Duration `env:"HEALTH_CHECK_TIMEOUT" default:"2s" usage:"time allowed for each readiness check"`
+	JWKSMaxAge time.Duration `env:"HEALTH_JWKS_MAX_AGE" default:"1h" usage:"age after which a cached JWKS reports degraded"`
+}
+
+type Cache struct {
+	Size         int           `env:"ARTWORK_CACHE_SIZE" default:"10000" usage:"artworks cached by ID; 0 disables the cache"`
+	TTL          time.Duration `env:"ARTWORK_CACHE_TTL" default:"5m" usage:"how long a cached artwork is served"`
+	NegativeTTL  time.Duration `env:"ARTWORK_CACHE_NEGATIVE_TTL" default:"30s" usage:"how long an unknown artwork ID is remembered"`
+	Invalidation string        `env:"CACHE_INVALIDATION" default:"local" usage:"local, or redis to invalidate the caches of every replica"`
+	RedisURL     string        `env:"CACHE_REDIS_URL" secret:"true" usage:"Redis URL for CACHE_INVALIDATION=redis"`
+}
+
+// Production reports whether the configuration is for the production environment.
+func (c *Config) Production() bool {
+	return c.Environment == "production"
+}
+
+// Development reports whether development features were explicitly allowed.
+func (c *Config) Development() bool {
+	return c.AppEnv == "development" && !c.Production()
+}
+
+// Loopback reports whether the server only listens on a loopback address.
+func (c *Config) Loopback() bool {
+	if c.Server.Host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(c.Server.Host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// Source reports where the value of an environment variable name came from:
+// default, file, env or flag.
+func (c *Config) Source(name string) string {
+	if s, ok := c.sources[name]; ok {
+		return s
+	}
+	return "default"
+}
+
+// PublicURL is the base URL browsers use to reach this backend.
+func (c *Config) PublicURL() string {
+	return auth.BaseURL(c.Server.Domain)
+}
+
+// CookieDomain is the Domain attribute for cookies set by this backend.
+func (c *Config) CookieDomain() string {
+	return auth.CookieDomain(c.Server.Domain)
+}
+
+// RealmURL is the issuer URL of the Keycloak realm.
+func (c *Config) RealmURL() string {
+	return auth.BaseURL(c.Keycloak.Domain) + "/realms/" + c.Keycloak.Realm
+}
+
+// JWKSURL returns JWKS_URL or, when unset, the realm's certs endpoint.
+func (c *Config) JWKSURL() string {
+	if c.Keycloak.JWKSURL != "" {
+		return c.Keycloak.JWKSURL
+	}
+	return c.RealmURL() + "/protocol/openid-connect/certs"
+}
+
+// Validate checks the configuration and returns every problem found.
+func (c *Config) Validate() error {
+	var errs []error
+	require := func(name, value string) {
+		if value == "" {
+			errs = append(errs, fmt.Errorf("%s is required", name))
+		}
+	}
+
+	require("ART_DB_URL", c.Database.URL)
+	require("APOLLO_DOMAIN", c.Server.Domain)
+	require("KEYCLOAK_DOMAIN", c.Keycloak.Domain)
+	require("KEYCLOAK_CLIENT_ID", c.Keycloak.ClientID)
+	require("KEYCLOAK_REALM", c.Keycloak.Realm)
+
+	for name, raw := range map[string]string{
+		"JWKS_URL":          c.Keycloak.JWKSURL,
+		"INTROSPECTION_URL": c.Auth.Introspection.URL,
+	} {
+		if raw == "" {
+			continue
+		}
+		if u, err := url.Parse(raw); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("%s must be an http or https URL", name))
+		}
+	}
+	for _, origin := range c.Server.CSRFTrustedOrigins {
+		if u, err := url.Parse(origin); err != nil || u.Scheme == "" || u.Host == "" {
+			errs = append(errs, fmt.Errorf("CSRF_TRUSTED_ORIGINS entry %q must be scheme://host", origin))
+		}
+	}
+
+	for _, proxy := range c.Server.TrustedProxies {
+		if _, _, err := net.ParseCIDR(proxy); err != nil && net.ParseIP(proxy) == nil {
+			errs = append(errs, fmt.Errorf("TRUSTED_PROXIES entry %q must be an IP address or CIDR", proxy))
+		}
+	}
+
+	if _, err := strconv.Atoi(c.Server.Port); err != nil {
+		errs = append(errs, fmt.Errorf("PORT must be a number, got %q", c.Server.Port))
+	}
+	for name, d := range map[string]time.Duration{
+		"HTTP_READ_TIMEOUT":        c.Server.ReadTimeout,
+		"HTTP_READ_HEADER_TIMEOUT": c.Server.ReadHeaderTimeout,
+		"HTTP_WRITE_TIMEOUT":       c.Server.WriteTimeout,
+		"HTTP_IDLE_TIMEOUT":        c.Server.IdleTimeout,
+		"REQUEST_TIMEOUT":          c.Server.RequestTimeout,
+		"SHUTDOWN_TIMEOUT":         c.Server.ShutdownTimeout,
+	} {
+		if d <= 0 {
+			errs = append(errs, fmt.Errorf("%s must be positive", name))
+		}
+	}
+	if c.Server.MaxHeaderBytes < 1024 {
+		errs = append(errs, errors.New("HTTP_MAX_HEADER_BYTES must be at least 1024"))
+	}
+
+	if (c.Server.TLSCertFile == "") != (c.Server.TLSKeyFile == "") {
+		errs = append(errs, errors.New("TLS_CERT_FILE and TLS_KEY_FILE must be set together"))
+	}
+	if c.Server.MTLSClientCAFile != "" && c.Server.TLSCertFile == "" {
+		errs = append(errs, errors.New("MTLS_CLIENT_CA_FILE requires TLS_CERT_FILE"))
+	}
+	if c.Server.RedirectPort != "" {
+		if c.Server.TLSCertFile == "" {
+			errs = append(errs, errors.New("HTTP_REDIRECT_PORT requires TLS_CERT_FILE"))
+		}
+		if _, err := strconv.Atoi(c.Server.RedirectPort); err != nil || c.Server.RedirectPort == c.Server.Port {
+			errs = append(errs, fmt.Errorf("HTTP_REDIRECT_PORT must be a number other than PORT, got %q", c.Server.RedirectPort))
+		}
+	}
+	if _, err := ParseTLSVersion(c.Server.TLSMinVersion); err != nil {
+		errs = append(errs, err)
+	}
+	if _, err := ParseCipherSuites(c.Server.TLSCipherSuites); err != nil {
+		errs = append(errs, err)
+	}
+	if c.Server.TLSCertFile != "" && c.Server.TLSReloadInterval <= 0 {
+		errs = append(errs, errors.New("TLS_RELOAD_INTERVAL must be positive"))
+	}
+
+	switch c.Session.Mode {
+	case "cookie", "bff":
+	default:
+		errs = append(errs, fmt.Errorf("SESSION_MODE must be cookie or bff, got %q", c.Session.Mode))
+	}
+	switch c.Session.Store {
+	case "memory", "postgres":
+	case "redis":
+		require("SESSION_REDIS_URL", c.Session.RedisURL)
+	default:
+		errs = append(errs, fmt.Errorf("SESSION_STORE must be memory, postgres or redis, got %q", c.Session.Store))
+	}
+	if c.Session.TTL <= 0 {
+		errs = append(errs, errors.New("SESSION_TTL must be positive"))
+	}
+
+	// The dev IdP signs in anyone with any roles, so it is only served where
+	// nobody else can reach it, unless development is declared explicitly
+	if c.DevIdP.Enabled && c.Production() {
+		errs = append(errs, errors.New("DEV_IDP_ENABLED must not be set in production"))
+	} else if c.DevIdP.Enabled && !c.Loopback() && !c.Development() {
+		errs = append(errs, errors.New("DEV_IDP_ENABLED requires HOST to be a loopback address or APP_ENV=development"))
+	}
+
+	if c.Lockout.Enabled {
+		if c.Lockout.Threshold < 1 {
+			errs = append(errs, errors.New("LOCKOUT_THRESHOLD must be at least 1"))
+		}
+		if c.Lockout.Instances < 1 {
+			errs = append(errs, errors.New("LOCKOUT_INSTANCES must be at least 1"))
+		}
+		if c.Lockout.Window <= 0 || c.Lockout.Base <= 0 || c.Lockout.Max < c.Lockout.Base {
+			errs = append(errs, errors.New("LOCKOUT_WINDOW and LOCKOUT_BASE must be positive and LOCKOUT_MAX at least LOCKOUT_BASE"))
+		}
+	}
+	if c.Server.MetricsToken != "" && len(c.Server.MetricsToken) < 32 {
+		errs = append(errs, errors.New("METRICS_TOKEN must be at least 32 bytes"))
+	}
+	if c.Audit.QueueSize < 1 || c.Audit.AnonymousPerIP < 0 || c.Audit.AnonymousWindow <= 0 {
+		errs = append(errs, errors.New("AUDIT_QUEUE_SIZE must be at least 1, AUDIT_ANONYMOUS_PER_IP at least 0 and AUDIT_ANONYMOUS_WINDOW positive"))
+	}
+	if c.Auth.Introspection.CacheTTL <= 0 {
+		errs = append(errs, errors.New("INTROSPECTION_CACHE_TTL must be positive"))
+	}
+	if c.Database.MaxOpenConns < 1 || c.Database.MaxIdleConns < 0 || c.Database.MaxIdleConns > c.Database.MaxOpenConns {
+		errs = append(errs, errors.New("DB_MAX_OPEN_CONNS must be at least 1 and DB_MAX_IDLE_CONNS between 0 and DB_MAX_OPEN_CONNS"))
+	}
+	for name, d := range map[string]time.Duration{
+		"DB_CONN_MAX_LIFETIME":  c.Database.ConnMaxLifetime,
+		"DB_CONN_MAX_IDLE_TIME": c.Database.ConnMaxIdleTime,
+		"DB_STATEMENT_TIMEOUT":  c.Database.StatementTimeout,
+		"DB_CONNECT_TIMEOUT":    c.Database.ConnectTimeout,
+	} {
+		if d <= 0 {
+			errs = append(errs, fmt.Errorf("%s must be positive", name))
+		}
+	}
+	if len(c.Database.ReplicaURLs) > 0 {
+		for name, d := range map[string]time.Duration{
+			"DB_READ_YOUR_WRITES_WINDOW": c.Database.ReadYourWritesWindow,
+			"DB_REPLICA_MAX_LAG":         c.Database.ReplicaMaxLag,
+			"DB_REPLICA_CHECK_INTERVAL":  c.Database.ReplicaCheckInterval,
+		} {
+			if d <= 0 {
+				errs = append(errs, fmt.Errorf("%s must be positive", name))
+			}
+		}
+		if len(c.Database.ReadYourWritesKey) < 32 {
+			errs = append(errs, errors.New("DB_READ_YOUR_WRITES_KEY must be at least 32 bytes when ART_DB_REPLICA_URLS is set"))
+		}
+		// Sessions must not read from a replica that may not have their write yet
+		if c.Database.ReadYourWritesWindow < c.Database.ReplicaMaxLag {
+			errs = append(errs, errors.New("DB_READ_YOUR_WRITES_WINDOW must be at least DB_REPLICA_MAX_LAG"))
+		}
+	}
+	if c.Database.RetryBackoff <= 0 || c.Database.RetryBackoffMax < c.Database.RetryBackoff {
+		errs = append(errs, errors.New("DB_RETRY_BACKOFF must be positive and DB_RETRY_BACKOFF_MAX at least DB_RETRY_BACKOFF"))
+	}
+	if c.Server.RequestTimeout > c.Server.WriteTimeout {
+		errs = append(errs, errors.New("REQUEST_TIMEOUT must not exceed HTTP_WRITE_TIMEOUT, or timed out requests cannot be answered"))
+	}
+	if c.Server.DrainDelay < 0 || c.Server.DrainDelay >= c.Server.ShutdownTimeout {
+		errs = append(errs, errors.New("SHUTDOWN_DRAIN_DELAY must not be negative and must be shorter than SHUTDOWN_TIMEOUT"))
+	}
+	if c.Health.Timeout <= 0 || c.Health.JWKSMaxAge <= 0 {
+		errs = append(errs, errors.New("HEALTH_CHECK_TIMEOUT and HEALTH_JWKS_MAX_AGE must be positive"))
+	}
+	if c.Cache.Size < 0 {
+		errs = append(errs, errors.New("ARTWORK_CACHE_SIZE must not be negative"))
+	}
+	if c.Cache.Size > 0 && (c.Cache.TTL <= 0 || c.Cache.NegativeTTL < 0) {
+		errs = append(errs, errors.New("ARTWORK_CACHE_TTL must be positive and ARTWORK_CACHE_NEGATIVE_TTL must not be negative"))
+	}
+	switch c.Cache.Invalidation {
+	case "local":
+	case "redis":
+		require("CACHE_REDIS_URL", c.Cache.RedisURL)
+	default:
+		errs = append(errs, fmt.Errorf("CACHE_INVALIDATION must be local or redis, got %q", c.Cache.Invalidation))
+	}
+
+	return errors.Join(errs...)
+}
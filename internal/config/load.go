@@ -0,0 +1,213 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+)
+
+// DefaultEnvFile is read when present unless running in production.
+const DefaultEnvFile = ".env"
+
+// setting is one leaf field of Config together with its tags.
+type setting struct {
+	names  []string // environment variable and aliases
+	def    string
+	usage  string
+	secret bool
+	value  reflect.Value
+}
+
+func (s setting) name() string { return s.names[0] }
+
+// flagName turns ART_DB_URL into art-db-url.
+func (s setting) flagName() string {
+	return strings.ReplaceAll(strings.ToLower(s.name()), "_", "-")
+}
+
+// Load builds the configuration from, in increasing order of precedence,
+// the field defaults, the dotenv file, the environment and the flags in args.
+// lookupEnv is normally os.LookupEnv. The result is not validated.
+func Load(args []string, lookupEnv func(string) (string, bool)) (*Config, error) {
+	cfg := &Config{sources: map[string]string{}}
+	settings := collect(reflect.ValueOf(cfg).Elem())
+
+	fs := flag.NewFlagSet("apollo", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	envFile := fs.String("env-file", DefaultEnvFile, "dotenv file with configuration values")
+	flagValues := map[string]*string{}
+	for _, s := range settings {
+		usage := s.usage
+		if s.def != "" {
+			usage += " (default " + s.def + ")"
+		}
+		flagValues[s.name()] = fs.String(s.flagName(), "", usage+" [$"+s.name()+"]")
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if fs.NArg() > 0 {
+		return nil, fmt.Errorf("unexpected arguments: %s", strings.Join(fs.Args(), " "))
+	}
+	setFlags := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { setFlags[f.Name] = true })
+
+	fileValues, err := readEnvFile(*envFile, setFlags["env-file"], settings, flagValues, lookupEnv)
+	if err != nil {
+		return nil, err
+	}
+
+	var errs []error
+	for _, s := range settings {
+		raw, source := s.def, "default"
+		for _, name := range s.names {
+			if v, ok := fileValues[name]; ok {
+				raw, source = v, "file"
+				break
+			}
+		}
+		for _, name := range s.names {
+			if v, ok := lookupEnv(name); ok {
+				raw, source = v, "env"
+				break
+			}
+		}
+		if setFlags[s.flagName()] {
+			raw, source = *flagValues[s.name()], "flag"
+		}
+
+		if err := assign(s.value, raw); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", s.name(), err))
+			continue
+		}
+		if source != "default" {
+			cfg.sources[s.name()] = source
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// readEnvFile reads the dotenv file. The default file is optional and skipped
+// in production and in containers, whose environment is set by the platform;
+// an explicitly requested file must exist.
+func readEnvFile(path string, explicit bool, settings []setting, flags map[string]*string, lookupEnv func(string) (string, bool)) (map[string]string, error) {
+	if !explicit {
+		env, _ := lookupEnv("RAILWAY_ENVIRONMENT")
+		if v := *flags["RAILWAY_ENVIRONMENT"]; v != "" {
+			env = v
+		}
+		if docker, _ := lookupEnv("RUNNING_IN_DOCKER"); env == "production" || docker == "true" {
+			return nil, nil
+		}
+		if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+	}
+	values, err := godotenv.Read(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return values, nil
+}
+
+// collect walks the struct and returns its tagged leaf fields in declaration order.
+func collect(v reflect.Value) []setting {
+	var out []setting
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		if tag, ok := f.Tag.Lookup("env"); ok {
+			out = append(out, setting{
+				names:  strings.Split(tag, ","),
+				def:    f.Tag.Get("default"),
+				usage:  f.Tag.Get("usage"),
+				secret: f.Tag.Get("secret") == "true",
+				value:  v.Field(i),
+			})
+			continue
+		}
+		if f.Type.Kind() == reflect.Struct {
+			out = append(out, collect(v.Field(i))...)
+		}
+	}
+	return out
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+func assign(v reflect.Value, raw string) error {
+	switch {
+	case v.Type() == durationType:
+		if raw == "" {
+			v.SetInt(0)
+			return nil
+		}
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q", raw)
+		}
+		v.SetInt(int64(d))
+	case v.Kind() == reflect.String:
+		v.SetString(raw)
+	case v.Kind() == reflect.Bool:
+		if raw == "" {
+			v.SetBool(false)
+			return nil
+		}
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", raw)
+		}
+		v.SetBool(b)
+	case v.Kind() == reflect.Int:
+		if raw == "" {
+			v.SetInt(0)
+			return nil
+		}
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", raw)
+		}
+		v.SetInt(int64(n))
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.String:
+		v.Set(reflect.ValueOf(splitList(raw)))
+	default:
+		return fmt.Errorf("unsupported field type %s", v.Type())
+	}
+	return nil
+}
+
+func format(v reflect.Value) string {
+	switch {
+	case v.Type() == durationType:
+		return time.Duration(v.Int()).String()
+	case v.Kind() == reflect.Slice:
+		return strings.Join(v.Interface().([]string), ",")
+	default:
+		return fmt.Sprint(v.Interface())
+	}
+}
+
+func splitList(s string) []string {
+	var out []string
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
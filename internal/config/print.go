@@ -0,0 +1,37 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+)
+
+// redacted replaces secret values in printed output.
+const redacted = "[REDACTED]"
+
+// Redacted returns every setting as NAME=value with secrets masked, in
+// declaration order.
+func (c *Config) Redacted() []string {
+	settings := collect(reflect.ValueOf(c).Elem())
+	out := make([]string, 0, len(settings))
+	for _, s := range settings {
+		value := format(s.value)
+		if s.secret && value != "" {
+			value = redacted
+		}
+		out = append(out, s.name()+"="+value)
+	}
+	return out
+}
+
+// Print writes the redacted configuration in dotenv format, noting where
+// each value came from.
+func (c *Config) Print(w io.Writer) error {
+	settings := collect(reflect.ValueOf(c).Elem())
+	for i, line := range c.Redacted() {
+		if _, err := fmt.Fprintf(w, "%s # %s\n", line, c.Source(settings[i].name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
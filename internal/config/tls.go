@@ -0,0 +1,40 @@
+package config
+
+import (
+	"crypto/tls"
+	"fmt"
+)
+
+// ParseTLSVersion maps TLS_MIN_VERSION ("1.2" or "1.3") to its constant.
+func ParseTLSVersion(v string) (uint16, error) {
+	switch v {
+	case "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	default:
+		return 0, fmt.Errorf("TLS_MIN_VERSION must be 1.2 or 1.3, got %q", v)
+	}
+}
+
+// ParseCipherSuites maps IANA cipher suite names to their IDs. Insecure
+// suites are rejected. An empty list selects Go's defaults. TLS 1.3 suites
+// are not configurable and always enabled.
+func ParseCipherSuites(names []string) ([]uint16, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+	known := map[string]uint16{}
+	for _, s := range tls.CipherSuites() {
+		known[s.Name] = s.ID
+	}
+	ids := make([]uint16, 0, len(names))
+	for _, name := range names {
+		id, ok := known[name]
+		if !ok {
+			return nil, fmt.Errorf("TLS_CIPHER_SUITES: unknown or insecure cipher suite %q", name)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
@@ -0,0 +1,96 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/paumarro/apollo-be/internal/auth"
+	"github.com/paumarro/apollo-be/internal/dto"
+	"github.com/paumarro/apollo-be/internal/services"
+)
+
+// APIKeyController handles API key management for gallery admins
+type APIKeyController struct {
+	APIKeyService *services.APIKeyService
+}
+
+// NewAPIKeyController creates a new instance of APIKeyController
+func NewAPIKeyController(service *services.APIKeyService) *APIKeyController {
+	return &APIKeyController{APIKeyService: service}
+}
+
+// Create mints a new API key and returns its plaintext exactly once
+func (kc *APIKeyController) Create(c *gin.Context) {
+	principal, ok := auth.PrincipalFrom(c)
+	if !ok {
+		respondWithError(c, http.StatusUnauthorized, "Not authenticated", nil)
+		return
+	}
+
+	var req dto.APIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondWithError(c, http.StatusBadRequest, "Invalid API key request", err.Error())
+		return
+	}
+
+	ttl := time.Duration(req.ExpiresInDays) * 24 * time.Hour
+	plaintext, key, err := kc.APIKeyService.MintAPIKey(c.Request.Context(), principal, req.Name, req.Gallery, req.Roles, ttl)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrForbidden):
+			respondWithError(c, http.StatusForbidden, "Insufficient permissions for requested key", err.Error())
+		case errors.Is(err, services.ErrBadRequest):
+			respondWithError(c, http.StatusBadRequest, "Invalid API key request", err.Error())
+		default:
+			respondWithError(c, http.StatusInternalServerError, "Failed to create API key", err.Error())
+		}
+		return
+	}
+
+	recordAdminAction(c, "api_key.create", key.Gallery, map[string]string{"key_id": strconv.FormatUint(uint64(key.ID), 10), "prefix": key.Prefix})
+	c.JSON(http.StatusCreated, gin.H{"api_key": key, "key": plaintext})
+}
+
+// Index lists the API keys of the caller's galleries
+func (kc *APIKeyController) Index(c *gin.Context) {
+	principal, ok := auth.PrincipalFrom(c)
+	if !ok {
+		respondWithError(c, http.StatusUnauthorized, "Not authenticated", nil)
+		return
+	}
+
+	keys, err := kc.APIKeyService.ListAPIKeys(c.Request.Context(), principal)
+	if err != nil {
+		respondWithError(c, http.StatusInternalServerError, "Failed to fetch API keys", err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"api_keys": keys})
+}
+
+// Delete revokes an API key
+func (kc *APIKeyController) Delete(c *gin.Context) {
+	principal, ok := auth.PrincipalFrom(c)
+	if !ok {
+		respondWithError(c, http.StatusUnauthorized, "Not authenticated", nil)
+		return
+	}
+
+	if _, ok := parseIDParam(c); !ok {
+		return
+	}
+
+	key, err := kc.APIKeyService.RevokeAPIKey(c.Request.Context(), principal, c.Param("id"))
+	if err != nil {
+		if errors.Is(err, services.ErrNotFound) {
+			respondWithError(c, http.StatusNotFound, "API key not found", nil)
+		} else {
+			respondWithError(c, http.StatusInternalServerError, "Failed to revoke API key", err)
+		}
+		return
+	}
+	recordAdminAction(c, "api_key.revoke", key.Gallery, map[string]string{"key_id": c.Param("id"), "prefix": key.Prefix})
+	c.JSON(http.StatusOK, gin.H{"message": "API key successfully revoked"})
+}
@@ -1,13 +1,15 @@
 package controllers
 
 import (
+	"context"
 	"errors"
 	"log"
 	"net/http"
 	"strconv"
-	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/paumarro/apollo-be/internal/audit"
+	"github.com/paumarro/apollo-be/internal/auth"
 	"github.com/paumarro/apollo-be/internal/dto"
 	"github.com/paumarro/apollo-be/internal/models"
 	"github.com/paumarro/apollo-be/internal/services"
@@ -30,6 +32,9 @@ func parseIDParam(c *gin.Context) (uint64, bool) {
 // ArtworkController handles artwork-related operations
 type ArtworkController struct {
 	ArtworkService *services.ArtworkService
+	// Policies authorizes actions against loaded artworks. When nil, no
+	// authorization is performed.
+	Policies *auth.Engine
 }
 
 // NewArtworkController creates a new instance of ArtworkController
@@ -39,10 +44,58 @@ func NewArtworkController(service *services.ArtworkService) *ArtworkController {
 
 // respondWithError is a helper function to send error responses
 func respondWithError(c *gin.Context, code int, message string, details interface{}) {
+	// Failures caused by the request deadline are reported as timeouts
+	if code == http.StatusInternalServerError && c.Request != nil && errors.Is(c.Request.Context().Err(), context.DeadlineExceeded) {
+		code, message = http.StatusGatewayTimeout, "Request timed out"
+	}
 	log.Printf("Error: %s, Details: %v", message, details)
 	c.JSON(code, gin.H{"error": message})
 }
 
+// respondWithDuplicate writes a 409 naming the conflicting artwork when it is known
+func respondWithDuplicate(c *gin.Context, err error) {
+	log.Printf("Error: Artwork already exists, Details: %v", err)
+	var duplicate *services.DuplicateError
+	if errors.As(err, &duplicate) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Artwork already exists", "conflicting_id": duplicate.ID})
+		return
+	}
+	c.JSON(http.StatusConflict, gin.H{"error": "Artwork already exists"})
+}
+
+// authorize evaluates the policy for an action on an artwork and writes a 403 when it is denied
+func (ac *ArtworkController) authorize(c *gin.Context, action string, artwork *models.Artwork) bool {
+	if ac.Policies == nil {
+		return true
+	}
+	principal, _ := auth.PrincipalFrom(c)
+	if err := ac.Policies.Authorize(principal, action, artworkResource(artwork)); err != nil {
+		audit.RecordRequest(c, models.AuditEvent{
+			Type:    audit.TypeAccessDenied,
+			Outcome: audit.OutcomeDenied,
+			Gallery: artwork.Gallery,
+			Reason:  "policy",
+			Details: map[string]string{"action": action, "artwork_id": strconv.FormatUint(uint64(artwork.ID), 10)},
+		})
+		respondWithError(c, http.StatusForbidden, "Insufficient permissions", err.Error())
+		return false
+	}
+	return true
+}
+
+// canRead reports whether the caller may see an artwork, without writing a response
+func (ac *ArtworkController) canRead(c *gin.Context, artwork *models.Artwork) bool {
+	if ac.Policies == nil {
+		return true
+	}
+	principal, _ := auth.PrincipalFrom(c)
+	return ac.Policies.Allowed(principal, auth.ActionArtworkRead, artworkResource(artwork))
+}
+
+func artworkResource(artwork *models.Artwork) auth.Resource {
+	return auth.Resource{Type: "artwork", Gallery: artwork.Gallery, Status: artwork.Status}
+}
+
 // ArtworkCreate handles the creation of a new artwork
 func (ac *ArtworkController) Create(c *gin.Context) {
 	sanitizedArtwork, exists := c.Get("sanitizedArtwork")
@@ -61,12 +114,29 @@ func (ac *ArtworkController) Create(c *gin.Context) {
 		Artist:      req.Artist,
 		Description: req.Description,
 		Image:       req.Image,
+		Gallery:     req.Gallery,
+		Status:      req.Status,
+	}
+	if artwork.Status == "" {
+		artwork.Status = models.StatusPublished
+	}
+	if principal, ok := auth.PrincipalFrom(c); ok {
+		artwork.CreatedBy = principal.Subject
+		artwork.UpdatedBy = principal.Subject
+		// Callers bound to a single gallery do not need to name it
+		if artwork.Gallery == "" && len(principal.Galleries) == 1 {
+			artwork.Gallery = principal.Galleries[0]
+		}
 	}
 
-	if err := ac.ArtworkService.CreateArtwork(&artwork); err != nil {
-		if strings.Contains(err.Error(), "already exists") {
+	if !ac.authorize(c, auth.ActionArtworkCreate, &artwork) {
+		return
+	}
+
+	if err := ac.ArtworkService.CreateArtwork(c.Request.Context(), &artwork); err != nil {
+		if errors.Is(err, services.ErrDuplicate) {
 			// Map duplicate error to 409 Conflict
-			respondWithError(c, http.StatusConflict, "Artwork already exists", err.Error())
+			respondWithDuplicate(c, err)
 		} else {
 			// Handle other errors as 500 Internal Server Error
 			respondWithError(c, http.StatusInternalServerError, "Failed to create artwork", err.Error())
@@ -74,16 +144,42 @@ func (ac *ArtworkController) Create(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusCreated, gin.H{"artwork": artwork})
+	response := gin.H{"artwork": artwork}
+	// Likely duplicates are reported, not rejected; the check is best effort
+	similar, err := ac.ArtworkService.FindSimilarArtworks(c.Request.Context(), &artwork)
+	if err != nil {
+		log.Printf("Error checking for similar artworks: %v", err)
+	}
+	visible := make([]services.SimilarArtwork, 0, len(similar))
+	for i := range similar {
+		if ac.canRead(c, &similar[i].Artwork) {
+			visible = append(visible, similar[i])
+		}
+	}
+	if len(visible) > 0 {
+		response["similar"] = visible
+	}
+
+	c.JSON(http.StatusCreated, response)
 }
 
 func (ac *ArtworkController) Index(c *gin.Context) {
-	artworks, err := ac.ArtworkService.GetAllArtworks()
+	artworks, err := ac.ArtworkService.GetAllArtworks(c.Request.Context())
 	if err != nil {
 		respondWithError(c, http.StatusInternalServerError, "Failed to fetch artworks", err)
 		return
 	}
 
+	if ac.Policies != nil {
+		visible := make([]models.Artwork, 0, len(artworks))
+		for i := range artworks {
+			if ac.canRead(c, &artworks[i]) {
+				visible = append(visible, artworks[i])
+			}
+		}
+		artworks = visible
+	}
+
 	c.JSON(http.StatusOK, gin.H{"artworks": artworks})
 }
 
@@ -95,15 +191,24 @@ func (ac *ArtworkController) Find(c *gin.Context) {
 	}
 
 	id := c.Param("id") // still pass the string if your service expects string today
-	artwork, err := ac.ArtworkService.GetArtworkByID(id)
+	artwork, err := ac.ArtworkService.GetArtworkByID(c.Request.Context(), id)
 	if err != nil {
 		if errors.Is(err, services.ErrNotFound) {
+			// IDs retired by a merge lead to the artwork that replaced them
+			if ac.redirectMerged(c, id) {
+				return
+			}
 			respondWithError(c, http.StatusNotFound, "Artwork not found", nil)
 		} else {
 			respondWithError(c, http.StatusInternalServerError, "Failed to find artwork", err)
 		}
 		return
 	}
+	// Hidden artworks are reported as missing so drafts are not disclosed
+	if !ac.canRead(c, artwork) {
+		respondWithError(c, http.StatusNotFound, "Artwork not found", nil)
+		return
+	}
 	c.JSON(http.StatusOK, gin.H{"artwork": artwork})
 }
 
@@ -116,7 +221,7 @@ func (ac *ArtworkController) Update(c *gin.Context) {
 	}
 
 	id := c.Param("id")
-	artwork, err := ac.ArtworkService.GetArtworkByID(id)
+	artwork, err := ac.ArtworkService.GetArtworkByID(c.Request.Context(), id)
 	if err != nil {
 		if errors.Is(err, services.ErrNotFound) {
 			respondWithError(c, http.StatusNotFound, "Artwork not found", nil)
@@ -126,6 +231,10 @@ func (ac *ArtworkController) Update(c *gin.Context) {
 		return
 	}
 
+	if !ac.authorize(c, auth.ActionArtworkUpdate, artwork) {
+		return
+	}
+
 	sanitizedArtwork, exists := c.Get("sanitizedArtwork")
 	if !exists {
 		respondWithError(c, http.StatusInternalServerError, "Failed to retrieve sanitized input", nil)
@@ -141,9 +250,26 @@ func (ac *ArtworkController) Update(c *gin.Context) {
 	artwork.Artist = req.Artist
 	artwork.Description = req.Description
 	artwork.Image = req.Image
+	if req.Status != "" {
+		artwork.Status = req.Status
+	}
+	if req.Gallery != "" && req.Gallery != artwork.Gallery {
+		// Moving an artwork requires write access to the destination gallery as well
+		artwork.Gallery = req.Gallery
+		if !ac.authorize(c, auth.ActionArtworkUpdate, artwork) {
+			return
+		}
+	}
+	if principal, ok := auth.PrincipalFrom(c); ok {
+		artwork.UpdatedBy = principal.Subject
+	}
 
-	if err := ac.ArtworkService.UpdateArtwork(artwork); err != nil {
-		respondWithError(c, http.StatusInternalServerError, "Failed to update artwork", err)
+	if err := ac.ArtworkService.UpdateArtwork(c.Request.Context(), artwork); err != nil {
+		if errors.Is(err, services.ErrDuplicate) {
+			respondWithDuplicate(c, err)
+		} else {
+			respondWithError(c, http.StatusInternalServerError, "Failed to update artwork", err)
+		}
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"artwork": artwork})
@@ -157,7 +283,22 @@ func (ac *ArtworkController) Delete(c *gin.Context) {
 	}
 
 	id := c.Param("id")
-	if err := ac.ArtworkService.DeleteArtwork(id); err != nil {
+	if ac.Policies != nil {
+		artwork, err := ac.ArtworkService.GetArtworkByID(c.Request.Context(), id)
+		if err != nil {
+			if errors.Is(err, services.ErrNotFound) {
+				respondWithError(c, http.StatusNotFound, "Artwork not found", nil)
+			} else {
+				respondWithError(c, http.StatusInternalServerError, "Failed to find artwork", err)
+			}
+			return
+		}
+		if !ac.authorize(c, auth.ActionArtworkDelete, artwork) {
+			return
+		}
+	}
+
+	if err := ac.ArtworkService.DeleteArtwork(c.Request.Context(), id); err != nil {
 		if errors.Is(err, services.ErrNotFound) {
 			respondWithError(c, http.StatusNotFound, "Artwork not found", nil)
 		} else {
@@ -167,3 +308,36 @@ func (ac *ArtworkController) Delete(c *gin.Context) {
 	}
 	c.JSON(http.StatusOK, gin.H{"message": "Artwork successfully deleted"})
 }
+
+// Purge permanently removes an artwork, including one that was already deleted
+func (ac *ArtworkController) Purge(c *gin.Context) {
+	_, ok := parseIDParam(c)
+	if !ok {
+		return
+	}
+
+	id := c.Param("id")
+	artwork, err := ac.ArtworkService.GetArtworkByIDUnscoped(c.Request.Context(), id)
+	if err != nil {
+		if errors.Is(err, services.ErrNotFound) {
+			respondWithError(c, http.StatusNotFound, "Artwork not found", nil)
+		} else {
+			respondWithError(c, http.StatusInternalServerError, "Failed to find artwork", err)
+		}
+		return
+	}
+	if !ac.authorize(c, auth.ActionArtworkPurge, artwork) {
+		return
+	}
+
+	if err := ac.ArtworkService.PurgeArtwork(c.Request.Context(), id); err != nil {
+		if errors.Is(err, services.ErrNotFound) {
+			respondWithError(c, http.StatusNotFound, "Artwork not found", nil)
+		} else {
+			respondWithError(c, http.StatusInternalServerError, "Failed to purge artwork", err)
+		}
+		return
+	}
+	recordAdminAction(c, "artwork.purge", artwork.Gallery, map[string]string{"artwork_id": id, "title": artwork.Title})
+	c.JSON(http.StatusOK, gin.H{"message": "Artwork permanently deleted"})
+}
@@ -0,0 +1,160 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/paumarro/apollo-be/internal/audit"
+	"github.com/paumarro/apollo-be/internal/auth"
+	"github.com/paumarro/apollo-be/internal/dto"
+	"github.com/paumarro/apollo-be/internal/models"
+	"github.com/paumarro/apollo-be/internal/services"
+)
+
+// Duplicates lists clusters of artworks that likely describe the same work
+// in the galleries of the calling curator or gallery admin. The optional
+// min_score query parameter overrides the similarity threshold.
+func (ac *ArtworkController) Duplicates(c *gin.Context) {
+	principal, ok := auth.PrincipalFrom(c)
+	if !ok || !(principal.HasRole(auth.RoleGallery) || principal.HasRole(auth.RoleGalleryAdmin)) {
+		audit.RecordRequest(c, models.AuditEvent{
+			Type:    audit.TypeAccessDenied,
+			Outcome: audit.OutcomeDenied,
+			Reason:  "missing_role",
+			Details: map[string]string{"required_role": auth.RoleGallery + "|" + auth.RoleGalleryAdmin},
+		})
+		respondWithError(c, http.StatusForbidden, "Insufficient permissions", nil)
+		return
+	}
+
+	var minScore float64
+	if raw := c.Query("min_score"); raw != "" {
+		score, err := strconv.ParseFloat(raw, 64)
+		if err != nil || score < services.MinDuplicateScore || score > 1 {
+			respondWithError(c, http.StatusBadRequest, fmt.Sprintf("min_score must be a number from %g to 1", services.MinDuplicateScore), raw)
+			return
+		}
+		minScore = score
+	}
+
+	clusters, err := ac.ArtworkService.FindDuplicateClusters(c.Request.Context(), principal.Galleries, minScore)
+	if err != nil {
+		respondWithError(c, http.StatusInternalServerError, "Failed to find duplicate artworks", err)
+		return
+	}
+
+	// Callers only see the artworks they may read
+	visible := make([]services.DuplicateCluster, 0, len(clusters))
+	for _, cluster := range clusters {
+		artworks := make([]models.Artwork, 0, len(cluster.Artworks))
+		for i := range cluster.Artworks {
+			if ac.canRead(c, &cluster.Artworks[i]) {
+				artworks = append(artworks, cluster.Artworks[i])
+			}
+		}
+		if len(artworks) == len(cluster.Artworks) {
+			visible = append(visible, cluster)
+		} else if len(artworks) > 1 {
+			// The score may come from a hidden pair; recompute it for what is shown
+			cluster.Artworks = artworks
+			cluster.Score = 0
+			for i := range artworks {
+				for j := i + 1; j < len(artworks); j++ {
+					if score := services.ArtworkSimilarity(&artworks[i], &artworks[j]); score > cluster.Score {
+						cluster.Score = score
+					}
+				}
+			}
+			visible = append(visible, cluster)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"clusters": visible})
+}
+
+// Merge merges the artwork retire_id into keep_id. It requires permission to
+// update the kept artwork and to delete the retired one.
+func (ac *ArtworkController) Merge(c *gin.Context) {
+	var req dto.MergeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondWithError(c, http.StatusBadRequest, "Invalid merge request", err.Error())
+		return
+	}
+
+	keep, ok := ac.loadArtwork(c, req.KeepID)
+	if !ok {
+		return
+	}
+	retired, ok := ac.loadArtwork(c, req.RetireID)
+	if !ok {
+		return
+	}
+	if !ac.authorize(c, auth.ActionArtworkUpdate, keep) || !ac.authorize(c, auth.ActionArtworkDelete, retired) {
+		return
+	}
+
+	var mergedBy string
+	if principal, ok := auth.PrincipalFrom(c); ok {
+		mergedBy = principal.Subject
+	}
+	merge, err := ac.ArtworkService.MergeArtworks(c.Request.Context(), keep, retired, mergedBy)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrBadRequest):
+			respondWithError(c, http.StatusBadRequest, "Invalid merge request", err.Error())
+		case errors.Is(err, services.ErrNotFound):
+			respondWithError(c, http.StatusNotFound, "Artwork not found", nil)
+		case errors.Is(err, services.ErrDuplicate):
+			respondWithDuplicate(c, err)
+		default:
+			respondWithError(c, http.StatusInternalServerError, "Failed to merge artworks", err)
+		}
+		return
+	}
+
+	recordAdminAction(c, "artwork.merge", keep.Gallery, map[string]string{
+		"artwork_id": strconv.FormatUint(uint64(keep.ID), 10),
+		"retired_id": strconv.FormatUint(uint64(retired.ID), 10),
+	})
+	c.JSON(http.StatusOK, gin.H{"artwork": keep, "merge": merge})
+}
+
+// loadArtwork fetches an artwork named in a request body and writes a 404 when it is missing
+func (ac *ArtworkController) loadArtwork(c *gin.Context, id uint) (*models.Artwork, bool) {
+	artwork, err := ac.ArtworkService.GetArtworkByID(c.Request.Context(), strconv.FormatUint(uint64(id), 10))
+	if err != nil {
+		if errors.Is(err, services.ErrNotFound) {
+			respondWithError(c, http.StatusNotFound, "Artwork not found", id)
+		} else {
+			respondWithError(c, http.StatusInternalServerError, "Failed to find artwork", err)
+		}
+		return nil, false
+	}
+	return artwork, true
+}
+
+// redirectMerged redirects a request for an artwork retired by a merge to
+// the artwork it was merged into, if the caller could read the retired one.
+func (ac *ArtworkController) redirectMerged(c *gin.Context, id string) bool {
+	merge, err := ac.ArtworkService.FindMerge(c.Request.Context(), id)
+	if err != nil {
+		if !errors.Is(err, services.ErrNotFound) {
+			log.Printf("Error looking up merge of artwork %s: %v", id, err)
+		}
+		return false
+	}
+	if !ac.canRead(c, &merge.Retired) {
+		return false
+	}
+
+	location := *c.Request.URL
+	location.Path = strings.TrimSuffix(location.Path, id) + strconv.FormatUint(uint64(merge.ArtworkID), 10)
+	location.RawPath = ""
+	c.Redirect(http.StatusMovedPermanently, location.RequestURI())
+	return true
+}
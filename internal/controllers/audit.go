@@ -0,0 +1,118 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/paumarro/apollo-be/internal/audit"
+	"github.com/paumarro/apollo-be/internal/auth"
+	"github.com/paumarro/apollo-be/internal/models"
+)
+
+const (
+	defaultAuditLimit = 100
+	maxAuditLimit     = 500
+)
+
+// AuditController exposes the audit trail to gallery admins
+type AuditController struct {
+	Store audit.Store
+}
+
+// NewAuditController creates a new instance of AuditController
+func NewAuditController(store audit.Store) *AuditController {
+	return &AuditController{Store: store}
+}
+
+// Index lists audit events of the admin's galleries and tenant, newest first.
+// Filters: type, outcome, actor, gallery, since, until (RFC 3339), before_id and limit.
+func (ac *AuditController) Index(c *gin.Context) {
+	principal, ok := auth.PrincipalFrom(c)
+	if !ok {
+		respondWithError(c, http.StatusUnauthorized, "Not authenticated", nil)
+		return
+	}
+
+	f := audit.Filter{
+		Galleries: principal.Galleries,
+		Tenant:    principal.Tenant,
+		Type:      c.Query("type"),
+		Outcome:   c.Query("outcome"),
+		Actor:     c.Query("actor"),
+		Gallery:   c.Query("gallery"),
+		Limit:     defaultAuditLimit,
+	}
+
+	var err error
+	if f.Since, err = parseTimeQuery(c, "since"); err != nil {
+		respondWithError(c, http.StatusBadRequest, "Invalid since parameter, must be RFC 3339", nil)
+		return
+	}
+	if f.Until, err = parseTimeQuery(c, "until"); err != nil {
+		respondWithError(c, http.StatusBadRequest, "Invalid until parameter, must be RFC 3339", nil)
+		return
+	}
+	if v := c.Query("before_id"); v != "" {
+		id, err := strconv.ParseUint(v, 10, 64)
+		if err != nil || id == 0 {
+			respondWithError(c, http.StatusBadRequest, "Invalid before_id parameter, must be a positive integer", nil)
+			return
+		}
+		f.BeforeID = uint(id)
+	}
+	if v := c.Query("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > maxAuditLimit {
+			respondWithError(c, http.StatusBadRequest, "Invalid limit parameter, must be between 1 and 500", nil)
+			return
+		}
+		f.Limit = limit
+	}
+
+	events, err := ac.Store.Query(c.Request.Context(), f)
+	if err != nil {
+		respondWithError(c, http.StatusInternalServerError, "Failed to fetch audit events", err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"events": events})
+}
+
+// Verify checks the hash chain of the whole audit trail
+func (ac *AuditController) Verify(c *gin.Context) {
+	count, err := audit.Verify(c.Request.Context(), ac.Store)
+	if err != nil {
+		if errors.Is(err, audit.ErrChainBroken) {
+			c.JSON(http.StatusConflict, gin.H{"valid": false, "checked": count, "error": err.Error()})
+			return
+		}
+		respondWithError(c, http.StatusInternalServerError, "Failed to verify audit trail", err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"valid": true, "checked": count})
+}
+
+func parseTimeQuery(c *gin.Context, name string) (time.Time, error) {
+	v := c.Query(name)
+	if v == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, v)
+}
+
+// recordAdminAction writes an admin action performed by the caller to the audit trail
+func recordAdminAction(c *gin.Context, action, gallery string, details map[string]string) {
+	if details == nil {
+		details = map[string]string{}
+	}
+	details["action"] = action
+	audit.RecordRequest(c, models.AuditEvent{
+		Type:    audit.TypeAdminAction,
+		Outcome: audit.OutcomeSuccess,
+		Gallery: gallery,
+		Reason:  action,
+		Details: details,
+	})
+}
@@ -1,97 +1,116 @@
 package controllers
 
 import (
-	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"net/http"
-	"net/url"
-	"os"
-	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/paumarro/apollo-be/internal/audit"
+	"github.com/paumarro/apollo-be/internal/auth"
+	"github.com/paumarro/apollo-be/internal/lockout"
+	"github.com/paumarro/apollo-be/internal/models"
+	"github.com/paumarro/apollo-be/internal/sessions"
 )
 
-func AuthCallback(c *gin.Context) {
+// AuthController completes the OIDC login started by the Auth middleware
+type AuthController struct {
+	OIDC         *auth.OIDCClient
+	CookieDomain string
+	// Sessions keeps the tokens server-side (BFF mode); when nil they are
+	// stored in cookies
+	Sessions *sessions.Manager
+}
+
+// NewAuthController creates a new instance of AuthController
+func NewAuthController(oidc *auth.OIDCClient, cookieDomain string, manager *sessions.Manager) *AuthController {
+	return &AuthController{OIDC: oidc, CookieDomain: cookieDomain, Sessions: manager}
+}
+
+// Callback exchanges the authorization code. With a session manager the
+// tokens are kept server-side and only an opaque session cookie is set.
+func (ac *AuthController) Callback(c *gin.Context) {
+	if !lockout.Check(c, "") {
+		return
+	}
+
 	code := c.Query("code")
 	if code == "" {
+		recordLoginFailure(c, "missing_code")
+		lockout.Failure(c, "")
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Authorization code not found"})
 		return
 	}
 
 	originalURL := c.Query("state")
 
-	kcClientSecret := os.Getenv("KEYCLOAK_CLIENT_SECRET")
-	kcClientID := os.Getenv("KEYCLOAK_CLIENT_ID")
-	kcDomain := os.Getenv("KEYCLOAK_DOMAIN")
-	apollobridgeDomain := os.Getenv("APOLLO_DOMAIN")
-
-	tokenURL := fmt.Sprintf("https://%s/realms/apollo/protocol/openid-connect/token", kcDomain)
-	data := url.Values{}
-	data.Set("grant_type", "authorization_code")
-	data.Set("code", code)
-	data.Set("redirect_uri", fmt.Sprintf("https://%s/auth/callback", apollobridgeDomain))
-	data.Set("client_id", kcClientID)
-	data.Set("client_secret", kcClientSecret)
-
-	req, err := http.NewRequest("POST", tokenURL, strings.NewReader(data.Encode()))
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create request"})
-		return
-	}
-	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
-
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get token", "details": err.Error()})
-		return
-	}
-	defer func() {
-		if resp != nil {
-			if err := resp.Body.Close(); err != nil {
-				fmt.Printf("Error closing response body: %v\n", err)
-			}
+	accessToken, refreshToken, err := ac.OIDC.Exchange(c.Request.Context(), code)
+	var endpointErr *auth.TokenEndpointError
+	switch {
+	case errors.As(err, &endpointErr):
+		recordLoginFailure(c, fmt.Sprintf("token_exchange_status_%d", endpointErr.StatusCode))
+		// Rejected codes point at a client replaying or guessing them; IdP outages do not count
+		if endpointErr.StatusCode >= 400 && endpointErr.StatusCode < 500 {
+			lockout.Failure(c, "")
 		}
-	}()
-
-	if resp.StatusCode != http.StatusOK {
-		bodyBytes, _ := io.ReadAll(resp.Body)
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":    "Failed to get token",
-			"status":   resp.StatusCode,
-			"response": string(bodyBytes),
+			"status":   endpointErr.StatusCode,
+			"response": endpointErr.Body,
 		})
 		return
-	}
-
-	var tokenResponse map[string]interface{}
-	if err := json.NewDecoder(resp.Body).Decode(&tokenResponse); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decode token response"})
-		return
-	}
-
-	accessToken, ok := tokenResponse["access_token"].(string)
-	if !ok {
+	case errors.Is(err, auth.ErrInvalidTokenResponse):
+		recordLoginFailure(c, "invalid_token_response")
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid token response"})
 		return
-	}
-
-	refreshToken, ok := tokenResponse["refresh_token"].(string)
-	if !ok {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid token response: missing refresh token"})
+	case err != nil:
+		recordLoginFailure(c, "token_endpoint_unreachable")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get token", "details": err.Error()})
 		return
 	}
 
-	c.SetCookie("refresh_token", refreshToken, 3600*24, "/", apollobridgeDomain, true, true)
+	// Lax keeps the cookies off cross-site subresource and form requests
+	c.SetSameSite(http.SameSiteLaxMode)
+
+	if ac.Sessions != nil {
+		sessionID, session, err := ac.Sessions.Create(c.Request.Context(), accessToken, refreshToken, c.Request.UserAgent(), c.ClientIP())
+		if err != nil {
+			recordLoginFailure(c, "session_create_failed")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create session"})
+			return
+		}
+		c.SetCookie(sessions.CookieName, sessionID, int(time.Until(session.ExpiresAt).Seconds()), "/", ac.CookieDomain, true, true)
+		fmt.Printf("Session created for subject %s\n", session.Subject)
+	} else {
+		c.SetCookie("refresh_token", refreshToken, 3600*24, "/", ac.CookieDomain, true, true)
+
+		c.SetCookie("access_token", accessToken, 3600, "/", ac.CookieDomain, true, true)
 
-	c.SetCookie("access_token", accessToken, 3600, "/", apollobridgeDomain, true, true)
+		fmt.Printf("Access and refresh tokens set in cookies\n")
+	}
 
-	fmt.Printf("Access and refresh tokens set in cookies\n")
+	recordLoginSuccess(c, accessToken)
 
 	if originalURL != "" {
 		c.Redirect(http.StatusFound, originalURL)
 	} else {
-		c.JSON(http.StatusOK, gin.H{"message": "Token stored in cookie"})
+		c.JSON(http.StatusOK, gin.H{"message": "Login successful"})
+	}
+}
+
+func recordLoginFailure(c *gin.Context, reason string) {
+	audit.RecordRequest(c, models.AuditEvent{Type: audit.TypeLoginFailure, Outcome: audit.OutcomeFailure, Reason: reason})
+}
+
+// recordLoginSuccess attributes the login to the token subject. The token
+// came straight from the identity provider, so it is read without verification.
+func recordLoginSuccess(c *gin.Context, accessToken string) {
+	e := models.AuditEvent{Type: audit.TypeLoginSuccess, Outcome: audit.OutcomeSuccess, ActorKind: auth.KindUser}
+	claims := jwt.MapClaims{}
+	if _, _, err := jwt.NewParser().ParseUnverified(accessToken, claims); err == nil {
+		e.Actor, _ = claims["sub"].(string)
 	}
+	audit.RecordRequest(c, e)
 }
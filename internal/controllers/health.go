@@ -0,0 +1,35 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/paumarro/apollo-be/internal/health"
+)
+
+// HealthController serves the liveness and readiness probes
+type HealthController struct {
+	Checker *health.Checker
+}
+
+// NewHealthController creates a new instance of HealthController
+func NewHealthController(checker *health.Checker) *HealthController {
+	return &HealthController{Checker: checker}
+}
+
+// Live reports that the process is up and serving requests. It checks no
+// dependencies, so a failing database does not get the process restarted.
+func (hc *HealthController) Live(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": health.StatusOK})
+}
+
+// Ready runs the dependency checks and responds 503 when any fails.
+// Degraded checks still report ready.
+func (hc *HealthController) Ready(c *gin.Context) {
+	report := hc.Checker.Run(c.Request.Context())
+	code := http.StatusOK
+	if report.Status == health.StatusFail {
+		code = http.StatusServiceUnavailable
+	}
+	c.JSON(code, report)
+}
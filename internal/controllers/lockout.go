@@ -0,0 +1,43 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/paumarro/apollo-be/internal/lockout"
+)
+
+// LockoutController lets platform admins inspect and lift brute-force
+// lockouts. Lockouts span tenants, so gallery admins may not. Each server
+// instance keeps its own lockouts, and these requests see only the one that
+// serves them.
+type LockoutController struct {
+	Tracker *lockout.Tracker
+}
+
+// NewLockoutController creates a new instance of LockoutController
+func NewLockoutController(tracker *lockout.Tracker) *LockoutController {
+	return &LockoutController{Tracker: tracker}
+}
+
+// Index lists the IPs and subjects that are currently locked out
+func (lc *LockoutController) Index(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"lockouts": lc.Tracker.List()})
+}
+
+// Delete lifts the lockout selected by the kind and value query parameters
+func (lc *LockoutController) Delete(c *gin.Context) {
+	kind := c.Query("kind")
+	value := c.Query("value")
+	if (kind != lockout.KindIP && kind != lockout.KindSubject) || value == "" {
+		respondWithError(c, http.StatusBadRequest, "kind must be ip or subject and value is required", nil)
+		return
+	}
+
+	if !lc.Tracker.Clear(kind, value) {
+		respondWithError(c, http.StatusNotFound, "Lockout not found", nil)
+		return
+	}
+	recordAdminAction(c, "lockout.clear", "", map[string]string{"kind": kind, "value": value})
+	c.JSON(http.StatusOK, gin.H{"message": "Lockout cleared"})
+}
@@ -0,0 +1,19 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/paumarro/apollo-be/internal/auth"
+)
+
+// Me returns the identity, effective roles and managed galleries of the caller.
+func Me(c *gin.Context) {
+	principal, ok := auth.PrincipalFrom(c)
+	if !ok {
+		respondWithError(c, http.StatusUnauthorized, "Not authenticated", nil)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"user": principal})
+}
@@ -0,0 +1,99 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/paumarro/apollo-be/internal/audit"
+	"github.com/paumarro/apollo-be/internal/auth"
+	"github.com/paumarro/apollo-be/internal/models"
+	"github.com/paumarro/apollo-be/internal/sessions"
+)
+
+// SessionController handles server-side (BFF) sessions
+type SessionController struct {
+	Sessions     *sessions.Manager
+	CookieDomain string
+}
+
+// NewSessionController creates a new instance of SessionController
+func NewSessionController(manager *sessions.Manager, cookieDomain string) *SessionController {
+	return &SessionController{Sessions: manager, CookieDomain: cookieDomain}
+}
+
+// Logout ends the caller's session
+func (sc *SessionController) Logout(c *gin.Context) {
+	if sessionID, err := c.Cookie(sessions.CookieName); err == nil && sessionID != "" {
+		if err := sc.Sessions.Destroy(c.Request.Context(), sessionID); err != nil && !errors.Is(err, sessions.ErrNotFound) {
+			respondWithError(c, http.StatusInternalServerError, "Failed to end session", err)
+			return
+		}
+	}
+	c.SetCookie(sessions.CookieName, "", -1, "/", sc.CookieDomain, true, true)
+	audit.RecordRequest(c, models.AuditEvent{Type: audit.TypeLogout, Outcome: audit.OutcomeSuccess})
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out"})
+}
+
+// Index lists active sessions of users in the admin's galleries
+func (sc *SessionController) Index(c *gin.Context) {
+	principal, ok := auth.PrincipalFrom(c)
+	if !ok {
+		respondWithError(c, http.StatusUnauthorized, "Not authenticated", nil)
+		return
+	}
+
+	all, err := sc.Sessions.Store.List(c.Request.Context())
+	if err != nil {
+		respondWithError(c, http.StatusInternalServerError, "Failed to fetch sessions", err)
+		return
+	}
+
+	visible := make([]models.Session, 0, len(all))
+	for _, s := range all {
+		if sharesGallery(principal, s.Galleries) {
+			visible = append(visible, s)
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"sessions": visible})
+}
+
+// Delete kills a session of a user in one of the admin's galleries
+func (sc *SessionController) Delete(c *gin.Context) {
+	principal, ok := auth.PrincipalFrom(c)
+	if !ok {
+		respondWithError(c, http.StatusUnauthorized, "Not authenticated", nil)
+		return
+	}
+
+	id := c.Param("id")
+	s, err := sc.Sessions.Store.Get(c.Request.Context(), id)
+	if err != nil || !sharesGallery(principal, s.Galleries) {
+		if err == nil || errors.Is(err, sessions.ErrNotFound) {
+			respondWithError(c, http.StatusNotFound, "Session not found", nil)
+		} else {
+			respondWithError(c, http.StatusInternalServerError, "Failed to find session", err)
+		}
+		return
+	}
+
+	if err := sc.Sessions.Revoke(c.Request.Context(), id); err != nil && !errors.Is(err, sessions.ErrNotFound) {
+		respondWithError(c, http.StatusInternalServerError, "Failed to delete session", err)
+		return
+	}
+	gallery := ""
+	if len(s.Galleries) == 1 {
+		gallery = s.Galleries[0]
+	}
+	recordAdminAction(c, "session.revoke", gallery, map[string]string{"session_subject": s.Subject})
+	c.JSON(http.StatusOK, gin.H{"message": "Session successfully deleted"})
+}
+
+func sharesGallery(p *auth.Principal, galleries []string) bool {
+	for _, g := range galleries {
+		if p.ManagesGallery(g) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,235 @@
+// Package dbrouter sends reads to read replicas and everything else to the
+// primary database. Replicas that are down or lag behind are skipped, and a
+// client that has just written reads from the primary for a while, so it
+// sees its own writes. The client carries the time of its last write, so
+// the window holds whichever server instance its next request reaches.
+package dbrouter
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// postgresLag is how far a Postgres standby's replay is behind, in seconds,
+// or NULL when it cannot serve reads. A standby whose WAL receiver is not
+// streaming is down. One without a receiver (restarting, or replaying from
+// an archive) is as far behind as its last replayed transaction. A streaming
+// standby that has replayed everything it received is not behind, however
+// long ago the last transaction was. Receiver status needs pg_read_all_stats.
+const postgresLag = `SELECT CASE
+	WHEN NOT pg_is_in_recovery() THEN 0
+	WHEN EXISTS (SELECT 1 FROM pg_stat_wal_receiver WHERE status <> 'streaming') THEN NULL
+	WHEN NOT EXISTS (SELECT 1 FROM pg_stat_wal_receiver) THEN EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp())
+	WHEN pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+	ELSE COALESCE(EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()), 0) END`
+
+// Replica is a read replica and its last known state.
+type Replica struct {
+	DB *gorm.DB
+	// LagQuery returns the replica's lag in seconds, or NULL when it is
+	// down; empty for none
+	LagQuery string
+
+	mu   sync.Mutex
+	down bool
+	lag  time.Duration
+}
+
+// Router picks the database for each query.
+type Router struct {
+	Primary  *gorm.DB
+	Replicas []*Replica
+	// Window is how long a session reads from the primary after writing
+	Window time.Duration
+	// MaxLag is the lag from which a replica is skipped
+	MaxLag time.Duration
+	Now    func() time.Time
+
+	next atomic.Uint64
+}
+
+// Session is the read-your-writes state of a client for one request.
+type Session struct {
+	// LastWrite is when the client last wrote, as the client reported it
+	LastWrite time.Time
+	// OnWrite hands the time of a write in this request back to the client
+	OnWrite func(time.Time)
+}
+
+// New routes reads to replicas. Without replicas, everything goes to primary.
+func New(primary *gorm.DB, replicas []*gorm.DB, window, maxLag time.Duration) *Router {
+	r := &Router{
+		Primary: primary,
+		Window:  window,
+		MaxLag:  maxLag,
+		Now:     time.Now,
+	}
+	for _, db := range replicas {
+		replica := &Replica{DB: db}
+		if db.Dialector.Name() == "postgres" {
+			replica.LagQuery = postgresLag
+		}
+		r.Replicas = append(r.Replicas, replica)
+	}
+	return r
+}
+
+// errNotReplicating marks a replica that no longer receives changes.
+var errNotReplicating = errors.New("replica is not replicating from the primary")
+
+type sessionKey struct{}
+type primaryKey struct{}
+
+// WithSession tags ctx with the client session its queries belong to.
+func WithSession(ctx context.Context, session *Session) context.Context {
+	return context.WithValue(ctx, sessionKey{}, session)
+}
+
+// WithPrimary makes every read under ctx use the primary.
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKey{}, true)
+}
+
+// Reader returns the database for a read under ctx: a usable replica in
+// turn, or the primary. replica is nil when the primary was chosen.
+func (r *Router) Reader(ctx context.Context) (db *gorm.DB, replica *Replica) {
+	if r == nil {
+		return nil, nil
+	}
+	if len(r.Replicas) == 0 || ctx.Value(primaryKey{}) != nil || r.recentlyWrote(ctx) {
+		return r.Primary, nil
+	}
+	start := r.next.Add(1)
+	for i := range r.Replicas {
+		replica := r.Replicas[(start+uint64(i))%uint64(len(r.Replicas))]
+		if r.usable(replica) {
+			return replica.DB, replica
+		}
+	}
+	return r.Primary, nil
+}
+
+// Wrote starts the read-your-writes window of the session of ctx.
+func (r *Router) Wrote(ctx context.Context) {
+	if r == nil || len(r.Replicas) == 0 {
+		return
+	}
+	session, ok := ctx.Value(sessionKey{}).(*Session)
+	if !ok {
+		return
+	}
+	session.LastWrite = r.Now()
+	if session.OnWrite != nil {
+		session.OnWrite(session.LastWrite)
+	}
+}
+
+func (r *Router) recentlyWrote(ctx context.Context) bool {
+	session, ok := ctx.Value(sessionKey{}).(*Session)
+	if !ok || session.LastWrite.IsZero() {
+		return false
+	}
+	return r.Now().Before(session.LastWrite.Add(r.Window))
+}
+
+func (r *Router) usable(replica *Replica) bool {
+	replica.mu.Lock()
+	defer replica.mu.Unlock()
+	return !replica.down && replica.lag < r.MaxLag
+}
+
+// Failed reports whether a read that failed on replica should be retried on
+// the primary. Errors other than a missing record or the caller giving up
+// mark the replica down until its next successful check.
+func (r *Router) Failed(ctx context.Context, replica *Replica, err error) bool {
+	if replica == nil || err == nil || errors.Is(err, gorm.ErrRecordNotFound) || ctx.Err() != nil {
+		return false
+	}
+	replica.mu.Lock()
+	wasDown := replica.down
+	replica.down = true
+	replica.mu.Unlock()
+	if !wasDown {
+		log.Printf("Read replica failed, reading from the primary: %v", err)
+	}
+	return true
+}
+
+// Check pings every replica and measures its lag.
+func (r *Router) Check(ctx context.Context) {
+	for _, replica := range r.Replicas {
+		lag, err := replica.check(ctx)
+		replica.mu.Lock()
+		wasDown, wasBehind := replica.down, replica.lag >= r.MaxLag
+		replica.down, replica.lag = err != nil, lag
+		replica.mu.Unlock()
+
+		switch {
+		case err != nil && !wasDown:
+			log.Printf("Read replica is down: %v", err)
+		case err == nil && lag >= r.MaxLag && !wasBehind:
+			log.Printf("Read replica lags %s behind, reading from the primary", lag)
+		case err == nil && lag < r.MaxLag && (wasDown || wasBehind):
+			log.Printf("Read replica is back, lag %s", lag)
+		}
+	}
+}
+
+func (replica *Replica) check(ctx context.Context) (time.Duration, error) {
+	sqlDB, err := replica.DB.DB()
+	if err != nil {
+		return 0, err
+	}
+	if err := sqlDB.PingContext(ctx); err != nil {
+		return 0, err
+	}
+	if replica.LagQuery == "" {
+		return 0, nil
+	}
+	var seconds sql.NullFloat64
+	if err := replica.DB.WithContext(ctx).Raw(replica.LagQuery).Row().Scan(&seconds); err != nil {
+		return 0, err
+	}
+	if !seconds.Valid {
+		return 0, errNotReplicating
+	}
+	return time.Duration(seconds.Float64 * float64(time.Second)), nil
+}
+
+// Run checks the replicas every interval, each check bounded by timeout,
+// until ctx ends.
+func (r *Router) Run(ctx context.Context, interval, timeout time.Duration) {
+	if len(r.Replicas) == 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		checkCtx, cancel := context.WithTimeout(ctx, timeout)
+		r.Check(checkCtx)
+		cancel()
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Available returns the number of replicas reads may use.
+func (r *Router) Available() int {
+	n := 0
+	for _, replica := range r.Replicas {
+		if r.usable(replica) {
+			n++
+		}
+	}
+	return n
+}
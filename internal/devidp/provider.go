@@ -0,0 +1,340 @@
+// Package devidp is a minimal in-process OpenID Connect issuer for local
+// development and tests. It mimics the Keycloak endpoints used by the
+// backend so authentication can stay enabled without running Keycloak.
+// It must never be enabled in production.
+package devidp
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/lestrrat-go/jwx/jwk"
+)
+
+const (
+	// DefaultKeyFile is where the signing key is kept so the server and the
+	// devtoken CLI sign with the same key.
+	DefaultKeyFile = ".devidp/key.pem"
+
+	authPath  = "/protocol/openid-connect/auth"
+	tokenPath = "/protocol/openid-connect/token"
+	certsPath = "/protocol/openid-connect/certs"
+
+	codeTTL         = time.Minute
+	refreshTokenTTL = 24 * time.Hour
+)
+
+// Identity describes the user a token is issued for.
+type Identity struct {
+	Subject   string
+	Name      string
+	Email     string
+	Roles     []string
+	Galleries []string
+	// Claims are merged into the token last and may override standard claims.
+	Claims map[string]interface{}
+}
+
+type grant struct {
+	identity    Identity
+	redirectURI string
+	expiresAt   time.Time
+}
+
+// Provider issues RS256 tokens shaped like Keycloak access tokens.
+type Provider struct {
+	Issuer   string
+	ClientID string
+	TokenTTL time.Duration
+	// DefaultIdentity is used by the authorize endpoint when the request does
+	// not select a user.
+	DefaultIdentity Identity
+	// RedirectURI is the client's callback URL, the only place the authorize
+	// endpoint sends codes to. Without it, authorize refuses every request.
+	RedirectURI string
+
+	key *rsa.PrivateKey
+	kid string
+
+	mu            sync.Mutex
+	codes         map[string]grant
+	refreshTokens map[string]grant
+}
+
+// New creates a provider signing with key. The issuer is the realm base URL,
+// e.g. http://localhost:3000/realms/apollo.
+func New(issuer, clientID string, key *rsa.PrivateKey) *Provider {
+	der := x509.MarshalPKCS1PublicKey(&key.PublicKey)
+	sum := sha256.Sum256(der)
+
+	return &Provider{
+		Issuer:   strings.TrimRight(issuer, "/"),
+		ClientID: clientID,
+		TokenTTL: 10 * time.Minute,
+		DefaultIdentity: Identity{
+			Subject: "dev-user",
+			Name:    "Dev User",
+			Email:   "dev@example.com",
+			Roles:   []string{"Regular"},
+		},
+		key:           key,
+		kid:           hex.EncodeToString(sum[:8]),
+		codes:         map[string]grant{},
+		refreshTokens: map[string]grant{},
+	}
+}
+
+// LoadOrCreateKey reads a PEM encoded RSA key, generating and saving one if the file does not exist.
+func LoadOrCreateKey(path string) (*rsa.PrivateKey, error) {
+	data, err := os.ReadFile(path) // #nosec G304 -- path comes from developer configuration
+	if err == nil {
+		block, _ := pem.Decode(data)
+		if block == nil {
+			return nil, fmt.Errorf("no PEM data in %s", path)
+		}
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read dev IdP key: %w", err)
+	}
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate dev IdP key: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create dev IdP key directory: %w", err)
+	}
+	pemBytes := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	if err := os.WriteFile(path, pemBytes, 0o600); err != nil {
+		return nil, fmt.Errorf("failed to write dev IdP key: %w", err)
+	}
+	log.Printf("Generated dev IdP signing key at %s", path)
+	return key, nil
+}
+
+// Mint signs an access token for the identity.
+func (p *Provider) Mint(id Identity, ttl time.Duration) (string, error) {
+	if ttl <= 0 {
+		ttl = p.TokenTTL
+	}
+	now := time.Now()
+
+	roles := id.Roles
+	if roles == nil {
+		roles = []string{}
+	}
+	claims := jwt.MapClaims{
+		"iss":                p.Issuer,
+		"aud":                p.ClientID,
+		"azp":                p.ClientID,
+		"sub":                id.Subject,
+		"iat":                now.Unix(),
+		"nbf":                now.Unix(),
+		"exp":                now.Add(ttl).Unix(),
+		"typ":                "Bearer",
+		"preferred_username": id.Subject,
+		"resource_access": map[string]interface{}{
+			p.ClientID: map[string]interface{}{"roles": roles},
+		},
+	}
+	if id.Name != "" {
+		claims["name"] = id.Name
+	}
+	if id.Email != "" {
+		claims["email"] = id.Email
+	}
+	if len(id.Galleries) > 0 {
+		claims["galleries"] = id.Galleries
+	}
+	for k, v := range id.Claims {
+		claims[k] = v
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = p.kid
+	return token.SignedString(p.key)
+}
+
+// JWKS returns the public key set in JSON form.
+func (p *Provider) JWKS() ([]byte, error) {
+	key, err := jwk.New(&p.key.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	if err := key.Set(jwk.KeyIDKey, p.kid); err != nil {
+		return nil, err
+	}
+	if err := key.Set(jwk.AlgorithmKey, "RS256"); err != nil {
+		return nil, err
+	}
+	if err := key.Set(jwk.KeyUsageKey, "sig"); err != nil {
+		return nil, err
+	}
+	set := jwk.NewSet()
+	set.Add(key)
+	return json.Marshal(set)
+}
+
+// Register mounts the discovery, JWKS, authorize and token endpoints on a
+// router group whose URL matches the provider issuer.
+func (p *Provider) Register(r gin.IRouter) {
+	r.GET("/.well-known/openid-configuration", p.discovery)
+	r.GET(certsPath, p.certs)
+	r.GET(authPath, p.authorize)
+	r.POST(tokenPath, p.token)
+}
+
+func (p *Provider) discovery(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"issuer":                                p.Issuer,
+		"authorization_endpoint":                p.Issuer + authPath,
+		"token_endpoint":                        p.Issuer + tokenPath,
+		"jwks_uri":                              p.Issuer + certsPath,
+		"response_types_supported":              []string{"code"},
+		"grant_types_supported":                 []string{"authorization_code", "refresh_token"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+	})
+}
+
+func (p *Provider) certs(c *gin.Context) {
+	body, err := p.JWKS()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build JWKS"})
+		return
+	}
+	c.Data(http.StatusOK, "application/json", body)
+}
+
+// authorize skips the login screen and immediately issues a code. The user can
+// be selected with the dev_sub, dev_roles and dev_galleries query parameters.
+func (p *Provider) authorize(c *gin.Context) {
+	if c.Query("response_type") != "code" || c.Query("client_id") != p.ClientID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unsupported_response_type_or_client"})
+		return
+	}
+	redirectURI, err := url.Parse(c.Query("redirect_uri"))
+	if err != nil || p.RedirectURI == "" || c.Query("redirect_uri") != p.RedirectURI {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_redirect_uri"})
+		return
+	}
+
+	id := p.DefaultIdentity
+	if sub := c.Query("dev_sub"); sub != "" {
+		id = Identity{Subject: sub, Name: sub}
+	}
+	if roles := c.Query("dev_roles"); roles != "" {
+		id.Roles = SplitList(roles)
+	}
+	if galleries := c.Query("dev_galleries"); galleries != "" {
+		id.Galleries = SplitList(galleries)
+	}
+
+	code, err := randomString()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "server_error"})
+		return
+	}
+	p.mu.Lock()
+	p.codes[code] = grant{identity: id, redirectURI: redirectURI.String(), expiresAt: time.Now().Add(codeTTL)}
+	p.mu.Unlock()
+
+	q := redirectURI.Query()
+	q.Set("code", code)
+	if state := c.Query("state"); state != "" {
+		q.Set("state", state)
+	}
+	redirectURI.RawQuery = q.Encode()
+	c.Redirect(http.StatusFound, redirectURI.String())
+}
+
+func (p *Provider) token(c *gin.Context) {
+	if c.PostForm("client_id") != p.ClientID {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid_client"})
+		return
+	}
+
+	var g grant
+	var ok bool
+	p.mu.Lock()
+	switch c.PostForm("grant_type") {
+	case "authorization_code":
+		code := c.PostForm("code")
+		g, ok = p.codes[code]
+		delete(p.codes, code)
+		ok = ok && g.redirectURI == c.PostForm("redirect_uri")
+	case "refresh_token":
+		refreshToken := c.PostForm("refresh_token")
+		g, ok = p.refreshTokens[refreshToken]
+		delete(p.refreshTokens, refreshToken)
+	}
+	p.mu.Unlock()
+
+	if !ok || time.Now().After(g.expiresAt) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_grant"})
+		return
+	}
+	p.issueTokens(c, g.identity)
+}
+
+func (p *Provider) issueTokens(c *gin.Context, id Identity) {
+	accessToken, err := p.Mint(id, 0)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "server_error"})
+		return
+	}
+	refreshToken, err := randomString()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "server_error"})
+		return
+	}
+
+	p.mu.Lock()
+	p.refreshTokens[refreshToken] = grant{identity: id, expiresAt: time.Now().Add(refreshTokenTTL)}
+	p.mu.Unlock()
+
+	c.JSON(http.StatusOK, gin.H{
+		"access_token":  accessToken,
+		"refresh_token": refreshToken,
+		"token_type":    "Bearer",
+		"expires_in":    int(p.TokenTTL.Seconds()),
+	})
+}
+
+// SplitList splits a comma separated list, dropping empty entries.
+func SplitList(s string) []string {
+	var out []string
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
+
+func randomString() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,8 @@
+package dto
+
+type APIKeyRequest struct {
+	Name          string   `json:"name" binding:"required,min=3,max=100"`             // Required, human-readable label
+	Gallery       string   `json:"gallery" binding:"required,max=100"`                // Required, must be managed by the caller
+	Roles         []string `json:"roles" binding:"required,min=1,dive,required"`      // Required, subset of the caller's roles
+	ExpiresInDays int      `json:"expires_in_days" binding:"omitempty,min=1,max=365"` // Optional, defaults to 90 days
+}
@@ -1,8 +1,10 @@
 package dto
 
 type ArtworkRequest struct {
-	Title       string `json:"title" validate:"required,min=3,max=255"`  // Required, min 3, max 255 characters
-	Artist      string `json:"artist" validate:"required,min=3,max=255"` // Required, min 3, max 255 characters
-	Description string `json:"description" validate:"required,max=1000"` // Required, max 1000 characters
-	Image       string `json:"image" validate:"required,url"`            // Required, must be a valid URL
+	Title       string `json:"title" validate:"required,min=3,max=255"`                     // Required, min 3, max 255 characters
+	Artist      string `json:"artist" validate:"required,min=3,max=255"`                    // Required, min 3, max 255 characters
+	Description string `json:"description" validate:"required,max=1000"`                    // Required, max 1000 characters
+	Image       string `json:"image" validate:"required,url"`                               // Required, must be a valid URL
+	Gallery     string `json:"gallery,omitempty" validate:"omitempty,max=100"`              // Optional, defaults to the caller's only gallery
+	Status      string `json:"status,omitempty" validate:"omitempty,oneof=draft published"` // Optional, defaults to published
 }
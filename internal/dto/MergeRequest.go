@@ -0,0 +1,6 @@
+package dto
+
+type MergeRequest struct {
+	KeepID   uint `json:"keep_id" binding:"required"`   // Required, the artwork that remains
+	RetireID uint `json:"retire_id" binding:"required"` // Required, merged into keep_id and retired
+}
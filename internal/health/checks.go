@@ -0,0 +1,95 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/paumarro/apollo-be/internal/auth"
+	"gorm.io/gorm"
+)
+
+// DatabaseCheck pings the database and reports the round trip and pool usage.
+func DatabaseCheck(db *gorm.DB) Check {
+	return func(ctx context.Context) (map[string]interface{}, error) {
+		sqlDB, err := db.DB()
+		if err != nil {
+			return nil, err
+		}
+		start := time.Now()
+		if err := sqlDB.PingContext(ctx); err != nil {
+			return nil, fmt.Errorf("ping failed: %w", err)
+		}
+		stats := sqlDB.Stats()
+		return map[string]interface{}{
+			"ping_ms": float64(time.Since(start).Microseconds()) / 1000,
+			"open":    stats.OpenConnections,
+			"in_use":  stats.InUse,
+			"idle":    stats.Idle,
+		}, nil
+	}
+}
+
+// KeySetCheck fails when no JWKS can be fetched, since no token can be
+// verified, and is degraded when some cannot be fetched or a cached key set
+// has not been refreshed within maxAge. The readiness response is public, so
+// it only carries counts; URLs and fetch errors go to the log.
+func KeySetCheck(reporter auth.KeySetReporter, maxAge time.Duration) Check {
+	return func(ctx context.Context) (map[string]interface{}, error) {
+		sets := reporter.KeySets(ctx)
+
+		var unavailable, stale int
+		for _, set := range sets {
+			switch {
+			case set.Error != "":
+				unavailable++
+				log.Printf("Key set %s unavailable: %s", set.URL, set.Error)
+			case !set.LastRefresh.IsZero() && time.Since(set.LastRefresh) > maxAge:
+				stale++
+				log.Printf("Key set %s not refreshed since %s", set.URL, set.LastRefresh.Format(time.RFC3339))
+			}
+		}
+		details := map[string]interface{}{"key_sets": len(sets), "unavailable": unavailable, "stale": stale}
+
+		switch {
+		case len(sets) > 0 && unavailable == len(sets):
+			return details, errors.New("no key set available")
+		case unavailable > 0:
+			return details, Degraded(fmt.Errorf("%d of %d key sets unavailable", unavailable, len(sets)))
+		case stale > 0:
+			return details, Degraded(fmt.Errorf("%d of %d key sets not refreshed within %s", stale, len(sets), maxAge))
+		}
+		return details, nil
+	}
+}
+
+// Worker is a background loop that records when it last ran and the error
+// of that run, if any.
+type Worker interface {
+	LastRun() (time.Time, error)
+}
+
+// WorkerCheck fails when the worker has not run within maxSilence, counted
+// from the creation of the check if it never ran, and is degraded when its
+// last run failed.
+func WorkerCheck(worker Worker, maxSilence time.Duration) Check {
+	created := time.Now()
+	return func(ctx context.Context) (map[string]interface{}, error) {
+		last, err := worker.LastRun()
+		details := map[string]interface{}{}
+		since := created
+		if !last.IsZero() {
+			details["last_run"] = last
+			since = last
+		}
+		if silent := time.Since(since); silent > maxSilence {
+			return details, fmt.Errorf("worker has not run for %s", silent.Round(time.Second))
+		}
+		if err != nil {
+			return details, Degraded(err)
+		}
+		return details, nil
+	}
+}
@@ -0,0 +1,147 @@
+// Package health runs dependency checks for the liveness and readiness
+// endpoints.
+package health
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Status of a check or of the whole report.
+type Status string
+
+const (
+	StatusOK Status = "ok"
+	// StatusDegraded reports a problem that does not yet stop the service
+	// from handling requests, such as a stale key set cache.
+	StatusDegraded Status = "degraded"
+	StatusFail     Status = "fail"
+)
+
+// Check probes one dependency and returns details for the report. Errors
+// wrapped with Degraded report a degraded check without failing readiness.
+type Check func(ctx context.Context) (map[string]interface{}, error)
+
+type degradedError struct{ err error }
+
+func (e degradedError) Error() string { return e.err.Error() }
+func (e degradedError) Unwrap() error { return e.err }
+
+// Degraded marks a check error as non-fatal.
+func Degraded(err error) error {
+	return degradedError{err: err}
+}
+
+// Result is the outcome of one check.
+type Result struct {
+	Status    Status                 `json:"status"`
+	LatencyMS float64                `json:"latency_ms"`
+	Error     string                 `json:"error,omitempty"`
+	Details   map[string]interface{} `json:"details,omitempty"`
+}
+
+// Report is the readiness response body.
+type Report struct {
+	Status Status            `json:"status"`
+	Checks map[string]Result `json:"checks"`
+}
+
+type namedCheck struct {
+	name  string
+	check Check
+}
+
+// Checker runs the registered checks concurrently, each bounded by Timeout.
+type Checker struct {
+	Timeout time.Duration
+
+	mu       sync.Mutex
+	checks   []namedCheck
+	draining atomic.Bool
+}
+
+// NewChecker creates a checker whose checks time out after timeout.
+func NewChecker(timeout time.Duration) *Checker {
+	return &Checker{Timeout: timeout}
+}
+
+// Register adds a named check to readiness.
+func (c *Checker) Register(name string, check Check) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checks = append(c.checks, namedCheck{name: name, check: check})
+}
+
+// Drain makes readiness fail so load balancers stop routing new requests
+// while in-flight ones finish.
+func (c *Checker) Drain() {
+	c.draining.Store(true)
+}
+
+// Run executes every check and summarises them. The report fails when any
+// check fails or the service is draining.
+func (c *Checker) Run(ctx context.Context) Report {
+	c.mu.Lock()
+	checks := append([]namedCheck(nil), c.checks...)
+	c.mu.Unlock()
+
+	report := Report{Status: StatusOK, Checks: make(map[string]Result, len(checks)+1)}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, nc := range checks {
+		wg.Add(1)
+		go func(nc namedCheck) {
+			defer wg.Done()
+			result := c.run(ctx, nc.check)
+			mu.Lock()
+			report.Checks[nc.name] = result
+			mu.Unlock()
+		}(nc)
+	}
+	wg.Wait()
+
+	if c.draining.Load() {
+		report.Checks["shutdown"] = Result{Status: StatusFail, Error: "draining"}
+	}
+	for _, result := range report.Checks {
+		switch {
+		case result.Status == StatusFail:
+			report.Status = StatusFail
+		case result.Status == StatusDegraded && report.Status == StatusOK:
+			report.Status = StatusDegraded
+		}
+	}
+	return report
+}
+
+func (c *Checker) run(ctx context.Context, check Check) (result Result) {
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	defer func() {
+		result.LatencyMS = float64(time.Since(start).Microseconds()) / 1000
+		if r := recover(); r != nil {
+			result.Status = StatusFail
+			result.Error = "check panicked"
+		}
+	}()
+
+	details, err := check(ctx)
+	result.Details = details
+	var degraded degradedError
+	switch {
+	case err == nil:
+		result.Status = StatusOK
+	case errors.As(err, &degraded):
+		result.Status = StatusDegraded
+		result.Error = err.Error()
+	default:
+		result.Status = StatusFail
+		result.Error = err.Error()
+	}
+	return result
+}
@@ -1,34 +1,252 @@
 package initializers
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"log"
-	"os"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/paumarro/apollo-be/internal/config"
 	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 )
 
-var DB *gorm.DB
+// attemptTimeout bounds a single connection attempt so an unresponsive host
+// does not use up the whole DB_CONNECT_TIMEOUT.
+const attemptTimeout = 5 * time.Second
 
-func ConnectToDB() {
-	// Retrieve the DSN (Data Source Name) from the environment variable
-	dsn := os.Getenv("ART_DB_URL")
-	log.Println(dsn)
+// ConnectToDB opens the database of cfg and configures its pool. A
+// sqlite:<path> URL, or sqlite::memory: for a private in-memory database,
+// selects SQLite; anything else is a Postgres URL or key=value string.
+// While the database is unreachable it retries with exponential backoff
+// until cfg.ConnectTimeout or ctx ends, so the app can start before
+// Postgres does. A malformed URL fails at once.
+func ConnectToDB(ctx context.Context, cfg config.Database) (*gorm.DB, error) {
+	target := RedactDSN(cfg.URL)
+	dialector, err := openDialector(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("invalid database URL %s", target)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, cfg.ConnectTimeout)
+	defer cancel()
+
+	var db *gorm.DB
+	err = Retry(ctx, Backoff{Base: cfg.RetryBackoff, Max: cfg.RetryBackoffMax}, func(attempt int) error {
+		var err error
+		// TranslateError reports unique violations as gorm.ErrDuplicatedKey on every dialect
+		db, err = gorm.Open(dialector, &gorm.Config{TranslateError: true})
+		if err != nil {
+			log.Printf("Database %s unavailable (attempt %d): %v", target, attempt, err)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to DB %s: %w", target, err)
+	}
+
+	if err := configurePool(db, cfg); err != nil {
+		return nil, err
+	}
+
+	log.Printf("Database connection to %s established successfully!", target)
+	return db, nil
+}
+
+// ConnectToReplicas opens the read replicas of cfg with the same pool
+// settings as the primary. Unlike ConnectToDB it does not wait for them: a
+// replica that is down is skipped by the router until it comes up.
+func ConnectToReplicas(cfg config.Database) ([]*gorm.DB, error) {
+	replicas := make([]*gorm.DB, 0, len(cfg.ReplicaURLs))
+	for _, replicaURL := range cfg.ReplicaURLs {
+		replicaCfg := cfg
+		replicaCfg.URL = replicaURL
+		target := RedactDSN(replicaURL)
+		dialector, err := openDialector(replicaCfg)
+		if err != nil {
+			return nil, fmt.Errorf("invalid replica URL %s", target)
+		}
+		db, err := gorm.Open(dialector, &gorm.Config{TranslateError: true, DisableAutomaticPing: true})
+		if err != nil {
+			return nil, fmt.Errorf("failed to open replica %s: %w", target, err)
+		}
+		if err := configurePool(db, replicaCfg); err != nil {
+			return nil, err
+		}
+		log.Printf("Read replica %s configured", target)
+		replicas = append(replicas, db)
+	}
+	return replicas, nil
+}
+
+func configurePool(db *gorm.DB, cfg config.Database) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	if sqliteMemory(cfg.URL) {
+		// Every connection would open its own empty database, so keep one
+		// connection for the lifetime of the pool
+		sqlDB.SetMaxOpenConns(1)
+		sqlDB.SetMaxIdleConns(1)
+		sqlDB.SetConnMaxLifetime(0)
+		sqlDB.SetConnMaxIdleTime(0)
+	} else {
+		sqlDB.SetMaxOpenConns(cfg.MaxOpenConns)
+		sqlDB.SetMaxIdleConns(cfg.MaxIdleConns)
+		sqlDB.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+		sqlDB.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+	}
+	return nil
+}
+
+// openDialector picks the driver from the scheme of cfg.URL.
+func openDialector(cfg config.Database) (gorm.Dialector, error) {
+	if path, ok := sqlitePath(cfg.URL); ok {
+		if path == "" {
+			return nil, errors.New("missing SQLite path")
+		}
+		if sqliteMemory(cfg.URL) {
+			return sqlite.Open(path), nil
+		}
+		// Wait for the file lock rather than failing concurrent writes
+		return sqlite.Open(withQueryDefaults(path, map[string]string{
+			"_busy_timeout": strconv.FormatInt(cfg.StatementTimeout.Milliseconds(), 10),
+			"_foreign_keys": "on",
+			"_journal_mode": "WAL",
+		})), nil
+	}
+
+	if _, err := pgconn.ParseConfig(cfg.URL); err != nil {
+		return nil, err
+	}
+	return postgres.Open(WithRuntimeParams(cfg.URL, map[string]string{
+		// Postgres cancels statements running longer than this
+		"statement_timeout": strconv.FormatInt(cfg.StatementTimeout.Milliseconds(), 10),
+		"connect_timeout":   strconv.Itoa(int(attemptTimeout.Seconds())),
+	})), nil
+}
+
+// sqlitePath returns the file path of a sqlite:<path> or sqlite://<path> URL.
+func sqlitePath(dsn string) (string, bool) {
+	rest, ok := strings.CutPrefix(dsn, "sqlite:")
+	if !ok {
+		return "", false
+	}
+	return strings.TrimPrefix(rest, "//"), true
+}
+
+func sqliteMemory(dsn string) bool {
+	path, ok := sqlitePath(dsn)
+	return ok && strings.HasPrefix(path, ":memory:")
+}
 
-	// Attempt to establish the connection to the database
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+// withQueryDefaults appends params missing from the query of a file path.
+func withQueryDefaults(path string, params map[string]string) string {
+	base, rawQuery, _ := strings.Cut(path, "?")
+	query, err := url.ParseQuery(rawQuery)
 	if err != nil {
-		log.Fatalf("Failed to connect to DB: %v", err)
+		query = url.Values{}
+	}
+	for k, v := range params {
+		if !query.Has(k) {
+			query.Set(k, v)
+		}
+	}
+	return base + "?" + query.Encode()
+}
+
+// Backoff doubles the delay between attempts from Base up to Max.
+type Backoff struct {
+	Base time.Duration
+	Max  time.Duration
+}
+
+// Delay returns the wait after the given failed attempt, counting from 1.
+func (b Backoff) Delay(attempt int) time.Duration {
+	delay := b.Base
+	for i := 1; i < attempt && delay < b.Max; i++ {
+		delay *= 2
+	}
+	if delay > b.Max {
+		delay = b.Max
 	}
+	return delay
+}
 
-	// Assign the connection to the global DB variable
-	DB = db
+// Retry calls fn until it succeeds or ctx ends, waiting between attempts as
+// given by backoff. It returns the last error of fn if ctx ends first.
+func Retry(ctx context.Context, backoff Backoff, fn func(attempt int) error) error {
+	for attempt := 1; ; attempt++ {
+		err := fn(attempt)
+		if err == nil {
+			return nil
+		}
+		timer := time.NewTimer(backoff.Delay(attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return errors.Join(err, fmt.Errorf("gave up after %d attempts: %w", attempt, ctx.Err()))
+		case <-timer.C:
+		}
+	}
+}
 
-	// Test the connection by running a simple query
-	var result int
-	if err := DB.Raw("SELECT 1").Scan(&result).Error; err != nil {
-		log.Fatalf("Error executing test query: %v", err)
+var (
+	dsnPassword = regexp.MustCompile(`(?i)(password\s*=\s*)('(?:[^'\\]|\\.)*'|[^\s&]+)`)
+	// urlPassword catches the userinfo of URLs net/url cannot parse
+	urlPassword = regexp.MustCompile(`(://[^:/@]*:)[^@]*@`)
+)
+
+// RedactDSN hides the password of a URL or key=value connection string so
+// it can be logged.
+func RedactDSN(dsn string) string {
+	if u, err := url.Parse(dsn); err == nil && u.Scheme != "" {
+		query := u.Query()
+		if query.Has("password") {
+			query.Set("password", "xxxxx")
+			u.RawQuery = query.Encode()
+		}
+		return u.Redacted()
 	}
+	dsn = urlPassword.ReplaceAllString(dsn, "${1}xxxxx@")
+	return dsnPassword.ReplaceAllString(dsn, "${1}xxxxx")
+}
 
-	log.Println("Database connection established successfully!")
+// WithRuntimeParams adds params to a URL or key=value connection string,
+// keeping values the string already sets.
+func WithRuntimeParams(dsn string, params map[string]string) string {
+	if u, err := url.Parse(dsn); err == nil && u.Scheme != "" {
+		query := u.Query()
+		for k, v := range params {
+			if !query.Has(k) {
+				query.Set(k, v)
+			}
+		}
+		u.RawQuery = query.Encode()
+		return u.String()
+	}
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		keys = append(keys, k)
+	}
+	// Keep the output stable for logs and tests
+	sort.Strings(keys)
+	var b strings.Builder
+	b.WriteString(dsn)
+	for _, k := range keys {
+		if regexp.MustCompile(`(^|\s)` + regexp.QuoteMeta(k) + `\s*=`).MatchString(dsn) {
+			continue
+		}
+		fmt.Fprintf(&b, " %s=%s", k, params[k])
+	}
+	return b.String()
 }
@@ -0,0 +1,93 @@
+package lockout
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/paumarro/apollo-be/internal/audit"
+	"github.com/paumarro/apollo-be/internal/models"
+)
+
+var (
+	defaultMu      sync.RWMutex
+	defaultTracker *Tracker
+)
+
+// Use enables lockouts with the given tracker. Lockouts are disabled while it is nil.
+func Use(t *Tracker) {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	defaultTracker = t
+}
+
+// Default returns the tracker set with Use, or nil.
+func Default() *Tracker {
+	defaultMu.RLock()
+	defer defaultMu.RUnlock()
+	return defaultTracker
+}
+
+// Check aborts the request with 429 when the client IP or the subject is
+// locked out. subject may be empty when it is not known yet.
+func Check(c *gin.Context, subject string) bool {
+	t := Default()
+	if t == nil {
+		return true
+	}
+
+	until, locked := t.Locked(KindIP, c.ClientIP())
+	if !locked && subject != "" {
+		until, locked = t.Locked(KindSubject, subject)
+	}
+	if !locked {
+		return true
+	}
+
+	retryAfter := int(math.Ceil(time.Until(until).Seconds()))
+	if retryAfter < 1 {
+		retryAfter = 1
+	}
+	c.Header("Retry-After", strconv.Itoa(retryAfter))
+	c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "Too many failed attempts, try again later"})
+	return false
+}
+
+// Failure counts a failed attempt for the client IP and the subject, if known,
+// and audits any resulting lockout.
+func Failure(c *gin.Context, subject string) {
+	t := Default()
+	if t == nil {
+		return
+	}
+	fail(c, t, KindIP, c.ClientIP())
+	if subject != "" {
+		fail(c, t, KindSubject, subject)
+	}
+}
+
+// Success clears the subject's failures. The IP counter only decays, so one
+// valid credential cannot reset an IP that is guessing others.
+func Success(c *gin.Context, subject string) {
+	t := Default()
+	if t == nil || subject == "" {
+		return
+	}
+	t.Succeed(KindSubject, subject)
+}
+
+func fail(c *gin.Context, t *Tracker, kind, value string) {
+	until, locked := t.Fail(kind, value)
+	if !locked {
+		return
+	}
+	audit.RecordRequest(c, models.AuditEvent{
+		Type:    audit.TypeLockout,
+		Outcome: audit.OutcomeDenied,
+		Reason:  "too_many_failures",
+		Details: map[string]string{"kind": kind, "value": value, "locked_until": until.UTC().Format(time.RFC3339)},
+	})
+}
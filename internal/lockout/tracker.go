@@ -0,0 +1,193 @@
+// Package lockout counts failed authentication attempts per client IP and
+// per subject and locks offenders out for exponentially growing periods.
+package lockout
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Counter kinds
+const (
+	KindIP      = "ip"
+	KindSubject = "subject"
+)
+
+// DefaultMaxEntries bounds the counters a tracker keeps.
+const DefaultMaxEntries = 100000
+
+// Policy controls when and for how long a key is locked out.
+type Policy struct {
+	// Threshold failures within Window lock a key out.
+	Threshold int
+	Window    time.Duration
+	// The first lockout lasts BaseLockout; each further one doubles, up to MaxLockout.
+	BaseLockout time.Duration
+	MaxLockout  time.Duration
+	// ResetAfter forgets a key, including its lockout history, once it has
+	// been idle that long.
+	ResetAfter time.Duration
+}
+
+// DefaultPolicy locks out after 10 failures in 5 minutes, starting at one minute.
+func DefaultPolicy() Policy {
+	return Policy{
+		Threshold:   10,
+		Window:      5 * time.Minute,
+		BaseLockout: time.Minute,
+		MaxLockout:  time.Hour,
+		ResetAfter:  24 * time.Hour,
+	}
+}
+
+// Lockout describes the state of one counter.
+type Lockout struct {
+	Kind        string    `json:"kind"`
+	Value       string    `json:"value"`
+	Failures    int       `json:"failures"`
+	Lockouts    int       `json:"lockouts"`
+	LastFailure time.Time `json:"last_failure"`
+	LockedUntil time.Time `json:"locked_until"`
+}
+
+// Tracker keeps failure counters in memory. It is safe for concurrent use.
+type Tracker struct {
+	Policy Policy
+	Now    func() time.Time
+	// MaxEntries bounds the counters kept. Once reached, idle counters are
+	// swept and then the counters that failed longest ago are evicted; keys
+	// that are locked out are kept.
+	MaxEntries int
+
+	mu      sync.Mutex
+	entries map[string]*Lockout
+}
+
+func NewTracker(policy Policy) *Tracker {
+	return &Tracker{Policy: policy, Now: time.Now, MaxEntries: DefaultMaxEntries, entries: map[string]*Lockout{}}
+}
+
+func key(kind, value string) string {
+	return kind + ":" + value
+}
+
+// Locked returns when the key's lockout ends, if it is locked out now.
+func (t *Tracker) Locked(kind, value string) (time.Time, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	e, ok := t.entries[key(kind, value)]
+	if !ok || !t.Now().Before(e.LockedUntil) {
+		return time.Time{}, false
+	}
+	return e.LockedUntil, true
+}
+
+// Fail counts a failure. It reports the lockout end when this failure locks the key out.
+func (t *Tracker) Fail(kind, value string) (time.Time, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := t.Now()
+
+	k := key(kind, value)
+	e, ok := t.entries[k]
+	if ok && now.Sub(e.LastFailure) > t.Policy.ResetAfter {
+		ok = false
+	}
+	if !ok {
+		if len(t.entries) >= t.MaxEntries {
+			t.sweep(now)
+			t.evict(now)
+		}
+		if len(t.entries) >= t.MaxEntries {
+			// Every counter is locked out; new keys wait until some expire
+			return time.Time{}, false
+		}
+		e = &Lockout{Kind: kind, Value: value}
+		t.entries[k] = e
+	}
+
+	if now.Sub(e.LastFailure) > t.Policy.Window {
+		e.Failures = 0
+	}
+	e.Failures++
+	e.LastFailure = now
+
+	if e.Failures < t.Policy.Threshold || now.Before(e.LockedUntil) {
+		return time.Time{}, false
+	}
+
+	d := t.Policy.BaseLockout << uint(e.Lockouts)
+	if d > t.Policy.MaxLockout || d <= 0 {
+		d = t.Policy.MaxLockout
+	}
+	e.Lockouts++
+	e.Failures = 0
+	e.LockedUntil = now.Add(d)
+	return e.LockedUntil, true
+}
+
+// Succeed forgets the key's failures and lockout history.
+func (t *Tracker) Succeed(kind, value string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.entries, key(kind, value))
+}
+
+// Clear lifts a lockout and forgets the key. It reports whether the key was known.
+func (t *Tracker) Clear(kind, value string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	k := key(kind, value)
+	_, ok := t.entries[k]
+	delete(t.entries, k)
+	return ok
+}
+
+// List returns the keys that are locked out now, soonest expiry first.
+func (t *Tracker) List() []Lockout {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := t.Now()
+	out := []Lockout{}
+	for _, e := range t.entries {
+		if now.Before(e.LockedUntil) {
+			out = append(out, *e)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].LockedUntil.Before(out[j].LockedUntil) })
+	return out
+}
+
+func (t *Tracker) sweep(now time.Time) {
+	for k, e := range t.entries {
+		if now.Sub(e.LastFailure) > t.Policy.ResetAfter || (now.Sub(e.LastFailure) > t.Policy.Window && !now.Before(e.LockedUntil)) {
+			delete(t.entries, k)
+		}
+	}
+}
+
+// evict drops the counters that failed longest ago and are not locked out,
+// down to nine tenths of MaxEntries so that a flood of new keys does not
+// evict on every failure.
+func (t *Tracker) evict(now time.Time) {
+	target := t.MaxEntries * 9 / 10
+	if len(t.entries) <= target {
+		return
+	}
+	var idle []string
+	for k, e := range t.entries {
+		if !now.Before(e.LockedUntil) {
+			idle = append(idle, k)
+		}
+	}
+	sort.Slice(idle, func(i, j int) bool {
+		return t.entries[idle[i]].LastFailure.Before(t.entries[idle[j]].LastFailure)
+	})
+	for _, k := range idle {
+		if len(t.entries) <= target {
+			return
+		}
+		delete(t.entries, k)
+	}
+}
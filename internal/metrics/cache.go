@@ -0,0 +1,27 @@
+package metrics
+
+import (
+	"fmt"
+
+	"github.com/paumarro/apollo-be/internal/cache"
+)
+
+// RegisterCacheStats exposes the counters of a cache, labelled with its name.
+func RegisterCacheStats(r *Registry, name string, stats func() cache.Stats) {
+	label := fmt.Sprintf("{cache=%q}", name)
+	stat := func(read func(cache.Stats) float64) func() float64 {
+		return func() float64 { return read(stats()) }
+	}
+	r.Register("apollo_cache_hits_total"+label, "Lookups answered from the cache.", Counter,
+		stat(func(s cache.Stats) float64 { return float64(s.Hits) }))
+	r.Register("apollo_cache_negative_hits_total"+label, "Lookups answered by a cached miss.", Counter,
+		stat(func(s cache.Stats) float64 { return float64(s.NegativeHits) }))
+	r.Register("apollo_cache_misses_total"+label, "Lookups that went to the database.", Counter,
+		stat(func(s cache.Stats) float64 { return float64(s.Misses) }))
+	r.Register("apollo_cache_evictions_total"+label, "Entries evicted to make room for others.", Counter,
+		stat(func(s cache.Stats) float64 { return float64(s.Evictions) }))
+	r.Register("apollo_cache_invalidations_total"+label, "Entries invalidated by writes through this replica.", Counter,
+		stat(func(s cache.Stats) float64 { return float64(s.Invalidations) }))
+	r.Register("apollo_cache_entries"+label, "Entries currently held, including expired ones not yet removed.", Gauge,
+		stat(func(s cache.Stats) float64 { return float64(s.Entries) }))
+}
@@ -0,0 +1,28 @@
+package metrics
+
+import "database/sql"
+
+// RegisterDBStats exposes the connection pool statistics of db.
+func RegisterDBStats(r *Registry, db *sql.DB) {
+	stat := func(read func(sql.DBStats) float64) func() float64 {
+		return func() float64 { return read(db.Stats()) }
+	}
+	r.Register("apollo_db_max_open_connections", "Maximum number of open connections to the database.", Gauge,
+		stat(func(s sql.DBStats) float64 { return float64(s.MaxOpenConnections) }))
+	r.Register("apollo_db_open_connections", "Number of established connections, in use and idle.", Gauge,
+		stat(func(s sql.DBStats) float64 { return float64(s.OpenConnections) }))
+	r.Register("apollo_db_in_use_connections", "Number of connections currently in use.", Gauge,
+		stat(func(s sql.DBStats) float64 { return float64(s.InUse) }))
+	r.Register("apollo_db_idle_connections", "Number of idle connections.", Gauge,
+		stat(func(s sql.DBStats) float64 { return float64(s.Idle) }))
+	r.Register("apollo_db_wait_count_total", "Number of times a query waited for a free connection.", Counter,
+		stat(func(s sql.DBStats) float64 { return float64(s.WaitCount) }))
+	r.Register("apollo_db_wait_duration_seconds_total", "Total time spent waiting for a free connection.", Counter,
+		stat(func(s sql.DBStats) float64 { return s.WaitDuration.Seconds() }))
+	r.Register("apollo_db_max_idle_closed_total", "Connections closed because of DB_MAX_IDLE_CONNS.", Counter,
+		stat(func(s sql.DBStats) float64 { return float64(s.MaxIdleClosed) }))
+	r.Register("apollo_db_max_idle_time_closed_total", "Connections closed because of DB_CONN_MAX_IDLE_TIME.", Counter,
+		stat(func(s sql.DBStats) float64 { return float64(s.MaxIdleTimeClosed) }))
+	r.Register("apollo_db_max_lifetime_closed_total", "Connections closed because of DB_CONN_MAX_LIFETIME.", Counter,
+		stat(func(s sql.DBStats) float64 { return float64(s.MaxLifetimeClosed) }))
+}
@@ -0,0 +1,81 @@
+// Package metrics exposes application metrics in the Prometheus text format.
+// Values are read from their source on every scrape.
+package metrics
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Kind is the Prometheus metric type.
+type Kind string
+
+const (
+	Gauge   Kind = "gauge"
+	Counter Kind = "counter"
+)
+
+type metric struct {
+	name  string
+	help  string
+	kind  Kind
+	value func() float64
+}
+
+// Registry holds the metrics served by its ServeHTTP.
+type Registry struct {
+	mu      sync.Mutex
+	metrics []metric
+}
+
+// NewRegistry creates an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// Register adds a metric read from value. name may carry labels, as in
+// `apollo_cache_hits_total{cache="artworks"}`; metrics sharing a base name
+// share the HELP and TYPE of the first one registered.
+func (r *Registry) Register(name, help string, kind Kind, value func() float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.metrics = append(r.metrics, metric{name: name, help: help, kind: kind, value: value})
+}
+
+// ServeHTTP writes every metric in registration order, grouped by base name.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	r.mu.Lock()
+	metrics := append([]metric(nil), r.metrics...)
+	r.mu.Unlock()
+
+	var order []string
+	groups := map[string][]metric{}
+	for _, m := range metrics {
+		base := baseName(m.name)
+		if _, ok := groups[base]; !ok {
+			order = append(order, base)
+		}
+		groups[base] = append(groups[base], m)
+	}
+
+	var b strings.Builder
+	for _, base := range order {
+		group := groups[base]
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s %s\n", base, group[0].help, base, group[0].kind)
+		for _, m := range group {
+			fmt.Fprintf(&b, "%s %s\n", m.name, strconv.FormatFloat(m.value(), 'g', -1, 64))
+		}
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_, _ = w.Write([]byte(b.String()))
+}
+
+func baseName(name string) string {
+	if i := strings.IndexByte(name, '{'); i >= 0 {
+		return name[:i]
+	}
+	return name
+}
@@ -0,0 +1,77 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/paumarro/apollo-be/internal/audit"
+	"github.com/paumarro/apollo-be/internal/auth"
+	"github.com/paumarro/apollo-be/internal/lockout"
+	"github.com/paumarro/apollo-be/internal/models"
+	"github.com/paumarro/apollo-be/internal/services"
+)
+
+// APIKeyHeader carries machine-client credentials.
+const APIKeyHeader = "X-API-Key"
+
+// APIKeyAuthenticator resolves a plaintext API key to a principal.
+type APIKeyAuthenticator interface {
+	Authenticate(ctx context.Context, key string) (*auth.Principal, error)
+}
+
+// AuthOrAPIKey accepts either an X-API-Key header or the JWT flow handled by
+// Auth. Both paths place an auth.Principal in the context and enforce the
+// same required role.
+func (a *Authenticator) AuthOrAPIKey(requiredRole string, keys APIKeyAuthenticator) gin.HandlerFunc {
+	jwtAuth := a.Auth(requiredRole)
+
+	return func(c *gin.Context) {
+		apiKey := c.GetHeader(APIKeyHeader)
+		if apiKey == "" {
+			jwtAuth(c)
+			return
+		}
+
+		subject := apiKeySubject(apiKey)
+		if !lockout.Check(c, subject) {
+			return
+		}
+
+		principal, err := keys.Authenticate(c.Request.Context(), apiKey)
+		if err != nil {
+			fmt.Printf("API key authentication error: %v\n", err)
+			reason := "invalid_api_key"
+			if errors.Is(err, services.ErrExpiredAPIKey) {
+				reason = "expired_api_key"
+			}
+			audit.RecordRequest(c, models.AuditEvent{Type: audit.TypeTokenRejected, Outcome: audit.OutcomeFailure, Reason: reason})
+			lockout.Failure(c, subject)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired API key"})
+			return
+		}
+
+		lockout.Success(c, subject)
+
+		if !principal.HasRole(requiredRole) {
+			denyRole(c, principal, requiredRole)
+			return
+		}
+
+		auth.SetPrincipal(c, principal)
+		c.Next()
+	}
+}
+
+// apiKeySubject identifies a key by its public prefix for failure counting,
+// so guesses at one key's secret lock out that key only.
+func apiKeySubject(key string) string {
+	parts := strings.SplitN(key, "_", 3)
+	if len(parts) != 3 || parts[1] == "" || len(parts[1]) > 16 {
+		return ""
+	}
+	return "apikey-prefix:" + parts[1]
+}
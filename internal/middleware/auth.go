@@ -2,93 +2,174 @@ package middleware
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
-	"net/url"
-	"os"
 	"strings"
-	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v4"
-	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/paumarro/apollo-be/internal/audit"
+	"github.com/paumarro/apollo-be/internal/auth"
+	"github.com/paumarro/apollo-be/internal/lockout"
+	"github.com/paumarro/apollo-be/internal/models"
+	"github.com/paumarro/apollo-be/internal/sessions"
 )
 
-var (
-	kcDomain           = os.Getenv("KEYCLOAK_DOMAIN")
-	apollobridgeDomain = os.Getenv("APOLLO_DOMAIN")
-	jwksURL            = os.Getenv("JWKS_URL")
-	client_id          = os.Getenv("KEYCLOAK_CLIENT_ID")
-	loginPageUrl       = fmt.Sprintf(
-		"https://%s/realms/apollo/protocol/openid-connect/auth?response_type=code&client_id=%s&redirect_uri=https://%s/auth/callback&scope=openid",
-		kcDomain,
-		client_id,
-		apollobridgeDomain,
-	)
-)
-
-func refreshAccessToken(refreshToken string) (string, string, error) {
-	kcClientSecret := os.Getenv("KEYCLOAK_CLIENT_SECRET")
-	kcClientID := os.Getenv("KEYCLOAK_CLIENT_ID")
-	kcDomain := os.Getenv("KEYCLOAK_DOMAIN")
-
-	tokenURL := fmt.Sprintf("https://%s/realms/apollo/protocol/openid-connect/token", kcDomain)
-
-	data := url.Values{}
-	data.Set("grant_type", "refresh_token")
-	data.Set("refresh_token", refreshToken)
-	data.Set("client_id", kcClientID)
-	data.Set("client_secret", kcClientSecret)
+// Authenticator holds the identity provider settings and token verification
+// used by Auth and AuthOrAPIKey. Exactly one of Verifier and Issuers is set.
+type Authenticator struct {
+	// ClientID selects the resource_access roles in single-issuer mode.
+	ClientID string
+	// OIDC builds login redirects and refreshes cookie-held tokens.
+	OIDC *auth.OIDCClient
+	// CookieDomain is the Domain attribute of the token and session cookies.
+	CookieDomain string
+
+	Verifier auth.Verifier
+	// Issuers accepts tokens of several trusted issuers with per-issuer
+	// settings, replacing Verifier, ClaimMapper and ClientID.
+	Issuers *auth.Registry
+	// ClaimMapper overrides the default Keycloak claim mapping in single-issuer mode.
+	ClaimMapper *auth.ClaimMapper
+	// Services maps client-credentials tokens and client certificates to
+	// service principals.
+	Services *auth.ServiceRegistry
+	// Sessions is set in BFF mode, where the browser holds an opaque session
+	// cookie and tokens stay on the server.
+	Sessions *sessions.Manager
+}
 
-	req, err := http.NewRequest("POST", tokenURL, strings.NewReader(data.Encode()))
-	if err != nil {
-		return "", "", fmt.Errorf("failed to create refresh token request: %v", err)
+// Close stops the background key refresh of the token verifiers.
+func (a *Authenticator) Close() error {
+	var errs []error
+	if c, ok := a.Verifier.(io.Closer); ok {
+		errs = append(errs, c.Close())
 	}
-	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
-
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return "", "", fmt.Errorf("failed to refresh token: %v", err)
+	if a.Issuers != nil {
+		errs = append(errs, a.Issuers.Close())
 	}
-	defer resp.Body.Close()
+	return errors.Join(errs...)
+}
 
-	if resp.StatusCode != http.StatusOK {
-		bodyBytes, _ := io.ReadAll(resp.Body)
-		return "", "", fmt.Errorf("failed to refresh token: %s", string(bodyBytes))
+// KeySets reports the JWKS key sets used to verify tokens.
+func (a *Authenticator) KeySets(ctx context.Context) []auth.KeySetStatus {
+	if a.Issuers != nil {
+		return a.Issuers.KeySets(ctx)
 	}
-
-	var tokenResponse map[string]interface{}
-	if err := json.NewDecoder(resp.Body).Decode(&tokenResponse); err != nil {
-		return "", "", fmt.Errorf("failed to decode refresh token response: %v", err)
+	if r, ok := a.Verifier.(auth.KeySetReporter); ok {
+		return r.KeySets(ctx)
 	}
+	return nil
+}
 
-	accessToken, ok := tokenResponse["access_token"].(string)
+// authenticate verifies a token and maps it to a principal. Service-account
+// tokens of a registered service client become that service's principal,
+// bound to the galleries its issuer may grant.
+func (a *Authenticator) authenticate(ctx context.Context, token string) (*auth.Principal, error) {
+	principal, err := a.tokenPrincipal(ctx, token)
+	if err != nil || a.Services == nil {
+		return principal, err
+	}
+	service, ok, err := a.Services.ForToken(principal)
+	if err != nil {
+		return nil, err
+	}
 	if !ok {
-		return "", "", fmt.Errorf("invalid refresh token response: missing access token")
+		return principal, nil
+	}
+	if a.Issuers != nil {
+		a.Issuers.Restrict(service)
 	}
+	return service, nil
+}
 
-	newRefreshToken, ok := tokenResponse["refresh_token"].(string)
-	if !ok {
-		return "", "", fmt.Errorf("invalid refresh token response: missing refresh token")
+// Principal verifies a token and maps it to the principal a request
+// bearing it would have.
+func (a *Authenticator) Principal(ctx context.Context, token string) (*auth.Principal, error) {
+	return a.authenticate(ctx, token)
+}
+
+func (a *Authenticator) tokenPrincipal(ctx context.Context, token string) (*auth.Principal, error) {
+	if a.Issuers != nil {
+		return a.Issuers.Authenticate(ctx, token)
+	}
+	claims, err := a.Verifier.Verify(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	if a.ClaimMapper != nil {
+		return a.ClaimMapper.Principal(claims), nil
 	}
+	return auth.PrincipalFromClaims(claims, a.ClientID), nil
+}
 
-	return accessToken, newRefreshToken, nil
+// certificatePrincipal maps a verified TLS client certificate to a service.
+// Unverified certificates are ignored; the TLS server must request and
+// verify them against the configured client CAs.
+func (a *Authenticator) certificatePrincipal(c *gin.Context) (*auth.Principal, bool) {
+	if a.Services == nil || c.Request.TLS == nil || len(c.Request.TLS.VerifiedChains) == 0 {
+		return nil, false
+	}
+	chain := c.Request.TLS.VerifiedChains[0]
+	if len(chain) == 0 {
+		return nil, false
+	}
+	return a.Services.ForCertificate(chain[0])
 }
 
-func Auth(requiredRole string, clientID string) gin.HandlerFunc {
+// Auth authenticates the request with a client certificate, session cookie,
+// bearer token or access_token cookie and requires requiredRole, which may
+// be empty to accept any authenticated caller.
+func (a *Authenticator) Auth(requiredRole string) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		// Services presenting a verified client certificate need no token
+		if principal, ok := a.certificatePrincipal(c); ok {
+			if !principal.HasRole(requiredRole) {
+				denyRole(c, principal, requiredRole)
+				return
+			}
+			auth.SetPrincipal(c, principal)
+			c.Next()
+			return
+		}
+
 		authHeader := c.GetHeader("Authorization")
 
+		// In BFF mode the session cookie resolves to a server-side access token,
+		// refreshed by the session manager when needed
+		fromSession := false
+		if authHeader == "" && a.Sessions != nil {
+			if sessionID, err := c.Cookie(sessions.CookieName); err == nil && sessionID != "" {
+				accessToken, err := a.Sessions.AccessToken(c.Request.Context(), sessionID)
+				if err != nil && !errors.Is(err, sessions.ErrNotFound) {
+					// The session may still be good once the identity provider
+					// or store recovers, so the cookie stays
+					fmt.Println("Error resolving session:", err)
+					c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "Session temporarily unavailable"})
+					return
+				}
+				if err != nil {
+					fmt.Println("Error resolving session:", err)
+					audit.RecordRequest(c, models.AuditEvent{Type: audit.TypeTokenRejected, Outcome: audit.OutcomeFailure, Reason: "session_invalid"})
+					c.SetCookie(sessions.CookieName, "", -1, "/", a.CookieDomain, true, true)
+					a.redirectToLogin(c, c.Request.URL.String(), "session_invalid")
+					return
+				}
+				authHeader = "Bearer " + accessToken
+				c.Request.Header.Set("Authorization", authHeader)
+				fromSession = true
+			}
+		}
+
 		// If Authorization header is missing, try to set it from the cookie
 		if authHeader == "" {
 			accessToken, err := c.Cookie("access_token")
 			if err != nil {
 				fmt.Println("Error fetching access_token cookie:", err)
 				originalURL := c.Request.URL.String()
-				redirectToLogin(c, originalURL)
+				a.redirectToLogin(c, originalURL, "missing_credentials")
 				return
 			}
 
@@ -98,131 +179,133 @@ func Auth(requiredRole string, clientID string) gin.HandlerFunc {
 
 		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
 
-		// Mitigation: Validate length of token to avoid excessive memory allocation
-		if len(tokenString) > 2024 {
+		// Mitigation: Validate length of token to avoid excessive memory allocation.
+		// Session tokens come from our own store and are exempt.
+		if !fromSession && len(tokenString) > 2024 {
+			if !lockout.Check(c, "") {
+				return
+			}
+			audit.RecordRequest(c, models.AuditEvent{Type: audit.TypeTokenRejected, Outcome: audit.OutcomeFailure, Reason: "token_too_large"})
+			lockout.Failure(c, "")
 			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "JWT too large"})
 			return
 		}
 
-		// Parse the token
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			if token.Method != jwt.SigningMethodRS256 {
-				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		// Clients that keep presenting bad tokens are locked out before
+		// verification. Only by IP: the sub of an unverified token may name anyone.
+		if !lockout.Check(c, "") {
+			return
+		}
+
+		// Verify the token
+		principal, err := a.authenticate(c.Request.Context(), tokenString)
+
+		if errors.Is(err, auth.ErrTokenExpired) && !fromSession {
+			fmt.Println("Access token expired, attempting to refresh")
+
+			// Fetch the refresh token
+			refreshToken, cookieErr := c.Cookie("refresh_token")
+			if cookieErr != nil {
+				fmt.Println("Error fetching refresh_token cookie:", cookieErr)
+				a.redirectToLogin(c, c.Request.URL.String(), "expired_without_refresh_token")
+				return
 			}
-			return getKeycloakPublicKey(token)
-		})
-
-		if err != nil || !token.Valid {
-			// Check if the error is due to token expiry
-			if claims, ok := token.Claims.(jwt.MapClaims); ok {
-				if exp, ok := claims["exp"].(float64); ok && int64(exp) < time.Now().Unix() {
-					fmt.Println("Access token expired, attempting to refresh")
-
-					// Fetch the refresh token
-					refreshToken, err := c.Cookie("refresh_token")
-					if err != nil {
-						fmt.Println("Error fetching refresh_token cookie:", err)
-						redirectToLogin(c, c.Request.URL.String())
-						return
-					}
-
-					// Attempt to refresh the token
-					newAccessToken, newRefreshToken, err := refreshAccessToken(refreshToken)
-					if err != nil {
-						fmt.Println("Failed to refresh token:", err)
-						redirectToLogin(c, c.Request.URL.String())
-						return
-					}
-
-					// Update cookies with the new tokens
-					c.SetCookie("access_token", newAccessToken, 3600, "/", apollobridgeDomain, true, true)
-					c.SetCookie("refresh_token", newRefreshToken, 3600*24, "/", apollobridgeDomain, true, true)
-
-					// Retry the request with the new access token
-					c.Request.Header.Set("Authorization", "Bearer "+newAccessToken)
-					c.Next()
-					return
-				}
+
+			// Attempt to refresh the token
+			newAccessToken, newRefreshToken, refreshErr := a.OIDC.Refresh(refreshToken)
+			if refreshErr != nil {
+				fmt.Println("Failed to refresh token:", refreshErr)
+				audit.RecordRequest(c, models.AuditEvent{Type: audit.TypeTokenRefresh, Outcome: audit.OutcomeFailure, Reason: "refresh_failed"})
+				a.redirectToLogin(c, c.Request.URL.String(), "refresh_failed")
+				return
+			}
+
+			// Update cookies with the new tokens
+			c.SetSameSite(http.SameSiteLaxMode)
+			c.SetCookie("access_token", newAccessToken, 3600, "/", a.CookieDomain, true, true)
+			c.SetCookie("refresh_token", newRefreshToken, 3600*24, "/", a.CookieDomain, true, true)
+
+			// Retry the request with the new access token
+			c.Request.Header.Set("Authorization", "Bearer "+newAccessToken)
+			principal, err = a.authenticate(c.Request.Context(), newAccessToken)
+			if err == nil {
+				audit.RecordRequest(c, models.AuditEvent{Type: audit.TypeTokenRefresh, Outcome: audit.OutcomeSuccess, Actor: principal.Subject, ActorKind: principal.Kind, Tenant: principal.Tenant})
 			}
+		}
 
+		if err != nil {
 			// Other token errors
-			fmt.Printf("Token parsing error: %v\n", err)
+			fmt.Printf("Token verification error: %v\n", err)
+			audit.RecordRequest(c, models.AuditEvent{Type: audit.TypeTokenRejected, Outcome: audit.OutcomeFailure, Reason: rejectionReason(err)})
+			lockout.Failure(c, failureSubject(tokenString, err))
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
 			return
 		}
 
-		if !hasRole(token, requiredRole, clientID) {
-			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+		if !lockout.Check(c, principal.Subject) {
 			return
 		}
+		lockout.Success(c, principal.Subject)
 
-		c.Next()
-	}
-}
-
-func hasRole(token *jwt.Token, requiredRole string, clientID string) bool {
-	claims, ok := token.Claims.(jwt.MapClaims)
-	if requiredRole == "" {
-		return true
-	}
-	if !ok {
-		fmt.Println("Failed to parse claims")
-		return false
-	}
-
-	resourceAccess, ok := claims["resource_access"].(map[string]interface{})
-	if !ok {
-		fmt.Println("Failed to extract resource_access")
-		return false
-	}
-
-	clientRoles, ok := resourceAccess[clientID].(map[string]interface{})["roles"].([]interface{})
-	if !ok {
-		fmt.Println("Failed to extract client roles")
-		return false
-	}
+		if !principal.HasRole(requiredRole) {
+			denyRole(c, principal, requiredRole)
+			return
+		}
 
-	fmt.Println("Client roles found:", clientRoles)
+		auth.SetPrincipal(c, principal)
 
-	for _, role := range clientRoles {
-		if role == requiredRole {
-			fmt.Printf("User has client role %s\n", requiredRole)
-			return true
-		}
+		c.Next()
 	}
-	return false
 }
 
-func redirectToLogin(c *gin.Context, originalURL string) {
-	loginURL := fmt.Sprintf("%s&state=%s", loginPageUrl, url.QueryEscape(originalURL))
-	c.Redirect(http.StatusFound, loginURL)
-	c.Abort()
+// denyRole audits a failed role check and responds with 403.
+func denyRole(c *gin.Context, principal *auth.Principal, requiredRole string) {
+	audit.RecordRequest(c, models.AuditEvent{
+		Type:      audit.TypeAccessDenied,
+		Outcome:   audit.OutcomeDenied,
+		Actor:     principal.Subject,
+		ActorKind: principal.Kind,
+		Tenant:    principal.Tenant,
+		Reason:    "missing_role",
+		Details:   map[string]string{"required_role": requiredRole},
+	})
+	c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
 }
 
-func getKeycloakPublicKey(token *jwt.Token) (interface{}, error) {
-	// Extract the "kid" from the token header
-	kid, ok := token.Header["kid"].(string)
-	if !ok {
-		return nil, fmt.Errorf("missing kid in token header")
+// failureSubject returns the subject a rejected token's failure counts
+// towards, if any. Only expired tokens have passed signature verification;
+// the sub of any other rejected token may be forged, so it only counts
+// towards the client IP. Opaque tokens have no readable subject.
+func failureSubject(token string, err error) string {
+	if !errors.Is(err, auth.ErrTokenExpired) {
+		return ""
 	}
-
-	// Fetch the JWKS
-	set, err := jwk.Fetch(context.Background(), jwksURL)
-	if err != nil {
-		return nil, fmt.Errorf("failed to fetch JWK set: %v", err)
-	}
-
-	// Find the key with the matching "kid"
-	keys, _ := set.LookupKeyID(kid)
-	if keys == nil {
-		return nil, fmt.Errorf("no matching key found for kid: %s", kid)
+	claims := jwt.MapClaims{}
+	if _, _, err := jwt.NewParser().ParseUnverified(token, claims); err != nil {
+		return ""
 	}
+	sub, _ := claims["sub"].(string)
+	return sub
+}
 
-	// Extract the public key
-	var pubKey interface{}
-	if err := keys.Raw(&pubKey); err != nil {
-		return nil, fmt.Errorf("failed to extract public key: %v", err)
+// rejectionReason classifies a verification error for the audit trail.
+func rejectionReason(err error) string {
+	switch {
+	case errors.Is(err, auth.ErrTokenExpired):
+		return "expired"
+	case errors.Is(err, auth.ErrTokenInactive):
+		return "inactive"
+	case errors.Is(err, auth.ErrUnknownIssuer):
+		return "unknown_issuer"
+	case errors.Is(err, auth.ErrNotServiceAccount):
+		return "not_service_account"
+	default:
+		return "invalid"
 	}
+}
 
-	return pubKey, nil
+func (a *Authenticator) redirectToLogin(c *gin.Context, originalURL string, reason string) {
+	audit.RecordRequest(c, models.AuditEvent{Type: audit.TypeLoginRedirect, Outcome: audit.OutcomeFailure, Reason: reason})
+	c.Redirect(http.StatusFound, a.OIDC.LoginURL(originalURL))
+	c.Abort()
 }
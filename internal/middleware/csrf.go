@@ -0,0 +1,118 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/paumarro/apollo-be/internal/sessions"
+)
+
+const (
+	// CSRFCookie holds the double-submit token. It is readable by scripts so
+	// the frontend can echo it in CSRFHeader.
+	CSRFCookie = "csrf_token"
+	CSRFHeader = "X-CSRF-Token"
+)
+
+// CSRF protects cookie-authenticated state-changing requests.
+//   - Safe methods get a csrf_token cookie (SameSite=Strict) if missing
+//   - Requests with an explicit bearer token or API key are exempt
+//   - Requests without session cookies are exempt; Auth rejects them anyway
+//   - Otherwise Sec-Fetch-Site must not be cross-site, Origin/Referer must be
+//     trusted and the X-CSRF-Token header must match the csrf_token cookie
+//
+// Must run before Auth, which copies the access_token cookie into the
+// Authorization header. cookieDomain is the Domain of the csrf_token cookie.
+func CSRF(trustedOrigins []string, cookieDomain string) gin.HandlerFunc {
+	trusted := make(map[string]bool, len(trustedOrigins))
+	for _, o := range trustedOrigins {
+		if origin := normalizeOrigin(o); origin != "" {
+			trusted[origin] = true
+		}
+	}
+
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			ensureCSRFCookie(c, cookieDomain)
+			c.Next()
+			return
+		}
+
+		if !usesCookieCredentials(c) {
+			c.Next()
+			return
+		}
+
+		if c.GetHeader("Sec-Fetch-Site") == "cross-site" {
+			errorResponse(c, http.StatusForbidden, "csrf_failed", "Cross-site request rejected", nil)
+			return
+		}
+
+		origin := c.GetHeader("Origin")
+		if origin == "" {
+			origin = c.GetHeader("Referer")
+		}
+		if origin != "" && !trusted[normalizeOrigin(origin)] {
+			errorResponse(c, http.StatusForbidden, "csrf_failed", "Request origin not allowed", nil)
+			return
+		}
+
+		cookieToken, err := c.Cookie(CSRFCookie)
+		headerToken := c.GetHeader(CSRFHeader)
+		if err != nil || cookieToken == "" || headerToken == "" ||
+			subtle.ConstantTimeCompare([]byte(cookieToken), []byte(headerToken)) != 1 {
+			errorResponse(c, http.StatusForbidden, "csrf_failed", "Missing or invalid CSRF token", nil)
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// usesCookieCredentials reports whether the request would be authenticated by
+// browser-attached cookies rather than explicit credentials.
+func usesCookieCredentials(c *gin.Context) bool {
+	if strings.HasPrefix(c.GetHeader("Authorization"), "Bearer ") || c.GetHeader(APIKeyHeader) != "" {
+		return false
+	}
+	for _, name := range []string{"access_token", "refresh_token", sessions.CookieName} {
+		if _, err := c.Cookie(name); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+func ensureCSRFCookie(c *gin.Context, cookieDomain string) {
+	if token, err := c.Cookie(CSRFCookie); err == nil && token != "" {
+		return
+	}
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return
+	}
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     CSRFCookie,
+		Value:    base64.RawURLEncoding.EncodeToString(b),
+		Path:     "/",
+		Domain:   cookieDomain,
+		Secure:   true,
+		HttpOnly: false,
+		SameSite: http.SameSiteStrictMode,
+	})
+}
+
+// normalizeOrigin reduces an Origin or Referer value to scheme://host[:port].
+func normalizeOrigin(raw string) string {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return ""
+	}
+	return strings.ToLower(u.Scheme + "://" + u.Host)
+}
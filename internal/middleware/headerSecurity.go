@@ -31,7 +31,7 @@ func SecurityHeaders() gin.HandlerFunc {
 		// 8. Cross-Origin Resource Sharing (CORS) Headers
 		// c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
 		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-API-Key, X-CSRF-Token")
 
 		// Proceed to the next middleware or handler
 		c.Next()
@@ -0,0 +1,84 @@
+package middleware
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/paumarro/apollo-be/internal/auth"
+	"github.com/paumarro/apollo-be/internal/dbrouter"
+)
+
+// WroteCookie carries the signed time of the client's last write.
+const WroteCookie = "apollo_wrote"
+
+// WroteHeader carries the same value for clients that keep no cookies. It
+// is set on responses to writes and read from requests.
+const WroteHeader = "X-Apollo-Wrote"
+
+// ReadYourWrites tags the request context with the time the authenticated
+// principal last wrote, as carried by the client, so its reads go to the
+// primary database until replicas have caught up, whichever server instance
+// serves them. Writes in the request hand the client a new time, signed with
+// key, which every instance must share. It belongs after the authentication
+// middleware.
+func ReadYourWrites(key []byte, window time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := auth.PrincipalFrom(c)
+		if !ok || principal.Subject == "" || len(key) == 0 {
+			c.Next()
+			return
+		}
+
+		session := &dbrouter.Session{OnWrite: func(at time.Time) {
+			value := signWrite(key, principal.Subject, at)
+			c.Header(WroteHeader, value)
+			c.SetSameSite(http.SameSiteLaxMode)
+			c.SetCookie(WroteCookie, value, int(math.Ceil(window.Seconds())), "/", "", true, true)
+		}}
+		value := c.GetHeader(WroteHeader)
+		if value == "" {
+			value, _ = c.Cookie(WroteCookie)
+		}
+		if at, ok := verifyWrite(key, principal.Subject, value); ok {
+			session.LastWrite = at
+		}
+		c.Request = c.Request.WithContext(dbrouter.WithSession(c.Request.Context(), session))
+		c.Next()
+	}
+}
+
+// signWrite binds a write time to the subject, so clients can neither pin
+// their reads to the primary nor borrow another principal's window.
+func signWrite(key []byte, subject string, at time.Time) string {
+	millis := strconv.FormatInt(at.UnixMilli(), 10)
+	return millis + "." + base64.RawURLEncoding.EncodeToString(writeMAC(key, subject, millis))
+}
+
+func verifyWrite(key []byte, subject, value string) (time.Time, bool) {
+	millis, sig, ok := strings.Cut(value, ".")
+	if !ok {
+		return time.Time{}, false
+	}
+	ms, err := strconv.ParseInt(millis, 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil || !hmac.Equal(mac, writeMAC(key, subject, millis)) {
+		return time.Time{}, false
+	}
+	return time.UnixMilli(ms), true
+}
+
+func writeMAC(key []byte, subject, millis string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(subject + "\n" + millis))
+	return h.Sum(nil)
+}
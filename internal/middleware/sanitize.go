@@ -53,6 +53,8 @@ func Sanitize() gin.HandlerFunc {
 		req.Artist = normalizeString(req.Artist)
 		req.Description = normalizeString(req.Description)
 		req.Image = normalizeString(req.Image)
+		req.Gallery = normalizeString(req.Gallery)
+		req.Status = normalizeString(req.Status)
 
 		// Re-encode normalized JSON back into the request body for any downstream binders (optional)
 		buf, _ := json.Marshal(req)
@@ -141,6 +141,9 @@ func validateRequestBody(c *gin.Context) map[string]string {
 	if containsControlChars(req.Image, false) {
 		return map[string]string{"image": "image contains invalid control characters"}
 	}
+	if containsControlChars(req.Gallery, false) {
+		return map[string]string{"gallery": "gallery contains invalid control characters"}
+	}
 
 	return nil
 }
@@ -183,6 +186,8 @@ func formatValidationErrors(err error) map[string]string {
 				errors[field] = field + " is below the minimum required length."
 			case "url":
 				errors[field] = field + " must be a valid URL."
+			case "oneof":
+				errors[field] = field + " must be one of: " + fieldErr.Param() + "."
 			default:
 				errors[field] = field + " has an invalid value."
 			}
@@ -0,0 +1,38 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"log"
+)
+
+// AssignGallery gives artworks without a gallery, which were created before
+// artworks had one, the given gallery, so the members of that gallery can
+// manage them. Without a gallery it only warns about such artworks, since
+// policies grant nobody write access to them. It returns how many artworks
+// it assigned.
+func AssignGallery(ctx context.Context, db *sql.DB, gallery string) (int64, error) {
+	if gallery == "" {
+		var n int64
+		if err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM artworks WHERE gallery IS NULL OR gallery = ''").Scan(&n); err != nil {
+			return 0, err
+		}
+		if n > 0 {
+			log.Printf("WARNING: %d artworks have no gallery and cannot be edited; set DB_DEFAULT_GALLERY to assign them one", n)
+		}
+		return 0, nil
+	}
+
+	res, err := db.ExecContext(ctx, "UPDATE artworks SET gallery = $1 WHERE gallery IS NULL OR gallery = ''", gallery)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	if n > 0 {
+		log.Printf("Assigned %d artworks without a gallery to %q", n, gallery)
+	}
+	return n, nil
+}
@@ -0,0 +1,142 @@
+package migrate
+
+import (
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Dir holds a directory of migration files per dialect, relative to the
+// repository root. Every dialect has the same versions.
+const Dir = "internal/migrate/sql"
+
+// Dialects are the GORM dialector names with migrations.
+var Dialects = []string{"postgres", "sqlite"}
+
+//go:embed sql/postgres/*.sql sql/sqlite/*.sql
+var embedded embed.FS
+
+// Migration is a pair of SQL scripts that move the schema one version up or
+// back down. Checksum covers Up, so edits to applied migrations are caught.
+type Migration struct {
+	Version  int64
+	Name     string
+	Up       string
+	Down     string
+	Checksum string
+}
+
+var fileName = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+// Embedded returns the migrations of dialect compiled into the binary.
+func Embedded(dialect string) ([]Migration, error) {
+	sub, err := fs.Sub(embedded, "sql/"+dialect)
+	if err != nil {
+		return nil, err
+	}
+	migrations, err := Load(sub)
+	if err == nil && len(migrations) == 0 {
+		return nil, fmt.Errorf("no migrations for database dialect %q", dialect)
+	}
+	return migrations, err
+}
+
+// Load reads <version>_<name>.up.sql and .down.sql files from the root of
+// fsys, ordered by version. Every version needs an up script; a missing down
+// script makes the migration irreversible.
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %w", err)
+	}
+
+	byVersion := map[int64]*Migration{}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".sql") {
+			continue
+		}
+		match := fileName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("invalid migration file name %q, want <version>_<name>.up.sql or .down.sql", entry.Name())
+		}
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version in %q: %w", entry.Name(), err)
+		}
+		content, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %w", entry.Name(), err)
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		} else if m.Name != match[2] {
+			return nil, fmt.Errorf("migration version %d is used by %q and %q", version, m.Name, match[2])
+		}
+		if match[3] == "up" {
+			m.Up = string(content)
+		} else {
+			m.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %04d_%s has no up script", m.Version, m.Name)
+		}
+		sum := sha256.Sum256([]byte(m.Up))
+		m.Checksum = hex.EncodeToString(sum[:])
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Create writes empty up and down scripts for the next version in dir and
+// returns their paths.
+func Create(dir, name string) (string, string, error) {
+	name = strings.ToLower(strings.Join(strings.Fields(name), "_"))
+	if !regexp.MustCompile(`^[a-z0-9_]+$`).MatchString(name) {
+		return "", "", fmt.Errorf("migration name %q may only contain letters, digits and underscores", name)
+	}
+	existing, err := Load(os.DirFS(dir))
+	if err != nil {
+		return "", "", err
+	}
+	var version int64 = 1
+	if len(existing) > 0 {
+		version = existing[len(existing)-1].Version + 1
+	}
+
+	base := filepath.Join(dir, fmt.Sprintf("%04d_%s", version, name))
+	up, down := base+".up.sql", base+".down.sql"
+	for path, header := range map[string]string{
+		up:   "-- Migration " + name + ": describe the schema change here.\n",
+		down: "-- Revert migration " + name + ". Delete this file if it cannot be undone.\n",
+	} {
+		// O_EXCL keeps a concurrent create from overwriting a script
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644) // #nosec G302 G304 -- source files in the repository
+		if err != nil {
+			return "", "", err
+		}
+		_, err = f.WriteString(header)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return "", "", err
+		}
+	}
+	return up, down, nil
+}
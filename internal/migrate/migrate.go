@@ -1,20 +1,294 @@
-package main
+// Package migrate applies the versioned SQL migrations in sql/<dialect> and
+// records them in the schema_migrations table. On Postgres an advisory lock
+// serialises replicas migrating at the same time.
+package migrate
 
 import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
 	"log"
+	"sort"
+	"time"
+)
+
+// Table records the applied migrations.
+const Table = "schema_migrations"
+
+// lockKey identifies the migration advisory lock among others on the server.
+const lockKey int64 = 0x61706f6c6c6f // "apollo"
+
+// ErrChecksumMismatch reports an applied migration whose up script changed.
+var ErrChecksumMismatch = errors.New("applied migration was modified")
+
+// Locker serialises migrations across processes.
+type Locker interface {
+	Lock(ctx context.Context, conn *sql.Conn) error
+	Unlock(ctx context.Context, conn *sql.Conn) error
+}
+
+// AdvisoryLock holds a Postgres session-level advisory lock for the duration
+// of a migration run. Replicas starting together wait for each other.
+type AdvisoryLock struct{}
+
+func (AdvisoryLock) Lock(ctx context.Context, conn *sql.Conn) error {
+	_, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", lockKey)
+	return err
+}
+
+func (AdvisoryLock) Unlock(ctx context.Context, conn *sql.Conn) error {
+	_, err := conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", lockKey)
+	return err
+}
+
+// Migrator applies Migrations to DB.
+type Migrator struct {
+	DB         *sql.DB
+	Migrations []Migration
+	Locker     Locker
+}
 
-	initializers "github.com/paumarro/apollo-be/internal/initializers"
-	"github.com/paumarro/apollo-be/internal/models"
-	env "github.com/paumarro/apollo-be/pkg"
+// New creates a migrator for a Postgres database.
+func New(db *sql.DB, migrations []Migration) *Migrator {
+	return &Migrator{DB: db, Migrations: migrations, Locker: AdvisoryLock{}}
+}
+
+// ForDialect creates a migrator with the embedded migrations of dialect.
+// SQLite needs no advisory lock since its file lock already serialises
+// writers.
+func ForDialect(db *sql.DB, dialect string) (*Migrator, error) {
+	migrations, err := Embedded(dialect)
+	if err != nil {
+		return nil, err
+	}
+	m := New(db, migrations)
+	if dialect == "sqlite" {
+		m.Locker = nil
+	}
+	return m, nil
+}
+
+// State of a migration in Status.
+type State string
+
+const (
+	StateApplied State = "applied"
+	StatePending State = "pending"
+	// StateModified is an applied migration whose up script has changed.
+	StateModified State = "modified"
+	// StateMissing is an applied migration with no file, for example one
+	// applied by a newer release.
+	StateMissing State = "missing"
 )
 
-func init() {
-	env.LoadEnvVariables(".../.../.env")
-	initializers.ConnectToDB()
+// Status describes one migration.
+type Status struct {
+	Version   int64
+	Name      string
+	State     State
+	AppliedAt *time.Time
+}
+
+type applied struct {
+	name      string
+	checksum  string
+	appliedAt time.Time
 }
 
-func main() {
-	if err := initializers.DB.AutoMigrate(&models.Artwork{}); err != nil {
-		log.Fatalf("Failed to migrate database: %v", err)
+// Up applies every pending migration in version order, each in its own
+// transaction, and returns those applied. It refuses to run when an applied
+// migration was modified.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	var done []Migration
+	err := m.locked(ctx, func(conn *sql.Conn) error {
+		history, err := m.history(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, migration := range m.Migrations {
+			record, ok := history[migration.Version]
+			if ok && record.checksum != migration.Checksum {
+				return fmt.Errorf("%w: %04d_%s", ErrChecksumMismatch, migration.Version, migration.Name)
+			}
+		}
+		for _, migration := range m.Migrations {
+			if _, ok := history[migration.Version]; ok {
+				continue
+			}
+			log.Printf("Applying migration %04d_%s", migration.Version, migration.Name)
+			if err := m.apply(ctx, conn, migration.Up, func(tx *sql.Tx) error {
+				_, err := tx.ExecContext(ctx,
+					"INSERT INTO "+Table+" (version, name, checksum, applied_at) VALUES ($1, $2, $3, $4)",
+					migration.Version, migration.Name, migration.Checksum, time.Now().UTC())
+				return err
+			}); err != nil {
+				return fmt.Errorf("migration %04d_%s failed: %w", migration.Version, migration.Name, err)
+			}
+			done = append(done, migration)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Down reverts the latest steps applied migrations, newest first, and
+// returns those reverted.
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	byVersion := make(map[int64]Migration, len(m.Migrations))
+	for _, migration := range m.Migrations {
+		byVersion[migration.Version] = migration
+	}
+
+	var done []Migration
+	err := m.locked(ctx, func(conn *sql.Conn) error {
+		versions, err := m.appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for i := 0; i < steps && i < len(versions); i++ {
+			version := versions[len(versions)-1-i]
+			migration, ok := byVersion[version]
+			if !ok {
+				return fmt.Errorf("applied migration %d has no file to revert it", version)
+			}
+			if migration.Down == "" {
+				return fmt.Errorf("migration %04d_%s is irreversible", migration.Version, migration.Name)
+			}
+			log.Printf("Reverting migration %04d_%s", migration.Version, migration.Name)
+			if err := m.apply(ctx, conn, migration.Down, func(tx *sql.Tx) error {
+				_, err := tx.ExecContext(ctx, "DELETE FROM "+Table+" WHERE version = $1", migration.Version)
+				return err
+			}); err != nil {
+				return fmt.Errorf("reverting migration %04d_%s failed: %w", migration.Version, migration.Name, err)
+			}
+			done = append(done, migration)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Status lists every known and applied migration in version order.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	var statuses []Status
+	err := m.locked(ctx, func(conn *sql.Conn) error {
+		history, err := m.history(ctx, conn)
+		if err != nil {
+			return err
+		}
+		known := make(map[int64]bool, len(m.Migrations))
+		for _, migration := range m.Migrations {
+			known[migration.Version] = true
+			status := Status{Version: migration.Version, Name: migration.Name, State: StatePending}
+			if record, ok := history[migration.Version]; ok {
+				appliedAt := record.appliedAt
+				status.AppliedAt = &appliedAt
+				status.State = StateApplied
+				if record.checksum != migration.Checksum {
+					status.State = StateModified
+				}
+			}
+			statuses = append(statuses, status)
+		}
+		versions, err := m.appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, version := range versions {
+			if !known[version] {
+				record := history[version]
+				statuses = append(statuses, Status{Version: version, Name: record.name, State: StateMissing, AppliedAt: &record.appliedAt})
+			}
+		}
+		return nil
+	})
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Version < statuses[j].Version })
+	return statuses, err
+}
+
+// locked runs fn on a single connection holding the migration lock, after
+// creating the migrations table if needed.
+func (m *Migrator) locked(ctx context.Context, fn func(conn *sql.Conn) error) (err error) {
+	conn, err := m.DB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if m.Locker != nil {
+		if err := m.Locker.Lock(ctx, conn); err != nil {
+			return fmt.Errorf("failed to acquire migration lock: %w", err)
+		}
+		defer func() {
+			// Unlock even if ctx was cancelled mid-run
+			if unlockErr := m.Locker.Unlock(context.Background(), conn); unlockErr != nil {
+				err = errors.Join(err, fmt.Errorf("failed to release migration lock: %w", unlockErr))
+			}
+		}()
+	}
+
+	if _, err := conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS `+Table+` (
+		version BIGINT PRIMARY KEY,
+		name TEXT NOT NULL,
+		checksum TEXT NOT NULL,
+		applied_at TIMESTAMP NOT NULL
+	)`); err != nil {
+		return fmt.Errorf("failed to create %s: %w", Table, err)
+	}
+	return fn(conn)
+}
+
+// apply runs script and record in one transaction.
+func (m *Migrator) apply(ctx context.Context, conn *sql.Conn, script string, record func(tx *sql.Tx) error) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	if err := record(tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func (m *Migrator) history(ctx context.Context, conn *sql.Conn) (map[int64]applied, error) {
+	rows, err := conn.QueryContext(ctx, "SELECT version, name, checksum, applied_at FROM "+Table)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", Table, err)
+	}
+	defer rows.Close()
+
+	history := map[int64]applied{}
+	for rows.Next() {
+		var version int64
+		var record applied
+		if err := rows.Scan(&version, &record.name, &record.checksum, &record.appliedAt); err != nil {
+			return nil, err
+		}
+		history[version] = record
+	}
+	return history, rows.Err()
+}
+
+func (m *Migrator) appliedVersions(ctx context.Context, conn *sql.Conn) ([]int64, error) {
+	rows, err := conn.QueryContext(ctx, "SELECT version FROM "+Table+" ORDER BY version")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", Table, err)
+	}
+	defer rows.Close()
+
+	var versions []int64
+	for rows.Next() {
+		var version int64
+		if err := rows.Scan(&version); err != nil {
+			return nil, err
+		}
+		versions = append(versions, version)
 	}
+	return versions, rows.Err()
 }
@@ -0,0 +1,14 @@
+-- Baseline: the artworks table as GORM AutoMigrate created it before
+-- versioned migrations. IF NOT EXISTS adopts databases it already set up.
+
+CREATE TABLE IF NOT EXISTS artworks (
+    id          BIGSERIAL PRIMARY KEY,
+    title       TEXT,
+    artist      TEXT,
+    description TEXT,
+    image       TEXT,
+    created_at  TIMESTAMPTZ,
+    updated_at  TIMESTAMPTZ,
+    deleted_at  TIMESTAMPTZ
+);
+CREATE INDEX IF NOT EXISTS idx_artworks_deleted_at ON artworks (deleted_at);
@@ -0,0 +1,8 @@
+DROP TABLE IF EXISTS audit_events;
+DROP TABLE IF EXISTS sessions;
+DROP TABLE IF EXISTS api_keys;
+DROP INDEX IF EXISTS idx_artworks_gallery;
+ALTER TABLE artworks DROP COLUMN IF EXISTS updated_by;
+ALTER TABLE artworks DROP COLUMN IF EXISTS created_by;
+ALTER TABLE artworks DROP COLUMN IF EXISTS status;
+ALTER TABLE artworks DROP COLUMN IF EXISTS gallery;
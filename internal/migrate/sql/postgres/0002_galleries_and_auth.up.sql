@@ -0,0 +1,70 @@
+-- Galleries and authorship on artworks, and the tables for API keys,
+-- sessions and the audit trail. IF NOT EXISTS adopts databases that
+-- AutoMigrate set up between the baseline and versioned migrations.
+
+ALTER TABLE artworks ADD COLUMN IF NOT EXISTS gallery TEXT;
+ALTER TABLE artworks ADD COLUMN IF NOT EXISTS status TEXT DEFAULT 'published';
+ALTER TABLE artworks ADD COLUMN IF NOT EXISTS created_by TEXT;
+ALTER TABLE artworks ADD COLUMN IF NOT EXISTS updated_by TEXT;
+CREATE INDEX IF NOT EXISTS idx_artworks_gallery ON artworks (gallery);
+
+CREATE TABLE IF NOT EXISTS api_keys (
+    id           BIGSERIAL PRIMARY KEY,
+    name         TEXT,
+    prefix       TEXT,
+    hash         TEXT,
+    gallery      TEXT,
+    roles        TEXT,
+    created_by   TEXT,
+    expires_at   TIMESTAMPTZ,
+    last_used_at TIMESTAMPTZ,
+    created_at   TIMESTAMPTZ,
+    updated_at   TIMESTAMPTZ,
+    deleted_at   TIMESTAMPTZ
+);
+CREATE INDEX IF NOT EXISTS idx_api_keys_prefix ON api_keys (prefix);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_api_keys_hash ON api_keys (hash);
+CREATE INDEX IF NOT EXISTS idx_api_keys_gallery ON api_keys (gallery);
+CREATE INDEX IF NOT EXISTS idx_api_keys_deleted_at ON api_keys (deleted_at);
+
+CREATE TABLE IF NOT EXISTS sessions (
+    id                VARCHAR(64) PRIMARY KEY,
+    subject           TEXT,
+    name              TEXT,
+    galleries         TEXT,
+    access_token      TEXT,
+    refresh_token     TEXT,
+    access_expires_at TIMESTAMPTZ,
+    expires_at        TIMESTAMPTZ,
+    user_agent        TEXT,
+    ip                TEXT,
+    created_at        TIMESTAMPTZ,
+    last_seen_at      TIMESTAMPTZ
+);
+CREATE INDEX IF NOT EXISTS idx_sessions_subject ON sessions (subject);
+CREATE INDEX IF NOT EXISTS idx_sessions_expires_at ON sessions (expires_at);
+
+CREATE TABLE IF NOT EXISTS audit_events (
+    id          BIGSERIAL PRIMARY KEY,
+    occurred_at TIMESTAMPTZ,
+    type        VARCHAR(64),
+    outcome     VARCHAR(16),
+    actor       TEXT,
+    actor_kind  TEXT,
+    tenant      TEXT,
+    gallery     TEXT,
+    method      TEXT,
+    path        TEXT,
+    ip          TEXT,
+    user_agent  TEXT,
+    reason      TEXT,
+    details     TEXT,
+    prev_hash   VARCHAR(64),
+    hash        VARCHAR(64)
+);
+CREATE INDEX IF NOT EXISTS idx_audit_events_occurred_at ON audit_events (occurred_at);
+CREATE INDEX IF NOT EXISTS idx_audit_events_type ON audit_events (type);
+CREATE INDEX IF NOT EXISTS idx_audit_events_actor ON audit_events (actor);
+CREATE INDEX IF NOT EXISTS idx_audit_events_tenant ON audit_events (tenant);
+CREATE INDEX IF NOT EXISTS idx_audit_events_gallery ON audit_events (gallery);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_audit_events_hash ON audit_events (hash);
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS idx_artworks_gallery_title_artist;
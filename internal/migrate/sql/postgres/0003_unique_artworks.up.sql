@@ -0,0 +1,11 @@
+-- Artworks are unique per gallery by title and artist, ignoring case and
+-- surrounding spaces. Deleted artworks do not count. The index cannot be
+-- created while duplicates exist; list them with
+--
+--   SELECT gallery, lower(trim(title)), lower(trim(artist)), count(*)
+--   FROM artworks WHERE deleted_at IS NULL
+--   GROUP BY 1, 2, 3 HAVING count(*) > 1;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_artworks_gallery_title_artist
+    ON artworks (gallery, lower(trim(title)), lower(trim(artist)))
+    WHERE deleted_at IS NULL;
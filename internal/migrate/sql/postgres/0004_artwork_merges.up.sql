@@ -0,0 +1,13 @@
+-- Merged artworks: the retired artwork's last state as JSON, and the artwork
+-- its ID now redirects to.
+
+CREATE TABLE IF NOT EXISTS artwork_merges (
+    id         BIGSERIAL PRIMARY KEY,
+    artwork_id BIGINT NOT NULL,
+    retired_id BIGINT NOT NULL,
+    retired    TEXT,
+    merged_by  TEXT,
+    merged_at  TIMESTAMPTZ
+);
+CREATE INDEX IF NOT EXISTS idx_artwork_merges_artwork_id ON artwork_merges (artwork_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_artwork_merges_retired_id ON artwork_merges (retired_id);
@@ -0,0 +1,14 @@
+-- SQLite version of the baseline schema for local development and tests.
+-- INTEGER PRIMARY KEY makes id an auto-incrementing rowid.
+
+CREATE TABLE IF NOT EXISTS artworks (
+    id          INTEGER PRIMARY KEY AUTOINCREMENT,
+    title       TEXT,
+    artist      TEXT,
+    description TEXT,
+    image       TEXT,
+    created_at  DATETIME,
+    updated_at  DATETIME,
+    deleted_at  DATETIME
+);
+CREATE INDEX IF NOT EXISTS idx_artworks_deleted_at ON artworks (deleted_at);
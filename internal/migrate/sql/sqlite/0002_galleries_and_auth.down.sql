@@ -0,0 +1,8 @@
+DROP TABLE IF EXISTS audit_events;
+DROP TABLE IF EXISTS sessions;
+DROP TABLE IF EXISTS api_keys;
+DROP INDEX IF EXISTS idx_artworks_gallery;
+ALTER TABLE artworks DROP COLUMN updated_by;
+ALTER TABLE artworks DROP COLUMN created_by;
+ALTER TABLE artworks DROP COLUMN status;
+ALTER TABLE artworks DROP COLUMN gallery;
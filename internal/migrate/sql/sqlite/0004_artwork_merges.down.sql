@@ -0,0 +1 @@
+DROP TABLE IF EXISTS artwork_merges;
@@ -0,0 +1,24 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// APIKey is a scoped, expiring credential for machine clients. Only the
+// SHA-256 hash of the key is stored; the plaintext is shown once at creation.
+type APIKey struct {
+	ID         uint           `gorm:"primaryKey" json:"id"`
+	Name       string         `json:"name"`
+	Prefix     string         `gorm:"index" json:"prefix"`
+	Hash       string         `gorm:"uniqueIndex" json:"-"`
+	Gallery    string         `gorm:"index" json:"gallery"`
+	Roles      []string       `gorm:"serializer:json" json:"roles"`
+	CreatedBy  string         `json:"created_by"`
+	ExpiresAt  time.Time      `json:"expires_at"`
+	LastUsedAt *time.Time     `json:"last_used_at"`
+	CreatedAt  time.Time      `json:"created_at"`
+	UpdatedAt  time.Time      `json:"updated_at"`
+	DeletedAt  gorm.DeletedAt `gorm:"index" json:"-"`
+}
@@ -7,12 +7,22 @@ import (
 	"gorm.io/gorm"
 )
 
+// Artwork publication states
+const (
+	StatusDraft     = "draft"
+	StatusPublished = "published"
+)
+
 type Artwork struct {
 	ID          uint           `gorm:"primaryKey" json:"id"`
 	Title       string         `json:"title"`
 	Artist      string         `json:"artist"`
 	Description string         `json:"description"`
 	Image       string         `json:"image"`
+	Gallery     string         `gorm:"index" json:"gallery"`
+	Status      string         `gorm:"default:published" json:"status"`
+	CreatedBy   string         `json:"created_by,omitempty"`
+	UpdatedBy   string         `json:"updated_by,omitempty"`
 	CreatedAt   time.Time      `json:"created_at"`
 	UpdatedAt   time.Time      `json:"updated_at"`
 	DeletedAt   gorm.DeletedAt `gorm:"index" json:"-"`
@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// ArtworkMerge records an artwork retired by merging it into another. The
+// retired artwork stays soft-deleted, its last state is kept in Retired, and
+// requests for RetiredID are redirected to ArtworkID.
+type ArtworkMerge struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	ArtworkID uint      `gorm:"index" json:"artwork_id"`
+	RetiredID uint      `gorm:"uniqueIndex" json:"retired_id"`
+	Retired   Artwork   `gorm:"serializer:json" json:"retired"`
+	MergedBy  string    `json:"merged_by,omitempty"`
+	MergedAt  time.Time `json:"merged_at"`
+}
@@ -0,0 +1,26 @@
+package models
+
+import (
+	"time"
+)
+
+// AuditEvent is a security-relevant event. Events form a hash chain: Hash
+// covers every other field plus PrevHash, so edits and deletions are detectable.
+type AuditEvent struct {
+	ID        uint              `gorm:"primaryKey" json:"id"`
+	Time      time.Time         `gorm:"column:occurred_at;index" json:"time"`
+	Type      string            `gorm:"index;size:64" json:"type"`
+	Outcome   string            `gorm:"size:16" json:"outcome"`
+	Actor     string            `gorm:"index" json:"actor,omitempty"`
+	ActorKind string            `json:"actor_kind,omitempty"`
+	Tenant    string            `gorm:"index" json:"tenant,omitempty"`
+	Gallery   string            `gorm:"index" json:"gallery,omitempty"`
+	Method    string            `json:"method,omitempty"`
+	Path      string            `json:"path,omitempty"`
+	IP        string            `json:"ip,omitempty"`
+	UserAgent string            `json:"user_agent,omitempty"`
+	Reason    string            `json:"reason,omitempty"`
+	Details   map[string]string `gorm:"serializer:json" json:"details,omitempty"`
+	PrevHash  string            `gorm:"size:64" json:"prev_hash"`
+	Hash      string            `gorm:"size:64;uniqueIndex" json:"hash"`
+}
@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+)
+
+// Session is a server-side login session (BFF mode). The browser only holds
+// the opaque session ID; ID here is the SHA-256 hash of that value.
+type Session struct {
+	ID              string    `gorm:"primaryKey;size:64" json:"id"`
+	Subject         string    `gorm:"index" json:"sub"`
+	Name            string    `json:"name,omitempty"`
+	Galleries       []string  `gorm:"serializer:json" json:"galleries"`
+	AccessToken     string    `json:"-"`
+	RefreshToken    string    `json:"-"`
+	AccessExpiresAt time.Time `json:"access_expires_at"`
+	ExpiresAt       time.Time `gorm:"index" json:"expires_at"`
+	UserAgent       string    `json:"user_agent,omitempty"`
+	IP              string    `json:"ip,omitempty"`
+	CreatedAt       time.Time `json:"created_at"`
+	LastSeenAt      time.Time `json:"last_seen_at"`
+}
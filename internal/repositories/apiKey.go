@@ -0,0 +1,73 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/paumarro/apollo-be/internal/models"
+	"gorm.io/gorm"
+)
+
+// APIKeyRepository defines the database operations for API keys. Queries
+// are cancelled when ctx ends.
+type APIKeyRepository interface {
+	Create(ctx context.Context, key *models.APIKey) error
+	FindByHash(ctx context.Context, hash string) (*models.APIKey, error)
+	FindByID(ctx context.Context, id string) (*models.APIKey, error)
+	FindByGalleries(ctx context.Context, galleries []string) ([]models.APIKey, error)
+	TouchLastUsed(ctx context.Context, id uint, at time.Time) error
+	Delete(ctx context.Context, id string) error
+}
+
+// GormAPIKeyRepository is the GORM-based implementation of APIKeyRepository
+type GormAPIKeyRepository struct {
+	DB *gorm.DB
+}
+
+func NewGormAPIKeyRepository(db *gorm.DB) *GormAPIKeyRepository {
+	return &GormAPIKeyRepository{DB: db}
+}
+
+func (r *GormAPIKeyRepository) Create(ctx context.Context, key *models.APIKey) error {
+	return r.DB.WithContext(ctx).Create(key).Error
+}
+
+func (r *GormAPIKeyRepository) FindByHash(ctx context.Context, hash string) (*models.APIKey, error) {
+	var key models.APIKey
+	if err := r.DB.WithContext(ctx).First(&key, "hash = ?", hash).Error; err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+func (r *GormAPIKeyRepository) FindByID(ctx context.Context, id string) (*models.APIKey, error) {
+	var key models.APIKey
+	if err := r.DB.WithContext(ctx).First(&key, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+func (r *GormAPIKeyRepository) FindByGalleries(ctx context.Context, galleries []string) ([]models.APIKey, error) {
+	keys := []models.APIKey{}
+	if len(galleries) == 0 {
+		return keys, nil
+	}
+	err := r.DB.WithContext(ctx).Where("gallery IN ?", galleries).Order("id").Find(&keys).Error
+	return keys, err
+}
+
+func (r *GormAPIKeyRepository) TouchLastUsed(ctx context.Context, id uint, at time.Time) error {
+	return r.DB.WithContext(ctx).Model(&models.APIKey{}).Where("id = ?", id).UpdateColumn("last_used_at", at).Error
+}
+
+func (r *GormAPIKeyRepository) Delete(ctx context.Context, id string) error {
+	res := r.DB.WithContext(ctx).Delete(&models.APIKey{}, "id = ?", id)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
@@ -1,52 +1,126 @@
 package repositories
 
 import (
+	"context"
+
+	"github.com/paumarro/apollo-be/internal/dbrouter"
 	"github.com/paumarro/apollo-be/internal/models"
 	"gorm.io/gorm"
 )
 
-// ArtworkRepository defines the database operations for artworks
+// ArtworkRepository defines the database operations for artworks. Queries
+// are cancelled when ctx ends. Create and Update fail with
+// gorm.ErrDuplicatedKey when another live artwork in the same gallery has the
+// same title and artist, ignoring case and surrounding spaces.
 type ArtworkRepository interface {
-	Create(artwork *models.Artwork) error
-	FindAll() ([]models.Artwork, error)
-	FindByID(id string) (*models.Artwork, error)
-	Update(artwork *models.Artwork) error
-	Delete(id string) error
+	Create(ctx context.Context, artwork *models.Artwork) error
+	FindAll(ctx context.Context) ([]models.Artwork, error)
+	FindByGallery(ctx context.Context, gallery string) ([]models.Artwork, error)
+	FindByID(ctx context.Context, id string) (*models.Artwork, error)
+	FindByIDUnscoped(ctx context.Context, id string) (*models.Artwork, error)
+	FindDuplicate(ctx context.Context, artwork *models.Artwork) (*models.Artwork, error)
+	Update(ctx context.Context, artwork *models.Artwork) error
+	Delete(ctx context.Context, id string) error
+	Purge(ctx context.Context, id string) error
+	// Merge saves keep, soft-deletes retired and stores merge in one
+	// transaction. Earlier merges into retired are redirected to keep.
+	Merge(ctx context.Context, keep, retired *models.Artwork, merge *models.ArtworkMerge) error
+	// FindMerge returns the merge that retired the artwork with the given ID
+	FindMerge(ctx context.Context, retiredID string) (*models.ArtworkMerge, error)
 }
 
 // GormArtworkRepository is the GORM-based implementation of ArtworkRepository
 type GormArtworkRepository struct {
 	DB *gorm.DB
+	// Router sends lookups and lists to read replicas; nil reads from DB.
+	// Writes and FindDuplicate, which checks a failed write, use DB.
+	Router *dbrouter.Router
 }
 
 func NewGormArtworkRepository(db *gorm.DB) *GormArtworkRepository {
 	return &GormArtworkRepository{DB: db}
 }
 
-func (r *GormArtworkRepository) Create(artwork *models.Artwork) error {
-	return r.DB.Create(artwork).Error
+// read runs query on the database the router picks for ctx, retrying on DB
+// when a replica fails.
+func (r *GormArtworkRepository) read(ctx context.Context, query func(db *gorm.DB) error) error {
+	db, replica := r.Router.Reader(ctx)
+	if db == nil {
+		db = r.DB
+	}
+	err := query(db.WithContext(ctx))
+	if r.Router.Failed(ctx, replica, err) {
+		err = query(r.DB.WithContext(ctx))
+	}
+	return err
+}
+
+func (r *GormArtworkRepository) Create(ctx context.Context, artwork *models.Artwork) error {
+	defer r.Router.Wrote(ctx)
+	return r.DB.WithContext(ctx).Create(artwork).Error
+}
+
+func (r *GormArtworkRepository) FindAll(ctx context.Context) ([]models.Artwork, error) {
+	var artworks []models.Artwork
+	err := r.read(ctx, func(db *gorm.DB) error {
+		artworks = nil
+		return db.Find(&artworks).Error
+	})
+	return artworks, err
 }
 
-func (r *GormArtworkRepository) FindAll() ([]models.Artwork, error) {
+func (r *GormArtworkRepository) FindByGallery(ctx context.Context, gallery string) ([]models.Artwork, error) {
 	var artworks []models.Artwork
-	err := r.DB.Find(&artworks).Error
+	err := r.read(ctx, func(db *gorm.DB) error {
+		artworks = nil
+		return db.Where("gallery = ?", gallery).Order("id").Find(&artworks).Error
+	})
 	return artworks, err
 }
 
-func (r *GormArtworkRepository) FindByID(id string) (*models.Artwork, error) {
+func (r *GormArtworkRepository) FindByID(ctx context.Context, id string) (*models.Artwork, error) {
 	var artwork models.Artwork
-	if err := r.DB.First(&artwork, "id = ?", id).Error; err != nil {
+	err := r.read(ctx, func(db *gorm.DB) error { return db.First(&artwork, "id = ?", id).Error })
+	if err != nil {
 		return nil, err
 	}
 	return &artwork, nil
 }
 
-func (r *GormArtworkRepository) Update(artwork *models.Artwork) error {
-	return r.DB.Save(artwork).Error
+// FindByIDUnscoped also returns soft-deleted artworks
+func (r *GormArtworkRepository) FindByIDUnscoped(ctx context.Context, id string) (*models.Artwork, error) {
+	var artwork models.Artwork
+	err := r.read(ctx, func(db *gorm.DB) error { return db.Unscoped().First(&artwork, "id = ?", id).Error })
+	if err != nil {
+		return nil, err
+	}
+	return &artwork, nil
+}
+
+// FindDuplicate returns the live artwork, other than artwork itself, that
+// holds its title and artist in its gallery. The normalization matches the
+// unique index.
+func (r *GormArtworkRepository) FindDuplicate(ctx context.Context, artwork *models.Artwork) (*models.Artwork, error) {
+	var duplicate models.Artwork
+	err := r.DB.WithContext(ctx).
+		Where("gallery = ? AND lower(trim(title)) = lower(trim(?)) AND lower(trim(artist)) = lower(trim(?)) AND id <> ?",
+			artwork.Gallery, artwork.Title, artwork.Artist, artwork.ID).
+		Order("id").
+		First(&duplicate).Error
+	if err != nil {
+		return nil, err
+	}
+	return &duplicate, nil
+}
+
+func (r *GormArtworkRepository) Update(ctx context.Context, artwork *models.Artwork) error {
+	defer r.Router.Wrote(ctx)
+	return r.DB.WithContext(ctx).Save(artwork).Error
 }
 
-func (r *GormArtworkRepository) Delete(id string) error {
-	res := r.DB.Delete(&models.Artwork{}, "id = ?", id)
+func (r *GormArtworkRepository) Delete(ctx context.Context, id string) error {
+	defer r.Router.Wrote(ctx)
+	res := r.DB.WithContext(ctx).Delete(&models.Artwork{}, "id = ?", id)
 	if res.Error != nil {
 		return res.Error
 	}
@@ -55,3 +129,46 @@ func (r *GormArtworkRepository) Delete(id string) error {
 	}
 	return nil
 }
+
+// Purge permanently removes an artwork, including soft-deleted ones
+func (r *GormArtworkRepository) Purge(ctx context.Context, id string) error {
+	defer r.Router.Wrote(ctx)
+	res := r.DB.WithContext(ctx).Unscoped().Delete(&models.Artwork{}, "id = ?", id)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *GormArtworkRepository) Merge(ctx context.Context, keep, retired *models.Artwork, merge *models.ArtworkMerge) error {
+	defer r.Router.Wrote(ctx)
+	return r.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// The retired artwork leaves the unique index before keep is saved
+		res := tx.Delete(&models.Artwork{}, "id = ?", retired.ID)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		if err := tx.Save(keep).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.ArtworkMerge{}).Where("artwork_id = ?", retired.ID).Update("artwork_id", keep.ID).Error; err != nil {
+			return err
+		}
+		return tx.Create(merge).Error
+	})
+}
+
+func (r *GormArtworkRepository) FindMerge(ctx context.Context, retiredID string) (*models.ArtworkMerge, error) {
+	var merge models.ArtworkMerge
+	err := r.read(ctx, func(db *gorm.DB) error { return db.First(&merge, "retired_id = ?", retiredID).Error })
+	if err != nil {
+		return nil, err
+	}
+	return &merge, nil
+}
@@ -0,0 +1,277 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/paumarro/apollo-be/internal/cache"
+	"github.com/paumarro/apollo-be/internal/dbrouter"
+	"github.com/paumarro/apollo-be/internal/models"
+	"gorm.io/gorm"
+)
+
+// CachedArtworkRepository caches FindByID of another ArtworkRepository in a
+// bounded LRU. Found artworks are kept for TTL and IDs that do not exist for
+// NegativeTTL. Writes through the decorator drop the IDs they touch, here
+// and, through Bus, on every other replica; other methods pass through.
+// Writes that bypass it, such as those of another service, are only seen
+// once the entries expire. Lookups the cache cannot answer read from the
+// primary database, so replica lag is never cached.
+type CachedArtworkRepository struct {
+	ArtworkRepository
+	TTL         time.Duration
+	NegativeTTL time.Duration
+	// Bus carries invalidations to other replicas; nil for a single instance
+	Bus cache.Bus
+
+	entries *cache.LRU[uint, *models.Artwork] // nil marks a missing ID
+	// mu orders fills after invalidations: a fill started before an
+	// invalidation does not store what it read
+	mu         sync.Mutex
+	generation uint64
+
+	hits, negativeHits, misses, invalidations atomic.Uint64
+}
+
+// NewCachedArtworkRepository caches up to size artworks of repo.
+func NewCachedArtworkRepository(repo ArtworkRepository, size int, ttl, negativeTTL time.Duration) *CachedArtworkRepository {
+	return &CachedArtworkRepository{
+		ArtworkRepository: repo,
+		TTL:               ttl,
+		NegativeTTL:       negativeTTL,
+		entries:           cache.NewLRU[uint, *models.Artwork](size),
+	}
+}
+
+// Subscribe applies invalidations published by other replicas on r.Bus
+// until ctx ends.
+func (r *CachedArtworkRepository) Subscribe(ctx context.Context) {
+	if r.Bus == nil {
+		return
+	}
+	r.Bus.Subscribe(ctx, func(key string) {
+		if id, ok := cacheKey(key); ok {
+			r.drop(id)
+		}
+	}, r.Reset)
+}
+
+func (r *CachedArtworkRepository) FindByID(ctx context.Context, id string) (*models.Artwork, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	key, ok := cacheKey(id)
+	if !ok {
+		return r.ArtworkRepository.FindByID(ctx, id)
+	}
+	if artwork, ok := r.entries.Get(key); ok {
+		if artwork == nil {
+			r.negativeHits.Add(1)
+			return nil, gorm.ErrRecordNotFound
+		}
+		r.hits.Add(1)
+		found := *artwork
+		return &found, nil
+	}
+	r.misses.Add(1)
+
+	r.mu.Lock()
+	generation := r.generation
+	r.mu.Unlock()
+
+	// A lagging replica could hand back what was just invalidated
+	artwork, err := r.ArtworkRepository.FindByID(dbrouter.WithPrimary(ctx), id)
+	switch {
+	case err == nil:
+		stored := *artwork
+		r.fill(key, &stored, r.TTL, generation)
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		r.fill(key, nil, r.NegativeTTL, generation)
+	}
+	return artwork, err
+}
+
+func (r *CachedArtworkRepository) fill(key uint, artwork *models.Artwork, ttl time.Duration, generation uint64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.generation == generation {
+		r.entries.Set(key, artwork, ttl)
+	}
+}
+
+// Create drops a cached miss for the new ID.
+func (r *CachedArtworkRepository) Create(ctx context.Context, artwork *models.Artwork) error {
+	err := r.ArtworkRepository.Create(ctx, artwork)
+	r.Invalidate(ctx, artwork.ID)
+	return err
+}
+
+func (r *CachedArtworkRepository) Update(ctx context.Context, artwork *models.Artwork) error {
+	err := r.ArtworkRepository.Update(ctx, artwork)
+	r.Invalidate(ctx, artwork.ID)
+	return err
+}
+
+func (r *CachedArtworkRepository) Delete(ctx context.Context, id string) error {
+	err := r.ArtworkRepository.Delete(ctx, id)
+	r.invalidateID(ctx, id)
+	return err
+}
+
+func (r *CachedArtworkRepository) Purge(ctx context.Context, id string) error {
+	err := r.ArtworkRepository.Purge(ctx, id)
+	r.invalidateID(ctx, id)
+	return err
+}
+
+func (r *CachedArtworkRepository) Merge(ctx context.Context, keep, retired *models.Artwork, merge *models.ArtworkMerge) error {
+	err := r.ArtworkRepository.Merge(ctx, keep, retired, merge)
+	r.Invalidate(ctx, keep.ID, retired.ID)
+	return err
+}
+
+// Invalidate drops the artworks with the given IDs here and on the replicas
+// listening on r.Bus. Writes invalidate even when they fail, as a failed
+// write may still have reached the database.
+func (r *CachedArtworkRepository) Invalidate(ctx context.Context, ids ...uint) {
+	for _, id := range ids {
+		if id == 0 {
+			continue
+		}
+		r.drop(id)
+		r.invalidations.Add(1)
+		if r.Bus == nil {
+			continue
+		}
+		// Publish even when the request is cancelled; other replicas rely on it
+		if err := r.Bus.Publish(context.WithoutCancel(ctx), strconv.FormatUint(uint64(id), 10)); err != nil {
+			log.Printf("Failed to publish cache invalidation of artwork %d: %v", id, err)
+		}
+	}
+}
+
+func (r *CachedArtworkRepository) invalidateID(ctx context.Context, id string) {
+	if key, ok := cacheKey(id); ok {
+		r.Invalidate(ctx, key)
+	}
+}
+
+// drop removes id from this replica's cache only.
+func (r *CachedArtworkRepository) drop(id uint) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.generation++
+	r.entries.Delete(id)
+}
+
+// Reset empties this replica's cache.
+func (r *CachedArtworkRepository) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.generation++
+	r.entries.Purge()
+}
+
+// Stats returns the cache's counters. Invalidations counts the artworks
+// written through this replica.
+func (r *CachedArtworkRepository) Stats() cache.Stats {
+	return cache.Stats{
+		Hits:          r.hits.Load(),
+		NegativeHits:  r.negativeHits.Load(),
+		Misses:        r.misses.Load(),
+		Evictions:     r.entries.Evictions(),
+		Invalidations: r.invalidations.Load(),
+		Entries:       r.entries.Len(),
+	}
+}
+
+// CachedUnitOfWork runs the units of work of another UnitOfWork and drops
+// the artworks they write from Cache when they end. Reads within a unit of
+// work are not cached, as they may see writes that are rolled back.
+type CachedUnitOfWork struct {
+	UnitOfWork
+	Cache *CachedArtworkRepository
+}
+
+func NewCachedUnitOfWork(unit UnitOfWork, c *CachedArtworkRepository) *CachedUnitOfWork {
+	return &CachedUnitOfWork{UnitOfWork: unit, Cache: c}
+}
+
+func (u *CachedUnitOfWork) Do(ctx context.Context, fn func(ctx context.Context, repos Repos) error) error {
+	written := &writtenArtworks{}
+	err := u.UnitOfWork.Do(ctx, func(ctx context.Context, repos Repos) error {
+		written.ArtworkRepository = repos.Artworks
+		repos.Artworks = written
+		return fn(ctx, repos)
+	})
+	// After the commit, so readers cannot cache the state before it again
+	u.Cache.Invalidate(ctx, written.list()...)
+	return err
+}
+
+// writtenArtworks records the IDs written within a unit of work.
+type writtenArtworks struct {
+	ArtworkRepository
+	mu  sync.Mutex
+	ids []uint
+}
+
+func (w *writtenArtworks) add(ids ...uint) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.ids = append(w.ids, ids...)
+}
+
+func (w *writtenArtworks) list() []uint {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.ids
+}
+
+func (w *writtenArtworks) Create(ctx context.Context, artwork *models.Artwork) error {
+	err := w.ArtworkRepository.Create(ctx, artwork)
+	w.add(artwork.ID)
+	return err
+}
+
+func (w *writtenArtworks) Update(ctx context.Context, artwork *models.Artwork) error {
+	err := w.ArtworkRepository.Update(ctx, artwork)
+	w.add(artwork.ID)
+	return err
+}
+
+func (w *writtenArtworks) Delete(ctx context.Context, id string) error {
+	err := w.ArtworkRepository.Delete(ctx, id)
+	if key, ok := cacheKey(id); ok {
+		w.add(key)
+	}
+	return err
+}
+
+func (w *writtenArtworks) Purge(ctx context.Context, id string) error {
+	err := w.ArtworkRepository.Purge(ctx, id)
+	if key, ok := cacheKey(id); ok {
+		w.add(key)
+	}
+	return err
+}
+
+func (w *writtenArtworks) Merge(ctx context.Context, keep, retired *models.Artwork, merge *models.ArtworkMerge) error {
+	err := w.ArtworkRepository.Merge(ctx, keep, retired, merge)
+	w.add(keep.ID, retired.ID)
+	return err
+}
+
+// cacheKey parses an artwork ID. IDs that are not numbers are not cached.
+func cacheKey(id string) (uint, bool) {
+	n, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return uint(n), true
+}
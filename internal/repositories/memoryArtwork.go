@@ -0,0 +1,300 @@
+package repositories
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/paumarro/apollo-be/internal/models"
+	"gorm.io/gorm"
+)
+
+// MemoryArtworkRepository keeps artworks in memory for local development and
+// tests. It follows the GORM repository: soft deletes, gorm.ErrRecordNotFound
+// for missing artworks, gorm.ErrDuplicatedKey for duplicate titles, the
+// context's error once it is done, and copies in and out, so callers never
+// share state with the store.
+type MemoryArtworkRepository struct {
+	mu       sync.RWMutex
+	artworks map[uint]models.Artwork
+	nextID   uint
+	// merges are keyed by the retired artwork's ID
+	merges      map[uint]models.ArtworkMerge
+	nextMergeID uint
+}
+
+func NewMemoryArtworkRepository() *MemoryArtworkRepository {
+	return &MemoryArtworkRepository{
+		artworks:    map[uint]models.Artwork{},
+		nextID:      1,
+		merges:      map[uint]models.ArtworkMerge{},
+		nextMergeID: 1,
+	}
+}
+
+func (r *MemoryArtworkRepository) Create(ctx context.Context, artwork *models.Artwork) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.duplicate(artwork); ok {
+		return gorm.ErrDuplicatedKey
+	}
+	if artwork.ID == 0 {
+		artwork.ID = r.nextID
+	} else if _, ok := r.artworks[artwork.ID]; ok {
+		return gorm.ErrDuplicatedKey
+	}
+	if artwork.ID >= r.nextID {
+		r.nextID = artwork.ID + 1
+	}
+
+	now := time.Now()
+	if artwork.CreatedAt.IsZero() {
+		artwork.CreatedAt = now
+	}
+	if artwork.UpdatedAt.IsZero() {
+		artwork.UpdatedAt = now
+	}
+	// Column default of the artworks table
+	if artwork.Status == "" {
+		artwork.Status = models.StatusPublished
+	}
+	r.artworks[artwork.ID] = *artwork
+	return nil
+}
+
+func (r *MemoryArtworkRepository) FindAll(ctx context.Context) ([]models.Artwork, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return r.live(func(models.Artwork) bool { return true }), nil
+}
+
+func (r *MemoryArtworkRepository) FindByGallery(ctx context.Context, gallery string) ([]models.Artwork, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return r.live(func(artwork models.Artwork) bool { return artwork.Gallery == gallery }), nil
+}
+
+// live returns the artworks that are not deleted and match, by ID.
+func (r *MemoryArtworkRepository) live(match func(models.Artwork) bool) []models.Artwork {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	artworks := make([]models.Artwork, 0, len(r.artworks))
+	for _, artwork := range r.artworks {
+		if !artwork.DeletedAt.Valid && match(artwork) {
+			artworks = append(artworks, artwork)
+		}
+	}
+	sort.Slice(artworks, func(i, j int) bool { return artworks[i].ID < artworks[j].ID })
+	return artworks
+}
+
+func (r *MemoryArtworkRepository) FindByID(ctx context.Context, id string) (*models.Artwork, error) {
+	artwork, err := r.FindByIDUnscoped(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if artwork.DeletedAt.Valid {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return artwork, nil
+}
+
+// FindByIDUnscoped also returns soft-deleted artworks
+func (r *MemoryArtworkRepository) FindByIDUnscoped(ctx context.Context, id string) (*models.Artwork, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	artwork, ok := r.artworks[parseID(id)]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &artwork, nil
+}
+
+// FindDuplicate returns the live artwork, other than artwork itself, that
+// holds its title and artist in its gallery
+func (r *MemoryArtworkRepository) FindDuplicate(ctx context.Context, artwork *models.Artwork) (*models.Artwork, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	duplicate, ok := r.duplicate(artwork)
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &duplicate, nil
+}
+
+// duplicate applies the unique index on normalized gallery, title and artist.
+// Deleted artworks neither conflict nor are checked. The caller holds r.mu.
+func (r *MemoryArtworkRepository) duplicate(artwork *models.Artwork) (models.Artwork, bool) {
+	if artwork.DeletedAt.Valid {
+		return models.Artwork{}, false
+	}
+	var found []models.Artwork
+	for id, other := range r.artworks {
+		if id != artwork.ID && !other.DeletedAt.Valid && other.Gallery == artwork.Gallery &&
+			normalize(other.Title) == normalize(artwork.Title) && normalize(other.Artist) == normalize(artwork.Artist) {
+			found = append(found, other)
+		}
+	}
+	if len(found) == 0 {
+		return models.Artwork{}, false
+	}
+	sort.Slice(found, func(i, j int) bool { return found[i].ID < found[j].ID })
+	return found[0], true
+}
+
+// normalize matches lower(trim(...)) in the unique index.
+func normalize(s string) string {
+	return strings.ToLower(strings.Trim(s, " "))
+}
+
+// Update saves every field of artwork, inserting it if it does not exist,
+// like gorm's Save.
+func (r *MemoryArtworkRepository) Update(ctx context.Context, artwork *models.Artwork) error {
+	if artwork.ID == 0 {
+		return r.Create(ctx, artwork)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.duplicate(artwork); ok {
+		return gorm.ErrDuplicatedKey
+	}
+	artwork.UpdatedAt = time.Now()
+	if artwork.ID >= r.nextID {
+		r.nextID = artwork.ID + 1
+	}
+	r.artworks[artwork.ID] = *artwork
+	return nil
+}
+
+func (r *MemoryArtworkRepository) Delete(ctx context.Context, id string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	artwork, ok := r.artworks[parseID(id)]
+	if !ok || artwork.DeletedAt.Valid {
+		return gorm.ErrRecordNotFound
+	}
+	artwork.DeletedAt = gorm.DeletedAt{Time: time.Now(), Valid: true}
+	r.artworks[artwork.ID] = artwork
+	return nil
+}
+
+// Purge permanently removes an artwork, including soft-deleted ones
+func (r *MemoryArtworkRepository) Purge(ctx context.Context, id string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := parseID(id)
+	if _, ok := r.artworks[key]; !ok {
+		return gorm.ErrRecordNotFound
+	}
+	delete(r.artworks, key)
+	return nil
+}
+
+func (r *MemoryArtworkRepository) Merge(ctx context.Context, keep, retired *models.Artwork, merge *models.ArtworkMerge) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	before, ok := r.artworks[retired.ID]
+	if !ok || before.DeletedAt.Valid {
+		return gorm.ErrRecordNotFound
+	}
+	if _, ok := r.merges[merge.RetiredID]; ok {
+		return gorm.ErrDuplicatedKey
+	}
+
+	// The retired artwork leaves the unique index before keep is saved
+	now := time.Now()
+	deleted := before
+	deleted.DeletedAt = gorm.DeletedAt{Time: now, Valid: true}
+	r.artworks[deleted.ID] = deleted
+	if _, ok := r.duplicate(keep); ok {
+		r.artworks[before.ID] = before
+		return gorm.ErrDuplicatedKey
+	}
+	keep.UpdatedAt = now
+	r.artworks[keep.ID] = *keep
+
+	for id, earlier := range r.merges {
+		if earlier.ArtworkID == retired.ID {
+			earlier.ArtworkID = keep.ID
+			r.merges[id] = earlier
+		}
+	}
+	merge.ID = r.nextMergeID
+	r.nextMergeID++
+	r.merges[merge.RetiredID] = *merge
+	return nil
+}
+
+func (r *MemoryArtworkRepository) FindMerge(ctx context.Context, retiredID string) (*models.ArtworkMerge, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	merge, ok := r.merges[parseID(retiredID)]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &merge, nil
+}
+
+// clone copies the stores into a new repository. The caller holds r.mu.
+func (r *MemoryArtworkRepository) clone() *MemoryArtworkRepository {
+	c := &MemoryArtworkRepository{
+		artworks:    make(map[uint]models.Artwork, len(r.artworks)),
+		nextID:      r.nextID,
+		merges:      make(map[uint]models.ArtworkMerge, len(r.merges)),
+		nextMergeID: r.nextMergeID,
+	}
+	for id, artwork := range r.artworks {
+		c.artworks[id] = artwork
+	}
+	for id, merge := range r.merges {
+		c.merges[id] = merge
+	}
+	return c
+}
+
+// parseID maps an ID that is not a number to 0, which no artwork has.
+func parseID(id string) uint {
+	n, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		return 0
+	}
+	return uint(n)
+}
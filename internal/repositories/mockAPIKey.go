@@ -0,0 +1,55 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/paumarro/apollo-be/internal/models"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockAPIKeyRepository struct {
+	mock.Mock
+}
+
+func (m *MockAPIKeyRepository) Create(ctx context.Context, key *models.APIKey) error {
+	args := m.Called(ctx, key)
+	return args.Error(0)
+}
+
+func (m *MockAPIKeyRepository) FindByHash(ctx context.Context, hash string) (*models.APIKey, error) {
+	args := m.Called(ctx, hash)
+	var res *models.APIKey
+	if v := args.Get(0); v != nil {
+		res = v.(*models.APIKey)
+	}
+	return res, args.Error(1)
+}
+
+func (m *MockAPIKeyRepository) FindByID(ctx context.Context, id string) (*models.APIKey, error) {
+	args := m.Called(ctx, id)
+	var res *models.APIKey
+	if v := args.Get(0); v != nil {
+		res = v.(*models.APIKey)
+	}
+	return res, args.Error(1)
+}
+
+func (m *MockAPIKeyRepository) FindByGalleries(ctx context.Context, galleries []string) ([]models.APIKey, error) {
+	args := m.Called(ctx, galleries)
+	var res []models.APIKey
+	if v := args.Get(0); v != nil {
+		res = v.([]models.APIKey)
+	}
+	return res, args.Error(1)
+}
+
+func (m *MockAPIKeyRepository) TouchLastUsed(ctx context.Context, id uint, at time.Time) error {
+	args := m.Called(ctx, id, at)
+	return args.Error(0)
+}
+
+func (m *MockAPIKeyRepository) Delete(ctx context.Context, id string) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
@@ -1,6 +1,8 @@
 package repositories
 
 import (
+	"context"
+
 	"github.com/paumarro/apollo-be/internal/models"
 	"github.com/stretchr/testify/mock"
 )
@@ -9,13 +11,13 @@ type MockArtworkRepository struct {
 	mock.Mock
 }
 
-func (m *MockArtworkRepository) Create(artwork *models.Artwork) error {
-	args := m.Called(artwork)
+func (m *MockArtworkRepository) Create(ctx context.Context, artwork *models.Artwork) error {
+	args := m.Called(ctx, artwork)
 	return args.Error(0)
 }
 
-func (m *MockArtworkRepository) FindAll() ([]models.Artwork, error) {
-	args := m.Called()
+func (m *MockArtworkRepository) FindAll(ctx context.Context) ([]models.Artwork, error) {
+	args := m.Called(ctx)
 	var res []models.Artwork
 	if v := args.Get(0); v != nil {
 		res = v.([]models.Artwork)
@@ -23,8 +25,17 @@ func (m *MockArtworkRepository) FindAll() ([]models.Artwork, error) {
 	return res, args.Error(1)
 }
 
-func (m *MockArtworkRepository) FindByID(id string) (*models.Artwork, error) {
-	args := m.Called(id)
+func (m *MockArtworkRepository) FindByGallery(ctx context.Context, gallery string) ([]models.Artwork, error) {
+	args := m.Called(ctx, gallery)
+	var res []models.Artwork
+	if v := args.Get(0); v != nil {
+		res = v.([]models.Artwork)
+	}
+	return res, args.Error(1)
+}
+
+func (m *MockArtworkRepository) FindByID(ctx context.Context, id string) (*models.Artwork, error) {
+	args := m.Called(ctx, id)
 	var res *models.Artwork
 	if v := args.Get(0); v != nil {
 		res = v.(*models.Artwork)
@@ -32,12 +43,49 @@ func (m *MockArtworkRepository) FindByID(id string) (*models.Artwork, error) {
 	return res, args.Error(1)
 }
 
-func (m *MockArtworkRepository) Update(artwork *models.Artwork) error {
-	args := m.Called(artwork)
+func (m *MockArtworkRepository) FindByIDUnscoped(ctx context.Context, id string) (*models.Artwork, error) {
+	args := m.Called(ctx, id)
+	var res *models.Artwork
+	if v := args.Get(0); v != nil {
+		res = v.(*models.Artwork)
+	}
+	return res, args.Error(1)
+}
+
+func (m *MockArtworkRepository) FindDuplicate(ctx context.Context, artwork *models.Artwork) (*models.Artwork, error) {
+	args := m.Called(ctx, artwork)
+	var res *models.Artwork
+	if v := args.Get(0); v != nil {
+		res = v.(*models.Artwork)
+	}
+	return res, args.Error(1)
+}
+
+func (m *MockArtworkRepository) Update(ctx context.Context, artwork *models.Artwork) error {
+	args := m.Called(ctx, artwork)
+	return args.Error(0)
+}
+
+func (m *MockArtworkRepository) Delete(ctx context.Context, id string) error {
+	args := m.Called(ctx, id)
 	return args.Error(0)
 }
 
-func (m *MockArtworkRepository) Delete(id string) error {
-	args := m.Called(id)
+func (m *MockArtworkRepository) Purge(ctx context.Context, id string) error {
+	args := m.Called(ctx, id)
 	return args.Error(0)
 }
+
+func (m *MockArtworkRepository) Merge(ctx context.Context, keep, retired *models.Artwork, merge *models.ArtworkMerge) error {
+	args := m.Called(ctx, keep, retired, merge)
+	return args.Error(0)
+}
+
+func (m *MockArtworkRepository) FindMerge(ctx context.Context, retiredID string) (*models.ArtworkMerge, error) {
+	args := m.Called(ctx, retiredID)
+	var res *models.ArtworkMerge
+	if v := args.Get(0); v != nil {
+		res = v.(*models.ArtworkMerge)
+	}
+	return res, args.Error(1)
+}
@@ -0,0 +1,270 @@
+// Package repotest holds contract tests that every repository
+// implementation must pass, so the GORM, SQLite and in-memory backends
+// behave the same.
+package repotest
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/paumarro/apollo-be/internal/models"
+	"github.com/paumarro/apollo-be/internal/repositories"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// ArtworkRepository runs the ArtworkRepository contract. newRepo returns an
+// empty repository for each subtest.
+func ArtworkRepository(t *testing.T, newRepo func(t *testing.T) repositories.ArtworkRepository) {
+	ctx := context.Background()
+
+	t.Run("CreateAssignsIDsAndDefaults", func(t *testing.T) {
+		repo := newRepo(t)
+		first := &models.Artwork{Title: "Water Lilies", Artist: "Claude Monet", Gallery: "orangerie"}
+		second := &models.Artwork{Title: "Sketch", Artist: "Unknown", Gallery: "orangerie", Status: models.StatusDraft}
+		require.NoError(t, repo.Create(ctx, first))
+		require.NoError(t, repo.Create(ctx, second))
+
+		assert.NotZero(t, first.ID)
+		assert.NotEqual(t, first.ID, second.ID)
+		assert.WithinDuration(t, time.Now(), first.CreatedAt, 5*time.Second)
+		assert.WithinDuration(t, time.Now(), first.UpdatedAt, 5*time.Second)
+
+		found, err := repo.FindByID(ctx, id(first))
+		require.NoError(t, err)
+		assert.Equal(t, "Water Lilies", found.Title)
+		assert.Equal(t, "Claude Monet", found.Artist)
+		assert.Equal(t, "orangerie", found.Gallery)
+		assert.Equal(t, models.StatusPublished, found.Status)
+
+		found, err = repo.FindByID(ctx, id(second))
+		require.NoError(t, err)
+		assert.Equal(t, models.StatusDraft, found.Status)
+	})
+
+	t.Run("FindByIDMissing", func(t *testing.T) {
+		repo := newRepo(t)
+		_, err := repo.FindByID(ctx, "999")
+		assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+		_, err = repo.FindByIDUnscoped(ctx, "999")
+		assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	})
+
+	t.Run("ResultsAreCopies", func(t *testing.T) {
+		repo := newRepo(t)
+		artwork := &models.Artwork{Title: "Original", Gallery: "g1"}
+		require.NoError(t, repo.Create(ctx, artwork))
+		artwork.Title = "Changed after create"
+
+		found, err := repo.FindByID(ctx, id(artwork))
+		require.NoError(t, err)
+		assert.Equal(t, "Original", found.Title)
+		found.Title = "Changed after find"
+
+		again, err := repo.FindByID(ctx, id(artwork))
+		require.NoError(t, err)
+		assert.Equal(t, "Original", again.Title)
+	})
+
+	t.Run("FindAllSkipsDeleted", func(t *testing.T) {
+		repo := newRepo(t)
+		all, err := repo.FindAll(ctx)
+		require.NoError(t, err)
+		assert.Empty(t, all)
+
+		var ids []uint
+		for _, title := range []string{"A", "B", "C"} {
+			artwork := &models.Artwork{Title: title, Gallery: "g1"}
+			require.NoError(t, repo.Create(ctx, artwork))
+			ids = append(ids, artwork.ID)
+		}
+		require.NoError(t, repo.Delete(ctx, strconv.FormatUint(uint64(ids[1]), 10)))
+
+		all, err = repo.FindAll(ctx)
+		require.NoError(t, err)
+		var got []uint
+		for _, artwork := range all {
+			got = append(got, artwork.ID)
+		}
+		assert.ElementsMatch(t, []uint{ids[0], ids[2]}, got)
+	})
+
+	t.Run("UpdateSavesFields", func(t *testing.T) {
+		repo := newRepo(t)
+		artwork := &models.Artwork{Title: "Draft title", Gallery: "g1", Status: models.StatusDraft}
+		require.NoError(t, repo.Create(ctx, artwork))
+		created := artwork.UpdatedAt
+
+		found, err := repo.FindByID(ctx, id(artwork))
+		require.NoError(t, err)
+		found.Title = "Final title"
+		found.Status = models.StatusPublished
+		found.UpdatedBy = "editor"
+		time.Sleep(5 * time.Millisecond)
+		require.NoError(t, repo.Update(ctx, found))
+
+		updated, err := repo.FindByID(ctx, id(artwork))
+		require.NoError(t, err)
+		assert.Equal(t, "Final title", updated.Title)
+		assert.Equal(t, models.StatusPublished, updated.Status)
+		assert.Equal(t, "editor", updated.UpdatedBy)
+		assert.Equal(t, "g1", updated.Gallery)
+		assert.True(t, updated.UpdatedAt.After(created), "UpdatedAt moves forward")
+		assert.WithinDuration(t, artwork.CreatedAt, updated.CreatedAt, time.Millisecond)
+	})
+
+	t.Run("DeleteIsSoft", func(t *testing.T) {
+		repo := newRepo(t)
+		artwork := &models.Artwork{Title: "Fragile", Gallery: "g1"}
+		require.NoError(t, repo.Create(ctx, artwork))
+
+		require.NoError(t, repo.Delete(ctx, id(artwork)))
+		_, err := repo.FindByID(ctx, id(artwork))
+		assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+
+		deleted, err := repo.FindByIDUnscoped(ctx, id(artwork))
+		require.NoError(t, err)
+		assert.True(t, deleted.DeletedAt.Valid)
+		assert.Equal(t, "Fragile", deleted.Title)
+
+		assert.ErrorIs(t, repo.Delete(ctx, id(artwork)), gorm.ErrRecordNotFound, "deleting twice")
+		assert.ErrorIs(t, repo.Delete(ctx, "999"), gorm.ErrRecordNotFound)
+	})
+
+	t.Run("PurgeRemovesLiveAndDeleted", func(t *testing.T) {
+		repo := newRepo(t)
+		live := &models.Artwork{Title: "Live", Gallery: "g1"}
+		deleted := &models.Artwork{Title: "Deleted", Gallery: "g1"}
+		require.NoError(t, repo.Create(ctx, live))
+		require.NoError(t, repo.Create(ctx, deleted))
+		require.NoError(t, repo.Delete(ctx, id(deleted)))
+
+		for _, artwork := range []*models.Artwork{live, deleted} {
+			require.NoError(t, repo.Purge(ctx, id(artwork)))
+			_, err := repo.FindByIDUnscoped(ctx, id(artwork))
+			assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+			assert.ErrorIs(t, repo.Purge(ctx, id(artwork)), gorm.ErrRecordNotFound, "purging twice")
+		}
+	})
+
+	t.Run("TitleAndArtistUniquePerGallery", func(t *testing.T) {
+		repo := newRepo(t)
+		original := &models.Artwork{Title: "Water Lilies", Artist: "Claude Monet", Gallery: "orangerie"}
+		require.NoError(t, repo.Create(ctx, original))
+
+		copycat := &models.Artwork{Title: " water lilies", Artist: "CLAUDE MONET ", Gallery: "orangerie"}
+		assert.ErrorIs(t, repo.Create(ctx, copycat), gorm.ErrDuplicatedKey)
+		assert.Zero(t, copycat.ID, "rejected artworks get no ID")
+
+		duplicate, err := repo.FindDuplicate(ctx, copycat)
+		require.NoError(t, err)
+		assert.Equal(t, original.ID, duplicate.ID)
+		_, err = repo.FindDuplicate(ctx, original)
+		assert.ErrorIs(t, err, gorm.ErrRecordNotFound, "an artwork does not duplicate itself")
+
+		// Other galleries and other artists are independent
+		require.NoError(t, repo.Create(ctx, &models.Artwork{Title: "Water Lilies", Artist: "Claude Monet", Gallery: "marmottan"}))
+		other := &models.Artwork{Title: "Water Lilies", Artist: "Unknown", Gallery: "orangerie"}
+		require.NoError(t, repo.Create(ctx, other))
+
+		// Updates follow the same rule
+		found, err := repo.FindByID(ctx, id(other))
+		require.NoError(t, err)
+		found.Artist = "claude monet"
+		assert.ErrorIs(t, repo.Update(ctx, found), gorm.ErrDuplicatedKey)
+		found.Description = "Saving an artwork unchanged is no conflict"
+		found.Artist = "Unknown"
+		require.NoError(t, repo.Update(ctx, found))
+
+		// Deleted artworks release their title
+		require.NoError(t, repo.Delete(ctx, id(original)))
+		require.NoError(t, repo.Create(ctx, &models.Artwork{Title: "Water Lilies", Artist: "Claude Monet", Gallery: "orangerie"}))
+	})
+
+	t.Run("FindByGallery", func(t *testing.T) {
+		repo := newRepo(t)
+		first := &models.Artwork{Title: "Water Lilies", Gallery: "orangerie"}
+		second := &models.Artwork{Title: "Sketch", Gallery: "orangerie", Status: models.StatusDraft}
+		deleted := &models.Artwork{Title: "Gone", Gallery: "orangerie"}
+		for _, artwork := range []*models.Artwork{first, second, deleted, {Title: "Elsewhere", Gallery: "marmottan"}} {
+			require.NoError(t, repo.Create(ctx, artwork))
+		}
+		require.NoError(t, repo.Delete(ctx, id(deleted)))
+
+		artworks, err := repo.FindByGallery(ctx, "orangerie")
+		require.NoError(t, err)
+		require.Len(t, artworks, 2)
+		assert.Equal(t, first.ID, artworks[0].ID)
+		assert.Equal(t, second.ID, artworks[1].ID)
+
+		artworks, err = repo.FindByGallery(ctx, "louvre")
+		require.NoError(t, err)
+		assert.Empty(t, artworks)
+	})
+
+	t.Run("MergeRetiresAndRedirects", func(t *testing.T) {
+		repo := newRepo(t)
+		keep := &models.Artwork{Title: "Mona Lisa", Artist: "Leonardo", Gallery: "louvre"}
+		retired := &models.Artwork{Title: "Mona Liza", Artist: "Leonardo", Gallery: "louvre"}
+		older := &models.Artwork{Title: "La Gioconda", Artist: "Leonardo", Gallery: "louvre"}
+		for _, artwork := range []*models.Artwork{keep, retired, older} {
+			require.NoError(t, repo.Create(ctx, artwork))
+		}
+
+		// older was merged into retired before retired is merged into keep
+		require.NoError(t, repo.Merge(ctx, retired, older, &models.ArtworkMerge{
+			ArtworkID: retired.ID, RetiredID: older.ID, Retired: *older, MergedAt: time.Now(),
+		}))
+		keep.Description = "Portrait of Lisa Gherardini"
+		merge := &models.ArtworkMerge{ArtworkID: keep.ID, RetiredID: retired.ID, Retired: *retired, MergedBy: "curator", MergedAt: time.Now()}
+		require.NoError(t, repo.Merge(ctx, keep, retired, merge))
+		assert.NotZero(t, merge.ID)
+
+		found, err := repo.FindByID(ctx, id(keep))
+		require.NoError(t, err)
+		assert.Equal(t, "Portrait of Lisa Gherardini", found.Description, "the kept artwork is saved")
+		_, err = repo.FindByID(ctx, id(retired))
+		assert.ErrorIs(t, err, gorm.ErrRecordNotFound, "the retired artwork is deleted")
+		unscoped, err := repo.FindByIDUnscoped(ctx, id(retired))
+		require.NoError(t, err)
+		assert.NotNil(t, unscoped.DeletedAt, "the retired artwork is only soft-deleted")
+
+		for _, retiredID := range []uint{retired.ID, older.ID} {
+			found, err := repo.FindMerge(ctx, strconv.FormatUint(uint64(retiredID), 10))
+			require.NoError(t, err)
+			assert.Equal(t, keep.ID, found.ArtworkID, "earlier merges follow the retired artwork")
+		}
+		found2, err := repo.FindMerge(ctx, id(retired))
+		require.NoError(t, err)
+		assert.Equal(t, "Mona Liza", found2.Retired.Title)
+		assert.Equal(t, "curator", found2.MergedBy)
+
+		_, err = repo.FindMerge(ctx, id(keep))
+		assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+
+		// A retired artwork cannot be merged again
+		err = repo.Merge(ctx, keep, retired, &models.ArtworkMerge{ArtworkID: keep.ID, RetiredID: retired.ID, Retired: *retired, MergedAt: time.Now()})
+		assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	})
+
+	t.Run("CancelledContext", func(t *testing.T) {
+		repo := newRepo(t)
+		artwork := &models.Artwork{Title: "Unreachable", Gallery: "g1"}
+		require.NoError(t, repo.Create(ctx, artwork))
+
+		cancelled, cancel := context.WithCancel(ctx)
+		cancel()
+		_, err := repo.FindByID(cancelled, id(artwork))
+		assert.ErrorIs(t, err, context.Canceled)
+		_, err = repo.FindAll(cancelled)
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.ErrorIs(t, repo.Create(cancelled, &models.Artwork{Title: "Never", Gallery: "g1"}), context.Canceled)
+	})
+}
+
+func id(artwork *models.Artwork) string {
+	return strconv.FormatUint(uint64(artwork.ID), 10)
+}
@@ -0,0 +1,124 @@
+package repotest
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/paumarro/apollo-be/internal/models"
+	"github.com/paumarro/apollo-be/internal/repositories"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// UnitOfWork runs the UnitOfWork contract. newUnit returns a unit of work
+// over empty repositories, and the artwork repository it commits to.
+func UnitOfWork(t *testing.T, newUnit func(t *testing.T) (repositories.UnitOfWork, repositories.ArtworkRepository)) {
+	ctx := context.Background()
+
+	t.Run("CommitsOnSuccess", func(t *testing.T) {
+		unit, artworks := newUnit(t)
+		first := &models.Artwork{Title: "Water Lilies", Gallery: "orangerie"}
+		second := &models.Artwork{Title: "Sketch", Gallery: "orangerie"}
+		err := unit.Do(ctx, func(ctx context.Context, repos repositories.Repos) error {
+			if err := repos.Artworks.Create(ctx, first); err != nil {
+				return err
+			}
+			// Writes are visible within the unit of work
+			found, err := repos.Artworks.FindByID(ctx, id(first))
+			if err != nil {
+				return err
+			}
+			second.Description = "Study for " + found.Title
+			return repos.Artworks.Create(ctx, second)
+		})
+		require.NoError(t, err)
+
+		all, err := artworks.FindAll(ctx)
+		require.NoError(t, err)
+		require.Len(t, all, 2)
+		assert.Equal(t, "Study for Water Lilies", all[1].Description)
+	})
+
+	t.Run("RollsBackOnError", func(t *testing.T) {
+		unit, artworks := newUnit(t)
+		existing := &models.Artwork{Title: "Water Lilies", Gallery: "orangerie"}
+		require.NoError(t, artworks.Create(ctx, existing))
+
+		failure := errors.New("second write failed")
+		err := unit.Do(ctx, func(ctx context.Context, repos repositories.Repos) error {
+			if err := repos.Artworks.Create(ctx, &models.Artwork{Title: "Sketch", Gallery: "orangerie"}); err != nil {
+				return err
+			}
+			if err := repos.Artworks.Delete(ctx, id(existing)); err != nil {
+				return err
+			}
+			return failure
+		})
+		assert.ErrorIs(t, err, failure)
+
+		all, err := artworks.FindAll(ctx)
+		require.NoError(t, err)
+		require.Len(t, all, 1, "nothing written in the unit of work is kept")
+		assert.Equal(t, existing.ID, all[0].ID)
+	})
+
+	t.Run("RollsBackOnRepositoryError", func(t *testing.T) {
+		unit, artworks := newUnit(t)
+		require.NoError(t, artworks.Create(ctx, &models.Artwork{Title: "Water Lilies", Gallery: "orangerie"}))
+
+		err := unit.Do(ctx, func(ctx context.Context, repos repositories.Repos) error {
+			if err := repos.Artworks.Create(ctx, &models.Artwork{Title: "Sketch", Gallery: "orangerie"}); err != nil {
+				return err
+			}
+			return repos.Artworks.Create(ctx, &models.Artwork{Title: "water lilies", Gallery: "orangerie"})
+		})
+		assert.ErrorIs(t, err, gorm.ErrDuplicatedKey)
+
+		all, err := artworks.FindAll(ctx)
+		require.NoError(t, err)
+		assert.Len(t, all, 1)
+	})
+
+	t.Run("RollsBackOnPanic", func(t *testing.T) {
+		unit, artworks := newUnit(t)
+		assert.PanicsWithValue(t, "boom", func() {
+			_ = unit.Do(ctx, func(ctx context.Context, repos repositories.Repos) error {
+				if err := repos.Artworks.Create(ctx, &models.Artwork{Title: "Sketch", Gallery: "orangerie"}); err != nil {
+					return err
+				}
+				panic("boom")
+			})
+		})
+
+		all, err := artworks.FindAll(ctx)
+		require.NoError(t, err)
+		assert.Empty(t, all)
+
+		// The repository stays usable after the panic
+		require.NoError(t, unit.Do(ctx, func(ctx context.Context, repos repositories.Repos) error {
+			return repos.Artworks.Create(ctx, &models.Artwork{Title: "Sketch", Gallery: "orangerie"})
+		}))
+		all, err = artworks.FindAll(ctx)
+		require.NoError(t, err)
+		assert.Len(t, all, 1)
+	})
+
+	t.Run("CancelledContext", func(t *testing.T) {
+		unit, artworks := newUnit(t)
+		cancelled, cancel := context.WithCancel(ctx)
+		cancel()
+		called := false
+		err := unit.Do(cancelled, func(ctx context.Context, repos repositories.Repos) error {
+			called = true
+			return repos.Artworks.Create(ctx, &models.Artwork{Title: "Never", Gallery: "g1"})
+		})
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.False(t, called, "no transaction starts once the context is done")
+
+		all, err := artworks.FindAll(ctx)
+		require.NoError(t, err)
+		assert.Empty(t, all)
+	})
+}
@@ -0,0 +1,77 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/paumarro/apollo-be/internal/dbrouter"
+	"gorm.io/gorm"
+)
+
+// Repos are the repositories of a unit of work, all bound to its transaction.
+type Repos struct {
+	Artworks ArtworkRepository
+}
+
+// UnitOfWork runs several repository calls as one transaction. Do calls fn
+// with repositories bound to a new transaction and commits it when fn
+// returns nil. When fn returns an error or panics, nothing fn wrote is kept;
+// the error is returned and the panic is re-raised. fn must only use repos
+// and ctx, not repositories shared outside the unit of work.
+type UnitOfWork interface {
+	Do(ctx context.Context, fn func(ctx context.Context, repos Repos) error) error
+}
+
+// GormUnitOfWork runs units of work in database transactions
+type GormUnitOfWork struct {
+	DB *gorm.DB
+	// Router starts the session's read-your-writes window after a commit
+	Router *dbrouter.Router
+}
+
+func NewGormUnitOfWork(db *gorm.DB) *GormUnitOfWork {
+	return &GormUnitOfWork{DB: db}
+}
+
+func (u *GormUnitOfWork) Do(ctx context.Context, fn func(ctx context.Context, repos Repos) error) error {
+	// Transaction rolls back when fn returns an error or panics
+	err := u.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(ctx, Repos{Artworks: NewGormArtworkRepository(tx)})
+	})
+	if err == nil {
+		u.Router.Wrote(ctx)
+	}
+	return err
+}
+
+// MemoryUnitOfWork runs units of work against in-memory repositories. fn
+// works on a copy of the stores, which replaces them when fn succeeds.
+// Units of work hold the stores' locks while fn runs, so other callers wait
+// for them as they would for a database table lock.
+type MemoryUnitOfWork struct {
+	Artworks *MemoryArtworkRepository
+}
+
+func NewMemoryUnitOfWork(artworks *MemoryArtworkRepository) *MemoryUnitOfWork {
+	return &MemoryUnitOfWork{Artworks: artworks}
+}
+
+func (u *MemoryUnitOfWork) Do(ctx context.Context, fn func(ctx context.Context, repos Repos) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	u.Artworks.mu.Lock()
+	defer u.Artworks.mu.Unlock()
+
+	// A panic skips the commit below, discarding the copy
+	tx := u.Artworks.clone()
+	if err := fn(ctx, Repos{Artworks: tx}); err != nil {
+		return err
+	}
+	// Like a database, refuse to commit once the context is done
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	u.Artworks.artworks, u.Artworks.nextID = tx.artworks, tx.nextID
+	u.Artworks.merges, u.Artworks.nextMergeID = tx.merges, tx.nextMergeID
+	return nil
+}
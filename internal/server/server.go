@@ -0,0 +1,191 @@
+// Package server runs the HTTP server and shuts the application down in
+// order: it reports itself as draining, stops accepting connections, drains
+// in-flight requests within a deadline and then releases registered resources.
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/paumarro/apollo-be/internal/config"
+)
+
+// closer is a resource released during shutdown.
+type closer struct {
+	name  string
+	close func(context.Context) error
+}
+
+// Server wraps http.Server with graceful shutdown.
+type Server struct {
+	HTTP *http.Server
+	// Redirect, when set, serves plain HTTP redirects to the HTTPS server.
+	Redirect        *http.Server
+	ShutdownTimeout time.Duration
+	// DrainDelay keeps serving after shutdown begins, with readiness failing,
+	// so load balancers stop routing new requests first.
+	DrainDelay time.Duration
+
+	certs          *CertReloader
+	reloadInterval time.Duration
+
+	mu      sync.Mutex
+	closers []closer
+	drains  []func()
+}
+
+// New creates a server for handler with the listen address, timeouts and
+// limits of cfg.
+func New(cfg config.Server, handler http.Handler) *Server {
+	return &Server{
+		HTTP: &http.Server{
+			Addr:              net.JoinHostPort(cfg.Host, cfg.Port),
+			Handler:           handler,
+			ReadTimeout:       cfg.ReadTimeout,
+			ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+			WriteTimeout:      cfg.WriteTimeout,
+			IdleTimeout:       cfg.IdleTimeout,
+			MaxHeaderBytes:    cfg.MaxHeaderBytes,
+		},
+		ShutdownTimeout: cfg.ShutdownTimeout,
+		DrainDelay:      cfg.DrainDelay,
+	}
+}
+
+// OnDrain registers a function called as soon as shutdown begins, before
+// the server stops accepting connections, such as failing readiness.
+func (s *Server) OnDrain(fn func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.drains = append(s.drains, fn)
+}
+
+// Certificates returns the reloader of the TLS key pair, or nil without TLS.
+func (s *Server) Certificates() *CertReloader {
+	return s.certs
+}
+
+// OnShutdown registers a resource to release after the HTTP server has
+// drained. Resources are released in reverse order of registration, so
+// register the database before the workers and caches that use it.
+func (s *Server) OnShutdown(name string, fn func(context.Context) error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closers = append(s.closers, closer{name: name, close: fn})
+}
+
+// EnableTLS serves HTTPS with the certificate files of cfg, reloading them
+// when they change, and redirects plain HTTP on RedirectPort when set.
+func (s *Server) EnableTLS(cfg config.Server) error {
+	certs, err := NewCertReloader(cfg.TLSCertFile, cfg.TLSKeyFile)
+	if err != nil {
+		return err
+	}
+	tlsConfig, err := NewTLSConfig(cfg, certs)
+	if err != nil {
+		return err
+	}
+	s.HTTP.TLSConfig = tlsConfig
+	s.certs = certs
+	s.reloadInterval = cfg.TLSReloadInterval
+
+	if cfg.RedirectPort != "" {
+		s.Redirect = &http.Server{
+			Addr:              net.JoinHostPort(cfg.Host, cfg.RedirectPort),
+			Handler:           RedirectHandler(cfg.Port),
+			ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+			IdleTimeout:       cfg.IdleTimeout,
+			MaxHeaderBytes:    cfg.MaxHeaderBytes,
+		}
+	}
+	return nil
+}
+
+// Run listens on the configured address and serves until ctx is done.
+func (s *Server) Run(ctx context.Context) error {
+	ln, err := net.Listen("tcp", s.HTTP.Addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", s.HTTP.Addr, err)
+	}
+	return s.Serve(ctx, ln)
+}
+
+// Serve accepts connections on ln until ctx is done, then shuts down. It
+// returns the first error of serving, draining or releasing resources.
+func (s *Server) Serve(ctx context.Context, ln net.Listener) error {
+	serveErr := make(chan error, 2)
+	go func() {
+		if s.HTTP.TLSConfig != nil {
+			// Certificates come from TLSConfig.GetCertificate
+			serveErr <- s.HTTP.ServeTLS(ln, "", "")
+		} else {
+			serveErr <- s.HTTP.Serve(ln)
+		}
+	}()
+	log.Printf("Listening on %s", ln.Addr())
+
+	if s.certs != nil {
+		watchCtx, stopWatching := context.WithCancel(ctx)
+		defer stopWatching()
+		go s.certs.Watch(watchCtx, s.reloadInterval)
+	}
+	if s.Redirect != nil {
+		go func() {
+			if err := s.Redirect.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+				serveErr <- fmt.Errorf("redirect listener: %w", err)
+			}
+		}()
+		log.Printf("Redirecting HTTP on %s to HTTPS", s.Redirect.Addr)
+	}
+
+	var err error
+	select {
+	case err = <-serveErr:
+		// The listener failed; still release resources below
+	case <-ctx.Done():
+		log.Printf("Shutting down, draining requests for up to %s", s.ShutdownTimeout)
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), s.ShutdownTimeout)
+	defer cancel()
+
+	s.mu.Lock()
+	drains := s.drains
+	s.mu.Unlock()
+	for _, drain := range drains {
+		drain()
+	}
+	if err == nil && s.DrainDelay > 0 {
+		time.Sleep(s.DrainDelay)
+	}
+
+	if s.Redirect != nil {
+		_ = s.Redirect.Shutdown(shutdownCtx)
+	}
+	if shutdownErr := s.HTTP.Shutdown(shutdownCtx); shutdownErr != nil {
+		log.Printf("HTTP server did not drain in time: %v", shutdownErr)
+		_ = s.HTTP.Close()
+		err = errors.Join(err, shutdownErr)
+	}
+	if errors.Is(err, http.ErrServerClosed) {
+		err = nil
+	}
+
+	s.mu.Lock()
+	closers := s.closers
+	s.mu.Unlock()
+	for i := len(closers) - 1; i >= 0; i-- {
+		if closeErr := closers[i].close(shutdownCtx); closeErr != nil {
+			log.Printf("Failed to close %s: %v", closers[i].name, closeErr)
+			err = errors.Join(err, fmt.Errorf("%s: %w", closers[i].name, closeErr))
+		}
+	}
+	log.Println("Shutdown complete")
+	return err
+}
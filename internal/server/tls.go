@@ -0,0 +1,198 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/paumarro/apollo-be/internal/config"
+)
+
+// CertReloader serves a key pair from disk and reloads it when the files
+// change, so renewed certificates take effect without a restart.
+type CertReloader struct {
+	CertFile string
+	KeyFile  string
+
+	mu      sync.RWMutex
+	cert    *tls.Certificate
+	modTime time.Time
+	// lastCheck and lastErr record the latest poll of Watch for health
+	// checks; reloadErr is the error of the latest reload attempt
+	lastCheck time.Time
+	lastErr   error
+	reloadErr error
+}
+
+// NewCertReloader loads the key pair once; an invalid pair is an error.
+func NewCertReloader(certFile, keyFile string) (*CertReloader, error) {
+	r := &CertReloader{CertFile: certFile, KeyFile: keyFile}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Reload reads the key pair. On error the previous certificate stays in use.
+func (r *CertReloader) Reload() error {
+	modTime, err := r.latestModTime()
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(r.CertFile, r.KeyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load TLS key pair: %w", err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cert = &cert
+	r.modTime = modTime
+	return nil
+}
+
+// GetCertificate implements tls.Config.GetCertificate.
+func (r *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, nil
+}
+
+// Watch polls the files every interval and reloads them after a change
+// until ctx is done. Failed reloads are logged and retried on the next change.
+func (r *CertReloader) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.record(r.poll())
+		}
+	}
+}
+
+// poll reloads the key pair if the files changed since the last load.
+func (r *CertReloader) poll() error {
+	modTime, err := r.latestModTime()
+	if err != nil {
+		log.Printf("Failed to check TLS certificate: %v", err)
+		return err
+	}
+	r.mu.RLock()
+	changed := modTime.After(r.modTime)
+	reloadErr := r.reloadErr
+	r.mu.RUnlock()
+	if !changed {
+		// A broken key pair stays reported until it is replaced
+		return reloadErr
+	}
+	err = r.Reload()
+	r.mu.Lock()
+	r.reloadErr = err
+	if err != nil {
+		// Do not retry the same broken files on every tick
+		r.modTime = modTime
+	}
+	r.mu.Unlock()
+	if err != nil {
+		log.Printf("Keeping previous TLS certificate: %v", err)
+		return err
+	}
+	log.Printf("Reloaded TLS certificate from %s", r.CertFile)
+	return nil
+}
+
+func (r *CertReloader) record(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.lastCheck = time.Now()
+	r.lastErr = err
+}
+
+// LastRun reports the latest poll of Watch and its error. A failed reload
+// stays reported until the files load again.
+func (r *CertReloader) LastRun() (time.Time, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.lastCheck, r.lastErr
+}
+
+func (r *CertReloader) latestModTime() (time.Time, error) {
+	var latest time.Time
+	for _, path := range []string{r.CertFile, r.KeyFile} {
+		info, err := os.Stat(path)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("failed to stat %s: %w", path, err)
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+// NewTLSConfig builds the server TLS settings: certificates from certs, the
+// configured minimum version and cipher suites, and optional client
+// certificates signed by MTLS_CLIENT_CA_FILE. Client certificates are
+// optional so browsers and token clients still work.
+func NewTLSConfig(cfg config.Server, certs *CertReloader) (*tls.Config, error) {
+	minVersion, err := config.ParseTLSVersion(cfg.TLSMinVersion)
+	if err != nil {
+		return nil, err
+	}
+	suites, err := config.ParseCipherSuites(cfg.TLSCipherSuites)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig := &tls.Config{
+		MinVersion:     minVersion,
+		CipherSuites:   suites,
+		GetCertificate: certs.GetCertificate,
+	}
+
+	if cfg.MTLSClientCAFile == "" {
+		return tlsConfig, nil
+	}
+	pemBytes, err := os.ReadFile(cfg.MTLSClientCAFile) // #nosec G304 -- path comes from operator configuration
+	if err != nil {
+		return nil, fmt.Errorf("failed to read MTLS_CLIENT_CA_FILE: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pemBytes) {
+		return nil, fmt.Errorf("no certificates found in MTLS_CLIENT_CA_FILE")
+	}
+	tlsConfig.ClientCAs = pool
+	tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	return tlsConfig, nil
+}
+
+// RedirectHandler sends plain HTTP requests to the same host and path over
+// HTTPS on httpsPort. 308 keeps the method and body of API calls.
+func RedirectHandler(httpsPort string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if host == "" {
+			http.Error(w, "missing host", http.StatusBadRequest)
+			return
+		}
+		if httpsPort != "443" {
+			host = net.JoinHostPort(host, httpsPort)
+		} else if strings.Contains(host, ":") {
+			host = "[" + host + "]"
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusPermanentRedirect)
+	})
+}
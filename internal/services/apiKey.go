@@ -0,0 +1,183 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/paumarro/apollo-be/internal/auth"
+	"github.com/paumarro/apollo-be/internal/models"
+	"github.com/paumarro/apollo-be/internal/repositories"
+)
+
+// API key errors
+var (
+	ErrInvalidAPIKey = errors.New("invalid API key")
+	ErrExpiredAPIKey = errors.New("API key expired")
+	ErrForbidden     = errors.New("forbidden")
+)
+
+const (
+	apiKeyPrefix = "abk"
+	// DefaultAPIKeyTTL is used when a key is minted without an explicit lifetime.
+	DefaultAPIKeyTTL = 90 * 24 * time.Hour
+	// MaxAPIKeyTTL caps how long a key may remain valid.
+	MaxAPIKeyTTL = 365 * 24 * time.Hour
+	// lastUsedResolution throttles last-used writes for busy keys.
+	lastUsedResolution = time.Minute
+)
+
+// APIKeyService mints, lists, revokes and authenticates API keys.
+type APIKeyService struct {
+	Repo repositories.APIKeyRepository
+	Now  func() time.Time
+}
+
+// NewAPIKeyService creates a new instance of APIKeyService.
+func NewAPIKeyService(repo repositories.APIKeyRepository) *APIKeyService {
+	return &APIKeyService{Repo: repo, Now: time.Now}
+}
+
+// MintAPIKey creates a key for one of the admin's galleries. The requested
+// roles must be a subset of the admin's own roles. It returns the plaintext
+// key, which is not stored and cannot be recovered later.
+func (s *APIKeyService) MintAPIKey(ctx context.Context, admin *auth.Principal, name, gallery string, roles []string, ttl time.Duration) (string, *models.APIKey, error) {
+	if !admin.ManagesGallery(gallery) {
+		return "", nil, fmt.Errorf("%w: gallery %q is not managed by caller", ErrForbidden, gallery)
+	}
+	for _, role := range roles {
+		if !admin.HasRole(role) {
+			return "", nil, fmt.Errorf("%w: caller does not hold role %q", ErrForbidden, role)
+		}
+	}
+	if ttl <= 0 {
+		ttl = DefaultAPIKeyTTL
+	}
+	if ttl > MaxAPIKeyTTL {
+		return "", nil, fmt.Errorf("%w: lifetime exceeds %s", ErrBadRequest, MaxAPIKeyTTL)
+	}
+
+	prefixBytes := make([]byte, 4)
+	if _, err := rand.Read(prefixBytes); err != nil {
+		return "", nil, fmt.Errorf("failed to generate API key: %w", err)
+	}
+	secret, err := randomToken(32)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to generate API key: %w", err)
+	}
+	prefix := hex.EncodeToString(prefixBytes)
+	plaintext := fmt.Sprintf("%s_%s_%s", apiKeyPrefix, prefix, secret)
+
+	key := &models.APIKey{
+		Name:      name,
+		Prefix:    prefix,
+		Hash:      HashAPIKey(plaintext),
+		Gallery:   gallery,
+		Roles:     roles,
+		CreatedBy: admin.Subject,
+		ExpiresAt: s.Now().Add(ttl),
+	}
+
+	log.Printf("Minting API key %q for gallery %s", name, gallery)
+	if err := s.Repo.Create(ctx, key); err != nil {
+		return "", nil, fmt.Errorf("failed to create API key: %w", err)
+	}
+	return plaintext, key, nil
+}
+
+// ListAPIKeys returns the keys of every gallery the admin manages.
+func (s *APIKeyService) ListAPIKeys(ctx context.Context, admin *auth.Principal) ([]models.APIKey, error) {
+	keys, err := s.Repo.FindByGalleries(ctx, admin.Galleries)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch API keys: %w", err)
+	}
+	return keys, nil
+}
+
+// RevokeAPIKey deletes a key belonging to one of the admin's galleries and
+// returns the deleted key.
+func (s *APIKeyService) RevokeAPIKey(ctx context.Context, admin *auth.Principal, id string) (*models.APIKey, error) {
+	key, err := s.Repo.FindByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to fetch API key with ID %s: %w", id, err)
+	}
+	if !admin.ManagesGallery(key.Gallery) {
+		// Do not reveal keys of other galleries
+		return nil, ErrNotFound
+	}
+
+	log.Printf("Revoking API key with ID: %s", id)
+	if err := s.Repo.Delete(ctx, id); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to revoke API key with ID %s: %w", id, err)
+	}
+	return key, nil
+}
+
+// Authenticate resolves a plaintext key to a principal and records its use.
+func (s *APIKeyService) Authenticate(ctx context.Context, plaintext string) (*auth.Principal, error) {
+	if !strings.HasPrefix(plaintext, apiKeyPrefix+"_") || len(plaintext) > 128 {
+		return nil, ErrInvalidAPIKey
+	}
+
+	key, err := s.Repo.FindByHash(ctx, HashAPIKey(plaintext))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidAPIKey
+		}
+		return nil, fmt.Errorf("failed to look up API key: %w", err)
+	}
+
+	now := s.Now()
+	if !now.Before(key.ExpiresAt) {
+		return nil, ErrExpiredAPIKey
+	}
+
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) >= lastUsedResolution {
+		if err := s.Repo.TouchLastUsed(ctx, key.ID, now); err != nil {
+			// Tracking is best effort; never fail the request over it
+			log.Printf("Failed to record API key usage for ID %d: %v", key.ID, err)
+		}
+	}
+
+	roles := key.Roles
+	if roles == nil {
+		roles = []string{}
+	}
+	return &auth.Principal{
+		Kind:      auth.KindAPIKey,
+		Subject:   "apikey:" + strconv.FormatUint(uint64(key.ID), 10),
+		Name:      key.Name,
+		Roles:     roles,
+		Galleries: []string{key.Gallery},
+	}, nil
+}
+
+// HashAPIKey returns the hex-encoded SHA-256 digest under which a key is stored.
+func HashAPIKey(plaintext string) string {
+	sum := sha256.Sum256([]byte(plaintext))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -1,6 +1,7 @@
 package services
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"log"
@@ -16,11 +17,34 @@ var (
 	ErrNotFound   = errors.New("record not found")
 	ErrBadRequest = errors.New("bad request")
 	ErrDuplicate  = errors.New("Artwork already exists")
+	// ErrNoUnitOfWork is returned by InTransaction when the service has no
+	// UnitOfWork to run it in.
+	ErrNoUnitOfWork = errors.New("no unit of work configured")
 )
 
+// DuplicateError reports the artwork that already holds the title and artist
+// of a created or updated artwork. It matches ErrDuplicate.
+type DuplicateError struct {
+	ID uint
+}
+
+func (e *DuplicateError) Error() string {
+	return fmt.Sprintf("%s: conflicts with artwork %d", ErrDuplicate, e.ID)
+}
+
+func (e *DuplicateError) Is(target error) bool {
+	return target == ErrDuplicate
+}
+
 // ArtworkService provides business logic for managing artworks.
 type ArtworkService struct {
 	Repo repositories.ArtworkRepository
+	// SimilarityThreshold is the score from which artworks are likely
+	// duplicates. DefaultSimilarityThreshold is used when it is 0.
+	SimilarityThreshold float64
+	// UnitOfWork runs InTransaction, which fails without one rather than
+	// writing outside a transaction.
+	UnitOfWork repositories.UnitOfWork
 }
 
 // NewArtworkService creates a new instance of ArtworkService.
@@ -28,24 +52,25 @@ func NewArtworkService(repo repositories.ArtworkRepository) *ArtworkService {
 	return &ArtworkService{Repo: repo}
 }
 
+// InTransaction runs fn with repositories bound to one transaction, so
+// actions that write several records keep all of them or none. fn's writes
+// are rolled back when it returns an error or panics.
+func (s *ArtworkService) InTransaction(ctx context.Context, fn func(ctx context.Context, repos repositories.Repos) error) error {
+	if s.UnitOfWork == nil {
+		return ErrNoUnitOfWork
+	}
+	return s.UnitOfWork.Do(ctx, fn)
+}
+
 // CreateArtwork creates a new artwork in the repository.
-func (s *ArtworkService) CreateArtwork(artwork *models.Artwork) error {
+func (s *ArtworkService) CreateArtwork(ctx context.Context, artwork *models.Artwork) error {
 	log.Printf("Creating artwork: %+v", artwork)
 
-	// Business rule: prevent duplicates by title + artist
-	existing, err := s.Repo.FindAll()
-	if err != nil {
-		log.Printf("Error checking duplicates: %v", err)
-		return fmt.Errorf("failed to create artwork: %w", err)
-	}
-	for _, a := range existing {
-		if a.Title == artwork.Title && a.Artist == artwork.Artist {
-			return ErrDuplicate
+	// Business rule: title + artist are unique per gallery, enforced by the database
+	if err := s.Repo.Create(ctx, artwork); err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return s.duplicate(ctx, artwork)
 		}
-	}
-
-	// Persist
-	if err := s.Repo.Create(artwork); err != nil {
 		log.Printf("Error in CreateArtwork: %v", err)
 		return fmt.Errorf("failed to create artwork: %w", err)
 	}
@@ -53,9 +78,9 @@ func (s *ArtworkService) CreateArtwork(artwork *models.Artwork) error {
 }
 
 // GetAllArtworks retrieves all artworks from the repository.
-func (s *ArtworkService) GetAllArtworks() ([]models.Artwork, error) {
+func (s *ArtworkService) GetAllArtworks(ctx context.Context) ([]models.Artwork, error) {
 	log.Println("Fetching all artworks")
-	artworks, err := s.Repo.FindAll()
+	artworks, err := s.Repo.FindAll(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch artworks: %w", err)
 	}
@@ -63,9 +88,9 @@ func (s *ArtworkService) GetAllArtworks() ([]models.Artwork, error) {
 }
 
 // GetArtworkByID retrieves a single artwork by ID.
-func (s *ArtworkService) GetArtworkByID(id string) (*models.Artwork, error) {
+func (s *ArtworkService) GetArtworkByID(ctx context.Context, id string) (*models.Artwork, error) {
 	log.Printf("Fetching artwork with ID: %s", id)
-	artwork, err := s.Repo.FindByID(id)
+	artwork, err := s.Repo.FindByID(ctx, id)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, ErrNotFound
@@ -76,18 +101,21 @@ func (s *ArtworkService) GetArtworkByID(id string) (*models.Artwork, error) {
 }
 
 // UpdateArtwork updates an artwork in the repository.
-func (s *ArtworkService) UpdateArtwork(artwork *models.Artwork) error {
+func (s *ArtworkService) UpdateArtwork(ctx context.Context, artwork *models.Artwork) error {
 	log.Printf("Updating artwork with ID: %d", artwork.ID)
-	if err := s.Repo.Update(artwork); err != nil {
+	if err := s.Repo.Update(ctx, artwork); err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return s.duplicate(ctx, artwork)
+		}
 		return fmt.Errorf("failed to update artwork with ID %d: %w", artwork.ID, err)
 	}
 	return nil
 }
 
 // DeleteArtwork deletes an artwork by ID.
-func (s *ArtworkService) DeleteArtwork(id string) error {
+func (s *ArtworkService) DeleteArtwork(ctx context.Context, id string) error {
 	log.Printf("Deleting artwork with ID: %s", id)
-	if err := s.Repo.Delete(id); err != nil {
+	if err := s.Repo.Delete(ctx, id); err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return ErrNotFound
 		}
@@ -95,3 +123,41 @@ func (s *ArtworkService) DeleteArtwork(id string) error {
 	}
 	return nil
 }
+
+// GetArtworkByIDUnscoped retrieves an artwork by ID, including soft-deleted ones.
+func (s *ArtworkService) GetArtworkByIDUnscoped(ctx context.Context, id string) (*models.Artwork, error) {
+	artwork, err := s.Repo.FindByIDUnscoped(ctx, id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to fetch artwork with ID %s: %w", id, err)
+	}
+	return artwork, nil
+}
+
+// PurgeArtwork permanently deletes an artwork by ID.
+func (s *ArtworkService) PurgeArtwork(ctx context.Context, id string) error {
+	log.Printf("Purging artwork with ID: %s", id)
+	if err := s.Repo.Purge(ctx, id); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrNotFound
+		}
+		return fmt.Errorf("failed to purge artwork with ID %s: %w", id, err)
+	}
+	return nil
+}
+
+// duplicate looks up the artwork that made a write fail the unique index.
+// The conflicting artwork can be deleted in the meantime, leaving ErrDuplicate.
+func (s *ArtworkService) duplicate(ctx context.Context, artwork *models.Artwork) error {
+	existing, err := s.Repo.FindDuplicate(ctx, artwork)
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Printf("Error looking up duplicate of artwork %q: %v", artwork.Title, err)
+		}
+		return ErrDuplicate
+	}
+	log.Printf("Artwork %q conflicts with artwork %d", artwork.Title, existing.ID)
+	return &DuplicateError{ID: existing.ID}
+}
@@ -0,0 +1,208 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/paumarro/apollo-be/internal/models"
+	"github.com/paumarro/apollo-be/internal/similarity"
+)
+
+// DefaultSimilarityThreshold is the score from which two artworks are
+// reported as likely duplicates.
+const DefaultSimilarityThreshold = 0.8
+
+// MinDuplicateScore is the lowest threshold a duplicate search accepts.
+// Below it, nearly every pair in a gallery clusters and the work grows with
+// the square of its size.
+const MinDuplicateScore = 0.5
+
+// Weights of title and artist in the score of two artworks
+const (
+	titleWeight  = 0.7
+	artistWeight = 0.3
+)
+
+// SimilarArtwork is an artwork that resembles another, with their score.
+type SimilarArtwork struct {
+	models.Artwork
+	Score float64 `json:"score"`
+}
+
+// DuplicateCluster is a group of artworks in one gallery that likely
+// describe the same work. Score is the highest score of any two of them.
+type DuplicateCluster struct {
+	Gallery  string           `json:"gallery"`
+	Score    float64          `json:"score"`
+	Artworks []models.Artwork `json:"artworks"`
+}
+
+// artworkKeys holds the similarity keys of an artwork, computed once per comparison run
+type artworkKeys struct {
+	title, artist string
+}
+
+func keysOf(artwork *models.Artwork) artworkKeys {
+	return artworkKeys{title: similarity.Key(artwork.Title), artist: similarity.Key(artwork.Artist)}
+}
+
+func (k artworkKeys) score(other artworkKeys) float64 {
+	title := similarity.ScoreKeys(k.title, other.title)
+	// A missing artist neither confirms nor rules out a match
+	if k.artist == "" || other.artist == "" {
+		return title
+	}
+	return titleWeight*title + artistWeight*similarity.ScoreKeys(k.artist, other.artist)
+}
+
+// ArtworkSimilarity scores how likely two artworks describe the same work,
+// from 0 to 1, by their titles and artists.
+func ArtworkSimilarity(a, b *models.Artwork) float64 {
+	return keysOf(a).score(keysOf(b))
+}
+
+func (s *ArtworkService) threshold() float64 {
+	if s.SimilarityThreshold > 0 {
+		return s.SimilarityThreshold
+	}
+	return DefaultSimilarityThreshold
+}
+
+// FindSimilarArtworks returns the other artworks in artwork's gallery that
+// are likely duplicates of it, best match first.
+func (s *ArtworkService) FindSimilarArtworks(ctx context.Context, artwork *models.Artwork) ([]SimilarArtwork, error) {
+	candidates, err := s.Repo.FindByGallery(ctx, artwork.Gallery)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch artworks of gallery %q: %w", artwork.Gallery, err)
+	}
+
+	keys := keysOf(artwork)
+	var similar []SimilarArtwork
+	for _, candidate := range candidates {
+		if candidate.ID == artwork.ID {
+			continue
+		}
+		if score := keys.score(keysOf(&candidate)); score >= s.threshold() {
+			similar = append(similar, SimilarArtwork{Artwork: candidate, Score: score})
+		}
+	}
+	sort.SliceStable(similar, func(i, j int) bool { return similar[i].Score > similar[j].Score })
+	return similar, nil
+}
+
+// FindDuplicateClusters groups the artworks of each of the given galleries
+// whose score reaches minScore, or the service threshold when minScore is 0.
+// Thresholds below MinDuplicateScore are raised to it. Clusters are ordered
+// by score, highest first.
+func (s *ArtworkService) FindDuplicateClusters(ctx context.Context, galleries []string, minScore float64) ([]DuplicateCluster, error) {
+	if minScore <= 0 {
+		minScore = s.threshold()
+	}
+	minScore = max(minScore, MinDuplicateScore)
+	log.Printf("Finding duplicate artworks in %d galleries with score >= %.2f", len(galleries), minScore)
+
+	// Duplicates are only looked for within a gallery
+	byGallery := map[string][]models.Artwork{}
+	for _, gallery := range galleries {
+		if _, done := byGallery[gallery]; done || gallery == "" {
+			continue
+		}
+		artworks, err := s.Repo.FindByGallery(ctx, gallery)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch artworks of gallery %q: %w", gallery, err)
+		}
+		byGallery[gallery] = artworks
+	}
+
+	var clusters []DuplicateCluster
+	for gallery, members := range byGallery {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		keys := make([]artworkKeys, len(members))
+		for i := range members {
+			keys[i] = keysOf(&members[i])
+		}
+		score := func(i, j int) float64 { return keys[i].score(keys[j]) }
+
+		for _, group := range similarity.Cluster(len(members), score, minScore) {
+			cluster := DuplicateCluster{Gallery: gallery}
+			for n, i := range group {
+				cluster.Artworks = append(cluster.Artworks, members[i])
+				for _, j := range group[n+1:] {
+					if pair := score(i, j); pair > cluster.Score {
+						cluster.Score = pair
+					}
+				}
+			}
+			clusters = append(clusters, cluster)
+		}
+	}
+
+	sort.Slice(clusters, func(i, j int) bool {
+		if clusters[i].Score != clusters[j].Score {
+			return clusters[i].Score > clusters[j].Score
+		}
+		return clusters[i].Artworks[0].ID < clusters[j].Artworks[0].ID
+	})
+	return clusters, nil
+}
+
+// MergeArtworks merges retired into keep. Fields keep leaves blank are taken
+// from retired, retired is soft-deleted, and a merge record keeps its last
+// state and redirects its ID to keep.
+func (s *ArtworkService) MergeArtworks(ctx context.Context, keep, retired *models.Artwork, mergedBy string) (*models.ArtworkMerge, error) {
+	if keep.ID == retired.ID {
+		return nil, fmt.Errorf("%w: an artwork cannot be merged into itself", ErrBadRequest)
+	}
+	if keep.Gallery != retired.Gallery {
+		return nil, fmt.Errorf("%w: artworks %d and %d belong to different galleries", ErrBadRequest, keep.ID, retired.ID)
+	}
+
+	merge := &models.ArtworkMerge{
+		ArtworkID: keep.ID,
+		RetiredID: retired.ID,
+		Retired:   *retired,
+		MergedBy:  mergedBy,
+		MergedAt:  time.Now(),
+	}
+	if keep.Description == "" {
+		keep.Description = retired.Description
+	}
+	if keep.Image == "" {
+		keep.Image = retired.Image
+	}
+	if mergedBy != "" {
+		keep.UpdatedBy = mergedBy
+	}
+
+	log.Printf("Merging artwork %d into %d", retired.ID, keep.ID)
+	if err := s.Repo.Merge(ctx, keep, retired, merge); err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			return nil, ErrNotFound
+		case errors.Is(err, gorm.ErrDuplicatedKey):
+			return nil, s.duplicate(ctx, keep)
+		}
+		return nil, fmt.Errorf("failed to merge artwork %d into %d: %w", retired.ID, keep.ID, err)
+	}
+	return merge, nil
+}
+
+// FindMerge returns the merge that retired the artwork with the given ID.
+func (s *ArtworkService) FindMerge(ctx context.Context, id string) (*models.ArtworkMerge, error) {
+	merge, err := s.Repo.FindMerge(ctx, id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to fetch merge of artwork %s: %w", id, err)
+	}
+	return merge, nil
+}
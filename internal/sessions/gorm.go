@@ -0,0 +1,101 @@
+package sessions
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql/driver"
+	"encoding/binary"
+	"errors"
+	"time"
+
+	"github.com/paumarro/apollo-be/internal/models"
+	"gorm.io/gorm"
+)
+
+// GormStore keeps sessions in the application database.
+type GormStore struct {
+	DB *gorm.DB
+	// locks serves Lock on databases without advisory locks, which only
+	// a single process uses
+	locks keyedLock
+}
+
+func NewGormStore(db *gorm.DB) *GormStore {
+	return &GormStore{DB: db}
+}
+
+func (g *GormStore) Save(ctx context.Context, s *models.Session) error {
+	return g.DB.WithContext(ctx).Save(s).Error
+}
+
+func (g *GormStore) Touch(ctx context.Context, s *models.Session, t time.Time) error {
+	return g.DB.WithContext(ctx).Model(&models.Session{}).Where("id = ?", s.ID).Update("last_seen_at", t).Error
+}
+
+// Lock takes a Postgres advisory lock on a connection of its own, so it
+// holds across replicas; other databases fall back to a process-local lock.
+func (g *GormStore) Lock(ctx context.Context, id string) (func(), error) {
+	if g.DB.Dialector.Name() != "postgres" {
+		return g.locks.lock(ctx, id)
+	}
+	sqlDB, err := g.DB.DB()
+	if err != nil {
+		return nil, err
+	}
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	key := advisoryKey(id)
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", key); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return func() {
+		if _, err := conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", key); err != nil {
+			// Closing a connection that still holds the lock discards it,
+			// so the session lock is not leaked
+			_ = conn.Raw(func(any) error { return driver.ErrBadConn })
+		}
+		conn.Close()
+	}, nil
+}
+
+// advisoryKey derives a lock key from the leading bits of a hashed session ID.
+func advisoryKey(id string) int64 {
+	sum := sha256.Sum256([]byte("session:" + id))
+	return int64(binary.BigEndian.Uint64(sum[:8]))
+}
+
+func (g *GormStore) Get(ctx context.Context, id string) (*models.Session, error) {
+	var s models.Session
+	err := g.DB.WithContext(ctx).First(&s, "id = ? AND expires_at > ?", id, time.Now()).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (g *GormStore) Delete(ctx context.Context, id string) error {
+	res := g.DB.WithContext(ctx).Delete(&models.Session{}, "id = ?", id)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (g *GormStore) List(ctx context.Context) ([]models.Session, error) {
+	// Expired rows are removed lazily here rather than by a background job
+	if err := g.DB.WithContext(ctx).Delete(&models.Session{}, "expires_at <= ?", time.Now()).Error; err != nil {
+		return nil, err
+	}
+	var out []models.Session
+	err := g.DB.WithContext(ctx).Order("created_at").Find(&out).Error
+	return out, err
+}
@@ -0,0 +1,197 @@
+package sessions
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/paumarro/apollo-be/internal/auth"
+	"github.com/paumarro/apollo-be/internal/models"
+)
+
+// CookieName is the cookie holding the opaque session ID in BFF mode.
+const CookieName = "session_id"
+
+// refreshLeeway refreshes access tokens shortly before they expire so a
+// request never forwards a token that lapses mid-flight.
+const refreshLeeway = 30 * time.Second
+
+// touchInterval throttles last-seen updates, so most requests do not write.
+const touchInterval = time.Minute
+
+// RefreshFunc exchanges a refresh token for a new access and refresh token.
+type RefreshFunc func(refreshToken string) (string, string, error)
+
+// PrincipalFunc verifies an access token and maps it to its principal.
+type PrincipalFunc func(ctx context.Context, accessToken string) (*auth.Principal, error)
+
+// Manager creates sessions and resolves session IDs to usable access tokens,
+// refreshing them transparently.
+type Manager struct {
+	Store   Store
+	Refresh RefreshFunc
+	TTL     time.Duration
+	Now     func() time.Time
+	// Principal describes sessions with the subject, name and galleries the
+	// token grants, mapped the same way as on requests. Without it sessions
+	// carry no galleries and no gallery admin can see them.
+	Principal PrincipalFunc
+}
+
+func NewManager(store Store, refresh RefreshFunc, ttl time.Duration) *Manager {
+	return &Manager{Store: store, Refresh: refresh, TTL: ttl, Now: time.Now}
+}
+
+// Create stores a new session for the tokens and returns the raw session ID for the cookie.
+func (m *Manager) Create(ctx context.Context, accessToken, refreshToken, userAgent, ip string) (string, *models.Session, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", nil, fmt.Errorf("failed to generate session ID: %w", err)
+	}
+	rawID := base64.RawURLEncoding.EncodeToString(b)
+
+	now := m.Now()
+	s := &models.Session{
+		ID:              HashID(rawID),
+		AccessToken:     accessToken,
+		RefreshToken:    refreshToken,
+		AccessExpiresAt: tokenExpiry(accessToken, now),
+		ExpiresAt:       now.Add(m.TTL),
+		UserAgent:       userAgent,
+		IP:              ip,
+		CreatedAt:       now,
+		LastSeenAt:      now,
+	}
+	m.describe(ctx, s, accessToken)
+
+	if err := m.Store.Save(ctx, s); err != nil {
+		return "", nil, fmt.Errorf("failed to save session: %w", err)
+	}
+	return rawID, s, nil
+}
+
+// AccessToken resolves a raw session ID to a current access token, refreshing
+// it when needed. It fails with ErrNotFound when the session is unknown or
+// the identity provider ended it; other errors may be transient and leave
+// the session in place.
+func (m *Manager) AccessToken(ctx context.Context, rawID string) (string, error) {
+	s, err := m.Store.Get(ctx, HashID(rawID))
+	if err != nil {
+		return "", err
+	}
+
+	now := m.Now()
+	if now.Add(refreshLeeway).Before(s.AccessExpiresAt) {
+		if now.Sub(s.LastSeenAt) >= touchInterval {
+			if err := m.Store.Touch(ctx, s, now); err != nil {
+				log.Printf("Failed to update session last-seen time: %v", err)
+			}
+		}
+		return s.AccessToken, nil
+	}
+
+	// The lock spans every replica, so only one request spends the rotating
+	// refresh token; the others wait and use what it stored
+	unlock, err := m.Store.Lock(ctx, s.ID)
+	if err != nil {
+		return "", fmt.Errorf("failed to lock session for refresh: %w", err)
+	}
+	defer unlock()
+
+	s, err = m.Store.Get(ctx, s.ID)
+	if err != nil {
+		return "", err
+	}
+	if now.Add(refreshLeeway).Before(s.AccessExpiresAt) {
+		return s.AccessToken, nil
+	}
+
+	accessToken, refreshToken, err := m.Refresh(s.RefreshToken)
+	if err != nil {
+		if !rejected(err) {
+			return "", fmt.Errorf("refresh failed: %w", err)
+		}
+		// The identity provider no longer honours this session
+		_ = m.Store.Delete(ctx, s.ID)
+		return "", fmt.Errorf("%w: refresh failed: %v", ErrNotFound, err)
+	}
+
+	s.AccessToken = accessToken
+	s.RefreshToken = refreshToken
+	s.AccessExpiresAt = tokenExpiry(accessToken, now)
+	s.LastSeenAt = now
+	m.describe(ctx, s, accessToken)
+	if err := m.Store.Save(ctx, s); err != nil {
+		return "", fmt.Errorf("failed to save refreshed session: %w", err)
+	}
+	return accessToken, nil
+}
+
+// rejected reports whether a refresh failed because the identity provider
+// refused the refresh token, as opposed to being unreachable or failing.
+func rejected(err error) bool {
+	var endpointErr *auth.TokenEndpointError
+	return errors.As(err, &endpointErr) && endpointErr.StatusCode == http.StatusBadRequest &&
+		strings.Contains(endpointErr.Body, "invalid_grant")
+}
+
+// Destroy removes the session behind a raw session ID.
+func (m *Manager) Destroy(ctx context.Context, rawID string) error {
+	return m.Revoke(ctx, HashID(rawID))
+}
+
+// Revoke removes the session with the given storage key. It waits for a
+// refresh in progress, which would otherwise store the session again.
+func (m *Manager) Revoke(ctx context.Context, id string) error {
+	unlock, err := m.Store.Lock(ctx, id)
+	if err != nil {
+		return fmt.Errorf("failed to lock session for revocation: %w", err)
+	}
+	defer unlock()
+	return m.Store.Delete(ctx, id)
+}
+
+// HashID returns the storage key for a raw session ID.
+func HashID(rawID string) string {
+	sum := sha256.Sum256([]byte(rawID))
+	return hex.EncodeToString(sum[:])
+}
+
+// tokenExpiry reads the exp claim without verifying the token; verification
+// happens in the Auth middleware on every request.
+func tokenExpiry(accessToken string, now time.Time) time.Time {
+	claims := jwt.MapClaims{}
+	if _, _, err := jwt.NewParser().ParseUnverified(accessToken, claims); err == nil {
+		if exp, ok := claims["exp"].(float64); ok {
+			return time.Unix(int64(exp), 0)
+		}
+	}
+	// Unknown lifetime: refresh on next use
+	return now
+}
+
+// describe copies the token's principal to the session so admins can tell
+// sessions apart and see those of their galleries. A token that fails
+// verification leaves the session as it was.
+func (m *Manager) describe(ctx context.Context, s *models.Session, accessToken string) {
+	if m.Principal == nil {
+		return
+	}
+	p, err := m.Principal(ctx, accessToken)
+	if err != nil {
+		log.Printf("Failed to describe session: %v", err)
+		return
+	}
+	s.Subject = p.Subject
+	s.Name = p.Name
+	s.Galleries = p.Galleries
+}
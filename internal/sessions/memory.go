@@ -0,0 +1,85 @@
+package sessions
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/paumarro/apollo-be/internal/models"
+)
+
+// MemoryStore keeps sessions in process memory. Sessions are lost on restart
+// and not shared between replicas, so it suits development and single instances.
+type MemoryStore struct {
+	mu       sync.Mutex
+	sessions map[string]models.Session
+	locks    keyedLock
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{sessions: map[string]models.Session{}}
+}
+
+func (m *MemoryStore) Save(ctx context.Context, s *models.Session) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sessions[s.ID] = *s
+	return nil
+}
+
+func (m *MemoryStore) Touch(ctx context.Context, s *models.Session, t time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stored, ok := m.sessions[s.ID]
+	if !ok {
+		return ErrNotFound
+	}
+	stored.LastSeenAt = t
+	m.sessions[s.ID] = stored
+	return nil
+}
+
+func (m *MemoryStore) Lock(ctx context.Context, id string) (func(), error) {
+	return m.locks.lock(ctx, id)
+}
+
+func (m *MemoryStore) Get(ctx context.Context, id string) (*models.Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.sessions[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if time.Now().After(s.ExpiresAt) {
+		delete(m.sessions, id)
+		return nil, ErrNotFound
+	}
+	return &s, nil
+}
+
+func (m *MemoryStore) Delete(ctx context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.sessions[id]; !ok {
+		return ErrNotFound
+	}
+	delete(m.sessions, id)
+	return nil
+}
+
+func (m *MemoryStore) List(ctx context.Context) ([]models.Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	out := make([]models.Session, 0, len(m.sessions))
+	for id, s := range m.sessions {
+		if now.After(s.ExpiresAt) {
+			delete(m.sessions, id)
+			continue
+		}
+		out = append(out, s)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].CreatedAt.Before(out[j].CreatedAt) })
+	return out, nil
+}
@@ -0,0 +1,161 @@
+package sessions
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/paumarro/apollo-be/internal/models"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	redisKeyPrefix = "apollo:session:"
+	// Last-seen times and refresh locks live beside the session so that
+	// neither rewrites its tokens; their prefixes do not match redisKeyPrefix
+	redisSeenPrefix = "apollo:session-seen:"
+	redisLockPrefix = "apollo:session-lock:"
+)
+
+// redisLockTTL bounds how long a crashed holder keeps a session locked.
+const redisLockTTL = 30 * time.Second
+
+// redisUnlock deletes the lock only if it still holds this holder's token.
+var redisUnlock = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
+
+// redisSession carries the token fields that models.Session hides from JSON.
+type redisSession struct {
+	models.Session
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// RedisStore keeps sessions in Redis or any server speaking its protocol
+// (Valkey, KeyDB, ...). Keys expire together with the session.
+type RedisStore struct {
+	Client *redis.Client
+}
+
+// NewRedisStore connects using a redis:// or rediss:// URL.
+func NewRedisStore(redisURL string) (*RedisStore, error) {
+	opts, err := redis.ParseURL(redisURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid session Redis URL: %w", err)
+	}
+	return &RedisStore{Client: redis.NewClient(opts)}, nil
+}
+
+// Close releases the connection pool.
+func (r *RedisStore) Close() error {
+	return r.Client.Close()
+}
+
+func (r *RedisStore) Save(ctx context.Context, s *models.Session) error {
+	ttl := time.Until(s.ExpiresAt)
+	if ttl <= 0 {
+		return nil
+	}
+	data, err := json.Marshal(redisSession{Session: *s, AccessToken: s.AccessToken, RefreshToken: s.RefreshToken})
+	if err != nil {
+		return err
+	}
+	return r.Client.Set(ctx, redisKeyPrefix+s.ID, data, ttl).Err()
+}
+
+func (r *RedisStore) Touch(ctx context.Context, s *models.Session, t time.Time) error {
+	ttl := time.Until(s.ExpiresAt)
+	if ttl <= 0 {
+		return nil
+	}
+	return r.Client.Set(ctx, redisSeenPrefix+s.ID, t.UnixNano(), ttl).Err()
+}
+
+// Lock holds a lease that expires after redisLockTTL, polling while another
+// holder has it.
+func (r *RedisStore) Lock(ctx context.Context, id string) (func(), error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	token := hex.EncodeToString(b)
+	key := redisLockPrefix + id
+	for {
+		ok, err := r.Client.SetNX(ctx, key, token, redisLockTTL).Result()
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return func() { redisUnlock.Run(context.Background(), r.Client, []string{key}, token) }, nil
+		}
+		select {
+		case <-time.After(50 * time.Millisecond):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+func (r *RedisStore) Get(ctx context.Context, id string) (*models.Session, error) {
+	values, err := r.Client.MGet(ctx, redisKeyPrefix+id, redisSeenPrefix+id).Result()
+	if err != nil {
+		return nil, err
+	}
+	data, ok := values[0].(string)
+	if !ok {
+		return nil, ErrNotFound
+	}
+	var rs redisSession
+	if err := json.Unmarshal([]byte(data), &rs); err != nil {
+		return nil, err
+	}
+	s := rs.Session
+	s.AccessToken = rs.AccessToken
+	s.RefreshToken = rs.RefreshToken
+	if seen, ok := values[1].(string); ok {
+		if nanos, err := strconv.ParseInt(seen, 10, 64); err == nil && time.Unix(0, nanos).After(s.LastSeenAt) {
+			s.LastSeenAt = time.Unix(0, nanos)
+		}
+	}
+	return &s, nil
+}
+
+func (r *RedisStore) Delete(ctx context.Context, id string) error {
+	n, err := r.Client.Del(ctx, redisKeyPrefix+id).Result()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return r.Client.Del(ctx, redisSeenPrefix+id).Err()
+}
+
+func (r *RedisStore) List(ctx context.Context) ([]models.Session, error) {
+	var out []models.Session
+	iter := r.Client.Scan(ctx, 0, redisKeyPrefix+"*", 100).Iterator()
+	for iter.Next(ctx) {
+		s, err := r.Get(ctx, iter.Val()[len(redisKeyPrefix):])
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, *s)
+	}
+	if err := iter.Err(); err != nil {
+		return nil, err
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].CreatedAt.Before(out[j].CreatedAt) })
+	return out, nil
+}
@@ -0,0 +1,62 @@
+// Package sessions keeps identity provider tokens server-side for the
+// backend-for-frontend (BFF) session mode.
+package sessions
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/paumarro/apollo-be/internal/models"
+)
+
+// ErrNotFound is returned for unknown or expired sessions.
+var ErrNotFound = errors.New("session not found")
+
+// Store persists sessions by hashed ID. Implementations must be safe for concurrent use.
+type Store interface {
+	Save(ctx context.Context, s *models.Session) error
+	// Touch records that s was used at t without writing its tokens back.
+	Touch(ctx context.Context, s *models.Session, t time.Time) error
+	// Lock waits until no one else, in any process, holds the lock of the
+	// session with the given ID, and takes it until unlock is called.
+	Lock(ctx context.Context, id string) (unlock func(), err error)
+	Get(ctx context.Context, id string) (*models.Session, error)
+	Delete(ctx context.Context, id string) error
+	List(ctx context.Context) ([]models.Session, error)
+}
+
+// keyedLock is a lock per session ID for stores used by a single process.
+type keyedLock struct {
+	mu   sync.Mutex
+	held map[string]chan struct{}
+}
+
+func (l *keyedLock) lock(ctx context.Context, id string) (func(), error) {
+	for {
+		l.mu.Lock()
+		if l.held == nil {
+			l.held = map[string]chan struct{}{}
+		}
+		released, busy := l.held[id]
+		if !busy {
+			released = make(chan struct{})
+			l.held[id] = released
+			l.mu.Unlock()
+			return func() {
+				l.mu.Lock()
+				delete(l.held, id)
+				l.mu.Unlock()
+				close(released)
+			}, nil
+		}
+		l.mu.Unlock()
+
+		select {
+		case <-released:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
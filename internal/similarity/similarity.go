@@ -0,0 +1,198 @@
+// Package similarity scores how alike two short texts are, such as artwork
+// titles and artist names, to find records that describe the same thing.
+//
+// Texts are compared by key: case and diacritics are folded, punctuation and
+// leading articles are dropped and the remaining tokens are sorted, so "The
+// Starry Night" and "Starry Night, The" share a key. Keys are scored by
+// trigram overlap and by edit distance, whichever finds them closer.
+package similarity
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// articles are dropped from keys in the languages gallery records mostly use.
+var articles = map[string]bool{
+	"a": true, "an": true, "the": true,
+	"le": true, "la": true, "les": true, "l": true, "un": true, "une": true,
+	"el": true, "los": true, "las": true, "il": true, "lo": true, "gli": true,
+	"der": true, "die": true, "das": true, "de": true, "het": true,
+}
+
+// ligatures are letters that do not decompose into a base letter and a mark.
+var ligatures = strings.NewReplacer(
+	"ß", "ss", "æ", "ae", "œ", "oe", "ø", "o", "ł", "l", "đ", "d", "ð", "d", "þ", "th", "ı", "i",
+)
+
+// Fold lower-cases s and strips its diacritics, so "Dalí" becomes "dali".
+func Fold(s string) string {
+	t := transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+	folded, _, err := transform.String(t, strings.ToLower(s))
+	if err != nil {
+		folded = strings.ToLower(s)
+	}
+	return ligatures.Replace(folded)
+}
+
+// Tokens returns the folded words of s without punctuation and articles, in
+// their original order. A text made only of articles keeps them.
+func Tokens(s string) []string {
+	words := strings.FieldsFunc(Fold(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	tokens := make([]string, 0, len(words))
+	for _, w := range words {
+		if !articles[w] {
+			tokens = append(tokens, w)
+		}
+	}
+	if len(tokens) == 0 {
+		return words
+	}
+	return tokens
+}
+
+// Key returns the sorted tokens of s joined by spaces. Texts with the same
+// key are considered identical.
+func Key(s string) string {
+	tokens := Tokens(s)
+	sort.Strings(tokens)
+	return strings.Join(tokens, " ")
+}
+
+// Score compares two texts and returns their similarity between 0 and 1.
+func Score(a, b string) float64 {
+	return ScoreKeys(Key(a), Key(b))
+}
+
+// ScoreKeys compares two keys returned by Key. Comparing precomputed keys
+// saves folding each text again when one is compared with many others.
+func ScoreKeys(a, b string) float64 {
+	if a == "" || b == "" {
+		return 0
+	}
+	if a == b {
+		return 1
+	}
+	trigram := Trigram(a, b)
+	if edit := EditSimilarity(a, b); edit > trigram {
+		return edit
+	}
+	return trigram
+}
+
+// Trigram returns the Jaccard similarity of the character trigrams of a and
+// b, padded so short words and word boundaries count.
+func Trigram(a, b string) float64 {
+	ta, tb := trigrams(a), trigrams(b)
+	if len(ta) == 0 || len(tb) == 0 {
+		return 0
+	}
+	shared := 0
+	for g := range ta {
+		if tb[g] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(ta)+len(tb)-shared)
+}
+
+func trigrams(s string) map[string]bool {
+	set := map[string]bool{}
+	for _, word := range strings.Fields(s) {
+		r := []rune("  " + word + " ")
+		for i := 0; i+3 <= len(r); i++ {
+			set[string(r[i:i+3])] = true
+		}
+	}
+	return set
+}
+
+// EditSimilarity scales the Levenshtein distance of a and b by the length of
+// the longer one, so 1 means equal and 0 means nothing in common.
+func EditSimilarity(a, b string) float64 {
+	ra, rb := []rune(a), []rune(b)
+	longest := len(ra)
+	if len(rb) > longest {
+		longest = len(rb)
+	}
+	if longest == 0 {
+		return 1
+	}
+	return 1 - float64(levenshtein(ra, rb))/float64(longest)
+}
+
+// Levenshtein returns the number of single-rune insertions, deletions and
+// substitutions that turn a into b.
+func Levenshtein(a, b string) int {
+	return levenshtein([]rune(a), []rune(b))
+}
+
+func levenshtein(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
+
+// Cluster groups the items 0..n-1 whose pairwise score reaches threshold,
+// joining clusters transitively. Only groups of two or more are returned,
+// each in ascending order. Every pair is scored, so n should be kept to what
+// is compared together, such as one gallery.
+func Cluster(n int, score func(i, j int) float64, threshold float64) [][]int {
+	parent := make([]int, n)
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+
+	for i := 0; i < n; i++ {
+		for j := i + 1; j < n; j++ {
+			if find(i) != find(j) && score(i, j) >= threshold {
+				parent[find(j)] = find(i)
+			}
+		}
+	}
+
+	groups := map[int][]int{}
+	var roots []int
+	for i := 0; i < n; i++ {
+		root := find(i)
+		if _, ok := groups[root]; !ok {
+			roots = append(roots, root)
+		}
+		groups[root] = append(groups[root], i)
+	}
+	var clusters [][]int
+	for _, root := range roots {
+		if len(groups[root]) > 1 {
+			clusters = append(clusters, groups[root])
+		}
+	}
+	return clusters
+}
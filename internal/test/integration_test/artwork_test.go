@@ -2,6 +2,7 @@ package integration_test
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -12,33 +13,59 @@ import (
 	"testing"
 
 	"github.com/gin-gonic/gin"
+	"github.com/paumarro/apollo-be/internal/config"
 	"github.com/paumarro/apollo-be/internal/controllers"
 	"github.com/paumarro/apollo-be/internal/dto"
 	"github.com/paumarro/apollo-be/internal/initializers"
-	"github.com/paumarro/apollo-be/internal/models"
+	"github.com/paumarro/apollo-be/internal/migrate"
 	"github.com/paumarro/apollo-be/internal/repositories"
 	"github.com/paumarro/apollo-be/internal/services"
-	env "github.com/paumarro/apollo-be/pkg"
 	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
 )
 
 var testArtworkID string
 
+// testDB is the database under test, configured like the server
+var testDB *gorm.DB
+
 func TestMain(m *testing.M) {
-	// Load environment variables
-	env.LoadEnvVariables("../../../.env")
+	// Load configuration, including the repository's .env file if there is one
+	var args []string
+	if _, err := os.Stat("../../../.env"); err == nil {
+		args = []string{"-env-file", "../../../.env"}
+	}
+	cfg, err := config.Load(args, os.LookupEnv)
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
 
 	// Connect to the database
-	initializers.ConnectToDB()
+	testDB, err = initializers.ConnectToDB(context.Background(), cfg.Database)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
 
-	// Run migrations
-	initializers.DB.AutoMigrate(&models.Artwork{})
+	// Run migrations; ART_DB_URL=sqlite::memory: runs the suite without Postgres
+	sqlDB, err := testDB.DB()
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	migrator, err := migrate.ForDialect(sqlDB, testDB.Dialector.Name())
+	if err != nil {
+		log.Fatalf("Failed to load migrations: %v", err)
+	}
+	if _, err := migrator.Up(context.Background()); err != nil {
+		log.Fatalf("Failed to migrate database: %v", err)
+	}
 
 	// Run the tests
 	code := m.Run()
 
 	// Cleanup database
-	initializers.DB.Exec("DROP TABLE artworks")
+	if _, err := migrator.Down(context.Background(), len(migrator.Migrations)); err != nil {
+		log.Printf("Failed to revert migrations: %v", err)
+	}
 
 	os.Exit(code)
 }
@@ -69,9 +96,7 @@ func setupContext(method string, body []byte, params map[string]string) (*gin.Co
 }
 
 func TestArtworkIntegration(t *testing.T) {
-	log.Printf("initializers.DB: %+v", initializers.DB)
-
-	repo := repositories.NewGormArtworkRepository(initializers.DB)
+	repo := repositories.NewGormArtworkRepository(testDB)
 	service := services.NewArtworkService(repo)
 	controller := controllers.NewArtworkController(service)
 
@@ -0,0 +1,102 @@
+package integration_test
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/paumarro/apollo-be/internal/auth"
+	"github.com/paumarro/apollo-be/internal/controllers"
+	"github.com/paumarro/apollo-be/internal/devidp"
+	"github.com/paumarro/apollo-be/internal/middleware"
+	"github.com/paumarro/apollo-be/internal/repositories"
+	"github.com/paumarro/apollo-be/internal/services"
+	"github.com/stretchr/testify/require"
+)
+
+// setupAuthRouter mirrors main.go's gallery routes with authentication enabled,
+// backed by the development identity provider.
+func setupAuthRouter(t *testing.T) (*gin.Engine, *devidp.Provider) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	r := gin.New()
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+
+	provider := devidp.New(srv.URL+"/realms/apollo", "apollo-client", key)
+	provider.Register(r.Group("/realms/apollo"))
+	authenticator := &middleware.Authenticator{
+		ClientID: "apollo-client",
+		OIDC:     auth.NewOIDCClient(provider.Issuer, "apollo-client", "", srv.URL+"/auth/callback"),
+		Verifier: &auth.JWTVerifier{JWKSURL: provider.Issuer + "/protocol/openid-connect/certs"},
+	}
+
+	policies, err := auth.LoadEngine("")
+	require.NoError(t, err)
+
+	controller := controllers.NewArtworkController(services.NewArtworkService(repositories.NewGormArtworkRepository(testDB)))
+	controller.Policies = policies
+
+	artworks := r.Group("/gallery/artworks")
+	artworks.Use(authenticator.Auth(""), middleware.Sanitize(), middleware.Validate())
+	artworks.POST("", controller.Create)
+	artworks.PUT("/:id", controller.Update)
+
+	return r, provider
+}
+
+func TestIntegration_Auth_RoleChecks(t *testing.T) {
+	r, provider := setupAuthRouter(t)
+
+	body, _ := json.Marshal(map[string]any{
+		"title":       "The Raft of the Medusa",
+		"artist":      "Theodore Gericault",
+		"description": "Oil on canvas",
+		"image":       "https://example.com/medusa.jpg",
+		"gallery":     "louvre",
+	})
+
+	post := func(token string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/gallery/artworks", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("Anonymous Redirected To Login", func(t *testing.T) {
+		require.Equal(t, http.StatusFound, post("").Code)
+	})
+
+	t.Run("Editor Of Other Gallery Forbidden", func(t *testing.T) {
+		token, err := provider.Mint(devidp.Identity{Subject: "prado-editor", Roles: []string{auth.RoleGallery}, Galleries: []string{"prado"}}, 0)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusForbidden, post(token).Code)
+	})
+
+	t.Run("Editor Of Gallery Creates", func(t *testing.T) {
+		token, err := provider.Mint(devidp.Identity{Subject: "louvre-editor", Roles: []string{auth.RoleGallery}, Galleries: []string{"louvre"}}, 0)
+		require.NoError(t, err)
+
+		w := post(token)
+		require.Equal(t, http.StatusCreated, w.Code)
+
+		var resp map[string]any
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		art := resp["artwork"].(map[string]any)
+		require.Equal(t, "louvre-editor", art["created_by"])
+		require.Equal(t, "louvre", art["gallery"])
+	})
+}
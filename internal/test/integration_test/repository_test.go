@@ -0,0 +1,25 @@
+package integration_test
+
+import (
+	"testing"
+
+	"github.com/paumarro/apollo-be/internal/repositories"
+	"github.com/paumarro/apollo-be/internal/repositories/repotest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestArtworkRepositoryContract_Database(t *testing.T) {
+	repotest.ArtworkRepository(t, func(t *testing.T) repositories.ArtworkRepository {
+		require.NoError(t, testDB.Exec("DELETE FROM artwork_merges").Error)
+		require.NoError(t, testDB.Exec("DELETE FROM artworks").Error)
+		return repositories.NewGormArtworkRepository(testDB)
+	})
+}
+
+func TestUnitOfWorkContract_Database(t *testing.T) {
+	repotest.UnitOfWork(t, func(t *testing.T) (repositories.UnitOfWork, repositories.ArtworkRepository) {
+		require.NoError(t, testDB.Exec("DELETE FROM artwork_merges").Error)
+		require.NoError(t, testDB.Exec("DELETE FROM artworks").Error)
+		return repositories.NewGormUnitOfWork(testDB), repositories.NewGormArtworkRepository(testDB)
+	})
+}
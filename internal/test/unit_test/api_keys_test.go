@@ -0,0 +1,219 @@
+package unit_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/gin-gonic/gin"
+	"github.com/paumarro/apollo-be/internal/audit"
+	"github.com/paumarro/apollo-be/internal/auth"
+	"github.com/paumarro/apollo-be/internal/controllers"
+	"github.com/paumarro/apollo-be/internal/middleware"
+	"github.com/paumarro/apollo-be/internal/models"
+	"github.com/paumarro/apollo-be/internal/repositories"
+	"github.com/paumarro/apollo-be/internal/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+var fixedNow = time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+func setupAPIKeyService() (*repositories.MockAPIKeyRepository, *services.APIKeyService) {
+	mockRepo := &repositories.MockAPIKeyRepository{}
+	service := services.NewAPIKeyService(mockRepo)
+	service.Now = func() time.Time { return fixedNow }
+	return mockRepo, service
+}
+
+func galleryAdmin() *auth.Principal {
+	return &auth.Principal{
+		Kind:      auth.KindUser,
+		Subject:   "admin-1",
+		Roles:     []string{auth.RoleGallery, auth.RoleGalleryAdmin},
+		Galleries: []string{"louvre"},
+	}
+}
+
+func TestMintAPIKey(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		mockRepo, service := setupAPIKeyService()
+		mockRepo.On("Create", mock.Anything, mock.AnythingOfType("*models.APIKey")).Return(nil)
+
+		plaintext, key, err := service.MintAPIKey(context.Background(), galleryAdmin(), "CMS sync", "louvre", []string{auth.RoleGallery}, 0)
+
+		require.NoError(t, err)
+		assert.True(t, strings.HasPrefix(plaintext, "abk_"+key.Prefix+"_"))
+		assert.Equal(t, services.HashAPIKey(plaintext), key.Hash)
+		assert.NotContains(t, key.Hash, plaintext)
+		assert.Equal(t, fixedNow.Add(services.DefaultAPIKeyTTL), key.ExpiresAt)
+		assert.Equal(t, "admin-1", key.CreatedBy)
+	})
+
+	t.Run("ForeignGallery", func(t *testing.T) {
+		mockRepo, service := setupAPIKeyService()
+
+		_, _, err := service.MintAPIKey(context.Background(), galleryAdmin(), "CMS sync", "prado", []string{auth.RoleGallery}, 0)
+
+		assert.True(t, errors.Is(err, services.ErrForbidden))
+		mockRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+	})
+
+	t.Run("RoleEscalation", func(t *testing.T) {
+		mockRepo, service := setupAPIKeyService()
+
+		_, _, err := service.MintAPIKey(context.Background(), galleryAdmin(), "CMS sync", "louvre", []string{"SuperUser"}, 0)
+
+		assert.True(t, errors.Is(err, services.ErrForbidden))
+		mockRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+	})
+
+	t.Run("LifetimeTooLong", func(t *testing.T) {
+		_, service := setupAPIKeyService()
+
+		_, _, err := service.MintAPIKey(context.Background(), galleryAdmin(), "CMS sync", "louvre", []string{auth.RoleGallery}, 2*services.MaxAPIKeyTTL)
+
+		assert.True(t, errors.Is(err, services.ErrBadRequest))
+	})
+}
+
+func TestAuthenticateAPIKey(t *testing.T) {
+	const plaintext = "abk_0a1b2c3d_secret"
+
+	t.Run("Valid", func(t *testing.T) {
+		mockRepo, service := setupAPIKeyService()
+		mockRepo.On("FindByHash", mock.Anything, services.HashAPIKey(plaintext)).Return(&models.APIKey{
+			ID: 7, Name: "CMS sync", Gallery: "louvre", Roles: []string{auth.RoleGallery},
+			ExpiresAt: fixedNow.Add(time.Hour),
+		}, nil)
+		mockRepo.On("TouchLastUsed", mock.Anything, uint(7), fixedNow).Return(nil)
+
+		principal, err := service.Authenticate(context.Background(), plaintext)
+
+		require.NoError(t, err)
+		assert.Equal(t, auth.KindAPIKey, principal.Kind)
+		assert.Equal(t, "apikey:7", principal.Subject)
+		assert.True(t, principal.HasRole(auth.RoleGallery))
+		assert.True(t, principal.ManagesGallery("louvre"))
+		mockRepo.AssertCalled(t, "TouchLastUsed", mock.Anything, uint(7), fixedNow)
+	})
+
+	t.Run("RecentlyUsedSkipsTouch", func(t *testing.T) {
+		mockRepo, service := setupAPIKeyService()
+		lastUsed := fixedNow.Add(-10 * time.Second)
+		mockRepo.On("FindByHash", mock.Anything, services.HashAPIKey(plaintext)).Return(&models.APIKey{
+			ID: 7, Gallery: "louvre", ExpiresAt: fixedNow.Add(time.Hour), LastUsedAt: &lastUsed,
+		}, nil)
+
+		_, err := service.Authenticate(context.Background(), plaintext)
+
+		require.NoError(t, err)
+		mockRepo.AssertNotCalled(t, "TouchLastUsed", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Expired", func(t *testing.T) {
+		mockRepo, service := setupAPIKeyService()
+		mockRepo.On("FindByHash", mock.Anything, services.HashAPIKey(plaintext)).Return(&models.APIKey{
+			ID: 7, Gallery: "louvre", ExpiresAt: fixedNow.Add(-time.Hour),
+		}, nil)
+
+		_, err := service.Authenticate(context.Background(), plaintext)
+
+		assert.True(t, errors.Is(err, services.ErrExpiredAPIKey))
+	})
+
+	t.Run("Unknown", func(t *testing.T) {
+		mockRepo, service := setupAPIKeyService()
+		mockRepo.On("FindByHash", mock.Anything, services.HashAPIKey(plaintext)).Return(nil, gorm.ErrRecordNotFound)
+
+		_, err := service.Authenticate(context.Background(), plaintext)
+
+		assert.True(t, errors.Is(err, services.ErrInvalidAPIKey))
+	})
+}
+
+func TestAuthOrAPIKeyMiddleware(t *testing.T) {
+	const plaintext = "abk_0a1b2c3d_secret"
+
+	setupRouter := func(requiredRole string) *gin.Engine {
+		mockRepo, service := setupAPIKeyService()
+		mockRepo.On("FindByHash", mock.Anything, services.HashAPIKey(plaintext)).Return(&models.APIKey{
+			ID: 3, Name: "CMS sync", Gallery: "louvre", Roles: []string{auth.RoleGallery},
+			ExpiresAt: fixedNow.Add(time.Hour),
+		}, nil)
+		mockRepo.On("FindByHash", mock.Anything, mock.Anything).Return(nil, gorm.ErrRecordNotFound)
+		mockRepo.On("TouchLastUsed", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+		gin.SetMode(gin.TestMode)
+		r := gin.New()
+		r.GET("/me", (&middleware.Authenticator{ClientID: "apollo-client"}).AuthOrAPIKey(requiredRole, service), func(c *gin.Context) {
+			p, _ := auth.PrincipalFrom(c)
+			c.JSON(http.StatusOK, p)
+		})
+		return r
+	}
+
+	t.Run("ValidKey", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/me", nil)
+		req.Header.Set(middleware.APIKeyHeader, plaintext)
+
+		setupRouter(auth.RoleGallery).ServeHTTP(w, req)
+
+		require.Equal(t, http.StatusOK, w.Code)
+		var p auth.Principal
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &p))
+		assert.Equal(t, "apikey:3", p.Subject)
+		assert.Equal(t, []string{"louvre"}, p.Galleries)
+	})
+
+	t.Run("InvalidKey", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/me", nil)
+		req.Header.Set(middleware.APIKeyHeader, "abk_unknown_key")
+
+		setupRouter(auth.RoleGallery).ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("MissingRole", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/me", nil)
+		req.Header.Set(middleware.APIKeyHeader, plaintext)
+
+		setupRouter(auth.RoleGalleryAdmin).ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+}
+
+func TestAPIKeyDelete_RecordsGallery(t *testing.T) {
+	store := useAuditStore(t)
+	mockRepo, service := setupAPIKeyService()
+	mockRepo.On("FindByID", mock.Anything, "7").Return(&models.APIKey{ID: 7, Prefix: "0a1b2c3d", Gallery: "louvre"}, nil)
+	mockRepo.On("Delete", mock.Anything, "7").Return(nil)
+
+	w := httptest.NewRecorder()
+	c := newTestContext(w)
+	c.Params = gin.Params{{Key: "id", Value: "7"}}
+	auth.SetPrincipal(c, galleryAdmin())
+	controllers.NewAPIKeyController(service).Delete(c)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	// Admins of the key's gallery see its revocation in their trail
+	events, err := store.Query(context.Background(), audit.Filter{Galleries: []string{"louvre"}, Type: audit.TypeAdminAction})
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, "louvre", events[0].Gallery)
+	assert.Equal(t, "api_key.revoke", events[0].Reason)
+	assert.Equal(t, "admin-1", events[0].Actor)
+}
@@ -0,0 +1,278 @@
+package unit_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/paumarro/apollo-be/internal/auth"
+	"github.com/paumarro/apollo-be/internal/controllers"
+	"github.com/paumarro/apollo-be/internal/dto"
+	"github.com/paumarro/apollo-be/internal/models"
+	"github.com/paumarro/apollo-be/internal/repositories"
+	"github.com/paumarro/apollo-be/internal/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// seedArtworks stores artworks in a fresh in-memory repository and returns
+// a service over it with the stored artworks, IDs assigned.
+func seedArtworks(t *testing.T, artworks ...models.Artwork) (*services.ArtworkService, []models.Artwork) {
+	t.Helper()
+	repo := repositories.NewMemoryArtworkRepository()
+	for i := range artworks {
+		require.NoError(t, repo.Create(context.Background(), &artworks[i]))
+	}
+	return services.NewArtworkService(repo), artworks
+}
+
+// duplicatesRouter serves the duplicate review endpoints as the given principal
+func duplicatesRouter(ac *controllers.ArtworkController, principal *auth.Principal) *gin.Engine {
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		if principal != nil {
+			auth.SetPrincipal(c, principal)
+		}
+	})
+	r.GET("/gallery/artworks/duplicates", ac.Duplicates)
+	r.POST("/gallery/artworks/merge", ac.Merge)
+	r.GET("/gallery/artworks/:id", ac.Find)
+	return r
+}
+
+func mergeRequest(keep, retire uint) *http.Request {
+	body, _ := json.Marshal(dto.MergeRequest{KeepID: keep, RetireID: retire})
+	req := httptest.NewRequest(http.MethodPost, "/gallery/artworks/merge", strings.NewReader(string(body)))
+	req.Header.Set("Content-Type", "application/json")
+	return req
+}
+
+func TestArtworkSimilarity(t *testing.T) {
+	starry := &models.Artwork{Title: "The Starry Night", Artist: "Vincent van Gogh"}
+	tests := []struct {
+		name  string
+		other *models.Artwork
+		match bool
+	}{
+		{"ReorderedArticle", &models.Artwork{Title: "Starry Night, The", Artist: "Vincent van Gogh"}, true},
+		{"ShortArtistName", &models.Artwork{Title: "Starry Night", Artist: "Van Gogh"}, true},
+		{"MissingArtist", &models.Artwork{Title: "The Starry Night"}, true},
+		{"OtherArtist", &models.Artwork{Title: "The Starry Night", Artist: "Edvard Munch"}, false},
+		{"OtherTitle", &models.Artwork{Title: "Sunflowers", Artist: "Vincent van Gogh"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			score := services.ArtworkSimilarity(starry, tt.other)
+			assert.Equal(t, tt.match, score >= services.DefaultSimilarityThreshold, "score %.2f", score)
+		})
+	}
+}
+
+func TestFindSimilarArtworks(t *testing.T) {
+	service, artworks := seedArtworks(t,
+		models.Artwork{Title: "Mona Lisa", Artist: "Leonardo da Vinci", Gallery: "louvre"},
+		models.Artwork{Title: "Mona Liza", Artist: "Leonardo da Vinci", Gallery: "louvre"},
+		models.Artwork{Title: "Mona Lisa", Artist: "Leonardo da Vinci", Gallery: "prado"},
+		models.Artwork{Title: "Liberty Leading the People", Artist: "Eugène Delacroix", Gallery: "louvre"},
+	)
+
+	similar, err := service.FindSimilarArtworks(context.Background(), &artworks[0])
+	require.NoError(t, err)
+	require.Len(t, similar, 1, "other galleries and unrelated works are not similar")
+	assert.Equal(t, artworks[1].ID, similar[0].ID)
+	assert.Greater(t, similar[0].Score, 0.9)
+}
+
+func TestFindDuplicateClusters(t *testing.T) {
+	service, artworks := seedArtworks(t,
+		models.Artwork{Title: "The Starry Night", Artist: "Vincent van Gogh", Gallery: "moma"},
+		models.Artwork{Title: "Starry Night, The", Artist: "Van Gogh", Gallery: "moma"},
+		models.Artwork{Title: "Starry Night", Artist: "Vincent van Gogh", Gallery: "moma", Status: models.StatusDraft},
+		models.Artwork{Title: "The Persistence of Memory", Artist: "Salvador Dalí", Gallery: "moma"},
+		models.Artwork{Title: "Persistence of Memory", Artist: "Salvador Dali", Gallery: "moma"},
+		models.Artwork{Title: "The Starry Night", Artist: "Vincent van Gogh", Gallery: "orsay"},
+	)
+
+	clusters, err := service.FindDuplicateClusters(context.Background(), []string{"moma", "orsay"}, 0)
+	require.NoError(t, err)
+	require.Len(t, clusters, 2)
+	for _, cluster := range clusters {
+		assert.Equal(t, "moma", cluster.Gallery)
+		assert.Equal(t, 1.0, cluster.Score)
+	}
+	ids := func(cluster services.DuplicateCluster) []uint {
+		var ids []uint
+		for _, artwork := range cluster.Artworks {
+			ids = append(ids, artwork.ID)
+		}
+		return ids
+	}
+	assert.Equal(t, []uint{artworks[0].ID, artworks[1].ID, artworks[2].ID}, ids(clusters[0]))
+	assert.Equal(t, []uint{artworks[3].ID, artworks[4].ID}, ids(clusters[1]))
+
+	t.Run("OnlyGivenGalleries", func(t *testing.T) {
+		clusters, err := service.FindDuplicateClusters(context.Background(), []string{"orsay"}, 0)
+		require.NoError(t, err)
+		assert.Empty(t, clusters)
+	})
+
+	t.Run("ScoreFloor", func(t *testing.T) {
+		clusters, err := service.FindDuplicateClusters(context.Background(), []string{"moma"}, 0.01)
+		require.NoError(t, err)
+		assert.Len(t, clusters, 2, "unrelated works do not cluster below the floor")
+	})
+
+	t.Run("Endpoint", func(t *testing.T) {
+		engine, err := auth.NewEngine(auth.DefaultRules())
+		require.NoError(t, err)
+		ac := controllers.NewArtworkController(service)
+		ac.Policies = engine
+		curator := &auth.Principal{Subject: "c", Roles: []string{auth.RoleGallery}, Galleries: []string{"moma"}}
+		foreign := &auth.Principal{Subject: "f", Roles: []string{auth.RoleGalleryAdmin}, Galleries: []string{"orsay"}}
+		get := func(principal *auth.Principal, query string) *httptest.ResponseRecorder {
+			w := httptest.NewRecorder()
+			duplicatesRouter(ac, principal).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/gallery/artworks/duplicates"+query, nil))
+			return w
+		}
+		clustersOf := func(w *httptest.ResponseRecorder) []services.DuplicateCluster {
+			require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+			var response struct {
+				Clusters []services.DuplicateCluster `json:"clusters"`
+			}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			return response.Clusters
+		}
+
+		response := clustersOf(get(curator, ""))
+		require.Len(t, response, 2)
+		assert.Equal(t, []uint{artworks[0].ID, artworks[1].ID, artworks[2].ID}, ids(response[0]))
+		assert.Empty(t, clustersOf(get(foreign, "")), "only the caller's galleries are scanned")
+
+		// Viewers and anonymous callers may not scan galleries
+		viewer := &auth.Principal{Subject: "v", Roles: []string{auth.RoleGalleryViewer}, Galleries: []string{"moma"}}
+		assert.Equal(t, http.StatusForbidden, get(viewer, "").Code)
+		assert.Equal(t, http.StatusForbidden, get(nil, "").Code)
+
+		assert.Equal(t, http.StatusBadRequest, get(curator, "?min_score=2").Code)
+		assert.Equal(t, http.StatusBadRequest, get(curator, "?min_score=0.1").Code)
+		assert.Len(t, clustersOf(get(curator, "?min_score=0.5")), 2)
+	})
+}
+
+func TestMergeArtworks(t *testing.T) {
+	engine, err := auth.NewEngine(auth.DefaultRules())
+	require.NoError(t, err)
+	editor := &auth.Principal{Subject: "e", Roles: []string{auth.RoleGallery}, Galleries: []string{"louvre"}}
+	viewer := &auth.Principal{Subject: "v", Roles: []string{auth.RoleGalleryViewer}, Galleries: []string{"louvre"}}
+
+	setup := func(t *testing.T) (*controllers.ArtworkController, []models.Artwork) {
+		service, artworks := seedArtworks(t,
+			models.Artwork{Title: "Mona Lisa", Artist: "Leonardo da Vinci", Gallery: "louvre"},
+			models.Artwork{Title: "Mona Liza", Artist: "Leonardo", Description: "Portrait of Lisa Gherardini", Image: "https://img.example/mona.jpg", Gallery: "louvre"},
+			models.Artwork{Title: "La Gioconda", Artist: "Leonardo da Vinci", Gallery: "louvre"},
+			models.Artwork{Title: "Las Meninas", Artist: "Diego Velázquez", Gallery: "prado"},
+		)
+		ac := controllers.NewArtworkController(service)
+		ac.Policies = engine
+		return ac, artworks
+	}
+
+	t.Run("CombinesAndRedirects", func(t *testing.T) {
+		ac, artworks := setup(t)
+		keep, retired, older := artworks[0], artworks[1], artworks[2]
+
+		// An earlier merge into the artwork that is now retired
+		w := httptest.NewRecorder()
+		duplicatesRouter(ac, editor).ServeHTTP(w, mergeRequest(retired.ID, older.ID))
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		w = httptest.NewRecorder()
+		duplicatesRouter(ac, editor).ServeHTTP(w, mergeRequest(keep.ID, retired.ID))
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var response struct {
+			Artwork models.Artwork      `json:"artwork"`
+			Merge   models.ArtworkMerge `json:"merge"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, "Mona Lisa", response.Artwork.Title, "the kept artwork's fields win")
+		assert.Equal(t, "Portrait of Lisa Gherardini", response.Artwork.Description, "blank fields are filled")
+		assert.Equal(t, "https://img.example/mona.jpg", response.Artwork.Image)
+		assert.Equal(t, "e", response.Artwork.UpdatedBy)
+		assert.Equal(t, retired.ID, response.Merge.RetiredID)
+		assert.Equal(t, "Mona Liza", response.Merge.Retired.Title, "the retired artwork is kept in history")
+		assert.Equal(t, "e", response.Merge.MergedBy)
+
+		// Both retired IDs lead to the kept artwork
+		for _, id := range []uint{retired.ID, older.ID} {
+			w = httptest.NewRecorder()
+			duplicatesRouter(ac, editor).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/gallery/artworks/"+strconv.FormatUint(uint64(id), 10), nil))
+			assert.Equal(t, http.StatusMovedPermanently, w.Code)
+			assert.Equal(t, "/gallery/artworks/"+strconv.FormatUint(uint64(keep.ID), 10), w.Header().Get("Location"))
+		}
+	})
+
+	t.Run("Rejected", func(t *testing.T) {
+		ac, artworks := setup(t)
+		tests := []struct {
+			name      string
+			principal *auth.Principal
+			req       *http.Request
+			status    int
+		}{
+			{"ViewerCannotMerge", viewer, mergeRequest(artworks[0].ID, artworks[1].ID), http.StatusForbidden},
+			{"ForeignGallery", editor, mergeRequest(artworks[0].ID, artworks[3].ID), http.StatusForbidden},
+			{"SameArtwork", editor, mergeRequest(artworks[0].ID, artworks[0].ID), http.StatusBadRequest},
+			{"MissingArtwork", editor, mergeRequest(artworks[0].ID, 999), http.StatusNotFound},
+			{"MissingID", editor, mergeRequest(artworks[0].ID, 0), http.StatusBadRequest},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				w := httptest.NewRecorder()
+				duplicatesRouter(ac, tt.principal).ServeHTTP(w, tt.req)
+				assert.Equal(t, tt.status, w.Code, w.Body.String())
+			})
+		}
+
+		// Nothing was merged
+		_, err := ac.ArtworkService.GetArtworkByID(context.Background(), strconv.FormatUint(uint64(artworks[1].ID), 10))
+		assert.NoError(t, err)
+	})
+
+	t.Run("CrossGallery", func(t *testing.T) {
+		ac, artworks := setup(t)
+		_, err := ac.ArtworkService.MergeArtworks(context.Background(), &artworks[0], &artworks[3], "admin")
+		assert.ErrorIs(t, err, services.ErrBadRequest)
+	})
+}
+
+func TestArtworkCreateWarnsAboutSimilar(t *testing.T) {
+	service, artworks := seedArtworks(t,
+		models.Artwork{Title: "The Starry Night", Artist: "Vincent van Gogh", Gallery: "moma"},
+	)
+	ac := controllers.NewArtworkController(service)
+
+	w := httptest.NewRecorder()
+	c := newTestContext(w)
+	c.Set("sanitizedArtwork", dto.ArtworkRequest{
+		Title:       "Starry Night",
+		Artist:      "Van Gogh",
+		Description: "Oil on canvas",
+		Image:       "https://img.example/starry.jpg",
+		Gallery:     "moma",
+	})
+
+	ac.Create(c)
+
+	require.Equal(t, http.StatusCreated, w.Code)
+	var response struct {
+		Similar []services.SimilarArtwork `json:"similar"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	require.Len(t, response.Similar, 1)
+	assert.Equal(t, artworks[0].ID, response.Similar[0].ID)
+}
@@ -0,0 +1,314 @@
+package unit_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/paumarro/apollo-be/internal/audit"
+	"github.com/paumarro/apollo-be/internal/auth"
+	"github.com/paumarro/apollo-be/internal/controllers"
+	"github.com/paumarro/apollo-be/internal/devidp"
+	"github.com/paumarro/apollo-be/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// useAuditStore records audit events to a fresh memory store for the test.
+func useAuditStore(t *testing.T) *audit.MemoryStore {
+	t.Helper()
+	store := audit.NewMemoryStore()
+	audit.Use(audit.NewRecorder(store))
+	t.Cleanup(func() { audit.Use(audit.NewRecorder(nil)) })
+	return store
+}
+
+func TestAuditChain(t *testing.T) {
+	ctx := context.Background()
+
+	newChain := func(t *testing.T) *audit.MemoryStore {
+		store := audit.NewMemoryStore()
+		recorder := audit.NewRecorder(store)
+		for _, typ := range []string{audit.TypeLoginSuccess, audit.TypeTokenRejected, audit.TypeAdminAction} {
+			require.NoError(t, recorder.Record(ctx, models.AuditEvent{Type: typ, Outcome: audit.OutcomeSuccess, Details: map[string]string{"k": "v"}}))
+		}
+		return store
+	}
+
+	t.Run("Intact", func(t *testing.T) {
+		store := newChain(t)
+		count, err := audit.Verify(ctx, store)
+		require.NoError(t, err)
+		assert.Equal(t, 3, count)
+
+		events, err := store.List(ctx, 0, 10)
+		require.NoError(t, err)
+		assert.Empty(t, events[0].PrevHash)
+		assert.Equal(t, events[0].Hash, events[1].PrevHash)
+	})
+
+	t.Run("ModifiedRecord", func(t *testing.T) {
+		store := newChain(t)
+		events, _ := store.List(ctx, 0, 10)
+		tampered := events[1]
+		tampered.Outcome = audit.OutcomeDenied
+		store.Tamper(tampered)
+
+		count, err := audit.Verify(ctx, store)
+		assert.ErrorIs(t, err, audit.ErrChainBroken)
+		assert.Equal(t, 1, count)
+	})
+
+	t.Run("RehashedRecord", func(t *testing.T) {
+		// Recomputing the altered record's hash still breaks its successor's link
+		store := newChain(t)
+		events, _ := store.List(ctx, 0, 10)
+		tampered := events[1]
+		tampered.Actor = "someone-else"
+		tampered.Hash = audit.Hash(&tampered)
+		store.Tamper(tampered)
+
+		count, err := audit.Verify(ctx, store)
+		assert.ErrorIs(t, err, audit.ErrChainBroken)
+		assert.Equal(t, 2, count)
+	})
+
+	t.Run("ContinuesAfterRestart", func(t *testing.T) {
+		store := newChain(t)
+		require.NoError(t, audit.NewRecorder(store).Record(ctx, models.AuditEvent{Type: audit.TypeLogout}))
+
+		count, err := audit.Verify(ctx, store)
+		require.NoError(t, err)
+		assert.Equal(t, 4, count)
+	})
+}
+
+func TestAuditChain_SharedStore(t *testing.T) {
+	ctx := context.Background()
+	for name, store := range map[string]audit.Store{
+		"Memory": audit.NewMemoryStore(),
+		"Gorm":   audit.NewGormStore(newSQLiteDB(t)),
+	} {
+		t.Run(name, func(t *testing.T) {
+			// Two replicas record to one store at the same time
+			replicas := []*audit.Recorder{audit.NewRecorder(store), audit.NewRecorder(store)}
+			var wg sync.WaitGroup
+			for _, recorder := range replicas {
+				wg.Add(1)
+				go func(recorder *audit.Recorder) {
+					defer wg.Done()
+					for i := 0; i < 20; i++ {
+						assert.NoError(t, recorder.Record(ctx, models.AuditEvent{Type: audit.TypeLoginSuccess, Outcome: audit.OutcomeSuccess}))
+					}
+				}(recorder)
+			}
+			wg.Wait()
+
+			count, err := audit.Verify(ctx, store)
+			require.NoError(t, err)
+			assert.Equal(t, 40, count)
+		})
+	}
+}
+
+// blockedStore holds every append until release is closed.
+type blockedStore struct {
+	*audit.MemoryStore
+	release chan struct{}
+}
+
+func (b *blockedStore) Append(ctx context.Context, e *models.AuditEvent, link func(last *models.AuditEvent)) error {
+	<-b.release
+	return b.MemoryStore.Append(ctx, e, link)
+}
+
+func TestAuditQueue(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("StoresInOrderBeforeClose", func(t *testing.T) {
+		store := audit.NewMemoryStore()
+		queue := audit.NewQueue(audit.NewRecorder(store), 100, 10, time.Minute)
+		for _, typ := range []string{audit.TypeLoginSuccess, audit.TypeAdminAction, audit.TypeLogout} {
+			require.NoError(t, queue.Record(ctx, models.AuditEvent{Type: typ, Actor: "alice"}))
+		}
+		require.NoError(t, queue.Close(ctx))
+
+		count, err := audit.Verify(ctx, store)
+		require.NoError(t, err)
+		assert.Equal(t, 3, count)
+		events, _ := store.List(ctx, 0, 10)
+		assert.Equal(t, audit.TypeLogout, events[2].Type)
+
+		// Events after shutdown are still stored
+		require.NoError(t, queue.Record(ctx, models.AuditEvent{Type: audit.TypeLogout, Actor: "bob"}))
+		count, _ = audit.Verify(ctx, store)
+		assert.Equal(t, 4, count)
+	})
+
+	t.Run("DropsWhenFull", func(t *testing.T) {
+		store := &blockedStore{MemoryStore: audit.NewMemoryStore(), release: make(chan struct{})}
+		queue := audit.NewQueue(audit.NewRecorder(store), 2, 10, time.Minute)
+
+		// The writer holds one event and the queue two more; the rest never wait
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for i := 0; i < 10; i++ {
+				_ = queue.Record(ctx, models.AuditEvent{Type: audit.TypeAdminAction, Actor: "alice"})
+			}
+		}()
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("Record waited for the store")
+		}
+
+		close(store.release)
+		require.NoError(t, queue.Close(ctx))
+		count, err := audit.Verify(ctx, store)
+		require.NoError(t, err)
+		assert.GreaterOrEqual(t, count, 2)
+		assert.LessOrEqual(t, count, 3)
+		assert.Equal(t, int64(10-count), queue.Dropped())
+	})
+
+	t.Run("LimitsAnonymousEventsPerIP", func(t *testing.T) {
+		store := audit.NewMemoryStore()
+		queue := audit.NewQueue(audit.NewRecorder(store), 100, 2, time.Minute)
+		for i := 0; i < 5; i++ {
+			require.NoError(t, queue.Record(ctx, models.AuditEvent{Type: audit.TypeTokenRejected, IP: "203.0.113.1"}))
+			require.NoError(t, queue.Record(ctx, models.AuditEvent{Type: audit.TypeLoginRedirect, IP: "203.0.113.1"}))
+		}
+		require.NoError(t, queue.Record(ctx, models.AuditEvent{Type: audit.TypeTokenRejected, IP: "203.0.113.2"}))
+		// Events with an actor, or of other types, are always kept
+		for i := 0; i < 5; i++ {
+			require.NoError(t, queue.Record(ctx, models.AuditEvent{Type: audit.TypeLoginFailure, IP: "203.0.113.1"}))
+			require.NoError(t, queue.Record(ctx, models.AuditEvent{Type: audit.TypeTokenRejected, Actor: "alice", IP: "203.0.113.1"}))
+		}
+		require.NoError(t, queue.Close(ctx))
+
+		events, err := store.Query(ctx, audit.Filter{Type: audit.TypeTokenRejected, Limit: 100})
+		require.NoError(t, err)
+		anonymous := 0
+		for _, e := range events {
+			if e.Actor == "" {
+				anonymous++
+			}
+		}
+		assert.Equal(t, 2, anonymous)
+		redirects, _ := store.Query(ctx, audit.Filter{Type: audit.TypeLoginRedirect, Limit: 100})
+		assert.Len(t, redirects, 1)
+		count, _ := audit.Verify(ctx, store)
+		assert.Equal(t, 13, count)
+		assert.Equal(t, int64(8), queue.Dropped())
+	})
+}
+
+func TestAuthMiddleware_RecordsAuditEvents(t *testing.T) {
+	provider := setupDevIdP(t)
+	store := useAuditStore(t)
+
+	r := gin.New()
+	r.GET("/gallery", newAuthenticator(provider).Auth(auth.RoleGallery), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	expired, err := provider.Mint(devidp.Identity{Subject: "alice", Claims: map[string]interface{}{"exp": time.Now().Add(-time.Minute).Unix()}}, 0)
+	require.NoError(t, err)
+	regular, err := provider.Mint(devidp.Identity{Subject: "bob", Roles: []string{auth.RoleRegular}}, 0)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		token   string
+		typ     string
+		reason  string
+		actor   string
+		outcome string
+	}{
+		{"Invalid", "not.a.jwt", audit.TypeTokenRejected, "invalid", "", audit.OutcomeFailure},
+		{"ExpiredWithoutRefreshToken", expired, audit.TypeLoginRedirect, "expired_without_refresh_token", "", audit.OutcomeFailure},
+		{"MissingRole", regular, audit.TypeAccessDenied, "missing_role", "bob", audit.OutcomeDenied},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/gallery", nil)
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+			r.ServeHTTP(w, req)
+
+			last, err := store.Last(context.Background())
+			require.NoError(t, err)
+			require.NotNil(t, last)
+			assert.Equal(t, tt.typ, last.Type)
+			assert.Equal(t, tt.reason, last.Reason)
+			assert.Equal(t, tt.actor, last.Actor)
+			assert.Equal(t, tt.outcome, last.Outcome)
+			assert.Equal(t, "/gallery", last.Path)
+		})
+	}
+}
+
+func TestAuditController_Index(t *testing.T) {
+	ctx := context.Background()
+	store := audit.NewMemoryStore()
+	recorder := audit.NewRecorder(store)
+	for _, e := range []models.AuditEvent{
+		{Type: audit.TypeAdminAction, Gallery: "louvre", Actor: "alice"},
+		{Type: audit.TypeAccessDenied, Gallery: "louvre", Actor: "bob"},
+		{Type: audit.TypeAdminAction, Gallery: "orsay", Actor: "carol"},
+		{Type: audit.TypeLoginFailure},
+		{Type: audit.TypeLoginFailure, Tenant: "museum"},
+	} {
+		require.NoError(t, recorder.Record(ctx, e))
+	}
+
+	ctrl := controllers.NewAuditController(store)
+	r := gin.New()
+	r.GET("/gallery/audit", func(c *gin.Context) {
+		auth.SetPrincipal(c, &auth.Principal{Subject: "admin", Roles: []string{auth.RoleGalleryAdmin}, Galleries: []string{"louvre"}})
+		c.Next()
+	}, ctrl.Index)
+
+	tests := []struct {
+		name   string
+		query  string
+		code   int
+		actors []string
+		types  []string
+	}{
+		{"VisibleOnly", "", http.StatusOK, []string{"", "bob", "alice"}, nil},
+		{"ByType", "?type=admin.action", http.StatusOK, []string{"alice"}, nil},
+		{"ByActor", "?actor=bob", http.StatusOK, []string{"bob"}, nil},
+		{"OtherGallery", "?gallery=orsay", http.StatusOK, []string{}, nil},
+		{"Paged", "?limit=1&before_id=3", http.StatusOK, []string{"bob"}, nil},
+		{"FutureSince", "?since=" + time.Now().Add(time.Hour).UTC().Format(time.RFC3339), http.StatusOK, []string{}, nil},
+		{"BadSince", "?since=yesterday", http.StatusBadRequest, nil, nil},
+		{"BadLimit", "?limit=1000", http.StatusBadRequest, nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/gallery/audit"+tt.query, nil))
+			require.Equal(t, tt.code, w.Code)
+			if tt.code != http.StatusOK {
+				return
+			}
+
+			var body struct {
+				Events []models.AuditEvent `json:"events"`
+			}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+			actors := []string{}
+			for _, e := range body.Events {
+				actors = append(actors, e.Actor)
+			}
+			assert.Equal(t, tt.actors, actors)
+		})
+	}
+}
@@ -0,0 +1,206 @@
+package unit_test
+
+import (
+	"context"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/paumarro/apollo-be/internal/cache"
+	"github.com/paumarro/apollo-be/internal/metrics"
+	"github.com/paumarro/apollo-be/internal/models"
+	"github.com/paumarro/apollo-be/internal/repositories"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestLRU(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	lru := cache.NewLRU[string, int](2)
+	lru.Now = func() time.Time { return now }
+
+	lru.Set("a", 1, time.Minute)
+	lru.Set("b", 2, time.Minute)
+	_, _ = lru.Get("a") // b is now least recently used
+	lru.Set("c", 3, time.Minute)
+
+	_, ok := lru.Get("b")
+	assert.False(t, ok, "the least recently used entry is evicted")
+	v, ok := lru.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, 1, v)
+	assert.Equal(t, uint64(1), lru.Evictions())
+	assert.Equal(t, 2, lru.Len())
+
+	lru.Set("a", 10, 2*time.Minute)
+	now = now.Add(90 * time.Second)
+	_, ok = lru.Get("c")
+	assert.False(t, ok, "entries expire after their TTL")
+	v, ok = lru.Get("a")
+	assert.True(t, ok, "setting a key again renews it")
+	assert.Equal(t, 10, v)
+
+	lru.Delete("a")
+	_, ok = lru.Get("a")
+	assert.False(t, ok)
+	lru.Set("d", 4, 0)
+	assert.Zero(t, lru.Len(), "entries without a TTL are not stored")
+
+	lru.Set("e", 5, time.Minute)
+	lru.Purge()
+	assert.Zero(t, lru.Len())
+}
+
+func TestLocalBus(t *testing.T) {
+	bus := cache.NewLocalBus()
+	ctx, cancel := context.WithCancel(context.Background())
+	var received atomic.Int32
+	bus.Subscribe(ctx, func(key string) {
+		assert.Equal(t, "7", key)
+		received.Add(1)
+	}, func() { t.Error("unexpected reset") })
+
+	require.NoError(t, bus.Publish(context.Background(), "7"))
+	assert.Equal(t, int32(1), received.Load())
+
+	cancel()
+	assert.Eventually(t, func() bool {
+		received.Store(0)
+		_ = bus.Publish(context.Background(), "7")
+		return received.Load() == 0
+	}, time.Second, 10*time.Millisecond, "cancelled subscribers stop receiving")
+}
+
+func TestCachedArtworkRepository(t *testing.T) {
+	ctx := context.Background()
+	newCached := func(t *testing.T) (*repositories.MockArtworkRepository, *repositories.CachedArtworkRepository) {
+		repo := new(repositories.MockArtworkRepository)
+		return repo, repositories.NewCachedArtworkRepository(repo, 10, time.Minute, time.Minute)
+	}
+
+	t.Run("ServesRepeatedLookups", func(t *testing.T) {
+		repo, cached := newCached(t)
+		repo.On("FindByID", mock.Anything, "1").Return(&models.Artwork{ID: 1, Title: "Water Lilies"}, nil).Once()
+
+		for i := 0; i < 3; i++ {
+			artwork, err := cached.FindByID(ctx, "1")
+			require.NoError(t, err)
+			assert.Equal(t, "Water Lilies", artwork.Title)
+			artwork.Title = "Changed by the caller"
+		}
+		repo.AssertExpectations(t)
+		stats := cached.Stats()
+		assert.Equal(t, uint64(2), stats.Hits)
+		assert.Equal(t, uint64(1), stats.Misses)
+		assert.Equal(t, 1, stats.Entries)
+	})
+
+	t.Run("RemembersMisses", func(t *testing.T) {
+		repo, cached := newCached(t)
+		repo.On("FindByID", mock.Anything, "9").Return(nil, gorm.ErrRecordNotFound).Once()
+
+		for i := 0; i < 2; i++ {
+			_, err := cached.FindByID(ctx, "9")
+			assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+		}
+		repo.AssertExpectations(t)
+		assert.Equal(t, uint64(1), cached.Stats().NegativeHits)
+
+		// Creating the artwork replaces the remembered miss
+		created := &models.Artwork{ID: 9, Title: "New"}
+		repo.On("Create", mock.Anything, created).Return(nil).Once()
+		repo.On("FindByID", mock.Anything, "9").Return(created, nil).Once()
+		require.NoError(t, cached.Create(ctx, created))
+		found, err := cached.FindByID(ctx, "9")
+		require.NoError(t, err)
+		assert.Equal(t, "New", found.Title)
+	})
+
+	t.Run("DoesNotCacheErrors", func(t *testing.T) {
+		repo, cached := newCached(t)
+		repo.On("FindByID", mock.Anything, "1").Return(nil, context.DeadlineExceeded).Twice()
+
+		for i := 0; i < 2; i++ {
+			_, err := cached.FindByID(ctx, "1")
+			assert.ErrorIs(t, err, context.DeadlineExceeded)
+		}
+		repo.AssertExpectations(t)
+		assert.Zero(t, cached.Stats().Entries)
+	})
+
+	t.Run("WritesInvalidate", func(t *testing.T) {
+		repo, cached := newCached(t)
+		artwork := &models.Artwork{ID: 1, Title: "Water Lilies"}
+		repo.On("FindByID", mock.Anything, "1").Return(artwork, nil).Times(3)
+		repo.On("Update", mock.Anything, artwork).Return(nil).Once()
+		repo.On("Delete", mock.Anything, "1").Return(nil).Once()
+
+		_, err := cached.FindByID(ctx, "1")
+		require.NoError(t, err)
+		require.NoError(t, cached.Update(ctx, artwork))
+		_, err = cached.FindByID(ctx, "1")
+		require.NoError(t, err)
+		require.NoError(t, cached.Delete(ctx, "1"))
+		_, err = cached.FindByID(ctx, "1")
+		require.NoError(t, err)
+
+		repo.AssertExpectations(t)
+		assert.Equal(t, uint64(2), cached.Stats().Invalidations)
+	})
+}
+
+func TestCachedArtworkRepository_Replicas(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Two replicas share a database and an invalidation bus
+	db := repositories.NewMemoryArtworkRepository()
+	bus := cache.NewLocalBus()
+	replicas := make([]*repositories.CachedArtworkRepository, 2)
+	for i := range replicas {
+		replicas[i] = repositories.NewCachedArtworkRepository(db, 10, time.Minute, time.Minute)
+		replicas[i].Bus = bus
+		replicas[i].Subscribe(ctx)
+	}
+
+	artwork := &models.Artwork{Title: "Water Lilies", Gallery: "orangerie"}
+	require.NoError(t, replicas[0].Create(ctx, artwork))
+	id := strconv.FormatUint(uint64(artwork.ID), 10)
+	_, err := replicas[1].FindByID(ctx, id)
+	require.NoError(t, err)
+
+	artwork.Title = "Nymphéas"
+	require.NoError(t, replicas[0].Update(ctx, artwork))
+	found, err := replicas[1].FindByID(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, "Nymphéas", found.Title, "the other replica dropped its copy")
+
+	// Units of work invalidate once they end
+	unit := repositories.NewCachedUnitOfWork(repositories.NewMemoryUnitOfWork(db), replicas[0])
+	require.NoError(t, unit.Do(ctx, func(ctx context.Context, repos repositories.Repos) error {
+		return repos.Artworks.Delete(ctx, id)
+	}))
+	_, err = replicas[1].FindByID(ctx, id)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+}
+
+func TestRegisterCacheStats(t *testing.T) {
+	registry := metrics.NewRegistry()
+	metrics.RegisterCacheStats(registry, "artworks", func() cache.Stats {
+		return cache.Stats{Hits: 5, NegativeHits: 2, Misses: 3, Entries: 4}
+	})
+
+	w := httptest.NewRecorder()
+	registry.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	body := w.Body.String()
+	assert.Contains(t, body, "# TYPE apollo_cache_hits_total counter\n")
+	assert.Contains(t, body, `apollo_cache_hits_total{cache="artworks"} 5`)
+	assert.Contains(t, body, `apollo_cache_negative_hits_total{cache="artworks"} 2`)
+	assert.Contains(t, body, `apollo_cache_misses_total{cache="artworks"} 3`)
+	assert.Contains(t, body, "# TYPE apollo_cache_entries gauge\n")
+	assert.Contains(t, body, `apollo_cache_entries{cache="artworks"} 4`)
+}
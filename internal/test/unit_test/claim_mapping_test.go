@@ -0,0 +1,177 @@
+package unit_test
+
+import (
+	"testing"
+
+	"github.com/paumarro/apollo-be/internal/auth"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseClaimPath(t *testing.T) {
+	claims := auth.Claims{
+		"sub": "alice",
+		"realm_access": map[string]interface{}{
+			"roles": []interface{}{"offline_access", "Regular"},
+		},
+		"resource_access": map[string]interface{}{
+			"apollo-client": map[string]interface{}{"roles": []interface{}{"Gallery"}},
+			"other":         map[string]interface{}{"roles": []interface{}{"Admin"}},
+		},
+		"groups": []interface{}{"/museum/curators", 42, "/staff"},
+		"nested": map[string]interface{}{"it's": "quoted"},
+	}
+
+	tests := []struct {
+		name string
+		expr string
+		want []string
+	}{
+		{"DottedMembers", "$.realm_access.roles", []string{"offline_access", "Regular"}},
+		{"WithoutRoot", "realm_access.roles", []string{"offline_access", "Regular"}},
+		{"DoubleQuotedMember", `$.resource_access["apollo-client"].roles`, []string{"Gallery"}},
+		{"SingleQuotedMember", `$.resource_access['apollo-client'].roles`, []string{"Gallery"}},
+		{"EscapedQuote", `$.nested['it\'s']`, []string{"quoted"}},
+		{"Index", "$.groups[2]", []string{"/staff"}},
+		{"WildcardMembers", "$.resource_access.*.roles", []string{"Gallery", "Admin"}},
+		{"WildcardItemsSkipNonStrings", "$.groups[*]", []string{"/museum/curators", "/staff"}},
+		{"ScalarClaim", "$.sub", []string{"alice"}},
+		{"MissingMember", "$.resource_access.missing.roles", nil},
+		{"IndexOutOfRange", "$.groups[9]", nil},
+		{"MemberOfScalar", "$.sub.roles", nil},
+		{"IndexIntoObject", "$.realm_access[0]", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path, err := auth.ParseClaimPath(tt.expr)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, path.Strings(claims))
+		})
+	}
+}
+
+func TestParseClaimPath_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		expr string
+	}{
+		{"Empty", ""},
+		{"RootOnly", "$"},
+		{"EmptyMember", "$.realm_access..roles"},
+		{"UnterminatedBracket", "$.groups[0"},
+		{"UnterminatedQuote", `$.resource_access["apollo-client].roles`},
+		{"NegativeIndex", "$.groups[-1]"},
+		{"NonNumericIndex", "$.groups[first]"},
+		{"Garbage", "$roles"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := auth.ParseClaimPath(tt.expr)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestClaimMapper_Principal(t *testing.T) {
+	mapping := auth.ClaimMapping{
+		Roles: []auth.RoleRule{
+			{Claim: `$.resource_access["apollo-client"].roles`},
+			{Claim: "$.realm_access.roles", Roles: []string{auth.RoleRegular}},
+			{Claim: "$.groups", Equals: "/museum/curators", Roles: []string{auth.RoleGallery, auth.RoleGalleryViewer}},
+		},
+		Galleries: []string{"$.galleries", "$.museum.galleries"},
+	}
+	mapper, err := auth.NewClaimMapper(mapping)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name          string
+		claims        auth.Claims
+		wantRoles     []string
+		wantGalleries []string
+	}{
+		{
+			name: "AllSources",
+			claims: auth.Claims{
+				"resource_access": map[string]interface{}{
+					"apollo-client": map[string]interface{}{"roles": []interface{}{auth.RoleGalleryAdmin}},
+				},
+				"realm_access": map[string]interface{}{"roles": []interface{}{"offline_access", auth.RoleRegular}},
+				"groups":       []interface{}{"/museum/curators"},
+				"galleries":    []interface{}{"louvre"},
+				"museum":       map[string]interface{}{"galleries": []interface{}{"orsay", "louvre"}},
+			},
+			wantRoles:     []string{auth.RoleGalleryAdmin, auth.RoleRegular, auth.RoleGallery, auth.RoleGalleryViewer},
+			wantGalleries: []string{"louvre", "orsay"},
+		},
+		{
+			name:          "GroupDoesNotMatch",
+			claims:        auth.Claims{"groups": []interface{}{"/museum/visitors"}},
+			wantRoles:     []string{},
+			wantGalleries: []string{},
+		},
+		{
+			name:          "MissingClientEntry",
+			claims:        auth.Claims{"resource_access": map[string]interface{}{"other": map[string]interface{}{"roles": []interface{}{"x"}}}},
+			wantRoles:     []string{},
+			wantGalleries: []string{},
+		},
+		{
+			name: "MalformedClaims",
+			claims: auth.Claims{
+				"resource_access": "not-an-object",
+				"realm_access":    map[string]interface{}{"roles": "Regular"},
+				"groups":          map[string]interface{}{"0": "/museum/curators"},
+				"galleries":       float64(3),
+			},
+			// A scalar where a list is expected still counts as a single value
+			wantRoles:     []string{auth.RoleRegular},
+			wantGalleries: []string{},
+		},
+		{
+			name:          "NoClaims",
+			claims:        auth.Claims{},
+			wantRoles:     []string{},
+			wantGalleries: []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := mapper.Principal(tt.claims)
+			assert.Equal(t, tt.wantRoles, p.Roles)
+			assert.Equal(t, tt.wantGalleries, p.Galleries)
+		})
+	}
+}
+
+func TestNewClaimMapper_Invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		mapping auth.ClaimMapping
+	}{
+		{"BadRolePath", auth.ClaimMapping{Roles: []auth.RoleRule{{Claim: "$.groups["}}}},
+		{"BadGalleryPath", auth.ClaimMapping{Galleries: []string{""}}},
+		{"EqualsWithoutRoles", auth.ClaimMapping{Roles: []auth.RoleRule{{Claim: "$.groups", Equals: "/admins"}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := auth.NewClaimMapper(tt.mapping)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestPrincipalFromClaims_DefaultKeycloakMapping(t *testing.T) {
+	p := auth.PrincipalFromClaims(auth.Claims{
+		"sub":                "alice",
+		"preferred_username": "alice",
+		"resource_access": map[string]interface{}{
+			"apollo-client": map[string]interface{}{"roles": []interface{}{auth.RoleGallery}},
+		},
+		"galleries": []interface{}{"louvre"},
+	}, "apollo-client")
+
+	assert.Equal(t, "alice", p.Subject)
+	assert.Equal(t, []string{auth.RoleGallery}, p.Roles)
+	assert.Equal(t, []string{"louvre"}, p.Galleries)
+}
@@ -1,14 +1,17 @@
 package unit_test
 
 import (
+	"context"
 	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"gorm.io/gorm"
 
 	"github.com/gin-gonic/gin"
+	"github.com/paumarro/apollo-be/internal/auth"
 	"github.com/paumarro/apollo-be/internal/controllers"
 	"github.com/paumarro/apollo-be/internal/dto"
 	"github.com/paumarro/apollo-be/internal/models"
@@ -22,6 +25,14 @@ func init() {
 	gin.SetMode(gin.ReleaseMode)
 }
 
+// newTestContext returns a gin context for w with an empty GET request,
+// whose context the controllers pass down.
+func newTestContext(w *httptest.ResponseRecorder) *gin.Context {
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+	return c
+}
+
 // SetupMockController initializes a mock repository and returns an ArtworkController instance.
 func setupMockController() (*repositories.MockArtworkRepository, *controllers.ArtworkController) {
 	mockRepo := &repositories.MockArtworkRepository{}
@@ -34,13 +45,12 @@ func TestArtworkCreate(t *testing.T) {
 	t.Run("Successful Creation", func(t *testing.T) {
 		mockRepo, ac := setupMockController() // Fresh mockRepo for this subtest
 
-		// Service checks duplicates via FindAll first
-		mockRepo.On("FindAll").Return([]models.Artwork{}, nil)
-		// Then persist
-		mockRepo.On("Create", mock.AnythingOfType("*models.Artwork")).Return(nil)
+		// The database enforces uniqueness, so the service persists directly
+		mockRepo.On("Create", mock.Anything, mock.AnythingOfType("*models.Artwork")).Return(nil)
+		mockRepo.On("FindByGallery", mock.Anything, mock.Anything).Return([]models.Artwork{}, nil)
 
 		w := httptest.NewRecorder()
-		c, _ := gin.CreateTestContext(w)
+		c := newTestContext(w)
 
 		// Set the sanitizedArtwork in the Gin context
 		c.Set("sanitizedArtwork", dto.ArtworkRequest{
@@ -57,22 +67,21 @@ func TestArtworkCreate(t *testing.T) {
 		assert.Equal(t, http.StatusCreated, w.Code)
 		assert.Contains(t, w.Body.String(), "Test Artwork")
 
-		// Verify the expected repo calls
-		mockRepo.AssertCalled(t, "FindAll")
-		mockRepo.AssertCalled(t, "Create", mock.AnythingOfType("*models.Artwork"))
+		// Verify the expected repo calls, without a table scan
+		mockRepo.AssertNotCalled(t, "FindAll", mock.Anything)
+		mockRepo.AssertCalled(t, "Create", mock.Anything, mock.AnythingOfType("*models.Artwork"))
 	})
 
 	t.Run("Duplicate Conflict", func(t *testing.T) {
 		mockRepo, ac := setupMockController()
 
-		// Service checks duplicates using FindAll (title + artist)
-		mockRepo.On("FindAll").Return([]models.Artwork{
-			{ID: 10, Title: "Test Artwork", Artist: "Test Artist"},
-		}, nil)
-		// Create should NOT be called
+		// The unique index rejects the insert and the service looks up the conflict
+		mockRepo.On("Create", mock.Anything, mock.AnythingOfType("*models.Artwork")).Return(gorm.ErrDuplicatedKey)
+		mockRepo.On("FindDuplicate", mock.Anything, mock.AnythingOfType("*models.Artwork")).Return(
+			&models.Artwork{ID: 10, Title: "Test Artwork", Artist: "Test Artist"}, nil)
 
 		w := httptest.NewRecorder()
-		c, _ := gin.CreateTestContext(w)
+		c := newTestContext(w)
 
 		c.Set("sanitizedArtwork", dto.ArtworkRequest{
 			Title:       "Test Artwork",
@@ -86,21 +95,19 @@ func TestArtworkCreate(t *testing.T) {
 		// Controller maps duplicate to 409 Conflict
 		assert.Equal(t, http.StatusConflict, w.Code)
 		assert.Contains(t, w.Body.String(), "Artwork already exists")
+		assert.Contains(t, w.Body.String(), `"conflicting_id":10`)
 
-		mockRepo.AssertCalled(t, "FindAll")
-		mockRepo.AssertNotCalled(t, "Create", mock.Anything)
+		mockRepo.AssertNotCalled(t, "FindAll", mock.Anything)
 	})
 
 	t.Run("Database Error", func(t *testing.T) {
 		mockRepo, ac := setupMockController() // Fresh mockRepo for this subtest
 
-		// No duplicates
-		mockRepo.On("FindAll").Return([]models.Artwork{}, nil)
 		// Configure the mock to return a database error on Create
-		mockRepo.On("Create", mock.AnythingOfType("*models.Artwork")).Return(errors.New("DB error"))
+		mockRepo.On("Create", mock.Anything, mock.AnythingOfType("*models.Artwork")).Return(errors.New("DB error"))
 
 		w := httptest.NewRecorder()
-		c, _ := gin.CreateTestContext(w)
+		c := newTestContext(w)
 
 		// Set the sanitizedArtwork in the Gin context
 		c.Set("sanitizedArtwork", dto.ArtworkRequest{
@@ -118,15 +125,14 @@ func TestArtworkCreate(t *testing.T) {
 		assert.Contains(t, w.Body.String(), "Failed to create artwork")
 
 		// Verify the mock was called
-		mockRepo.AssertCalled(t, "FindAll")
-		mockRepo.AssertCalled(t, "Create", mock.AnythingOfType("*models.Artwork"))
+		mockRepo.AssertCalled(t, "Create", mock.Anything, mock.AnythingOfType("*models.Artwork"))
 	})
 
 	t.Run("Missing Sanitized Artwork in Context", func(t *testing.T) {
 		_, ac := setupMockController()
 
 		w := httptest.NewRecorder()
-		c, _ := gin.CreateTestContext(w)
+		c := newTestContext(w)
 
 		// Do not set "sanitizedArtwork" in the context
 
@@ -142,13 +148,13 @@ func TestArtworkIndex(t *testing.T) {
 	t.Run("Successful Fetch with Artworks", func(t *testing.T) {
 		mockRepo, ac := setupMockController() // Fresh mockRepo for this subtest
 
-		mockRepo.On("FindAll").Return([]models.Artwork{
+		mockRepo.On("FindAll", mock.Anything).Return([]models.Artwork{
 			{Title: "Artwork 1", Artist: "Artist 1"},
 			{Title: "Artwork 2", Artist: "Artist 2"},
 		}, nil)
 
 		w := httptest.NewRecorder()
-		c, _ := gin.CreateTestContext(w)
+		c := newTestContext(w)
 
 		ac.Index(c)
 
@@ -156,53 +162,92 @@ func TestArtworkIndex(t *testing.T) {
 		assert.Contains(t, w.Body.String(), "Artwork 1")
 		assert.Contains(t, w.Body.String(), "Artwork 2")
 
-		mockRepo.AssertCalled(t, "FindAll")
+		mockRepo.AssertCalled(t, "FindAll", mock.Anything)
 	})
 
 	t.Run("Empty Database", func(t *testing.T) {
 		mockRepo, ac := setupMockController() // Fresh mockRepo for this subtest
 
-		mockRepo.On("FindAll").Return([]models.Artwork{}, nil)
+		mockRepo.On("FindAll", mock.Anything).Return([]models.Artwork{}, nil)
 
 		w := httptest.NewRecorder()
-		c, _ := gin.CreateTestContext(w)
+		c := newTestContext(w)
 
 		ac.Index(c)
 
 		assert.Equal(t, http.StatusOK, w.Code)
 		assert.Contains(t, w.Body.String(), `"artworks":[]`)
 
-		mockRepo.AssertCalled(t, "FindAll")
+		mockRepo.AssertCalled(t, "FindAll", mock.Anything)
 	})
 
 	t.Run("Database Error", func(t *testing.T) {
 		mockRepo, ac := setupMockController() // Fresh mockRepo for this subtest
 
-		mockRepo.On("FindAll").Return([]models.Artwork{}, errors.New("DB error"))
+		mockRepo.On("FindAll", mock.Anything).Return([]models.Artwork{}, errors.New("DB error"))
 
 		w := httptest.NewRecorder()
-		c, _ := gin.CreateTestContext(w)
+		c := newTestContext(w)
 
 		ac.Index(c)
 
 		assert.Equal(t, http.StatusInternalServerError, w.Code)
 		assert.Contains(t, w.Body.String(), "Failed to fetch artworks")
 
-		mockRepo.AssertCalled(t, "FindAll")
+		mockRepo.AssertCalled(t, "FindAll", mock.Anything)
+	})
+
+	t.Run("Request Context Reaches Repository", func(t *testing.T) {
+		mockRepo, ac := setupMockController()
+
+		w := httptest.NewRecorder()
+		c := newTestContext(w)
+		ctx, cancel := context.WithCancel(context.WithValue(c.Request.Context(), requestKey{}, "req-1"))
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+
+		mockRepo.On("FindAll", mock.MatchedBy(func(got context.Context) bool {
+			return got.Value(requestKey{}) == "req-1"
+		})).Return([]models.Artwork{}, nil)
+
+		ac.Index(c)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("Deadline Exceeded", func(t *testing.T) {
+		mockRepo, ac := setupMockController()
+
+		w := httptest.NewRecorder()
+		c := newTestContext(w)
+		ctx, cancel := context.WithDeadline(c.Request.Context(), time.Now().Add(-time.Second))
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+
+		mockRepo.On("FindAll", mock.Anything).Return(nil, context.DeadlineExceeded)
+
+		ac.Index(c)
+
+		assert.Equal(t, http.StatusGatewayTimeout, w.Code)
+		assert.Contains(t, w.Body.String(), "Request timed out")
 	})
 }
 
+// requestKey tags a request context so tests can recognise it downstream
+type requestKey struct{}
+
 func TestArtworkFind(t *testing.T) {
 	t.Run("Successful Retrieval", func(t *testing.T) {
 		mockRepo, ac := setupMockController() // Fresh mockRepo for this subtest
 
-		mockRepo.On("FindByID", "1").Return(&models.Artwork{
+		mockRepo.On("FindByID", mock.Anything, "1").Return(&models.Artwork{
 			Title:  "Artwork 1",
 			Artist: "Artist 1",
 		}, nil)
 
 		w := httptest.NewRecorder()
-		c, _ := gin.CreateTestContext(w)
+		c := newTestContext(w)
 		c.Params = []gin.Param{{Key: "id", Value: "1"}}
 
 		ac.Find(c)
@@ -210,17 +255,18 @@ func TestArtworkFind(t *testing.T) {
 		assert.Equal(t, http.StatusOK, w.Code)
 		assert.Contains(t, w.Body.String(), "Artwork 1")
 
-		mockRepo.AssertCalled(t, "FindByID", "1")
+		mockRepo.AssertCalled(t, "FindByID", mock.Anything, "1")
 	})
 
 	t.Run("Artwork Not Found", func(t *testing.T) {
 		mockRepo, ac := setupMockController() // Fresh mockRepo for this subtest
 
 		// Service maps gorm.ErrRecordNotFound to services.ErrNotFound
-		mockRepo.On("FindByID", "1").Return(nil, gorm.ErrRecordNotFound)
+		mockRepo.On("FindByID", mock.Anything, "1").Return(nil, gorm.ErrRecordNotFound)
+		mockRepo.On("FindMerge", mock.Anything, "1").Return(nil, gorm.ErrRecordNotFound)
 
 		w := httptest.NewRecorder()
-		c, _ := gin.CreateTestContext(w)
+		c := newTestContext(w)
 		c.Params = []gin.Param{{Key: "id", Value: "1"}}
 
 		ac.Find(c)
@@ -228,7 +274,24 @@ func TestArtworkFind(t *testing.T) {
 		assert.Equal(t, http.StatusNotFound, w.Code)
 		assert.Contains(t, w.Body.String(), "Artwork not found")
 
-		mockRepo.AssertCalled(t, "FindByID", "1")
+		mockRepo.AssertCalled(t, "FindByID", mock.Anything, "1")
+	})
+
+	t.Run("Merged Artwork Redirects", func(t *testing.T) {
+		mockRepo, ac := setupMockController()
+
+		mockRepo.On("FindByID", mock.Anything, "3").Return(nil, gorm.ErrRecordNotFound)
+		mockRepo.On("FindMerge", mock.Anything, "3").Return(&models.ArtworkMerge{ArtworkID: 12, RetiredID: 3}, nil)
+
+		w := httptest.NewRecorder()
+		c := newTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodGet, "/gallery/artworks/3?lang=en", nil)
+		c.Params = []gin.Param{{Key: "id", Value: "3"}}
+
+		ac.Find(c)
+
+		assert.Equal(t, http.StatusMovedPermanently, w.Code)
+		assert.Equal(t, "/gallery/artworks/12?lang=en", w.Header().Get("Location"))
 	})
 }
 
@@ -237,7 +300,7 @@ func TestArtworkUpdate(t *testing.T) {
 		mockRepo, ac := setupMockController() // Fresh mockRepo for this subtest
 
 		// Mock the repository to return an existing artwork
-		mockRepo.On("FindByID", "1").Return(&models.Artwork{
+		mockRepo.On("FindByID", mock.Anything, "1").Return(&models.Artwork{
 			ID:          1,
 			Title:       "Old Title",
 			Artist:      "Old Artist",
@@ -246,10 +309,10 @@ func TestArtworkUpdate(t *testing.T) {
 		}, nil)
 
 		// Mock the repository to update the artwork successfully
-		mockRepo.On("Update", mock.AnythingOfType("*models.Artwork")).Return(nil)
+		mockRepo.On("Update", mock.Anything, mock.AnythingOfType("*models.Artwork")).Return(nil)
 
 		w := httptest.NewRecorder()
-		c, _ := gin.CreateTestContext(w)
+		c := newTestContext(w)
 
 		// Set the ID parameter
 		c.Params = []gin.Param{{Key: "id", Value: "1"}}
@@ -271,18 +334,18 @@ func TestArtworkUpdate(t *testing.T) {
 		assert.Contains(t, w.Body.String(), "New Artist")
 
 		// Verify the mock was called
-		mockRepo.AssertCalled(t, "FindByID", "1")
-		mockRepo.AssertCalled(t, "Update", mock.AnythingOfType("*models.Artwork"))
+		mockRepo.AssertCalled(t, "FindByID", mock.Anything, "1")
+		mockRepo.AssertCalled(t, "Update", mock.Anything, mock.AnythingOfType("*models.Artwork"))
 	})
 
 	t.Run("Artwork Not Found", func(t *testing.T) {
 		mockRepo, ac := setupMockController() // Fresh mockRepo for this subtest
 
 		// Mock the repository to return not found
-		mockRepo.On("FindByID", "1").Return(nil, gorm.ErrRecordNotFound)
+		mockRepo.On("FindByID", mock.Anything, "1").Return(nil, gorm.ErrRecordNotFound)
 
 		w := httptest.NewRecorder()
-		c, _ := gin.CreateTestContext(w)
+		c := newTestContext(w)
 
 		// Set the ID parameter
 		c.Params = []gin.Param{{Key: "id", Value: "1"}}
@@ -295,14 +358,14 @@ func TestArtworkUpdate(t *testing.T) {
 		assert.Contains(t, w.Body.String(), "Artwork not found")
 
 		// Verify the mock was called
-		mockRepo.AssertCalled(t, "FindByID", "1")
+		mockRepo.AssertCalled(t, "FindByID", mock.Anything, "1")
 	})
 
 	t.Run("Missing Sanitized Input", func(t *testing.T) {
 		mockRepo, ac := setupMockController() // Fresh mockRepo for this subtest
 
 		// Mock the repository to return an existing artwork
-		mockRepo.On("FindByID", "1").Return(&models.Artwork{
+		mockRepo.On("FindByID", mock.Anything, "1").Return(&models.Artwork{
 			ID:          1,
 			Title:       "Old Title",
 			Artist:      "Old Artist",
@@ -311,7 +374,7 @@ func TestArtworkUpdate(t *testing.T) {
 		}, nil)
 
 		w := httptest.NewRecorder()
-		c, _ := gin.CreateTestContext(w)
+		c := newTestContext(w)
 
 		// Set the ID parameter
 		c.Params = []gin.Param{{Key: "id", Value: "1"}}
@@ -324,14 +387,14 @@ func TestArtworkUpdate(t *testing.T) {
 		assert.Contains(t, w.Body.String(), "Failed to retrieve sanitized input")
 
 		// Verify the mock was called
-		mockRepo.AssertCalled(t, "FindByID", "1")
+		mockRepo.AssertCalled(t, "FindByID", mock.Anything, "1")
 	})
 
 	t.Run("Database Error During Update", func(t *testing.T) {
 		mockRepo, ac := setupMockController() // Fresh mockRepo for this subtest
 
 		// Mock the repository to return an existing artwork
-		mockRepo.On("FindByID", "1").Return(&models.Artwork{
+		mockRepo.On("FindByID", mock.Anything, "1").Return(&models.Artwork{
 			ID:          1,
 			Title:       "Old Title",
 			Artist:      "Old Artist",
@@ -340,10 +403,10 @@ func TestArtworkUpdate(t *testing.T) {
 		}, nil)
 
 		// Mock the repository to fail during the update
-		mockRepo.On("Update", mock.AnythingOfType("*models.Artwork")).Return(errors.New("DB error"))
+		mockRepo.On("Update", mock.Anything, mock.AnythingOfType("*models.Artwork")).Return(errors.New("DB error"))
 
 		w := httptest.NewRecorder()
-		c, _ := gin.CreateTestContext(w)
+		c := newTestContext(w)
 
 		// Set the ID parameter
 		c.Params = []gin.Param{{Key: "id", Value: "1"}}
@@ -364,8 +427,36 @@ func TestArtworkUpdate(t *testing.T) {
 		assert.Contains(t, w.Body.String(), "Failed to update artwork")
 
 		// Verify the mock was called
-		mockRepo.AssertCalled(t, "FindByID", "1")
-		mockRepo.AssertCalled(t, "Update", mock.AnythingOfType("*models.Artwork"))
+		mockRepo.AssertCalled(t, "FindByID", mock.Anything, "1")
+		mockRepo.AssertCalled(t, "Update", mock.Anything, mock.AnythingOfType("*models.Artwork"))
+	})
+
+	t.Run("Duplicate Conflict", func(t *testing.T) {
+		mockRepo, ac := setupMockController()
+
+		mockRepo.On("FindByID", mock.Anything, "1").Return(&models.Artwork{
+			ID:     1,
+			Title:  "Old Title",
+			Artist: "Old Artist",
+		}, nil)
+		// Renaming onto another artwork's title and artist violates the unique index
+		mockRepo.On("Update", mock.Anything, mock.AnythingOfType("*models.Artwork")).Return(gorm.ErrDuplicatedKey)
+		mockRepo.On("FindDuplicate", mock.Anything, mock.AnythingOfType("*models.Artwork")).Return(
+			&models.Artwork{ID: 7, Title: "Taken Title", Artist: "Old Artist"}, nil)
+
+		w := httptest.NewRecorder()
+		c := newTestContext(w)
+		c.Params = []gin.Param{{Key: "id", Value: "1"}}
+		c.Set("sanitizedArtwork", dto.ArtworkRequest{
+			Title:  "Taken Title",
+			Artist: "Old Artist",
+		})
+
+		ac.Update(c)
+
+		assert.Equal(t, http.StatusConflict, w.Code)
+		assert.Contains(t, w.Body.String(), "Artwork already exists")
+		assert.Contains(t, w.Body.String(), `"conflicting_id":7`)
 	})
 }
 
@@ -373,10 +464,10 @@ func TestArtworkDelete(t *testing.T) {
 	t.Run("Successful Deletion", func(t *testing.T) {
 		mockRepo, ac := setupMockController() // Fresh mockRepo for this subtest
 
-		mockRepo.On("Delete", "1").Return(nil)
+		mockRepo.On("Delete", mock.Anything, "1").Return(nil)
 
 		w := httptest.NewRecorder()
-		c, _ := gin.CreateTestContext(w)
+		c := newTestContext(w)
 		c.Params = []gin.Param{{Key: "id", Value: "1"}}
 
 		ac.Delete(c)
@@ -384,17 +475,17 @@ func TestArtworkDelete(t *testing.T) {
 		assert.Equal(t, http.StatusOK, w.Code)
 		assert.Contains(t, w.Body.String(), "Artwork successfully deleted")
 
-		mockRepo.AssertCalled(t, "Delete", "1")
+		mockRepo.AssertCalled(t, "Delete", mock.Anything, "1")
 	})
 
 	t.Run("Artwork Not Found", func(t *testing.T) {
 		mockRepo, ac := setupMockController() // Fresh mockRepo for this subtest
 
 		// Service maps gorm.ErrRecordNotFound to services.ErrNotFound
-		mockRepo.On("Delete", "1").Return(gorm.ErrRecordNotFound)
+		mockRepo.On("Delete", mock.Anything, "1").Return(gorm.ErrRecordNotFound)
 
 		w := httptest.NewRecorder()
-		c, _ := gin.CreateTestContext(w)
+		c := newTestContext(w)
 		c.Params = []gin.Param{{Key: "id", Value: "1"}}
 
 		ac.Delete(c)
@@ -402,6 +493,62 @@ func TestArtworkDelete(t *testing.T) {
 		assert.Equal(t, http.StatusNotFound, w.Code)
 		assert.Contains(t, w.Body.String(), "Artwork not found")
 
-		mockRepo.AssertCalled(t, "Delete", "1")
+		mockRepo.AssertCalled(t, "Delete", mock.Anything, "1")
+	})
+}
+
+func TestMe(t *testing.T) {
+	t.Run("Authenticated", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		c := newTestContext(w)
+
+		auth.SetPrincipal(c, &auth.Principal{
+			Kind:      auth.KindUser,
+			Subject:   "user-1",
+			Name:      "Ada Curator",
+			Email:     "ada@example.com",
+			Roles:     []string{"Gallery"},
+			Galleries: []string{"louvre"},
+		})
+
+		controllers.Me(c)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"sub":"user-1"`)
+		assert.Contains(t, w.Body.String(), `"roles":["Gallery"]`)
+		assert.Contains(t, w.Body.String(), `"galleries":["louvre"]`)
+	})
+
+	t.Run("Missing Principal", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		c := newTestContext(w)
+
+		controllers.Me(c)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Contains(t, w.Body.String(), "Not authenticated")
 	})
 }
+
+func TestArtworkCreateRecordsPrincipal(t *testing.T) {
+	mockRepo, ac := setupMockController()
+
+	mockRepo.On("Create", mock.Anything, mock.AnythingOfType("*models.Artwork")).Return(nil)
+	mockRepo.On("FindByGallery", mock.Anything, mock.Anything).Return([]models.Artwork{}, nil)
+
+	w := httptest.NewRecorder()
+	c := newTestContext(w)
+
+	auth.SetPrincipal(c, &auth.Principal{Kind: auth.KindUser, Subject: "curator-7"})
+	c.Set("sanitizedArtwork", dto.ArtworkRequest{
+		Title:       "Test Artwork",
+		Artist:      "Test Artist",
+		Description: "Test Description",
+		Image:       "http://test.com/image.jpg",
+	})
+
+	ac.Create(c)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Contains(t, w.Body.String(), `"created_by":"curator-7"`)
+}
@@ -0,0 +1,107 @@
+package unit_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/paumarro/apollo-be/internal/middleware"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupCSRFRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(middleware.CSRF([]string{"https://apollo.example.com"}, "apollo.example.com"))
+	r.GET("/artworks", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"ok": true})
+	})
+	r.POST("/gallery/artworks", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"ok": true})
+	})
+	return r
+}
+
+func cookieRequest(origin, cookieToken, headerToken string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/gallery/artworks", nil)
+	req.AddCookie(&http.Cookie{Name: "access_token", Value: "jwt"})
+	if cookieToken != "" {
+		req.AddCookie(&http.Cookie{Name: middleware.CSRFCookie, Value: cookieToken})
+	}
+	if headerToken != "" {
+		req.Header.Set(middleware.CSRFHeader, headerToken)
+	}
+	if origin != "" {
+		req.Header.Set("Origin", origin)
+	}
+	return req
+}
+
+func TestCSRF_SafeMethodIssuesCookie(t *testing.T) {
+	w := httptest.NewRecorder()
+	setupCSRFRouter().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/artworks", nil))
+
+	require.Equal(t, http.StatusOK, w.Code)
+	var found *http.Cookie
+	for _, ck := range w.Result().Cookies() {
+		if ck.Name == middleware.CSRFCookie {
+			found = ck
+		}
+	}
+	require.NotNil(t, found)
+	assert.NotEmpty(t, found.Value)
+	assert.False(t, found.HttpOnly)
+	assert.Equal(t, http.SameSiteStrictMode, found.SameSite)
+}
+
+func TestCSRF_CookieAuthenticatedRequests(t *testing.T) {
+	tests := []struct {
+		name   string
+		req    *http.Request
+		status int
+	}{
+		{"matching token and trusted origin", cookieRequest("https://apollo.example.com", "tok", "tok"), http.StatusOK},
+		{"matching token without origin", cookieRequest("", "tok", "tok"), http.StatusOK},
+		{"missing header token", cookieRequest("https://apollo.example.com", "tok", ""), http.StatusForbidden},
+		{"mismatched token", cookieRequest("https://apollo.example.com", "tok", "other"), http.StatusForbidden},
+		{"untrusted origin", cookieRequest("https://evil.example.net", "tok", "tok"), http.StatusForbidden},
+		{"null origin", cookieRequest("null", "tok", "tok"), http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			setupCSRFRouter().ServeHTTP(w, tt.req)
+			assert.Equal(t, tt.status, w.Code)
+		})
+	}
+
+	t.Run("cross-site fetch metadata", func(t *testing.T) {
+		req := cookieRequest("https://apollo.example.com", "tok", "tok")
+		req.Header.Set("Sec-Fetch-Site", "cross-site")
+		w := httptest.NewRecorder()
+		setupCSRFRouter().ServeHTTP(w, req)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Contains(t, w.Body.String(), "csrf_failed")
+	})
+}
+
+func TestCSRF_ExplicitCredentialsExempt(t *testing.T) {
+	for name, header := range map[string][2]string{
+		"bearer":  {"Authorization", "Bearer jwt"},
+		"api key": {middleware.APIKeyHeader, "abk_x_y"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/gallery/artworks", nil)
+			req.AddCookie(&http.Cookie{Name: "access_token", Value: "jwt"})
+			req.Header.Set("Origin", "https://evil.example.net")
+			req.Header.Set(header[0], header[1])
+
+			w := httptest.NewRecorder()
+			setupCSRFRouter().ServeHTTP(w, req)
+			assert.Equal(t, http.StatusOK, w.Code)
+		})
+	}
+}
@@ -0,0 +1,228 @@
+package unit_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/paumarro/apollo-be/internal/auth"
+	"github.com/paumarro/apollo-be/internal/dbrouter"
+	"github.com/paumarro/apollo-be/internal/middleware"
+	"github.com/paumarro/apollo-be/internal/models"
+	"github.com/paumarro/apollo-be/internal/repositories"
+	"github.com/paumarro/apollo-be/internal/repositories/repotest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// newRoutedRepo returns a repository reading through a router with one
+// replica. The replica is a separate database, so what a read returns shows
+// where it went.
+func newRoutedRepo(t *testing.T) (*repositories.GormArtworkRepository, *dbrouter.Router, *gorm.DB) {
+	t.Helper()
+	primary, replica := newSQLiteDB(t), newSQLiteDB(t)
+	router := dbrouter.New(primary, []*gorm.DB{replica}, 5*time.Second, 5*time.Second)
+	repo := repositories.NewGormArtworkRepository(primary)
+	repo.Router = router
+	return repo, router, replica
+}
+
+func TestArtworkRepositoryContract_Routed(t *testing.T) {
+	repotest.ArtworkRepository(t, func(t *testing.T) repositories.ArtworkRepository {
+		// A replica that is the primary itself is always up to date
+		db := newSQLiteDB(t)
+		repo := repositories.NewGormArtworkRepository(db)
+		repo.Router = dbrouter.New(db, []*gorm.DB{db}, time.Second, time.Second)
+		return repo
+	})
+}
+
+func TestDBRouter(t *testing.T) {
+	curator := dbrouter.WithSession(context.Background(), &dbrouter.Session{})
+	visitor := dbrouter.WithSession(context.Background(), &dbrouter.Session{})
+
+	t.Run("ReadsFromReplica", func(t *testing.T) {
+		repo, _, replica := newRoutedRepo(t)
+		require.NoError(t, repositories.NewGormArtworkRepository(replica).Create(context.Background(),
+			&models.Artwork{Title: "Only on the replica", Gallery: "g1"}))
+
+		artworks, err := repo.FindAll(visitor)
+		require.NoError(t, err)
+		require.Len(t, artworks, 1)
+		assert.Equal(t, "Only on the replica", artworks[0].Title)
+
+		artworks, err = repo.FindAll(dbrouter.WithPrimary(visitor))
+		require.NoError(t, err)
+		assert.Empty(t, artworks, "WithPrimary reads from the primary")
+	})
+
+	t.Run("ReadYourWrites", func(t *testing.T) {
+		repo, router, _ := newRoutedRepo(t)
+		now := time.Now()
+		router.Now = func() time.Time { return now }
+
+		artwork := &models.Artwork{Title: "Water Lilies", Gallery: "orangerie"}
+		require.NoError(t, repo.Create(curator, artwork))
+		id := strconv.FormatUint(uint64(artwork.ID), 10)
+
+		_, err := repo.FindByID(curator, id)
+		assert.NoError(t, err, "the writing session reads from the primary")
+		_, err = repo.FindByID(visitor, id)
+		assert.ErrorIs(t, err, gorm.ErrRecordNotFound, "other sessions read from the replica")
+
+		now = now.Add(5 * time.Second)
+		_, err = repo.FindByID(curator, id)
+		assert.ErrorIs(t, err, gorm.ErrRecordNotFound, "the window has ended")
+	})
+
+	t.Run("UnitOfWorkStartsWindow", func(t *testing.T) {
+		repo, router, _ := newRoutedRepo(t)
+		unit := repositories.NewGormUnitOfWork(repo.DB)
+		unit.Router = router
+
+		require.NoError(t, unit.Do(curator, func(ctx context.Context, repos repositories.Repos) error {
+			return repos.Artworks.Create(ctx, &models.Artwork{Title: "Water Lilies", Gallery: "orangerie"})
+		}))
+		artworks, err := repo.FindAll(curator)
+		require.NoError(t, err)
+		assert.Len(t, artworks, 1)
+	})
+
+	t.Run("FallsBackWhenReplicaFails", func(t *testing.T) {
+		repo, router, replica := newRoutedRepo(t)
+		require.NoError(t, repositories.NewGormArtworkRepository(repo.DB).Create(context.Background(),
+			&models.Artwork{Title: "On the primary", Gallery: "g1"}))
+		replicaDB, err := replica.DB()
+		require.NoError(t, err)
+		require.NoError(t, replicaDB.Close())
+
+		artworks, err := repo.FindAll(visitor)
+		require.NoError(t, err)
+		require.Len(t, artworks, 1)
+		assert.Equal(t, "On the primary", artworks[0].Title)
+		assert.Zero(t, router.Available())
+
+		router.Check(context.Background())
+		assert.Zero(t, router.Available(), "the replica stays down while it fails checks")
+	})
+
+	t.Run("SkipsLaggingReplica", func(t *testing.T) {
+		repo, router, _ := newRoutedRepo(t)
+		require.NoError(t, repositories.NewGormArtworkRepository(repo.DB).Create(context.Background(),
+			&models.Artwork{Title: "On the primary", Gallery: "g1"}))
+
+		router.Replicas[0].LagQuery = "SELECT 60"
+		router.Check(context.Background())
+		assert.Zero(t, router.Available())
+		artworks, err := repo.FindAll(visitor)
+		require.NoError(t, err)
+		assert.Len(t, artworks, 1)
+
+		router.Replicas[0].LagQuery = "SELECT 0.5"
+		router.Check(context.Background())
+		assert.Equal(t, 1, router.Available())
+		artworks, err = repo.FindAll(visitor)
+		require.NoError(t, err)
+		assert.Empty(t, artworks)
+	})
+
+	t.Run("SkipsReplicaThatStoppedReplicating", func(t *testing.T) {
+		_, router, _ := newRoutedRepo(t)
+
+		// The lag query answers NULL for a standby whose WAL receiver is not streaming
+		router.Replicas[0].LagQuery = "SELECT NULL"
+		router.Check(context.Background())
+		assert.Zero(t, router.Available())
+
+		router.Replicas[0].LagQuery = "SELECT 0"
+		router.Check(context.Background())
+		assert.Equal(t, 1, router.Available())
+	})
+
+	t.Run("CacheFillsFromPrimary", func(t *testing.T) {
+		repo, _, _ := newRoutedRepo(t)
+		artwork := &models.Artwork{Title: "Water Lilies", Gallery: "orangerie"}
+		require.NoError(t, repositories.NewGormArtworkRepository(repo.DB).Create(context.Background(), artwork))
+
+		cached := repositories.NewCachedArtworkRepository(repo, 10, time.Minute, time.Minute)
+		found, err := cached.FindByID(visitor, strconv.FormatUint(uint64(artwork.ID), 10))
+		require.NoError(t, err)
+		assert.Equal(t, "Water Lilies", found.Title)
+	})
+}
+
+func TestReadYourWritesMiddleware(t *testing.T) {
+	primary, replica := newSQLiteDB(t), newSQLiteDB(t)
+	key := []byte("0123456789abcdef0123456789abcdef")
+
+	// Two instances share the databases and the key but nothing in memory
+	newInstance := func() (*gin.Engine, *dbrouter.Router) {
+		router := dbrouter.New(primary, []*gorm.DB{replica}, time.Minute, time.Second)
+		engine := gin.New()
+		engine.Use(func(c *gin.Context) {
+			if subject := c.GetHeader("X-Subject"); subject != "" {
+				auth.SetPrincipal(c, &auth.Principal{Subject: subject})
+			}
+		})
+		engine.Use(middleware.ReadYourWrites(key, time.Minute))
+		engine.POST("/", func(c *gin.Context) {
+			router.Wrote(c.Request.Context())
+			c.Status(http.StatusNoContent)
+		})
+		engine.GET("/", func(c *gin.Context) {
+			if db, _ := router.Reader(c.Request.Context()); db == primary {
+				c.String(http.StatusOK, "primary")
+			} else {
+				c.String(http.StatusOK, "replica")
+			}
+		})
+		return engine, router
+	}
+	first, _ := newInstance()
+	second, secondRouter := newInstance()
+
+	serve := func(engine *gin.Engine, method, subject string, cookie *http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/", nil)
+		if subject != "" {
+			req.Header.Set("X-Subject", subject)
+		}
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, "replica", serve(first, http.MethodGet, "curator", nil).Body.String())
+	w := serve(first, http.MethodPost, "curator", nil)
+	require.Len(t, w.Result().Cookies(), 1)
+	wrote := w.Result().Cookies()[0]
+	assert.Equal(t, middleware.WroteCookie, wrote.Name)
+	assert.Equal(t, wrote.Value, w.Header().Get(middleware.WroteHeader))
+
+	assert.Equal(t, "primary", serve(second, http.MethodGet, "curator", wrote).Body.String(), "the window follows the client")
+	assert.Equal(t, "replica", serve(second, http.MethodGet, "curator", nil).Body.String())
+	assert.Equal(t, "replica", serve(second, http.MethodGet, "visitor", wrote).Body.String(), "the window is bound to its principal")
+	assert.Equal(t, "replica", serve(second, http.MethodGet, "", wrote).Body.String())
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-Subject", "curator")
+	req.Header.Set(middleware.WroteHeader, wrote.Value)
+	w = httptest.NewRecorder()
+	second.ServeHTTP(w, req)
+	assert.Equal(t, "primary", w.Body.String(), "clients without cookies send the header")
+
+	forged := *wrote
+	forged.Value = strconv.FormatInt(time.Now().Add(time.Hour).UnixMilli(), 10) + wrote.Value[strings.Index(wrote.Value, "."):]
+	assert.Equal(t, "replica", serve(second, http.MethodGet, "curator", &forged).Body.String(), "edited times are ignored")
+
+	secondRouter.Now = func() time.Time { return time.Now().Add(2 * time.Minute) }
+	assert.Equal(t, "replica", serve(second, http.MethodGet, "curator", wrote).Body.String(), "the window has ended")
+}
@@ -0,0 +1,63 @@
+package unit_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/paumarro/apollo-be/internal/middleware"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupDeadlineRouter(timeout time.Duration, handler gin.HandlerFunc) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(middleware.Deadline(timeout))
+	r.GET("/artworks", handler)
+	return r
+}
+
+func TestDeadline_SetsRequestDeadline(t *testing.T) {
+	var deadline time.Time
+	var ok bool
+	r := setupDeadlineRouter(time.Minute, func(c *gin.Context) {
+		deadline, ok = c.Request.Context().Deadline()
+		c.JSON(http.StatusOK, gin.H{"ok": true})
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/artworks", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.True(t, ok)
+	assert.WithinDuration(t, time.Now().Add(time.Minute), deadline, time.Second)
+}
+
+func TestDeadline_TimedOutHandler(t *testing.T) {
+	t.Run("NoResponse", func(t *testing.T) {
+		r := setupDeadlineRouter(10*time.Millisecond, func(c *gin.Context) {
+			<-c.Request.Context().Done()
+		})
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/artworks", nil))
+
+		assert.Equal(t, http.StatusGatewayTimeout, w.Code)
+		assert.Contains(t, w.Body.String(), "Request timed out")
+	})
+
+	t.Run("ResponseKept", func(t *testing.T) {
+		r := setupDeadlineRouter(10*time.Millisecond, func(c *gin.Context) {
+			<-c.Request.Context().Done()
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "busy"})
+		})
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/artworks", nil))
+
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+		assert.Contains(t, w.Body.String(), "busy")
+	})
+}
@@ -0,0 +1,203 @@
+package unit_test
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/paumarro/apollo-be/internal/auth"
+	"github.com/paumarro/apollo-be/internal/controllers"
+	"github.com/paumarro/apollo-be/internal/devidp"
+	"github.com/paumarro/apollo-be/internal/middleware"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupDevIdP starts the dev identity provider on a test server.
+func setupDevIdP(t *testing.T) *devidp.Provider {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	group := r.Group("/realms/apollo")
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+
+	provider := devidp.New(srv.URL+"/realms/apollo", "apollo-client", key)
+	provider.RedirectURI = "http://localhost:3000/auth/callback"
+	provider.Register(group)
+
+	return provider
+}
+
+// newAuthenticator returns Auth middleware settings trusting the provider.
+func newAuthenticator(provider *devidp.Provider) *middleware.Authenticator {
+	return &middleware.Authenticator{
+		ClientID: provider.ClientID,
+		OIDC:     auth.NewOIDCClient(provider.Issuer, provider.ClientID, "", "http://localhost:3000/auth/callback"),
+		Verifier: &auth.JWTVerifier{JWKSURL: provider.Issuer + "/protocol/openid-connect/certs"},
+	}
+}
+
+func TestDevIdP_TokensPassAuthRoleChecks(t *testing.T) {
+	provider := setupDevIdP(t)
+
+	r := gin.New()
+	r.GET("/gallery", newAuthenticator(provider).Auth(auth.RoleGallery), func(c *gin.Context) {
+		p, _ := auth.PrincipalFrom(c)
+		c.JSON(http.StatusOK, p)
+	})
+
+	t.Run("RoleGranted", func(t *testing.T) {
+		token, err := provider.Mint(devidp.Identity{
+			Subject:   "alice",
+			Roles:     []string{auth.RoleGallery},
+			Galleries: []string{"louvre"},
+			Claims:    map[string]interface{}{"email": "alice@example.com"},
+		}, 0)
+		require.NoError(t, err)
+
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/gallery", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		r.ServeHTTP(w, req)
+
+		require.Equal(t, http.StatusOK, w.Code)
+		var p auth.Principal
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &p))
+		assert.Equal(t, "alice", p.Subject)
+		assert.Equal(t, "alice@example.com", p.Email)
+		assert.Equal(t, []string{"louvre"}, p.Galleries)
+	})
+
+	t.Run("RoleMissing", func(t *testing.T) {
+		token, err := provider.Mint(devidp.Identity{Subject: "bob", Roles: []string{auth.RoleRegular}}, 0)
+		require.NoError(t, err)
+
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/gallery", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+}
+
+func TestDevIdP_AuthorizationCodeFlow(t *testing.T) {
+	provider := setupDevIdP(t)
+
+	r := gin.New()
+	provider.Register(r.Group("/realms/apollo"))
+
+	// Authorize redirects straight back with a code
+	q := url.Values{}
+	q.Set("response_type", "code")
+	q.Set("client_id", "apollo-client")
+	q.Set("redirect_uri", "http://localhost:3000/auth/callback")
+	q.Set("state", "/artworks")
+	q.Set("dev_sub", "carol")
+	q.Set("dev_roles", "Gallery,GalleryAdmin")
+
+	// Codes only go to the registered callback
+	for _, redirectURI := range []string{"https://evil.example/auth/callback", "http://localhost:3000/auth/callback/../steal", ""} {
+		bad := url.Values{}
+		for k, v := range q {
+			bad[k] = v
+		}
+		bad.Set("redirect_uri", redirectURI)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/realms/apollo/protocol/openid-connect/auth?"+bad.Encode(), nil))
+		assert.Equal(t, http.StatusBadRequest, w.Code, redirectURI)
+		assert.Empty(t, w.Header().Get("Location"), redirectURI)
+	}
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/realms/apollo/protocol/openid-connect/auth?"+q.Encode(), nil))
+	require.Equal(t, http.StatusFound, w.Code)
+
+	location, err := url.Parse(w.Header().Get("Location"))
+	require.NoError(t, err)
+	assert.Equal(t, "/artworks", location.Query().Get("state"))
+	code := location.Query().Get("code")
+	require.NotEmpty(t, code)
+
+	exchange := func(form url.Values) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/realms/apollo/protocol/openid-connect/token", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("client_id", "apollo-client")
+	form.Set("code", code)
+	form.Set("redirect_uri", "http://localhost:3000/auth/callback")
+
+	w = exchange(form)
+	require.Equal(t, http.StatusOK, w.Code)
+	var tokens map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &tokens))
+	assert.NotEmpty(t, tokens["access_token"])
+	require.NotEmpty(t, tokens["refresh_token"])
+
+	// Codes are single use
+	assert.Equal(t, http.StatusBadRequest, exchange(form).Code)
+
+	refresh := url.Values{}
+	refresh.Set("grant_type", "refresh_token")
+	refresh.Set("client_id", "apollo-client")
+	refresh.Set("refresh_token", tokens["refresh_token"].(string))
+	assert.Equal(t, http.StatusOK, exchange(refresh).Code)
+}
+
+func TestAuthController_Callback(t *testing.T) {
+	provider := setupDevIdP(t)
+	oidc := newAuthenticator(provider).OIDC
+
+	// The login redirect points at the provider's authorize endpoint
+	login, err := url.Parse(oidc.LoginURL("/artworks"))
+	require.NoError(t, err)
+	assert.Equal(t, "/artworks", login.Query().Get("state"))
+
+	resp, err := (&http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}).Get(login.String())
+	require.NoError(t, err)
+	resp.Body.Close()
+	location, err := url.Parse(resp.Header.Get("Location"))
+	require.NoError(t, err)
+
+	r := gin.New()
+	r.GET("/auth/callback", controllers.NewAuthController(oidc, "apollo.example.com", nil).Callback)
+
+	t.Run("ValidCode", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/auth/callback?"+location.RawQuery, nil))
+
+		require.Equal(t, http.StatusFound, w.Code)
+		assert.Equal(t, "/artworks", w.Header().Get("Location"))
+		cookies := w.Header().Values("Set-Cookie")
+		require.Len(t, cookies, 2)
+		assert.Contains(t, cookies[0], "refresh_token=")
+		assert.Contains(t, cookies[1], "access_token=")
+		assert.Contains(t, cookies[1], "Domain=apollo.example.com")
+	})
+
+	t.Run("ReplayedCode", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/auth/callback?"+location.RawQuery, nil))
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		assert.Contains(t, w.Body.String(), "invalid_grant")
+	})
+}
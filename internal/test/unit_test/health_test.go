@@ -0,0 +1,232 @@
+package unit_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/paumarro/apollo-be/internal/auth"
+	"github.com/paumarro/apollo-be/internal/controllers"
+	"github.com/paumarro/apollo-be/internal/health"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type keySetReporter []auth.KeySetStatus
+
+func (r keySetReporter) KeySets(context.Context) []auth.KeySetStatus { return r }
+
+type worker struct {
+	last time.Time
+	err  error
+}
+
+func (w worker) LastRun() (time.Time, error) { return w.last, w.err }
+
+func passing(context.Context) (map[string]interface{}, error) { return nil, nil }
+
+func TestChecker_Run(t *testing.T) {
+	tests := []struct {
+		name   string
+		checks map[string]health.Check
+		want   health.Status
+	}{
+		{"no checks", nil, health.StatusOK},
+		{"all pass", map[string]health.Check{"a": passing, "b": passing}, health.StatusOK},
+		{
+			"degraded",
+			map[string]health.Check{"a": passing, "b": func(context.Context) (map[string]interface{}, error) {
+				return nil, health.Degraded(errors.New("stale"))
+			}},
+			health.StatusDegraded,
+		},
+		{
+			"failing wins over degraded",
+			map[string]health.Check{
+				"a": func(context.Context) (map[string]interface{}, error) {
+					return nil, health.Degraded(errors.New("stale"))
+				},
+				"b": func(context.Context) (map[string]interface{}, error) { return nil, errors.New("down") },
+			},
+			health.StatusFail,
+		},
+		{
+			"panic fails",
+			map[string]health.Check{"a": func(context.Context) (map[string]interface{}, error) { panic("boom") }},
+			health.StatusFail,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checker := health.NewChecker(time.Second)
+			for name, check := range tt.checks {
+				checker.Register(name, check)
+			}
+			report := checker.Run(context.Background())
+			assert.Equal(t, tt.want, report.Status)
+			assert.Len(t, report.Checks, len(tt.checks))
+		})
+	}
+}
+
+func TestChecker_Timeout(t *testing.T) {
+	checker := health.NewChecker(20 * time.Millisecond)
+	checker.Register("slow", func(ctx context.Context) (map[string]interface{}, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	checker.Register("fast", passing)
+
+	report := checker.Run(context.Background())
+	assert.Equal(t, health.StatusFail, report.Status)
+	assert.Equal(t, health.StatusFail, report.Checks["slow"].Status)
+	assert.Contains(t, report.Checks["slow"].Error, "deadline")
+	assert.Equal(t, health.StatusOK, report.Checks["fast"].Status)
+}
+
+func TestChecker_Drain(t *testing.T) {
+	checker := health.NewChecker(time.Second)
+	checker.Register("database", passing)
+	assert.Equal(t, health.StatusOK, checker.Run(context.Background()).Status)
+
+	checker.Drain()
+	report := checker.Run(context.Background())
+	assert.Equal(t, health.StatusFail, report.Status)
+	assert.Equal(t, "draining", report.Checks["shutdown"].Error)
+}
+
+func TestDatabaseCheck(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	check := health.DatabaseCheck(db)
+
+	details, err := check(context.Background())
+	require.NoError(t, err)
+	assert.Contains(t, details, "ping_ms")
+
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	require.NoError(t, sqlDB.Close())
+	_, err = check(context.Background())
+	assert.Error(t, err)
+}
+
+func TestKeySetCheck(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name     string
+		sets     keySetReporter
+		wantErr  bool
+		degraded bool
+	}{
+		{"no key sets", nil, false, false},
+		{"fresh", keySetReporter{{URL: "https://idp/certs", Keys: 2, LastRefresh: now}}, false, false},
+		{"stale", keySetReporter{{URL: "https://idp/certs", Keys: 2, LastRefresh: now.Add(-2 * time.Hour)}}, true, true},
+		{"unreachable", keySetReporter{{URL: "https://idp/certs", Error: "connection refused"}}, true, false},
+		{"one of two unreachable", keySetReporter{
+			{URL: "https://idp/certs", Keys: 2, LastRefresh: now},
+			{URL: "https://partner-idp/certs", Error: "connection refused"},
+		}, true, true},
+		{"all unreachable", keySetReporter{
+			{URL: "https://idp/certs", Error: "connection refused"},
+			{URL: "https://partner-idp/certs", Error: "no such host"},
+		}, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checker := health.NewChecker(time.Second)
+			checker.Register("jwks", health.KeySetCheck(tt.sets, time.Hour))
+			result := checker.Run(context.Background()).Checks["jwks"]
+
+			switch {
+			case tt.degraded:
+				assert.Equal(t, health.StatusDegraded, result.Status)
+			case tt.wantErr:
+				assert.Equal(t, health.StatusFail, result.Status)
+			default:
+				assert.Equal(t, health.StatusOK, result.Status)
+			}
+			assert.Contains(t, result.Details, "key_sets")
+
+			// The public report names neither the key sets nor their errors
+			body, err := json.Marshal(result)
+			require.NoError(t, err)
+			assert.NotContains(t, string(body), "https://")
+			assert.NotContains(t, string(body), "connection refused")
+		})
+	}
+}
+
+func TestWorkerCheck(t *testing.T) {
+	ctx := context.Background()
+
+	_, err := health.WorkerCheck(worker{last: time.Now()}, time.Minute)(ctx)
+	assert.NoError(t, err)
+
+	// A worker that has not run yet gets maxSilence to start
+	_, err = health.WorkerCheck(worker{}, time.Minute)(ctx)
+	assert.NoError(t, err)
+
+	_, err = health.WorkerCheck(worker{last: time.Now().Add(-time.Hour)}, time.Minute)(ctx)
+	assert.Error(t, err)
+
+	checker := health.NewChecker(time.Second)
+	checker.Register("tls_certificate", health.WorkerCheck(worker{last: time.Now(), err: errors.New("bad key pair")}, time.Minute))
+	assert.Equal(t, health.StatusDegraded, checker.Run(ctx).Status)
+}
+
+func TestHealthController(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	checker := health.NewChecker(time.Second)
+	checker.Register("database", passing)
+	hc := controllers.NewHealthController(checker)
+	router := gin.New()
+	router.GET("/healthz", hc.Live)
+	router.GET("/readyz", hc.Ready)
+
+	get := func(path string) (*httptest.ResponseRecorder, health.Report) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		var report health.Report
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+		return w, report
+	}
+
+	w, report := get("/readyz")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, health.StatusOK, report.Checks["database"].Status)
+
+	// Liveness stays up while readiness fails during shutdown
+	checker.Drain()
+	w, report = get("/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, health.StatusFail, report.Status)
+
+	w, report = get("/healthz")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, health.StatusOK, report.Status)
+}
+
+func TestAuthenticator_KeySets(t *testing.T) {
+	authenticator := newAuthenticator(setupDevIdP(t))
+	t.Cleanup(func() { _ = authenticator.Close() })
+
+	sets := authenticator.KeySets(context.Background())
+	require.Len(t, sets, 1)
+	assert.Empty(t, sets[0].Error)
+	assert.Equal(t, 1, sets[0].Keys)
+	assert.WithinDuration(t, time.Now(), sets[0].LastRefresh, 5*time.Second)
+
+	unreachable := &auth.JWTVerifier{JWKSURL: "http://127.0.0.1:1/certs"}
+	t.Cleanup(func() { _ = unreachable.Close() })
+	sets = unreachable.KeySets(context.Background())
+	require.Len(t, sets, 1)
+	assert.NotEmpty(t, sets[0].Error)
+}
@@ -0,0 +1,162 @@
+package unit_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/paumarro/apollo-be/internal/auth"
+	"github.com/paumarro/apollo-be/internal/devidp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupIntrospection serves an RFC 7662 endpoint that reports the given
+// responses by token and counts the calls it receives.
+func setupIntrospection(t *testing.T, responses map[string]map[string]interface{}) (string, *int32) {
+	t.Helper()
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		if user, pass, ok := r.BasicAuth(); !ok || user != "apollo-client" || pass != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		resp, ok := responses[r.PostFormValue("token")]
+		if !ok {
+			resp = map[string]interface{}{"active": false}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(resp)
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL, &calls
+}
+
+func TestIntrospectionVerifier(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	endpoint, calls := setupIntrospection(t, map[string]map[string]interface{}{
+		"opaque-active": {
+			"active":    true,
+			"sub":       "carol",
+			"username":  "carol",
+			"exp":       float64(now.Add(30 * time.Second).Unix()),
+			"galleries": []string{"tate"},
+			"resource_access": map[string]interface{}{
+				"apollo-client": map[string]interface{}{"roles": []string{auth.RoleGallery}},
+			},
+		},
+	})
+
+	newVerifier := func() *auth.IntrospectionVerifier {
+		v := auth.NewIntrospectionVerifier(endpoint, "apollo-client", "secret", time.Minute)
+		v.Now = func() time.Time { return now }
+		return v
+	}
+
+	t.Run("ActiveTokenIsCached", func(t *testing.T) {
+		atomic.StoreInt32(calls, 0)
+		v := newVerifier()
+
+		claims, err := v.Verify(context.Background(), "opaque-active")
+		require.NoError(t, err)
+		assert.Equal(t, "carol", claims["sub"])
+
+		_, err = v.Verify(context.Background(), "opaque-active")
+		require.NoError(t, err)
+		assert.Equal(t, int32(1), atomic.LoadInt32(calls))
+	})
+
+	t.Run("CacheBoundedByExp", func(t *testing.T) {
+		atomic.StoreInt32(calls, 0)
+		v := newVerifier()
+
+		_, err := v.Verify(context.Background(), "opaque-active")
+		require.NoError(t, err)
+
+		// Past the token's exp but within the cache TTL the result is not reused
+		v.Now = func() time.Time { return now.Add(31 * time.Second) }
+		_, err = v.Verify(context.Background(), "opaque-active")
+		assert.ErrorIs(t, err, auth.ErrTokenExpired)
+		assert.Equal(t, int32(2), atomic.LoadInt32(calls))
+	})
+
+	t.Run("InactiveToken", func(t *testing.T) {
+		v := newVerifier()
+		_, err := v.Verify(context.Background(), "revoked")
+		assert.ErrorIs(t, err, auth.ErrTokenInactive)
+	})
+
+	t.Run("SamePrincipalAsJWT", func(t *testing.T) {
+		v := newVerifier()
+		claims, err := v.Verify(context.Background(), "opaque-active")
+		require.NoError(t, err)
+
+		p := auth.PrincipalFromClaims(claims, "apollo-client")
+		assert.Equal(t, auth.KindUser, p.Kind)
+		assert.Equal(t, "carol", p.Subject)
+		assert.Equal(t, "carol", p.Name)
+		assert.Equal(t, []string{auth.RoleGallery}, p.Roles)
+		assert.Equal(t, []string{"tate"}, p.Galleries)
+	})
+}
+
+func TestIssuerRouter_AuthMiddleware(t *testing.T) {
+	provider := setupDevIdP(t)
+	endpoint, _ := setupIntrospection(t, map[string]map[string]interface{}{
+		"opaque-viewer": {
+			"active": true,
+			"sub":    "dave",
+			"exp":    float64(time.Now().Add(time.Minute).Unix()),
+			"resource_access": map[string]interface{}{
+				"apollo-client": map[string]interface{}{"roles": []string{auth.RoleGalleryViewer}},
+			},
+		},
+	})
+
+	authenticator := newAuthenticator(provider)
+	authenticator.Verifier = &auth.IssuerRouter{
+		Default: authenticator.Verifier,
+		Opaque:  auth.NewIntrospectionVerifier(endpoint, "apollo-client", "secret", time.Minute),
+	}
+
+	r := gin.New()
+	r.GET("/viewer", authenticator.Auth(auth.RoleGalleryViewer), func(c *gin.Context) {
+		p, _ := auth.PrincipalFrom(c)
+		c.JSON(http.StatusOK, p)
+	})
+
+	jwtToken, err := provider.Mint(devidp.Identity{Subject: "erin", Roles: []string{auth.RoleGalleryViewer}}, 0)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		token   string
+		code    int
+		subject string
+	}{
+		{"LocalJWT", jwtToken, http.StatusOK, "erin"},
+		{"OpaqueIntrospected", "opaque-viewer", http.StatusOK, "dave"},
+		{"OpaqueInactive", "opaque-unknown", http.StatusUnauthorized, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/viewer", nil)
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+			r.ServeHTTP(w, req)
+
+			require.Equal(t, tt.code, w.Code)
+			if tt.subject != "" {
+				var p auth.Principal
+				require.NoError(t, json.Unmarshal(w.Body.Bytes(), &p))
+				assert.Equal(t, tt.subject, p.Subject)
+			}
+		})
+	}
+}
@@ -0,0 +1,166 @@
+package unit_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/paumarro/apollo-be/internal/auth"
+	"github.com/paumarro/apollo-be/internal/controllers"
+	"github.com/paumarro/apollo-be/internal/devidp"
+	"github.com/paumarro/apollo-be/internal/middleware"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewRegistry_Validation(t *testing.T) {
+	tests := []struct {
+		name    string
+		issuers []auth.Issuer
+	}{
+		{"MissingIssuer", []auth.Issuer{{ClientID: "apollo-client"}}},
+		{"MissingClientID", []auth.Issuer{{Issuer: "https://a.example", Galleries: []string{"louvre"}}}},
+		{"MissingGalleries", []auth.Issuer{{Issuer: "https://a.example", ClientID: "apollo-client"}}},
+		{"Duplicate", []auth.Issuer{
+			{Issuer: "https://a.example", ClientID: "apollo-client", Galleries: []string{"louvre"}},
+			{Issuer: "https://a.example", ClientID: "apollo-client", Galleries: []string{"louvre"}},
+		}},
+		{"OpaqueWithoutIntrospection", []auth.Issuer{{Issuer: "https://a.example", ClientID: "apollo-client", Galleries: []string{"louvre"}, Opaque: true}}},
+		{"InvalidCacheTTL", []auth.Issuer{{
+			Issuer: "https://a.example", ClientID: "apollo-client", Galleries: []string{"louvre"},
+			Introspection: &auth.IntrospectionConfig{URL: "https://a.example/introspect", CacheTTL: "soon"},
+		}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := auth.NewRegistry(tt.issuers)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestRegistry_Authenticate(t *testing.T) {
+	home := setupDevIdP(t)
+	partner := setupDevIdP(t)
+	partner.ClientID = "museum-client"
+	stranger := setupDevIdP(t)
+
+	registry, err := auth.NewRegistry([]auth.Issuer{
+		{Issuer: home.Issuer, ClientID: "apollo-client", Tenant: "apollo", Galleries: []string{auth.AllGalleries}, Platform: true},
+		{Issuer: partner.Issuer, ClientID: "museum-client", Audience: "museum-client", Tenant: "museum", Galleries: []string{"museum-main"}},
+	})
+	require.NoError(t, err)
+
+	t.Run("HomeIssuer", func(t *testing.T) {
+		token, err := home.Mint(devidp.Identity{Subject: "alice", Roles: []string{auth.RoleGallery}, Galleries: []string{"louvre"}}, 0)
+		require.NoError(t, err)
+
+		p, err := registry.Authenticate(context.Background(), token)
+		require.NoError(t, err)
+		assert.Equal(t, "alice", p.Subject)
+		assert.Equal(t, "apollo", p.Tenant)
+		assert.Equal(t, home.Issuer, p.Issuer)
+		assert.Equal(t, []string{auth.RoleGallery}, p.Roles)
+		assert.Equal(t, []string{"louvre"}, p.Galleries)
+	})
+
+	t.Run("PartnerRolesAndGalleriesBound", func(t *testing.T) {
+		token, err := partner.Mint(devidp.Identity{
+			Subject:   "bob",
+			Roles:     []string{auth.RoleGalleryAdmin},
+			Galleries: []string{"museum-main", "louvre"},
+		}, 0)
+		require.NoError(t, err)
+
+		p, err := registry.Authenticate(context.Background(), token)
+		require.NoError(t, err)
+		assert.Equal(t, "museum", p.Tenant)
+		assert.Equal(t, []string{auth.RoleGalleryAdmin}, p.Roles)
+		assert.Equal(t, []string{"museum-main"}, p.Galleries)
+	})
+
+	t.Run("PlatformRoleOnlyFromPlatformIssuer", func(t *testing.T) {
+		roles := []string{auth.RoleGalleryAdmin, auth.RolePlatformAdmin}
+		for _, tt := range []struct {
+			provider *devidp.Provider
+			want     []string
+		}{
+			{home, roles},
+			{partner, []string{auth.RoleGalleryAdmin}},
+		} {
+			token, err := tt.provider.Mint(devidp.Identity{Subject: "operator", Roles: roles, Galleries: []string{"museum-main"}}, 0)
+			require.NoError(t, err)
+			p, err := registry.Authenticate(context.Background(), token)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, p.Roles, tt.provider.Issuer)
+		}
+	})
+
+	t.Run("WrongAudience", func(t *testing.T) {
+		token, err := partner.Mint(devidp.Identity{Subject: "bob", Claims: map[string]interface{}{"aud": "other"}}, 0)
+		require.NoError(t, err)
+
+		_, err = registry.Authenticate(context.Background(), token)
+		assert.ErrorIs(t, err, auth.ErrTokenInvalid)
+	})
+
+	t.Run("UnknownIssuer", func(t *testing.T) {
+		token, err := stranger.Mint(devidp.Identity{Subject: "mallory"}, 0)
+		require.NoError(t, err)
+
+		_, err = registry.Authenticate(context.Background(), token)
+		assert.ErrorIs(t, err, auth.ErrUnknownIssuer)
+	})
+
+	t.Run("ForgedIssuer", func(t *testing.T) {
+		// Signed by an untrusted key but claiming a trusted issuer
+		token, err := stranger.Mint(devidp.Identity{Subject: "mallory", Claims: map[string]interface{}{"iss": home.Issuer}}, 0)
+		require.NoError(t, err)
+
+		_, err = registry.Authenticate(context.Background(), token)
+		assert.ErrorIs(t, err, auth.ErrTokenInvalid)
+	})
+
+	t.Run("OpaqueRejected", func(t *testing.T) {
+		_, err := registry.Authenticate(context.Background(), "opaque-token")
+		assert.ErrorIs(t, err, auth.ErrTokenInvalid)
+	})
+}
+
+func TestAuthMiddleware_UnknownIssuerRejected(t *testing.T) {
+	home := setupDevIdP(t)
+	stranger := setupDevIdP(t)
+
+	registry, err := auth.NewRegistry([]auth.Issuer{{Issuer: home.Issuer, ClientID: "apollo-client", Tenant: "apollo", Galleries: []string{auth.AllGalleries}}})
+	require.NoError(t, err)
+	authenticator := &middleware.Authenticator{ClientID: "ignored-client", Issuers: registry}
+
+	r := gin.New()
+	r.GET("/me", authenticator.Auth(""), controllers.Me)
+
+	homeToken, err := home.Mint(devidp.Identity{Subject: "alice", Roles: []string{auth.RoleRegular}}, 0)
+	require.NoError(t, err)
+	strangerToken, err := stranger.Mint(devidp.Identity{Subject: "mallory"}, 0)
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/me", nil)
+	req.Header.Set("Authorization", "Bearer "+homeToken)
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	var body struct {
+		User auth.Principal `json:"user"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, "apollo", body.User.Tenant)
+	assert.Equal(t, []string{auth.RoleRegular}, body.User.Roles)
+
+	w = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodGet, "/me", nil)
+	req.Header.Set("Authorization", "Bearer "+strangerToken)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
@@ -0,0 +1,266 @@
+package unit_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/paumarro/apollo-be/internal/audit"
+	"github.com/paumarro/apollo-be/internal/auth"
+	"github.com/paumarro/apollo-be/internal/controllers"
+	"github.com/paumarro/apollo-be/internal/devidp"
+	"github.com/paumarro/apollo-be/internal/lockout"
+	"github.com/paumarro/apollo-be/internal/middleware"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestTracker(now *time.Time) *lockout.Tracker {
+	tracker := lockout.NewTracker(lockout.Policy{
+		Threshold:   3,
+		Window:      time.Minute,
+		BaseLockout: time.Minute,
+		MaxLockout:  5 * time.Minute,
+		ResetAfter:  time.Hour,
+	})
+	tracker.Now = func() time.Time { return *now }
+	return tracker
+}
+
+func TestTracker(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+
+	t.Run("ExponentialLockouts", func(t *testing.T) {
+		now := start
+		tracker := newTestTracker(&now)
+
+		var durations []time.Duration
+		for i := 0; i < 4; i++ {
+			tracker.Fail(lockout.KindIP, "192.0.2.1")
+			tracker.Fail(lockout.KindIP, "192.0.2.1")
+			until, locked := tracker.Fail(lockout.KindIP, "192.0.2.1")
+			require.True(t, locked)
+			durations = append(durations, until.Sub(now))
+
+			_, locked = tracker.Locked(lockout.KindIP, "192.0.2.1")
+			assert.True(t, locked)
+			now = until
+		}
+		assert.Equal(t, []time.Duration{time.Minute, 2 * time.Minute, 4 * time.Minute, 5 * time.Minute}, durations)
+	})
+
+	t.Run("FailuresOutsideWindowDoNotCount", func(t *testing.T) {
+		now := start
+		tracker := newTestTracker(&now)
+
+		tracker.Fail(lockout.KindIP, "192.0.2.1")
+		tracker.Fail(lockout.KindIP, "192.0.2.1")
+		now = now.Add(2 * time.Minute)
+		_, locked := tracker.Fail(lockout.KindIP, "192.0.2.1")
+		assert.False(t, locked)
+	})
+
+	t.Run("SuccessResetsSubject", func(t *testing.T) {
+		now := start
+		tracker := newTestTracker(&now)
+
+		tracker.Fail(lockout.KindSubject, "alice")
+		tracker.Fail(lockout.KindSubject, "alice")
+		tracker.Succeed(lockout.KindSubject, "alice")
+		_, locked := tracker.Fail(lockout.KindSubject, "alice")
+		assert.False(t, locked)
+	})
+
+	t.Run("BoundedEntries", func(t *testing.T) {
+		now := start
+		tracker := newTestTracker(&now)
+		tracker.MaxEntries = 10
+
+		for i := 0; i < 3; i++ {
+			tracker.Fail(lockout.KindIP, "192.0.2.1")
+		}
+		for i := 0; i < 100; i++ {
+			now = now.Add(100 * time.Millisecond)
+			tracker.Fail(lockout.KindIP, fmt.Sprintf("198.51.100.%d", i))
+		}
+		_, locked := tracker.Locked(lockout.KindIP, "192.0.2.1")
+		assert.True(t, locked, "a flood of new keys does not evict lockouts")
+
+		// Recent counters survive; the oldest were evicted
+		tracker.Fail(lockout.KindIP, "198.51.100.99")
+		_, locked = tracker.Fail(lockout.KindIP, "198.51.100.99")
+		assert.True(t, locked)
+		tracker.Fail(lockout.KindIP, "198.51.100.0")
+		_, locked = tracker.Fail(lockout.KindIP, "198.51.100.0")
+		assert.False(t, locked)
+	})
+
+	t.Run("ListAndClear", func(t *testing.T) {
+		now := start
+		tracker := newTestTracker(&now)
+		for i := 0; i < 3; i++ {
+			tracker.Fail(lockout.KindSubject, "mallory")
+		}
+		tracker.Fail(lockout.KindIP, "192.0.2.9")
+
+		list := tracker.List()
+		require.Len(t, list, 1)
+		assert.Equal(t, "mallory", list[0].Value)
+
+		assert.True(t, tracker.Clear(lockout.KindSubject, "mallory"))
+		assert.False(t, tracker.Clear(lockout.KindSubject, "mallory"))
+		_, locked := tracker.Locked(lockout.KindSubject, "mallory")
+		assert.False(t, locked)
+	})
+}
+
+func TestAuthMiddleware_Lockout(t *testing.T) {
+	provider := setupDevIdP(t)
+	store := useAuditStore(t)
+
+	now := time.Now()
+	tracker := newTestTracker(&now)
+	lockout.Use(tracker)
+	t.Cleanup(func() { lockout.Use(nil) })
+
+	r := gin.New()
+	r.GET("/me", newAuthenticator(provider).Auth(""), controllers.Me)
+
+	send := func(token string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/me", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	valid, err := provider.Mint(devidp.Identity{Subject: "alice"}, 0)
+	require.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, http.StatusUnauthorized, send("not-a-token").Code)
+	}
+
+	// The IP is locked out, even for a valid token
+	w := send(valid)
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.NotEmpty(t, w.Header().Get("Retry-After"))
+
+	events, err := store.Query(context.Background(), audit.Filter{Type: audit.TypeLockout})
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, lockout.KindIP, events[0].Details["kind"])
+
+	// An admin lifts the lockout
+	ctrl := controllers.NewLockoutController(tracker)
+	admin := gin.New()
+	admin.Use(func(c *gin.Context) {
+		auth.SetPrincipal(c, &auth.Principal{Subject: "admin", Roles: []string{auth.RolePlatformAdmin}})
+	})
+	admin.GET("/gallery/lockouts", ctrl.Index)
+	admin.DELETE("/gallery/lockouts", ctrl.Delete)
+
+	w = httptest.NewRecorder()
+	admin.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/gallery/lockouts", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	var body struct {
+		Lockouts []lockout.Lockout `json:"lockouts"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	require.Len(t, body.Lockouts, 1)
+	ip := body.Lockouts[0].Value
+
+	w = httptest.NewRecorder()
+	admin.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/gallery/lockouts?kind=bogus&value=x", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = httptest.NewRecorder()
+	admin.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/gallery/lockouts?kind=ip&value="+ip, nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	assert.Equal(t, http.StatusOK, send(valid).Code)
+}
+
+func TestAuthMiddleware_ForgedSubjectsDoNotLockOut(t *testing.T) {
+	provider := setupDevIdP(t)
+	stranger := setupDevIdP(t)
+
+	now := time.Now()
+	tracker := newTestTracker(&now)
+	lockout.Use(tracker)
+	t.Cleanup(func() { lockout.Use(nil) })
+
+	r := gin.New()
+	r.GET("/me", newAuthenticator(provider).Auth(""), controllers.Me)
+	send := func(token, ip string) int {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/me", nil)
+		req.RemoteAddr = ip + ":1234"
+		req.Header.Set("Authorization", "Bearer "+token)
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// An attacker presents tokens naming alice that are signed by another key
+	forged, err := stranger.Mint(devidp.Identity{Subject: "alice", Claims: map[string]interface{}{"iss": provider.Issuer}}, 0)
+	require.NoError(t, err)
+	for i := 0; i < 5; i++ {
+		send(forged, "203.0.113.66")
+	}
+	assert.Equal(t, http.StatusTooManyRequests, send(forged, "203.0.113.66"), "the attacker's IP is locked out")
+
+	valid, err := provider.Mint(devidp.Identity{Subject: "alice"}, 0)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, send(valid, "192.0.2.10"), "alice is not")
+	_, locked := tracker.Locked(lockout.KindSubject, "alice")
+	assert.False(t, locked)
+}
+
+func TestTrustedProxies(t *testing.T) {
+	r := gin.New()
+	require.NoError(t, r.SetTrustedProxies([]string{"10.0.0.0/8"}))
+	r.GET("/ip", func(c *gin.Context) { c.String(http.StatusOK, c.ClientIP()) })
+	clientIP := func(remote string) string {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/ip", nil)
+		req.RemoteAddr = remote + ":1234"
+		req.Header.Set("X-Forwarded-For", "198.51.100.7")
+		r.ServeHTTP(w, req)
+		return w.Body.String()
+	}
+
+	assert.Equal(t, "198.51.100.7", clientIP("10.1.2.3"), "forwarded by a trusted proxy")
+	assert.Equal(t, "203.0.113.66", clientIP("203.0.113.66"), "spoofed by a client")
+}
+
+func TestAPIKeyMiddleware_LockoutPerKeyPrefix(t *testing.T) {
+	now := time.Now()
+	tracker := newTestTracker(&now)
+	lockout.Use(tracker)
+	t.Cleanup(func() { lockout.Use(nil) })
+
+	r := gin.New()
+	r.GET("/me", (&middleware.Authenticator{ClientID: "apollo-client"}).AuthOrAPIKey("", rejectingKeys{}), controllers.Me)
+
+	for i := 0; i < 3; i++ {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/me", nil)
+		req.Header.Set(middleware.APIKeyHeader, "abk_deadbeef_guess")
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	}
+
+	_, locked := tracker.Locked(lockout.KindSubject, "apikey-prefix:deadbeef")
+	assert.True(t, locked)
+}
+
+type rejectingKeys struct{}
+
+func (rejectingKeys) Authenticate(context.Context, string) (*auth.Principal, error) {
+	return nil, assert.AnError
+}
@@ -0,0 +1,320 @@
+package unit_test
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/paumarro/apollo-be/internal/auth"
+	"github.com/paumarro/apollo-be/internal/migrate"
+	"github.com/paumarro/apollo-be/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// recordingLocker stands in for the Postgres advisory lock.
+type recordingLocker struct {
+	calls []string
+}
+
+func (l *recordingLocker) Lock(context.Context, *sql.Conn) error {
+	l.calls = append(l.calls, "lock")
+	return nil
+}
+
+func (l *recordingLocker) Unlock(context.Context, *sql.Conn) error {
+	l.calls = append(l.calls, "unlock")
+	return nil
+}
+
+func migrationFiles() fstest.MapFS {
+	return fstest.MapFS{
+		"0001_create_things.up.sql":   {Data: []byte("CREATE TABLE things (id INTEGER PRIMARY KEY, name TEXT);")},
+		"0001_create_things.down.sql": {Data: []byte("DROP TABLE things;")},
+		"0002_add_colour.up.sql":      {Data: []byte("ALTER TABLE things ADD COLUMN colour TEXT;")},
+		"0002_add_colour.down.sql":    {Data: []byte("ALTER TABLE things DROP COLUMN colour;")},
+		"0003_backfill.up.sql":        {Data: []byte("UPDATE things SET colour = 'red' WHERE colour IS NULL;")},
+	}
+}
+
+func newTestMigrator(t *testing.T, files fstest.MapFS) (*migrate.Migrator, *recordingLocker) {
+	t.Helper()
+	// A file rather than :memory:, since every pool connection would get
+	// its own in-memory database
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "migrate.db")), &gorm.Config{})
+	require.NoError(t, err)
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	migrations, err := migrate.Load(files)
+	require.NoError(t, err)
+	locker := &recordingLocker{}
+	migrator := migrate.New(sqlDB, migrations)
+	migrator.Locker = locker
+	return migrator, locker
+}
+
+func states(t *testing.T, migrator *migrate.Migrator) []migrate.State {
+	t.Helper()
+	statuses, err := migrator.Status(context.Background())
+	require.NoError(t, err)
+	var states []migrate.State
+	for _, s := range statuses {
+		states = append(states, s.State)
+	}
+	return states
+}
+
+func TestMigrateLoad(t *testing.T) {
+	migrations, err := migrate.Load(migrationFiles())
+	require.NoError(t, err)
+	require.Len(t, migrations, 3)
+	assert.Equal(t, int64(1), migrations[0].Version)
+	assert.Equal(t, "create_things", migrations[0].Name)
+	assert.Equal(t, "DROP TABLE things;", migrations[0].Down)
+	assert.Len(t, migrations[0].Checksum, 64)
+	assert.Empty(t, migrations[2].Down)
+
+	for name, files := range map[string]fstest.MapFS{
+		"BadName":          {"add_things.up.sql": {Data: []byte("SELECT 1;")}},
+		"MissingUp":        {"0001_things.down.sql": {Data: []byte("SELECT 1;")}},
+		"DuplicateVersion": {"0001_a.up.sql": {Data: []byte("SELECT 1;")}, "0001_b.up.sql": {Data: []byte("SELECT 1;")}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := migrate.Load(files)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestMigrateEmbedded(t *testing.T) {
+	var versions map[int64]string
+	for _, dialect := range migrate.Dialects {
+		migrations, err := migrate.Embedded(dialect)
+		require.NoError(t, err)
+		require.NotEmpty(t, migrations)
+		assert.Equal(t, "initial_schema", migrations[0].Name)
+
+		// Every dialect has the same versions
+		got := map[int64]string{}
+		for _, m := range migrations {
+			got[m.Version] = m.Name
+			assert.NotEmpty(t, m.Down, "%s migration %d should be reversible", dialect, m.Version)
+		}
+		if versions == nil {
+			versions = got
+		}
+		assert.Equal(t, versions, got, dialect)
+	}
+
+	_, err := migrate.Embedded("oracle")
+	assert.Error(t, err)
+}
+
+func TestMigrateEmbedded_SQLiteRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	db := newSQLiteDB(t)
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	migrator, err := migrate.ForDialect(sqlDB, "sqlite")
+	require.NoError(t, err)
+
+	reverted, err := migrator.Down(ctx, len(migrator.Migrations))
+	require.NoError(t, err)
+	assert.Len(t, reverted, len(migrator.Migrations))
+	assert.Error(t, db.Exec("SELECT 1 FROM artworks").Error)
+
+	applied, err := migrator.Up(ctx)
+	require.NoError(t, err)
+	assert.Len(t, applied, len(migrator.Migrations))
+}
+
+// baselineArtwork is the artwork model of the last release that created its
+// table with AutoMigrate.
+type baselineArtwork struct {
+	ID          uint `gorm:"primaryKey"`
+	Title       string
+	Artist      string
+	Description string
+	Image       string
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	DeletedAt   gorm.DeletedAt `gorm:"index"`
+}
+
+func (baselineArtwork) TableName() string { return "artworks" }
+
+func TestMigrateEmbedded_UpgradesBaseline(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "baseline.db")), &gorm.Config{})
+	require.NoError(t, err)
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	t.Cleanup(func() { sqlDB.Close() })
+	require.NoError(t, db.AutoMigrate(&baselineArtwork{}))
+	require.NoError(t, db.Create(&baselineArtwork{Title: "Water Lilies", Artist: "Monet"}).Error)
+
+	migrator, err := migrate.ForDialect(sqlDB, "sqlite")
+	require.NoError(t, err)
+	applied, err := migrator.Up(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, applied, len(migrator.Migrations))
+
+	// Existing artworks keep their data and get the new columns' defaults
+	var artwork models.Artwork
+	require.NoError(t, db.First(&artwork).Error)
+	assert.Equal(t, "Water Lilies", artwork.Title)
+	assert.Equal(t, models.StatusPublished, artwork.Status)
+	assert.Empty(t, artwork.Gallery)
+	require.NoError(t, db.Create(&models.Artwork{Title: "Nymphéas", Gallery: "orangerie"}).Error)
+
+	// Nobody may edit an artwork without a gallery until it is assigned one
+	policies, err := auth.NewEngine(auth.DefaultRules())
+	require.NoError(t, err)
+	admin := &auth.Principal{Subject: "admin", Roles: []string{auth.RoleGalleryAdmin}, Galleries: []string{"orangerie"}}
+	resource := func(a *models.Artwork) auth.Resource {
+		return auth.Resource{Type: "artwork", Gallery: a.Gallery, Status: a.Status}
+	}
+	assert.False(t, policies.Allowed(admin, auth.ActionArtworkUpdate, resource(&artwork)))
+
+	assigned, err := migrate.AssignGallery(context.Background(), sqlDB, "orangerie")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), assigned, "only artworks without a gallery are assigned")
+	require.NoError(t, db.First(&artwork, artwork.ID).Error)
+	assert.Equal(t, "orangerie", artwork.Gallery)
+	for _, action := range []string{auth.ActionArtworkUpdate, auth.ActionArtworkDelete, auth.ActionArtworkPurge} {
+		assert.True(t, policies.Allowed(admin, action, resource(&artwork)), action)
+	}
+
+	assigned, err = migrate.AssignGallery(context.Background(), sqlDB, "")
+	require.NoError(t, err)
+	assert.Zero(t, assigned)
+}
+
+func TestMigrator_UpDownStatus(t *testing.T) {
+	ctx := context.Background()
+	migrator, locker := newTestMigrator(t, migrationFiles())
+
+	assert.Equal(t, []migrate.State{migrate.StatePending, migrate.StatePending, migrate.StatePending}, states(t, migrator))
+
+	applied, err := migrator.Up(ctx)
+	require.NoError(t, err)
+	assert.Len(t, applied, 3)
+	assert.Equal(t, []migrate.State{migrate.StateApplied, migrate.StateApplied, migrate.StateApplied}, states(t, migrator))
+	_, err = migrator.DB.Exec("INSERT INTO things (name, colour) VALUES ('vase', 'blue')")
+	require.NoError(t, err)
+
+	// Applying again is a no-op
+	applied, err = migrator.Up(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, applied)
+
+	// The backfill has no down script
+	_, err = migrator.Down(ctx, 1)
+	assert.ErrorContains(t, err, "irreversible")
+
+	// Every run holds the lock
+	assert.Equal(t, []string{"lock", "unlock"}, locker.calls[len(locker.calls)-2:])
+	assert.Equal(t, len(locker.calls)/2, countOf(locker.calls, "unlock"))
+
+	files := migrationFiles()
+	files["0003_backfill.down.sql"] = &fstest.MapFile{Data: []byte("SELECT 1;")}
+	migrator, _ = newTestMigratorOn(t, migrator.DB, files)
+	reverted, err := migrator.Down(ctx, 2)
+	require.NoError(t, err)
+	require.Len(t, reverted, 2)
+	assert.Equal(t, int64(3), reverted[0].Version)
+	assert.Equal(t, int64(2), reverted[1].Version)
+	assert.Equal(t, []migrate.State{migrate.StateApplied, migrate.StatePending, migrate.StatePending}, states(t, migrator))
+
+	// The reverted column is gone but the data of the first migration stays
+	var name string
+	require.NoError(t, migrator.DB.QueryRow("SELECT name FROM things").Scan(&name))
+	assert.Equal(t, "vase", name)
+	_, err = migrator.DB.Exec("SELECT colour FROM things")
+	assert.Error(t, err)
+}
+
+func TestMigrator_ChecksumAndMissing(t *testing.T) {
+	ctx := context.Background()
+	migrator, _ := newTestMigrator(t, migrationFiles())
+	_, err := migrator.Up(ctx)
+	require.NoError(t, err)
+
+	// An edited applied migration blocks further migrations
+	files := migrationFiles()
+	files["0002_add_colour.up.sql"] = &fstest.MapFile{Data: []byte("ALTER TABLE things ADD COLUMN color TEXT;")}
+	files["0004_more.up.sql"] = &fstest.MapFile{Data: []byte("CREATE TABLE more (id INTEGER);")}
+	edited, _ := newTestMigratorOn(t, migrator.DB, files)
+	_, err = edited.Up(ctx)
+	assert.ErrorIs(t, err, migrate.ErrChecksumMismatch)
+	assert.Equal(t, []migrate.State{migrate.StateApplied, migrate.StateModified, migrate.StateApplied, migrate.StatePending}, states(t, edited))
+
+	// Migrations applied by a newer release show up as missing
+	files = migrationFiles()
+	delete(files, "0003_backfill.up.sql")
+	older, _ := newTestMigratorOn(t, migrator.DB, files)
+	assert.Equal(t, []migrate.State{migrate.StateApplied, migrate.StateApplied, migrate.StateMissing}, states(t, older))
+	_, err = older.Down(ctx, 1)
+	assert.ErrorContains(t, err, "no file")
+}
+
+func TestMigrator_FailedMigrationRollsBack(t *testing.T) {
+	files := migrationFiles()
+	files["0002_add_colour.up.sql"] = &fstest.MapFile{Data: []byte("ALTER TABLE things ADD COLUMN colour TEXT; SELECT * FROM nowhere;")}
+	migrator, _ := newTestMigrator(t, files)
+
+	applied, err := migrator.Up(context.Background())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "0002_add_colour")
+	assert.Len(t, applied, 1)
+	assert.Equal(t, []migrate.State{migrate.StateApplied, migrate.StatePending, migrate.StatePending}, states(t, migrator))
+	_, err = migrator.DB.Exec("SELECT colour FROM things")
+	assert.Error(t, err, "the partial migration is rolled back")
+}
+
+func TestMigrateCreate(t *testing.T) {
+	dir := t.TempDir()
+	up, down, err := migrate.Create(dir, "Add artwork index")
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "0001_add_artwork_index.up.sql"), up)
+	assert.Equal(t, filepath.Join(dir, "0001_add_artwork_index.down.sql"), down)
+
+	up, _, err = migrate.Create(dir, "backfill")
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "0002_backfill.up.sql"), up)
+
+	migrations, err := migrate.Load(os.DirFS(dir))
+	require.NoError(t, err)
+	assert.Len(t, migrations, 2)
+
+	_, _, err = migrate.Create(dir, "drop; table")
+	assert.Error(t, err)
+}
+
+// newTestMigratorOn creates a migrator with files for an existing database.
+func newTestMigratorOn(t *testing.T, db *sql.DB, files fstest.MapFS) (*migrate.Migrator, *recordingLocker) {
+	t.Helper()
+	migrations, err := migrate.Load(files)
+	require.NoError(t, err)
+	locker := &recordingLocker{}
+	migrator := migrate.New(db, migrations)
+	migrator.Locker = locker
+	return migrator, locker
+}
+
+func countOf(values []string, value string) int {
+	n := 0
+	for _, v := range values {
+		if v == value {
+			n++
+		}
+	}
+	return n
+}
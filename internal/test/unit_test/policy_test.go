@@ -0,0 +1,193 @@
+package unit_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/paumarro/apollo-be/internal/auth"
+	"github.com/paumarro/apollo-be/internal/dto"
+	"github.com/paumarro/apollo-be/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDefaultPolicies(t *testing.T) {
+	engine, err := auth.NewEngine(auth.DefaultRules())
+	require.NoError(t, err)
+
+	editor := &auth.Principal{Subject: "e", Roles: []string{auth.RoleGallery}, Galleries: []string{"louvre"}}
+	viewer := &auth.Principal{Subject: "v", Roles: []string{auth.RoleGalleryViewer}, Galleries: []string{"louvre"}}
+	admin := &auth.Principal{Subject: "a", Roles: []string{auth.RoleGalleryAdmin}, Galleries: []string{"louvre"}}
+	visitor := &auth.Principal{Subject: "r", Roles: []string{auth.RoleRegular}}
+
+	published := auth.Resource{Type: "artwork", Gallery: "louvre", Status: models.StatusPublished}
+	draft := auth.Resource{Type: "artwork", Gallery: "louvre", Status: models.StatusDraft}
+	foreign := auth.Resource{Type: "artwork", Gallery: "prado", Status: models.StatusPublished}
+
+	tests := []struct {
+		name      string
+		principal *auth.Principal
+		action    string
+		resource  auth.Resource
+		allowed   bool
+	}{
+		{"anonymous reads published", nil, auth.ActionArtworkRead, published, true},
+		{"anonymous cannot read draft", nil, auth.ActionArtworkRead, draft, false},
+		{"visitor cannot read draft", visitor, auth.ActionArtworkRead, draft, false},
+		{"viewer reads own gallery draft", viewer, auth.ActionArtworkRead, draft, true},
+		{"viewer cannot update", viewer, auth.ActionArtworkUpdate, published, false},
+		{"editor updates own gallery", editor, auth.ActionArtworkUpdate, published, true},
+		{"editor cannot update foreign gallery", editor, auth.ActionArtworkUpdate, foreign, false},
+		{"editor creates in own gallery", editor, auth.ActionArtworkCreate, draft, true},
+		{"editor cannot purge", editor, auth.ActionArtworkPurge, published, false},
+		{"admin purges own gallery", admin, auth.ActionArtworkPurge, published, true},
+		{"admin cannot purge foreign gallery", admin, auth.ActionArtworkPurge, foreign, false},
+		{"anonymous cannot create", nil, auth.ActionArtworkCreate, published, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.allowed, engine.Allowed(tt.principal, tt.action, tt.resource))
+			err := engine.Authorize(tt.principal, tt.action, tt.resource)
+			assert.Equal(t, !tt.allowed, errors.Is(err, auth.ErrForbidden))
+		})
+	}
+}
+
+func TestPolicyEngineRejectsUnknownCondition(t *testing.T) {
+	_, err := auth.NewEngine([]auth.Rule{{Name: "bad", Actions: []string{auth.ActionArtworkRead}, Conditions: []string{"full_moon"}}})
+	assert.Error(t, err)
+}
+
+func TestLoadPolicyFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policies.json")
+	require.NoError(t, os.WriteFile(path, []byte(`[
+		{"name": "everyone-updates", "actions": ["artwork:update"], "conditions": ["authenticated"]}
+	]`), 0o600))
+
+	engine, err := auth.LoadEngine(path)
+	require.NoError(t, err)
+
+	assert.True(t, engine.Allowed(&auth.Principal{Subject: "x"}, auth.ActionArtworkUpdate, auth.Resource{}))
+	assert.False(t, engine.Allowed(nil, auth.ActionArtworkUpdate, auth.Resource{}))
+	assert.False(t, engine.Allowed(&auth.Principal{Subject: "x"}, auth.ActionArtworkRead, auth.Resource{}))
+}
+
+func TestArtworkControllerPolicies(t *testing.T) {
+	engine, err := auth.NewEngine(auth.DefaultRules())
+	require.NoError(t, err)
+
+	editor := &auth.Principal{Subject: "e", Roles: []string{auth.RoleGallery}, Galleries: []string{"louvre"}}
+	request := dto.ArtworkRequest{
+		Title:       "New Title",
+		Artist:      "New Artist",
+		Description: "New Description",
+		Image:       "https://example.com/new.jpg",
+	}
+
+	t.Run("Update Foreign Gallery Forbidden", func(t *testing.T) {
+		mockRepo, ac := setupMockController()
+		ac.Policies = engine
+		mockRepo.On("FindByID", mock.Anything, "1").Return(&models.Artwork{ID: 1, Gallery: "prado", Status: models.StatusPublished}, nil)
+
+		w := httptest.NewRecorder()
+		c := newTestContext(w)
+		c.Params = []gin.Param{{Key: "id", Value: "1"}}
+		auth.SetPrincipal(c, editor)
+		c.Set("sanitizedArtwork", request)
+
+		ac.Update(c)
+
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		mockRepo.AssertNotCalled(t, "Update", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Update Own Gallery", func(t *testing.T) {
+		mockRepo, ac := setupMockController()
+		ac.Policies = engine
+		mockRepo.On("FindByID", mock.Anything, "1").Return(&models.Artwork{ID: 1, Gallery: "louvre", Status: models.StatusPublished}, nil)
+		mockRepo.On("Update", mock.Anything, mock.AnythingOfType("*models.Artwork")).Return(nil)
+
+		w := httptest.NewRecorder()
+		c := newTestContext(w)
+		c.Params = []gin.Param{{Key: "id", Value: "1"}}
+		auth.SetPrincipal(c, editor)
+		c.Set("sanitizedArtwork", request)
+
+		ac.Update(c)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"updated_by":"e"`)
+	})
+
+	t.Run("Create Defaults To Caller Gallery", func(t *testing.T) {
+		mockRepo, ac := setupMockController()
+		ac.Policies = engine
+		mockRepo.On("Create", mock.Anything, mock.AnythingOfType("*models.Artwork")).Return(nil)
+		mockRepo.On("FindByGallery", mock.Anything, mock.Anything).Return([]models.Artwork{}, nil)
+
+		w := httptest.NewRecorder()
+		c := newTestContext(w)
+		auth.SetPrincipal(c, editor)
+		c.Set("sanitizedArtwork", request)
+
+		ac.Create(c)
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Contains(t, w.Body.String(), `"gallery":"louvre"`)
+	})
+
+	t.Run("Draft Hidden From Anonymous", func(t *testing.T) {
+		mockRepo, ac := setupMockController()
+		ac.Policies = engine
+		mockRepo.On("FindByID", mock.Anything, "1").Return(&models.Artwork{ID: 1, Gallery: "louvre", Status: models.StatusDraft}, nil)
+
+		w := httptest.NewRecorder()
+		c := newTestContext(w)
+		c.Params = []gin.Param{{Key: "id", Value: "1"}}
+
+		ac.Find(c)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("Index Filters Drafts", func(t *testing.T) {
+		mockRepo, ac := setupMockController()
+		ac.Policies = engine
+		mockRepo.On("FindAll", mock.Anything).Return([]models.Artwork{
+			{ID: 1, Title: "Public Piece", Gallery: "louvre", Status: models.StatusPublished},
+			{ID: 2, Title: "Secret Piece", Gallery: "louvre", Status: models.StatusDraft},
+		}, nil)
+
+		w := httptest.NewRecorder()
+		c := newTestContext(w)
+
+		ac.Index(c)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), "Public Piece")
+		assert.NotContains(t, w.Body.String(), "Secret Piece")
+	})
+
+	t.Run("Purge Requires Admin", func(t *testing.T) {
+		mockRepo, ac := setupMockController()
+		ac.Policies = engine
+		mockRepo.On("FindByIDUnscoped", mock.Anything, "1").Return(&models.Artwork{ID: 1, Gallery: "louvre"}, nil)
+
+		w := httptest.NewRecorder()
+		c := newTestContext(w)
+		c.Params = []gin.Param{{Key: "id", Value: "1"}}
+		auth.SetPrincipal(c, editor)
+
+		ac.Purge(c)
+
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		mockRepo.AssertNotCalled(t, "Purge", mock.Anything, mock.Anything)
+	})
+}
@@ -0,0 +1,78 @@
+package unit_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/paumarro/apollo-be/internal/config"
+	"github.com/paumarro/apollo-be/internal/initializers"
+	"github.com/paumarro/apollo-be/internal/migrate"
+	"github.com/paumarro/apollo-be/internal/repositories"
+	"github.com/paumarro/apollo-be/internal/repositories/repotest"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// newSQLiteDB connects to a private in-memory SQLite database with the
+// migrations applied.
+func newSQLiteDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := initializers.ConnectToDB(context.Background(), config.Database{
+		URL:              "sqlite::memory:",
+		StatementTimeout: time.Second,
+		ConnectTimeout:   time.Second,
+		RetryBackoff:     10 * time.Millisecond,
+		RetryBackoffMax:  10 * time.Millisecond,
+	})
+	require.NoError(t, err)
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	migrator, err := migrate.ForDialect(sqlDB, db.Dialector.Name())
+	require.NoError(t, err)
+	_, err = migrator.Up(context.Background())
+	require.NoError(t, err)
+	return db
+}
+
+func TestArtworkRepositoryContract_Memory(t *testing.T) {
+	repotest.ArtworkRepository(t, func(t *testing.T) repositories.ArtworkRepository {
+		return repositories.NewMemoryArtworkRepository()
+	})
+}
+
+func TestArtworkRepositoryContract_SQLite(t *testing.T) {
+	repotest.ArtworkRepository(t, func(t *testing.T) repositories.ArtworkRepository {
+		return repositories.NewGormArtworkRepository(newSQLiteDB(t))
+	})
+}
+
+func TestUnitOfWorkContract_Memory(t *testing.T) {
+	repotest.UnitOfWork(t, func(t *testing.T) (repositories.UnitOfWork, repositories.ArtworkRepository) {
+		artworks := repositories.NewMemoryArtworkRepository()
+		return repositories.NewMemoryUnitOfWork(artworks), artworks
+	})
+}
+
+func TestUnitOfWorkContract_SQLite(t *testing.T) {
+	repotest.UnitOfWork(t, func(t *testing.T) (repositories.UnitOfWork, repositories.ArtworkRepository) {
+		db := newSQLiteDB(t)
+		return repositories.NewGormUnitOfWork(db), repositories.NewGormArtworkRepository(db)
+	})
+}
+
+func TestArtworkRepositoryContract_Cached(t *testing.T) {
+	repotest.ArtworkRepository(t, func(t *testing.T) repositories.ArtworkRepository {
+		return repositories.NewCachedArtworkRepository(repositories.NewMemoryArtworkRepository(), 100, time.Minute, time.Minute)
+	})
+}
+
+func TestUnitOfWorkContract_Cached(t *testing.T) {
+	repotest.UnitOfWork(t, func(t *testing.T) (repositories.UnitOfWork, repositories.ArtworkRepository) {
+		artworks := repositories.NewMemoryArtworkRepository()
+		cached := repositories.NewCachedArtworkRepository(artworks, 100, time.Minute, time.Minute)
+		return repositories.NewCachedUnitOfWork(repositories.NewMemoryUnitOfWork(artworks), cached), cached
+	})
+}
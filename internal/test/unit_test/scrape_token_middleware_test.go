@@ -0,0 +1,49 @@
+package unit_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/paumarro/apollo-be/internal/metrics"
+	"github.com/paumarro/apollo-be/internal/middleware"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScrapeToken(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	const token = "0123456789abcdef0123456789abcdef"
+	registry := metrics.NewRegistry()
+	registry.Register("apollo_test", "Test gauge.", metrics.Gauge, func() float64 { return 1 })
+	r := gin.New()
+	r.GET("/metrics", middleware.ScrapeToken(token), gin.WrapH(registry))
+
+	tests := []struct {
+		name   string
+		header string
+		status int
+	}{
+		{"NoToken", "", http.StatusUnauthorized},
+		{"WrongToken", "Bearer 0123456789abcdef0123456789abcdeX", http.StatusUnauthorized},
+		{"NotBearer", "Basic " + token, http.StatusUnauthorized},
+		{"ValidToken", "Bearer " + token, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.status, w.Code)
+			if tt.status == http.StatusOK {
+				assert.Contains(t, w.Body.String(), "apollo_test 1")
+			} else {
+				assert.NotContains(t, w.Body.String(), "apollo_test")
+			}
+		})
+	}
+}
@@ -0,0 +1,325 @@
+package unit_test
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/paumarro/apollo-be/internal/config"
+	"github.com/paumarro/apollo-be/internal/health"
+	"github.com/paumarro/apollo-be/internal/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testServerConfig(shutdownTimeout time.Duration) config.Server {
+	return config.Server{
+		Host:              "127.0.0.1",
+		Port:              "0",
+		ReadTimeout:       5 * time.Second,
+		ReadHeaderTimeout: 5 * time.Second,
+		WriteTimeout:      5 * time.Second,
+		IdleTimeout:       5 * time.Second,
+		MaxHeaderBytes:    4096,
+		ShutdownTimeout:   shutdownTimeout,
+	}
+}
+
+// startServer serves handler on a random port and returns its URL and the Serve result.
+func startServer(t *testing.T, srv *server.Server, ctx context.Context) (string, <-chan error) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	done := make(chan error, 1)
+	go func() { done <- srv.Serve(ctx, ln) }()
+	return "http://" + ln.Addr().String(), done
+}
+
+func TestServer_New(t *testing.T) {
+	srv := server.New(testServerConfig(time.Second), http.NotFoundHandler())
+	assert.Equal(t, "127.0.0.1:0", srv.HTTP.Addr)
+	assert.Equal(t, 4096, srv.HTTP.MaxHeaderBytes)
+	assert.Equal(t, 5*time.Second, srv.HTTP.ReadHeaderTimeout)
+	assert.Equal(t, time.Second, srv.ShutdownTimeout)
+}
+
+func TestServer_GracefulShutdown(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	srv := server.New(testServerConfig(5*time.Second), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		_, _ = io.WriteString(w, "done")
+	}))
+
+	var mu sync.Mutex
+	var closed []string
+	for _, name := range []string{"database", "workers", "cache"} {
+		name := name
+		srv.OnShutdown(name, func(context.Context) error {
+			mu.Lock()
+			defer mu.Unlock()
+			closed = append(closed, name)
+			return nil
+		})
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	url, done := startServer(t, srv, ctx)
+
+	// An in-flight request survives the shutdown signal
+	type result struct {
+		body string
+		err  error
+	}
+	response := make(chan result, 1)
+	go func() {
+		resp, err := http.Get(url)
+		if err != nil {
+			response <- result{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		response <- result{body: string(body), err: err}
+	}()
+	<-started
+	cancel()
+
+	// Resources stay open while the request drains
+	time.Sleep(50 * time.Millisecond)
+	mu.Lock()
+	assert.Empty(t, closed)
+	mu.Unlock()
+
+	close(release)
+	r := <-response
+	require.NoError(t, r.err)
+	assert.Equal(t, "done", r.body)
+
+	require.NoError(t, <-done)
+	assert.Equal(t, []string{"cache", "workers", "database"}, closed)
+
+	// New connections are refused after shutdown
+	_, err := http.Get(url)
+	assert.Error(t, err)
+}
+
+func TestServer_ShutdownDeadline(t *testing.T) {
+	release := make(chan struct{})
+	t.Cleanup(func() { close(release) })
+	started := make(chan struct{})
+	srv := server.New(testServerConfig(100*time.Millisecond), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	}))
+	databaseClosed := false
+	srv.OnShutdown("database", func(context.Context) error {
+		databaseClosed = true
+		return nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	url, done := startServer(t, srv, ctx)
+	go func() {
+		if resp, err := http.Get(url); err == nil {
+			resp.Body.Close()
+		}
+	}()
+	<-started
+	cancel()
+
+	select {
+	case err := <-done:
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	case <-time.After(5 * time.Second):
+		t.Fatal("shutdown did not respect its deadline")
+	}
+	// Resources are released even when draining times out
+	assert.True(t, databaseClosed)
+}
+
+// writeKeyPair writes a self-signed certificate for name and returns the file
+// paths. Modification times are pushed forward so reloads notice the change.
+func writeKeyPair(t *testing.T, dir, name string, modTime time.Time) (string, string) {
+	t.Helper()
+	cert := issueCert(t, name, false, nil)
+	keyDER, err := x509.MarshalPKCS8PrivateKey(cert.PrivateKey)
+	require.NoError(t, err)
+
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Certificate[0]}), 0o600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}), 0o600))
+	for _, f := range []string{certFile, keyFile} {
+		require.NoError(t, os.Chtimes(f, modTime, modTime))
+	}
+	return certFile, keyFile
+}
+
+func TestServer_TLSHotReload(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	certFile, keyFile := writeKeyPair(t, dir, "first.example", now)
+
+	cfg := testServerConfig(time.Second)
+	cfg.TLSCertFile, cfg.TLSKeyFile = certFile, keyFile
+	cfg.TLSMinVersion = "1.2"
+	cfg.TLSReloadInterval = 10 * time.Millisecond
+
+	srv := server.New(cfg, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "ok")
+	}))
+	require.NoError(t, srv.EnableTLS(cfg))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	url, done := startServer(t, srv, ctx)
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	url = "https" + url[len("http"):]
+
+	client := &http.Client{Transport: &http.Transport{
+		TLSClientConfig:   &tls.Config{InsecureSkipVerify: true}, // #nosec G402 -- self-signed test certificates
+		DisableKeepAlives: true,
+	}}
+	servedName := func() string {
+		resp, err := client.Get(url)
+		if err != nil {
+			return err.Error()
+		}
+		defer resp.Body.Close()
+		return resp.TLS.PeerCertificates[0].Subject.CommonName
+	}
+	assert.Equal(t, "first.example", servedName())
+
+	// A broken write keeps the previous certificate
+	require.NoError(t, os.WriteFile(certFile, []byte("not a certificate"), 0o600))
+	require.NoError(t, os.Chtimes(certFile, now.Add(time.Minute), now.Add(time.Minute)))
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, "first.example", servedName())
+	lastRun, err := srv.Certificates().LastRun()
+	assert.Error(t, err, "a broken key pair stays reported")
+	assert.WithinDuration(t, time.Now(), lastRun, time.Second)
+
+	// A renewed certificate is served without a restart
+	writeKeyPair(t, dir, "second.example", now.Add(2*time.Minute))
+	assert.Eventually(t, func() bool { return servedName() == "second.example" }, 2*time.Second, 10*time.Millisecond)
+	_, err = srv.Certificates().LastRun()
+	assert.NoError(t, err)
+}
+
+func TestServer_DrainBeforeShutdown(t *testing.T) {
+	cfg := testServerConfig(time.Second)
+	cfg.DrainDelay = 200 * time.Millisecond
+	checker := health.NewChecker(time.Second)
+	srv := server.New(cfg, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if checker.Run(r.Context()).Status == health.StatusFail {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	srv.OnDrain(checker.Drain)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	url, done := startServer(t, srv, ctx)
+	status := func() int {
+		resp, err := http.Get(url)
+		if err != nil {
+			return 0
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	assert.Equal(t, http.StatusOK, status())
+
+	// Readiness fails while connections are still accepted
+	cancel()
+	assert.Eventually(t, func() bool { return status() == http.StatusServiceUnavailable }, time.Second, 10*time.Millisecond)
+	require.NoError(t, <-done)
+}
+
+func TestServer_TLSMinVersion(t *testing.T) {
+	certFile, keyFile := writeKeyPair(t, t.TempDir(), "apollo.example", time.Now())
+	cfg := testServerConfig(time.Second)
+	cfg.TLSCertFile, cfg.TLSKeyFile = certFile, keyFile
+	cfg.TLSMinVersion = "1.3"
+	cfg.TLSReloadInterval = time.Minute
+
+	srv := server.New(cfg, http.NotFoundHandler())
+	require.NoError(t, srv.EnableTLS(cfg))
+	assert.Equal(t, uint16(tls.VersionTLS13), srv.HTTP.TLSConfig.MinVersion)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	url, done := startServer(t, srv, ctx)
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+
+	conn, err := tls.Dial("tcp", url[len("http://"):], &tls.Config{
+		InsecureSkipVerify: true, // #nosec G402 -- self-signed test certificates
+		MaxVersion:         tls.VersionTLS12,
+	})
+	if err == nil {
+		conn.Close()
+	}
+	assert.Error(t, err)
+}
+
+func TestServer_TLSConfigErrors(t *testing.T) {
+	certFile, keyFile := writeKeyPair(t, t.TempDir(), "apollo.example", time.Now())
+	cfg := testServerConfig(time.Second)
+	cfg.TLSCertFile, cfg.TLSKeyFile = certFile, keyFile
+	cfg.TLSMinVersion = "1.2"
+
+	t.Run("UnknownCipherSuite", func(t *testing.T) {
+		cfg := cfg
+		cfg.TLSCipherSuites = []string{"TLS_RSA_WITH_RC4_128_SHA"}
+		assert.Error(t, server.New(cfg, http.NotFoundHandler()).EnableTLS(cfg))
+	})
+
+	t.Run("CipherSuites", func(t *testing.T) {
+		cfg := cfg
+		cfg.TLSCipherSuites = []string{"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256"}
+		srv := server.New(cfg, http.NotFoundHandler())
+		require.NoError(t, srv.EnableTLS(cfg))
+		assert.Equal(t, []uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256}, srv.HTTP.TLSConfig.CipherSuites)
+	})
+
+	t.Run("MissingKeyFile", func(t *testing.T) {
+		cfg := cfg
+		cfg.TLSKeyFile = filepath.Join(t.TempDir(), "missing.key")
+		assert.Error(t, server.New(cfg, http.NotFoundHandler()).EnableTLS(cfg))
+	})
+}
+
+func TestRedirectHandler(t *testing.T) {
+	tests := []struct {
+		name      string
+		httpsPort string
+		target    string
+		want      string
+	}{
+		{"CustomPort", "8443", "http://apollo.example.com:8080/artworks?page=2", "https://apollo.example.com:8443/artworks?page=2"},
+		{"DefaultPort", "443", "http://apollo.example.com/gallery/artworks", "https://apollo.example.com/gallery/artworks"},
+		{"IPv6", "443", "http://[::1]:8080/me", "https://[::1]/me"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			server.RedirectHandler(tt.httpsPort).ServeHTTP(w, httptest.NewRequest(http.MethodPost, tt.target, nil))
+			assert.Equal(t, http.StatusPermanentRedirect, w.Code)
+			assert.Equal(t, tt.want, w.Header().Get("Location"))
+		})
+	}
+}
@@ -0,0 +1,299 @@
+package unit_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/paumarro/apollo-be/internal/auth"
+	"github.com/paumarro/apollo-be/internal/controllers"
+	"github.com/paumarro/apollo-be/internal/devidp"
+	"github.com/paumarro/apollo-be/internal/middleware"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func imageService(t *testing.T) *auth.ServiceRegistry {
+	t.Helper()
+	services, err := auth.NewServiceRegistry([]auth.Service{{
+		Name:      "image-service",
+		ClientID:  "apollo-image-service",
+		CertNames: []string{"image-service.internal"},
+		Roles:     []string{auth.RoleGalleryViewer},
+		Galleries: []string{"louvre"},
+	}})
+	require.NoError(t, err)
+	return services
+}
+
+func TestNewServiceRegistry_Validation(t *testing.T) {
+	tests := []struct {
+		name     string
+		services []auth.Service
+	}{
+		{"MissingName", []auth.Service{{ClientID: "a"}}},
+		{"NoCredentials", []auth.Service{{Name: "a"}}},
+		{"DuplicateClient", []auth.Service{{Name: "a", ClientID: "c"}, {Name: "b", ClientID: "c"}}},
+		{"DuplicateCertName", []auth.Service{{Name: "a", CertNames: []string{"x"}}, {Name: "b", CertNames: []string{"x"}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := auth.NewServiceRegistry(tt.services)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestClientCredentialsTokens(t *testing.T) {
+	provider := setupDevIdP(t)
+	authenticator := newAuthenticator(provider)
+	authenticator.Services = imageService(t)
+
+	r := gin.New()
+	r.GET("/me", authenticator.Auth(""), controllers.Me)
+	r.GET("/admin", authenticator.Auth(auth.RoleGalleryAdmin), controllers.Me)
+
+	request := func(path, token string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	// Roles in a service token are ignored in favour of the configured ones
+	serviceToken, err := provider.Mint(devidp.Identity{
+		Subject: "service-account-apollo-image-service",
+		Roles:   []string{auth.RoleGalleryAdmin},
+		Claims:  map[string]interface{}{"azp": "apollo-image-service"},
+	}, 0)
+	require.NoError(t, err)
+
+	w := request("/me", serviceToken)
+	require.Equal(t, http.StatusOK, w.Code)
+	var body struct {
+		User auth.Principal `json:"user"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, auth.KindService, body.User.Kind)
+	assert.Equal(t, "service:image-service", body.User.Subject)
+	assert.Equal(t, []string{auth.RoleGalleryViewer}, body.User.Roles)
+	assert.Equal(t, []string{"louvre"}, body.User.Galleries)
+
+	assert.Equal(t, http.StatusForbidden, request("/admin", serviceToken).Code)
+
+	// Tokens of other clients stay user principals
+	userToken, err := provider.Mint(devidp.Identity{Subject: "alice"}, 0)
+	require.NoError(t, err)
+	w = request("/me", userToken)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, auth.KindUser, body.User.Kind)
+	assert.Equal(t, "apollo-client", body.User.Client)
+
+	// A user logging in to the service's client does not become the service
+	loginToken, err := provider.Mint(devidp.Identity{
+		Subject: "alice",
+		Claims:  map[string]interface{}{"azp": "apollo-image-service", "sid": "login-session"},
+	}, 0)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, request("/me", loginToken).Code)
+}
+
+func TestClientCredentialsTokens_Subject(t *testing.T) {
+	provider := setupDevIdP(t)
+	authenticator := newAuthenticator(provider)
+	services, err := auth.NewServiceRegistry([]auth.Service{{
+		Name:     "image-service",
+		ClientID: "apollo-image-service",
+		Subject:  "service-account-apollo-image-service",
+		Roles:    []string{auth.RoleGalleryViewer},
+	}})
+	require.NoError(t, err)
+	authenticator.Services = services
+
+	r := gin.New()
+	r.GET("/me", authenticator.Auth(""), controllers.Me)
+	request := func(subject string) int {
+		token, err := provider.Mint(devidp.Identity{
+			Subject: subject,
+			Claims:  map[string]interface{}{"azp": "apollo-image-service", "session_state": "s"},
+		}, 0)
+		require.NoError(t, err)
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/me", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// With a configured subject, a session on the token does not matter
+	assert.Equal(t, http.StatusOK, request("service-account-apollo-image-service"))
+	assert.Equal(t, http.StatusUnauthorized, request("alice"))
+}
+
+func TestClientCredentialsTokens_Issuers(t *testing.T) {
+	home := setupDevIdP(t)
+	partner := setupDevIdP(t)
+	registry, err := auth.NewRegistry([]auth.Issuer{
+		{Issuer: home.Issuer, ClientID: "apollo-client", Tenant: "apollo", Galleries: []string{"louvre"}},
+		{Issuer: partner.Issuer, ClientID: "apollo-client", Tenant: "partner", Galleries: []string{"partner-main"}},
+	})
+	require.NoError(t, err)
+	services, err := auth.NewServiceRegistry([]auth.Service{{
+		Name:      "image-service",
+		Issuer:    home.Issuer,
+		ClientID:  "image-service",
+		Roles:     []string{auth.RoleGalleryViewer},
+		Galleries: []string{"louvre", "orsay"},
+	}})
+	require.NoError(t, err)
+	require.NoError(t, services.CheckIssuers(registry))
+	authenticator := &middleware.Authenticator{Issuers: registry, Services: services}
+
+	r := gin.New()
+	r.GET("/me", authenticator.Auth(""), controllers.Me)
+	me := func(provider *devidp.Provider) auth.Principal {
+		token, err := provider.Mint(devidp.Identity{
+			Subject: "service-account-image-service",
+			Claims:  map[string]interface{}{"azp": "image-service"},
+		}, 0)
+		require.NoError(t, err)
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/me", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		r.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+		var body struct {
+			User auth.Principal `json:"user"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		return body.User
+	}
+
+	service := me(home)
+	assert.Equal(t, auth.KindService, service.Kind)
+	assert.Equal(t, []string{"louvre"}, service.Galleries, "bound to the galleries of the issuer")
+
+	// A partner realm's client of the same name is not the service
+	impostor := me(partner)
+	assert.Equal(t, auth.KindUser, impostor.Kind)
+	assert.Empty(t, impostor.Roles)
+	assert.Empty(t, impostor.Galleries)
+}
+
+func TestServiceRegistry_CheckIssuers(t *testing.T) {
+	registry, err := auth.NewRegistry([]auth.Issuer{{Issuer: "https://sso.example.com/realms/apollo", ClientID: "apollo-client", Galleries: []string{auth.AllGalleries}}})
+	require.NoError(t, err)
+	services := func(issuer string) *auth.ServiceRegistry {
+		r, err := auth.NewServiceRegistry([]auth.Service{{Name: "a", Issuer: issuer, ClientID: "c"}})
+		require.NoError(t, err)
+		return r
+	}
+
+	assert.NoError(t, services("").CheckIssuers(nil))
+	assert.Error(t, services("https://sso.example.com/realms/apollo").CheckIssuers(nil))
+	assert.NoError(t, services("https://sso.example.com/realms/apollo").CheckIssuers(registry))
+	assert.Error(t, services("").CheckIssuers(registry))
+	assert.Error(t, services("https://other.example").CheckIssuers(registry))
+
+	// Client IDs are only unique within an issuer
+	_, err = auth.NewServiceRegistry([]auth.Service{{Name: "a", Issuer: "x", ClientID: "c"}, {Name: "b", Issuer: "y", ClientID: "c"}})
+	assert.NoError(t, err)
+}
+
+// issueCert signs a certificate for name with the CA, or self-signs when ca is nil.
+func issueCert(t *testing.T, name string, isCA bool, ca *tls.Certificate) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: name},
+		DNSNames:              []string{name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth, x509.ExtKeyUsageServerAuth},
+		IsCA:                  isCA,
+		BasicConstraintsValid: true,
+	}
+
+	parent, signer := tmpl, interface{}(key)
+	if ca != nil {
+		parent = ca.Leaf
+		signer = ca.PrivateKey
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, &key.PublicKey, signer)
+	require.NoError(t, err)
+	leaf, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}
+}
+
+func TestMutualTLSServicePrincipals(t *testing.T) {
+	authenticator := newAuthenticator(setupDevIdP(t))
+	authenticator.Services = imageService(t)
+
+	ca := issueCert(t, "Apollo Test CA", true, nil)
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.Leaf)
+
+	r := gin.New()
+	r.GET("/me", authenticator.Auth(auth.RoleGalleryViewer), controllers.Me)
+
+	srv := httptest.NewUnstartedServer(r)
+	srv.TLS = &tls.Config{ClientCAs: pool, ClientAuth: tls.VerifyClientCertIfGiven}
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+
+	get := func(cert *tls.Certificate) *http.Response {
+		client := srv.Client()
+		transport := client.Transport.(*http.Transport).Clone()
+		if cert != nil {
+			transport.TLSClientConfig.Certificates = []tls.Certificate{*cert}
+		}
+		client = &http.Client{
+			Transport:     transport,
+			CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+		}
+		resp, err := client.Get(srv.URL + "/me")
+		require.NoError(t, err)
+		t.Cleanup(func() { resp.Body.Close() })
+		return resp
+	}
+
+	t.Run("KnownCertificate", func(t *testing.T) {
+		cert := issueCert(t, "image-service.internal", false, &ca)
+		resp := get(&cert)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var body struct {
+			User auth.Principal `json:"user"`
+		}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		assert.Equal(t, "service:image-service", body.User.Subject)
+	})
+
+	t.Run("UnknownCertificateNeedsToken", func(t *testing.T) {
+		cert := issueCert(t, "other.internal", false, &ca)
+		assert.Equal(t, http.StatusFound, get(&cert).StatusCode)
+	})
+
+	t.Run("NoCertificate", func(t *testing.T) {
+		assert.Equal(t, http.StatusFound, get(nil).StatusCode)
+	})
+}
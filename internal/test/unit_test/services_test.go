@@ -2,6 +2,7 @@ package unit_test
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"log"
 	"testing"
@@ -12,6 +13,7 @@ import (
 	"github.com/paumarro/apollo-be/internal/repositories"
 	"github.com/paumarro/apollo-be/internal/services"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 )
 
 func setUpMockServiceWithLogger() (*repositories.MockArtworkRepository, *services.ArtworkService, *bytes.Buffer) {
@@ -27,15 +29,15 @@ func TestGetAllArtworks(t *testing.T) {
 		// Arrange
 		mockRepo, service, logBuffer := setUpMockServiceWithLogger()
 
-		mockRepo.On("FindAll").Return([]models.Artwork{}, nil)
+		mockRepo.On("FindAll", mock.Anything).Return([]models.Artwork{}, nil)
 
 		// Act
-		artworks, err := service.GetAllArtworks()
+		artworks, err := service.GetAllArtworks(context.Background())
 
 		// Assert
 		assert.NoError(t, err)
 		assert.Empty(t, artworks)
-		mockRepo.AssertCalled(t, "FindAll")
+		mockRepo.AssertCalled(t, "FindAll", mock.Anything)
 		assert.Contains(t, logBuffer.String(), "Fetching all artworks")
 	})
 
@@ -48,15 +50,15 @@ func TestGetAllArtworks(t *testing.T) {
 			{ID: 2, Title: "Artwork 2", Artist: "Artist 2"},
 		}
 
-		mockRepo.On("FindAll").Return(expectedArtworks, nil)
+		mockRepo.On("FindAll", mock.Anything).Return(expectedArtworks, nil)
 
 		// Act
-		artworks, err := service.GetAllArtworks()
+		artworks, err := service.GetAllArtworks(context.Background())
 
 		// Assert
 		assert.NoError(t, err)
 		assert.Equal(t, expectedArtworks, artworks)
-		mockRepo.AssertCalled(t, "FindAll")
+		mockRepo.AssertCalled(t, "FindAll", mock.Anything)
 		assert.Contains(t, logBuffer.String(), "Fetching all artworks")
 	})
 
@@ -64,16 +66,16 @@ func TestGetAllArtworks(t *testing.T) {
 		// Arrange
 		mockRepo, service, logBuffer := setUpMockServiceWithLogger()
 
-		mockRepo.On("FindAll").Return(nil, errors.New("database error"))
+		mockRepo.On("FindAll", mock.Anything).Return(nil, errors.New("database error"))
 
 		// Act
-		artworks, err := service.GetAllArtworks()
+		artworks, err := service.GetAllArtworks(context.Background())
 
 		// Assert
 		assert.Error(t, err)
 		assert.Nil(t, artworks)
 		assert.Contains(t, err.Error(), "database error")
-		mockRepo.AssertCalled(t, "FindAll")
+		mockRepo.AssertCalled(t, "FindAll", mock.Anything)
 		assert.Contains(t, logBuffer.String(), "Fetching all artworks")
 	})
 }
@@ -83,16 +85,16 @@ func TestGetArtworkByID(t *testing.T) {
 		// Arrange
 		mockRepo, service, logBuffer := setUpMockServiceWithLogger()
 
-		mockRepo.On("FindByID", "invalid-id").Return(nil, gorm.ErrRecordNotFound)
+		mockRepo.On("FindByID", mock.Anything, "invalid-id").Return(nil, gorm.ErrRecordNotFound)
 
 		// Act
-		artwork, err := service.GetArtworkByID("invalid-id")
+		artwork, err := service.GetArtworkByID(context.Background(), "invalid-id")
 
 		// Assert
 		assert.Error(t, err)
 		assert.Nil(t, artwork)
 		assert.True(t, errors.Is(err, services.ErrNotFound), "expected ErrNotFound error")
-		mockRepo.AssertCalled(t, "FindByID", "invalid-id")
+		mockRepo.AssertCalled(t, "FindByID", mock.Anything, "invalid-id")
 		assert.Contains(t, logBuffer.String(), "Fetching artwork with ID: invalid-id")
 	})
 
@@ -102,15 +104,15 @@ func TestGetArtworkByID(t *testing.T) {
 
 		expectedArtwork := &models.Artwork{ID: 1, Title: "Artwork 1", Artist: "Artist 1"}
 
-		mockRepo.On("FindByID", "1").Return(expectedArtwork, nil)
+		mockRepo.On("FindByID", mock.Anything, "1").Return(expectedArtwork, nil)
 
 		// Act
-		artwork, err := service.GetArtworkByID("1")
+		artwork, err := service.GetArtworkByID(context.Background(), "1")
 
 		// Assert
 		assert.NoError(t, err)
 		assert.Equal(t, expectedArtwork, artwork)
-		mockRepo.AssertCalled(t, "FindByID", "1")
+		mockRepo.AssertCalled(t, "FindByID", mock.Anything, "1")
 		assert.Contains(t, logBuffer.String(), "Fetching artwork with ID: 1")
 	})
 }
@@ -122,17 +124,16 @@ func TestCreateArtwork(t *testing.T) {
 
 		invalidArtwork := &models.Artwork{Title: "", Artist: "Someone"} // Title is required (validated by repo/db)
 
-		mockRepo.On("FindAll").Return([]models.Artwork{}, nil)
-		mockRepo.On("Create", invalidArtwork).Return(errors.New("validation error"))
+		mockRepo.On("Create", mock.Anything, invalidArtwork).Return(errors.New("validation error"))
 
 		// Act
-		err := service.CreateArtwork(invalidArtwork)
+		err := service.CreateArtwork(context.Background(), invalidArtwork)
 
 		// Assert
 		assert.Error(t, err)
 		assert.Contains(t, err.Error(), "validation error")
-		mockRepo.AssertCalled(t, "FindAll")
-		mockRepo.AssertCalled(t, "Create", invalidArtwork)
+		assert.False(t, errors.Is(err, services.ErrDuplicate))
+		mockRepo.AssertCalled(t, "Create", mock.Anything, invalidArtwork)
 		assert.Contains(t, logBuffer.String(), "Creating artwork:")
 		assert.Contains(t, logBuffer.String(), "Error in CreateArtwork: validation error")
 	})
@@ -142,21 +143,33 @@ func TestCreateArtwork(t *testing.T) {
 		mockRepo, service, logBuffer := setUpMockServiceWithLogger()
 
 		dup := &models.Artwork{Title: "Same", Artist: "Artist"}
-		// Service checks duplicates using FindAll (title + artist)
-		mockRepo.On("FindAll").Return([]models.Artwork{
-			{ID: 10, Title: "Same", Artist: "Artist"},
-		}, nil)
-		// Create should NOT be called
+		// The unique index rejects the insert; no table scan happens
+		mockRepo.On("Create", mock.Anything, dup).Return(gorm.ErrDuplicatedKey)
+		mockRepo.On("FindDuplicate", mock.Anything, dup).Return(&models.Artwork{ID: 10, Title: "same ", Artist: "ARTIST"}, nil)
 
 		// Act
-		err := service.CreateArtwork(dup)
+		err := service.CreateArtwork(context.Background(), dup)
 
 		// Assert
-		assert.Error(t, err)
 		assert.True(t, errors.Is(err, services.ErrDuplicate))
-		mockRepo.AssertCalled(t, "FindAll")
-		mockRepo.AssertNotCalled(t, "Create")
-		assert.Contains(t, logBuffer.String(), "Creating artwork:")
+		var duplicate *services.DuplicateError
+		if assert.True(t, errors.As(err, &duplicate)) {
+			assert.Equal(t, uint(10), duplicate.ID)
+		}
+		mockRepo.AssertNotCalled(t, "FindAll", mock.Anything)
+		assert.Contains(t, logBuffer.String(), "conflicts with artwork 10")
+	})
+
+	t.Run("DuplicateRemovedSinceConflict", func(t *testing.T) {
+		mockRepo, service, _ := setUpMockServiceWithLogger()
+
+		dup := &models.Artwork{Title: "Same", Artist: "Artist"}
+		mockRepo.On("Create", mock.Anything, dup).Return(gorm.ErrDuplicatedKey)
+		mockRepo.On("FindDuplicate", mock.Anything, dup).Return(nil, gorm.ErrRecordNotFound)
+
+		err := service.CreateArtwork(context.Background(), dup)
+
+		assert.Equal(t, services.ErrDuplicate, err)
 	})
 
 	t.Run("Success", func(t *testing.T) {
@@ -164,34 +177,63 @@ func TestCreateArtwork(t *testing.T) {
 		mockRepo, service, logBuffer := setUpMockServiceWithLogger()
 
 		validArtwork := &models.Artwork{Title: "Valid Title", Artist: "Valid Artist"}
-		// No duplicates
-		mockRepo.On("FindAll").Return([]models.Artwork{}, nil)
-		mockRepo.On("Create", validArtwork).Return(nil)
+		mockRepo.On("Create", mock.Anything, validArtwork).Return(nil)
 
 		// Act
-		err := service.CreateArtwork(validArtwork)
+		err := service.CreateArtwork(context.Background(), validArtwork)
 
 		// Assert
 		assert.NoError(t, err)
-		mockRepo.AssertCalled(t, "FindAll")
-		mockRepo.AssertCalled(t, "Create", validArtwork)
+		mockRepo.AssertNotCalled(t, "FindAll", mock.Anything)
+		mockRepo.AssertCalled(t, "Create", mock.Anything, validArtwork)
 		assert.Contains(t, logBuffer.String(), "Creating artwork:")
 	})
 }
 
+func TestUpdateArtwork(t *testing.T) {
+	t.Run("DuplicateArtwork", func(t *testing.T) {
+		mockRepo, service, _ := setUpMockServiceWithLogger()
+
+		artwork := &models.Artwork{ID: 3, Title: "Same", Artist: "Artist"}
+		mockRepo.On("Update", mock.Anything, artwork).Return(gorm.ErrDuplicatedKey)
+		mockRepo.On("FindDuplicate", mock.Anything, artwork).Return(&models.Artwork{ID: 10}, nil)
+
+		err := service.UpdateArtwork(context.Background(), artwork)
+
+		var duplicate *services.DuplicateError
+		if assert.True(t, errors.As(err, &duplicate)) {
+			assert.Equal(t, uint(10), duplicate.ID)
+		}
+		assert.True(t, errors.Is(err, services.ErrDuplicate))
+	})
+
+	t.Run("DatabaseError", func(t *testing.T) {
+		mockRepo, service, _ := setUpMockServiceWithLogger()
+
+		artwork := &models.Artwork{ID: 3, Title: "Same", Artist: "Artist"}
+		mockRepo.On("Update", mock.Anything, artwork).Return(errors.New("DB error"))
+
+		err := service.UpdateArtwork(context.Background(), artwork)
+
+		assert.Error(t, err)
+		assert.False(t, errors.Is(err, services.ErrDuplicate))
+		mockRepo.AssertNotCalled(t, "FindDuplicate", mock.Anything, mock.Anything)
+	})
+}
+
 func TestDeleteArtwork(t *testing.T) {
 	t.Run("Success", func(t *testing.T) {
 		// Arrange
 		mockRepo, service, logBuffer := setUpMockServiceWithLogger()
 
-		mockRepo.On("Delete", "valid-id").Return(nil)
+		mockRepo.On("Delete", mock.Anything, "valid-id").Return(nil)
 
 		// Act
-		err := service.DeleteArtwork("valid-id")
+		err := service.DeleteArtwork(context.Background(), "valid-id")
 
 		// Assert
 		assert.NoError(t, err)
-		mockRepo.AssertCalled(t, "Delete", "valid-id")
+		mockRepo.AssertCalled(t, "Delete", mock.Anything, "valid-id")
 		assert.Contains(t, logBuffer.String(), "Deleting artwork with ID: valid-id")
 	})
 
@@ -199,15 +241,15 @@ func TestDeleteArtwork(t *testing.T) {
 		// Arrange
 		mockRepo, service, logBuffer := setUpMockServiceWithLogger()
 
-		mockRepo.On("Delete", "invalid-id").Return(gorm.ErrRecordNotFound)
+		mockRepo.On("Delete", mock.Anything, "invalid-id").Return(gorm.ErrRecordNotFound)
 
 		// Act
-		err := service.DeleteArtwork("invalid-id")
+		err := service.DeleteArtwork(context.Background(), "invalid-id")
 
 		// Assert
 		assert.Error(t, err)
 		assert.True(t, errors.Is(err, services.ErrNotFound), "expected ErrNotFound error")
-		mockRepo.AssertCalled(t, "Delete", "invalid-id")
+		mockRepo.AssertCalled(t, "Delete", mock.Anything, "invalid-id")
 		assert.Contains(t, logBuffer.String(), "Deleting artwork with ID: invalid-id")
 	})
 
@@ -215,15 +257,47 @@ func TestDeleteArtwork(t *testing.T) {
 		// Arrange
 		mockRepo, service, logBuffer := setUpMockServiceWithLogger()
 
-		mockRepo.On("Delete", "valid-id").Return(errors.New("database error"))
+		mockRepo.On("Delete", mock.Anything, "valid-id").Return(errors.New("database error"))
 
 		// Act
-		err := service.DeleteArtwork("valid-id")
+		err := service.DeleteArtwork(context.Background(), "valid-id")
 
 		// Assert
 		assert.Error(t, err)
 		assert.Contains(t, err.Error(), "database error")
-		mockRepo.AssertCalled(t, "Delete", "valid-id")
+		mockRepo.AssertCalled(t, "Delete", mock.Anything, "valid-id")
 		assert.Contains(t, logBuffer.String(), "Deleting artwork with ID: valid-id")
 	})
 }
+
+func TestInTransaction(t *testing.T) {
+	ctx := context.Background()
+	failure := errors.New("second write failed")
+	createThenFail := func(ctx context.Context, repos repositories.Repos) error {
+		if err := repos.Artworks.Create(ctx, &models.Artwork{Title: "Water Lilies", Gallery: "orangerie"}); err != nil {
+			return err
+		}
+		return failure
+	}
+
+	t.Run("RollsBack", func(t *testing.T) {
+		repo := repositories.NewMemoryArtworkRepository()
+		service := services.NewArtworkService(repo)
+		service.UnitOfWork = repositories.NewMemoryUnitOfWork(repo)
+
+		assert.ErrorIs(t, service.InTransaction(ctx, createThenFail), failure)
+		artworks, err := service.GetAllArtworks(ctx)
+		assert.NoError(t, err)
+		assert.Empty(t, artworks)
+	})
+
+	t.Run("WithoutUnitOfWork", func(t *testing.T) {
+		repo := repositories.NewMemoryArtworkRepository()
+		service := services.NewArtworkService(repo)
+
+		assert.ErrorIs(t, service.InTransaction(ctx, createThenFail), services.ErrNoUnitOfWork)
+		artworks, err := service.GetAllArtworks(ctx)
+		assert.NoError(t, err)
+		assert.Empty(t, artworks, "without a unit of work, nothing runs")
+	})
+}
@@ -0,0 +1,283 @@
+package unit_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/paumarro/apollo-be/internal/auth"
+	"github.com/paumarro/apollo-be/internal/devidp"
+	"github.com/paumarro/apollo-be/internal/sessions"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSessionManager(t *testing.T) {
+	provider := setupDevIdP(t)
+	ctx := context.Background()
+
+	mint := func(ttl time.Duration) string {
+		token, err := provider.Mint(devidp.Identity{Subject: "alice", Roles: []string{auth.RoleRegular}, Galleries: []string{"louvre"}}, ttl)
+		require.NoError(t, err)
+		return token
+	}
+
+	t.Run("StoresHashedIDAndReturnsToken", func(t *testing.T) {
+		store := sessions.NewMemoryStore()
+		manager := sessions.NewManager(store, nil, time.Hour)
+		manager.Principal = newAuthenticator(provider).Principal
+		access := mint(time.Hour)
+
+		rawID, session, err := manager.Create(ctx, access, "refresh-1", "test-agent", "127.0.0.1")
+		require.NoError(t, err)
+		assert.Equal(t, sessions.HashID(rawID), session.ID)
+		assert.NotEqual(t, rawID, session.ID)
+		assert.Equal(t, "alice", session.Subject)
+		assert.Equal(t, []string{"louvre"}, session.Galleries)
+
+		token, err := manager.AccessToken(ctx, rawID)
+		require.NoError(t, err)
+		assert.Equal(t, access, token)
+	})
+
+	t.Run("DescribesWithConfiguredMapping", func(t *testing.T) {
+		mapper, err := auth.NewClaimMapper(auth.ClaimMapping{Galleries: []string{"$.org.galleries"}})
+		require.NoError(t, err)
+		authenticator := newAuthenticator(provider)
+		authenticator.ClaimMapper = mapper
+		manager := sessions.NewManager(sessions.NewMemoryStore(), nil, time.Hour)
+		manager.Principal = authenticator.Principal
+
+		// Only the configured claim grants galleries, not the default one
+		access, err := provider.Mint(devidp.Identity{Subject: "alice", Galleries: []string{"louvre"}, Claims: map[string]interface{}{
+			"org": map[string]interface{}{"galleries": []string{"orsay"}},
+		}}, time.Hour)
+		require.NoError(t, err)
+		_, session, err := manager.Create(ctx, access, "refresh-1", "", "")
+		require.NoError(t, err)
+		assert.Equal(t, "alice", session.Subject)
+		assert.Equal(t, []string{"orsay"}, session.Galleries)
+
+		// Tokens that fail verification grant nothing
+		_, session, err = manager.Create(ctx, "not.a.jwt", "refresh-1", "", "")
+		require.NoError(t, err)
+		assert.Empty(t, session.Galleries)
+	})
+
+	t.Run("RefreshesExpiringToken", func(t *testing.T) {
+		refreshed := mint(time.Hour)
+		calls := 0
+		manager := sessions.NewManager(sessions.NewMemoryStore(), func(refreshToken string) (string, string, error) {
+			calls++
+			assert.Equal(t, "refresh-1", refreshToken)
+			return refreshed, "refresh-2", nil
+		}, time.Hour)
+
+		rawID, _, err := manager.Create(ctx, mint(time.Second), "refresh-1", "", "")
+		require.NoError(t, err)
+
+		token, err := manager.AccessToken(ctx, rawID)
+		require.NoError(t, err)
+		assert.Equal(t, refreshed, token)
+
+		// The refreshed token is reused until it nears expiry
+		_, err = manager.AccessToken(ctx, rawID)
+		require.NoError(t, err)
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("FailedRefreshEndsSession", func(t *testing.T) {
+		store := sessions.NewMemoryStore()
+		manager := sessions.NewManager(store, func(string) (string, string, error) {
+			return "", "", &auth.TokenEndpointError{StatusCode: http.StatusBadRequest, Body: `{"error":"invalid_grant"}`}
+		}, time.Hour)
+
+		rawID, _, err := manager.Create(ctx, mint(time.Second), "refresh-1", "", "")
+		require.NoError(t, err)
+
+		_, err = manager.AccessToken(ctx, rawID)
+		assert.True(t, errors.Is(err, sessions.ErrNotFound))
+		_, err = store.Get(ctx, sessions.HashID(rawID))
+		assert.True(t, errors.Is(err, sessions.ErrNotFound))
+	})
+
+	t.Run("TransientRefreshFailureKeepsSession", func(t *testing.T) {
+		store := sessions.NewMemoryStore()
+		for name, refreshErr := range map[string]error{
+			"Unreachable": errors.New("dial tcp: connection refused"),
+			"ServerError": &auth.TokenEndpointError{StatusCode: http.StatusServiceUnavailable, Body: "maintenance"},
+		} {
+			manager := sessions.NewManager(store, func(string) (string, string, error) {
+				return "", "", refreshErr
+			}, time.Hour)
+			rawID, _, err := manager.Create(ctx, mint(time.Second), "refresh-1", "", "")
+			require.NoError(t, err)
+
+			_, err = manager.AccessToken(ctx, rawID)
+			require.Error(t, err, name)
+			assert.False(t, errors.Is(err, sessions.ErrNotFound), name)
+			_, err = store.Get(ctx, sessions.HashID(rawID))
+			assert.NoError(t, err, name)
+		}
+	})
+
+	t.Run("ConcurrentRequestsRefreshOnce", func(t *testing.T) {
+		refreshed := mint(time.Hour)
+		var calls atomic.Int32
+		store := sessions.NewMemoryStore()
+		// Two managers stand in for two replicas sharing the store
+		refresh := func(refreshToken string) (string, string, error) {
+			calls.Add(1)
+			assert.Equal(t, "refresh-1", refreshToken, "a rotated refresh token is spent once")
+			time.Sleep(10 * time.Millisecond)
+			return refreshed, "refresh-2", nil
+		}
+		managers := []*sessions.Manager{sessions.NewManager(store, refresh, time.Hour), sessions.NewManager(store, refresh, time.Hour)}
+		rawID, _, err := managers[0].Create(ctx, mint(time.Second), "refresh-1", "", "")
+		require.NoError(t, err)
+
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func(manager *sessions.Manager) {
+				defer wg.Done()
+				token, err := manager.AccessToken(ctx, rawID)
+				assert.NoError(t, err)
+				assert.Equal(t, refreshed, token)
+			}(managers[i%2])
+		}
+		wg.Wait()
+		assert.Equal(t, int32(1), calls.Load())
+	})
+
+	t.Run("RevokeDuringRefreshStaysRevoked", func(t *testing.T) {
+		store := sessions.NewMemoryStore()
+		refreshing := make(chan struct{})
+		release := make(chan struct{})
+		manager := sessions.NewManager(store, func(string) (string, string, error) {
+			close(refreshing)
+			<-release
+			return mint(time.Hour), "refresh-2", nil
+		}, time.Hour)
+		rawID, session, err := manager.Create(ctx, mint(time.Second), "refresh-1", "", "")
+		require.NoError(t, err)
+
+		refreshed := make(chan error, 1)
+		go func() {
+			_, err := manager.AccessToken(ctx, rawID)
+			refreshed <- err
+		}()
+		<-refreshing
+
+		// The revocation waits for the refresh instead of being overwritten by it
+		revoked := make(chan error, 1)
+		go func() { revoked <- manager.Revoke(ctx, session.ID) }()
+		select {
+		case <-revoked:
+			t.Fatal("revoke did not wait for the refresh")
+		case <-time.After(50 * time.Millisecond):
+		}
+		close(release)
+		require.NoError(t, <-refreshed)
+		require.NoError(t, <-revoked)
+
+		_, err = store.Get(ctx, session.ID)
+		assert.True(t, errors.Is(err, sessions.ErrNotFound))
+		_, err = manager.AccessToken(ctx, rawID)
+		assert.True(t, errors.Is(err, sessions.ErrNotFound))
+	})
+
+	t.Run("TouchIsThrottled", func(t *testing.T) {
+		store := sessions.NewMemoryStore()
+		manager := sessions.NewManager(store, nil, time.Hour)
+		start := time.Now()
+		now := start
+		manager.Now = func() time.Time { return now }
+		rawID, _, err := manager.Create(ctx, mint(time.Hour), "refresh-1", "", "")
+		require.NoError(t, err)
+
+		now = start.Add(30 * time.Second)
+		_, err = manager.AccessToken(ctx, rawID)
+		require.NoError(t, err)
+		session, err := store.Get(ctx, sessions.HashID(rawID))
+		require.NoError(t, err)
+		assert.True(t, session.LastSeenAt.Equal(start))
+
+		now = start.Add(2 * time.Minute)
+		_, err = manager.AccessToken(ctx, rawID)
+		require.NoError(t, err)
+		session, err = store.Get(ctx, sessions.HashID(rawID))
+		require.NoError(t, err)
+		assert.True(t, session.LastSeenAt.Equal(now))
+		assert.Equal(t, "refresh-1", session.RefreshToken)
+	})
+
+	t.Run("ExpiredSession", func(t *testing.T) {
+		manager := sessions.NewManager(sessions.NewMemoryStore(), nil, -time.Minute)
+		rawID, _, err := manager.Create(ctx, mint(time.Hour), "refresh-1", "", "")
+		require.NoError(t, err)
+
+		_, err = manager.AccessToken(ctx, rawID)
+		assert.True(t, errors.Is(err, sessions.ErrNotFound))
+	})
+}
+
+func TestAuth_SessionCookie(t *testing.T) {
+	provider := setupDevIdP(t)
+	manager := sessions.NewManager(sessions.NewMemoryStore(), nil, time.Hour)
+	authenticator := newAuthenticator(provider)
+	authenticator.Sessions = manager
+
+	token, err := provider.Mint(devidp.Identity{Subject: "alice", Roles: []string{auth.RoleRegular}}, time.Hour)
+	require.NoError(t, err)
+	rawID, _, err := manager.Create(context.Background(), token, "refresh-1", "", "")
+	require.NoError(t, err)
+
+	r := gin.New()
+	r.GET("/artworks", authenticator.Auth(auth.RoleRegular), func(c *gin.Context) {
+		p, _ := auth.PrincipalFrom(c)
+		c.JSON(http.StatusOK, gin.H{"sub": p.Subject})
+	})
+
+	t.Run("ValidSession", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/artworks", nil)
+		req.AddCookie(&http.Cookie{Name: sessions.CookieName, Value: rawID})
+		r.ServeHTTP(w, req)
+
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"sub":"alice"`)
+	})
+
+	t.Run("UnknownSessionRedirectsToLogin", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/artworks", nil)
+		req.AddCookie(&http.Cookie{Name: sessions.CookieName, Value: "forged"})
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusFound, w.Code)
+	})
+
+	t.Run("UnavailableRefreshKeepsCookie", func(t *testing.T) {
+		expiring, err := provider.Mint(devidp.Identity{Subject: "alice", Roles: []string{auth.RoleRegular}}, time.Second)
+		require.NoError(t, err)
+		manager.Refresh = func(string) (string, string, error) { return "", "", errors.New("connection refused") }
+		t.Cleanup(func() { manager.Refresh = nil })
+		expiringID, _, err := manager.Create(context.Background(), expiring, "refresh-1", "", "")
+		require.NoError(t, err)
+
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/artworks", nil)
+		req.AddCookie(&http.Cookie{Name: sessions.CookieName, Value: expiringID})
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+		assert.Empty(t, w.Result().Cookies())
+	})
+}
@@ -0,0 +1,54 @@
+package unit_test
+
+import (
+	"testing"
+
+	"github.com/paumarro/apollo-be/internal/similarity"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSimilarity_Key(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"The Starry Night", "night starry"},
+		{"Starry Night, The", "night starry"},
+		{"Salvador Dalí", "dali salvador"},
+		{"  Les Demoiselles d'Avignon ", "avignon d demoiselles"},
+		{"Øresund Straße", "oresund strasse"},
+		{"The", "the"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, similarity.Key(tt.in), tt.in)
+	}
+}
+
+func TestSimilarity_Score(t *testing.T) {
+	assert.Equal(t, 1.0, similarity.Score("The Starry Night", "Starry Night, The"))
+	assert.Equal(t, 1.0, similarity.Score("Frida Kahlo", "FRIDA  KAHLO"))
+	assert.Equal(t, 0.0, similarity.Score("", "Anything"))
+
+	typo := similarity.Score("Mona Lisa", "Mona Liza")
+	assert.Greater(t, typo, 0.85)
+	assert.Less(t, typo, 1.0)
+	assert.Less(t, similarity.Score("Sunflowers", "The Night Watch"), 0.3)
+
+	assert.Equal(t, 3, similarity.Levenshtein("kitten", "sitting"))
+	assert.Equal(t, 1, similarity.Levenshtein("dalí", "dali"), "distance counts runes")
+	assert.Equal(t, 1.0, similarity.Trigram("water lilies", "water lilies"))
+	assert.Equal(t, 0.0, similarity.Trigram("abc", "xyz"))
+}
+
+func TestSimilarity_Cluster(t *testing.T) {
+	// 0-1 and 1-2 are close, joining 0, 1 and 2; 3 and 4 pair up; 5 is alone
+	near := map[[2]int]bool{{0, 1}: true, {1, 2}: true, {3, 4}: true}
+	score := func(i, j int) float64 {
+		if near[[2]int{i, j}] {
+			return 0.9
+		}
+		return 0.1
+	}
+	assert.Equal(t, [][]int{{0, 1, 2}, {3, 4}}, similarity.Cluster(6, score, 0.8))
+	assert.Empty(t, similarity.Cluster(6, score, 0.95))
+}